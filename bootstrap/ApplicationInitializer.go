@@ -1,14 +1,19 @@
 package bootstrap
 
 import (
+	"github.com/jinzhu/gorm"
 	"hallo/domain"
 	"hallo/domain/entity"
 	"log"
 	"os"
 )
 
-func CreateInitialAccount(accountManager domain.AccountManager, repository domain.AccountRepository) (createdAccount *entity.Account, err error) {
-	accountName := "admin"
+const initialAccountName = "admin"
+
+// CreateInitialAccount creates the admin account and grants it the super admin role when there is no account,
+// the super admin role is migrated to the existing admin account otherwise
+func CreateInitialAccount(accountManager domain.AccountManager, repository domain.AccountRepository,
+	roleRepository domain.RoleRepository) (createdAccount *entity.Account, err error) {
 	accountSecret := os.Getenv("ADMIN_SECRET")
 	if accountSecret == "" {
 		accountSecret = "admin123"
@@ -21,18 +26,59 @@ func CreateInitialAccount(accountManager domain.AccountManager, repository domai
 
 	if count > 0 {
 		log.Println("[INIT.ACCOUNT] some accounts are existed, default admin account will not be create")
-		return nil, nil
+		return nil, MigrateSuperAdminRole(repository, roleRepository)
 	}
 
 	account, err := accountManager.CreateAccount(entity.EmailAccountCreateRequest{
-		Name:   accountName,
+		Name:   initialAccountName,
 		Secret: accountSecret,
 		Email:  "temp@test.fundwit.com",
 	})
 
-	if err == nil {
-		log.Println("[INIT.ACCOUNT] default admin account has been created!")
+	if err != nil {
+		return nil, err
+	}
+	log.Println("[INIT.ACCOUNT] default admin account has been created!")
+
+	if err := roleRepository.Save(domain.SuperAdminRole, []string{domain.AllPermissions}); err != nil {
+		return account, err
+	}
+	if err := roleRepository.Grant(account.Id, domain.SuperAdminRole); err != nil {
+		return account, err
+	}
+	log.Println("[INIT.ACCOUNT] super admin role has been granted to default admin account")
+
+	return account, nil
+}
+
+// MigrateSuperAdminRole seeds the super admin role and grants it to the default admin account for the databases
+// initialized before roles. Nothing is changed once the role is existed, so that the grants revoked by operators
+// are kept revoked
+func MigrateSuperAdminRole(repository domain.AccountRepository, roleRepository domain.RoleRepository) error {
+	existed, err := roleRepository.IsRoleExisted(domain.SuperAdminRole)
+	if err != nil {
+		return err
+	}
+	if existed {
+		return nil
+	}
+
+	if err := roleRepository.Save(domain.SuperAdminRole, []string{domain.AllPermissions}); err != nil {
+		return err
 	}
+	log.Println("[INIT.ACCOUNT] super admin role has been created")
 
-	return account, err
+	account, err := repository.FindByName(0, initialAccountName)
+	if gorm.IsRecordNotFoundError(err) {
+		log.Println("[INIT.ACCOUNT] default admin account is not existed, super admin role is not granted")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := roleRepository.Grant(account.Id, domain.SuperAdminRole); err != nil {
+		return err
+	}
+	log.Println("[INIT.ACCOUNT] super admin role has been granted to default admin account")
+	return nil
 }
@@ -3,6 +3,8 @@ package bootstrap
 import (
 	"github.com/stretchr/testify/assert"
 	"hallo/domain"
+	"hallo/domain/entity"
+	"hallo/infra"
 	"hallo/testinfra"
 	"hallo/util"
 	"testing"
@@ -17,19 +19,59 @@ func TestCreateInitialAccount(it *testing.T) {
 			AccountRepository:          &domain.DatabaseAccountRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database},
 			IdentityBindingRepository:  &domain.DatabaseIdentityBindingRepository{Database: ds.Database},
 			InternalIdentityRepository: &domain.DatabaseInternalIdentityRepository{Database: ds.Database},
+			UnitOfWork:                 &domain.DatabaseUnitOfWork{TransactionSupport: &infra.GormTransactionSupport{Database: ds.Database}, IdWorker: util.DefaultIdWorker},
 		}
 
-		account, err := CreateInitialAccount(&accountManager, accountManager.AccountRepository)
+		roleRepository := &domain.DatabaseRoleRepository{Database: ds.Database}
+		account, err := CreateInitialAccount(&accountManager, accountManager.AccountRepository, roleRepository)
 		assert.Nil(t, err)
 		assert.Equal(t, "admin", account.Name)
 
-		account, err = accountManager.AuthenticateInternalIdentity("admin", "admin123")
+		roles, permissions, err := roleRepository.FindGrantsByAccountId(account.Id)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{domain.SuperAdminRole}, roles)
+		assert.Equal(t, []string{domain.AllPermissions}, permissions)
+
+		account, err = accountManager.AuthenticateInternalIdentity("", "admin", "admin123")
 		assert.Nil(t, err)
 		assert.Equal(t, "admin", account.Name)
 
 		// case 2
-		account, err = CreateInitialAccount(&accountManager, accountManager.AccountRepository)
+		account, err = CreateInitialAccount(&accountManager, accountManager.AccountRepository, roleRepository)
 		assert.Nil(t, err)
 		assert.Nil(t, account)
 	})
+
+	it.Run("should grant super admin role to admin account created before roles", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		accountManager := domain.AccountManagerImpl{
+			AccountRepository:          &domain.DatabaseAccountRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database},
+			IdentityBindingRepository:  &domain.DatabaseIdentityBindingRepository{Database: ds.Database},
+			InternalIdentityRepository: &domain.DatabaseInternalIdentityRepository{Database: ds.Database},
+			UnitOfWork:                 &domain.DatabaseUnitOfWork{TransactionSupport: &infra.GormTransactionSupport{Database: ds.Database}, IdWorker: util.DefaultIdWorker},
+		}
+		admin, err := accountManager.CreateAccount(entity.EmailAccountCreateRequest{
+			Name: "admin", Secret: "admin123", Email: "temp@test.fundwit.com"})
+		assert.Nil(t, err)
+
+		roleRepository := &domain.DatabaseRoleRepository{Database: ds.Database}
+		account, err := CreateInitialAccount(&accountManager, accountManager.AccountRepository, roleRepository)
+		assert.Nil(t, err)
+		assert.Nil(t, account)
+
+		roles, permissions, err := roleRepository.FindGrantsByAccountId(admin.Id)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{domain.SuperAdminRole}, roles)
+		assert.Equal(t, []string{domain.AllPermissions}, permissions)
+
+		// the grant revoked by operators is not restored
+		assert.Nil(t, roleRepository.RevokeByAccountId(admin.Id))
+		_, err = CreateInitialAccount(&accountManager, accountManager.AccountRepository, roleRepository)
+		assert.Nil(t, err)
+		roles, _, err = roleRepository.FindGrantsByAccountId(admin.Id)
+		assert.Nil(t, err)
+		assert.Empty(t, roles)
+	})
 }
@@ -2,95 +2,542 @@ package domain
 
 import (
 	"fmt"
+	"github.com/go-playground/validator/v10"
+	"github.com/jinzhu/gorm"
 	"hallo/domain/entity"
 	"log"
+	"strings"
 	"time"
 )
 
-const InternalProviderId = "internal"
+const (
+	InternalProviderId = "internal"
+	LdapProviderId     = "ldap"
+)
+
+// IsReservedProviderId tells the ids of the providers built in hallo, which connectors and SAML providers must not use,
+// otherwise their identities would sign in the accounts of the built-in providers
+func IsReservedProviderId(providerId string) bool {
+	return providerId == InternalProviderId || providerId == LdapProviderId
+}
+
+// Directory authenticates the accounts of an external directory such as LDAP, AccountAuthenticationFailure is returned
+// when the credential is not match. The emails of directory are trusted as verified, as they are managed by administrators
+type Directory interface {
+	Authenticate(accountName, secret string) (*entity.ExternalIdentity, error)
+}
 
 //go:generate mockgen -destination AccountManager_mock.go -package domain hallo/domain AccountManager
 type AccountManager interface {
 	CreateAccount(action entity.EmailAccountCreateRequest) (*entity.Account, error)
-	AuthenticateInternalIdentity(accountName, secret string) (*entity.Account, error)
+	// AuthenticateInternalIdentity authenticates account in the organization of name organization, empty for the default organization
+	AuthenticateInternalIdentity(organization, accountName, secret string) (*entity.Account, error)
+	// ChangeSecret replaces the secret of internal identity after the current one is authenticated, revoke is optional
+	// and runs in the same transaction, so that the new secret is discarded when the sessions fail to be revoked
+	ChangeSecret(accountId uint64, secret, newSecret string, revoke func(accountId uint64) error) error
+	// AuthenticateExternalIdentity signs in the account bound to the identity of upstream provider. The identity is bound to
+	// the account of the same email when both emails are verified, otherwise a new account is created in the default organization
+	AuthenticateExternalIdentity(identity entity.ExternalIdentity) (*entity.Account, error)
+	// AuthenticateDirectoryIdentity authenticates account by Directory, the account is provisioned as the external identity
+	// at the first time, and its name and email are synchronized from directory each time
+	AuthenticateDirectoryIdentity(accountName, secret string) (*entity.Account, error)
+	// BindExternalIdentity links the identity to the signed in account, an account is bound to one identity of each provider
+	BindExternalIdentity(accountId uint64, identity entity.ExternalIdentity) error
+	// UnbindIdentity removes the identity of provider from account, the internal identity is deleted as well when providerId
+	// is InternalProviderId. It is refused when the account has no other login method, which is an identity of configured
+	// provider or a passkey
+	UnbindIdentity(accountId uint64, providerId string) error
+	// DeletePasskey removes the passkey of credentialId (the Id of WebAuthnCredential) from account, return
+	// gorm.ErrRecordNotFound when the account has no such passkey. It is refused as UnbindIdentity does
+	DeletePasskey(accountId uint64, credentialId string) error
+	UpdateAccount(accountId uint64, action entity.AccountUpdateRequest) (*entity.Account, error)
+	// DeleteAccount deletes the account with its internal identity, identity bindings, second factors, recovery codes,
+	// passkeys, password reset tokens, granted roles, group memberships and organization memberships
+	DeleteAccount(accountId uint64) error
 }
 
+// AccountManagerImpl runs operations of multiple steps in UnitOfWork, the other operations use the repositories directly
 type AccountManagerImpl struct {
 	AccountRepository          AccountRepository
 	IdentityBindingRepository  IdentityBindingRepository
 	InternalIdentityRepository InternalIdentityRepository
+	// Directory is optional, AuthenticateDirectoryIdentity always fails without it
+	Directory Directory
+	// ExternalProviderIds are the ids of configured connectors and SAML providers, the identities of the other providers
+	// are not login methods any more. The passkeys are login methods when PasskeyEnabled
+	ExternalProviderIds []string
+	PasskeyEnabled      bool
+
+	UnitOfWork UnitOfWork
 }
 
 func (manager *AccountManagerImpl) CreateAccount(action entity.EmailAccountCreateRequest) (*entity.Account, error) {
-	// TODO transaction
-	isNameOccupied, err := manager.AccountRepository.IsAccountNameOccupied(action.Name)
+	var account *entity.Account
+	err := manager.UnitOfWork.Do(func(repositories *Repositories) error {
+		organizationId, err := organizationIdOf(repositories, action.Organization)
+		if err != nil {
+			return err
+		}
+
+		isNameOccupied, err := repositories.AccountRepository.IsAccountNameOccupied(organizationId, action.Name)
+		if err != nil {
+			return err
+		}
+		if isNameOccupied {
+			return &AccountNameIsOccupied{}
+		}
+
+		isEmailOccupied, err := repositories.AccountRepository.IsEmailOccupied(action.Email)
+		if err != nil {
+			return err
+		}
+		if isEmailOccupied {
+			return &AccountEmailIsOccupied{}
+		}
+
+		accountId, err := repositories.AccountRepository.NextId()
+		if err != nil {
+			log.Println(err)
+			return IdGenerateFailure
+		}
+
+		now := time.Now()
+		account = &entity.Account{
+			Id:             accountId,
+			OrganizationId: organizationId,
+			Name:           action.Name,
+			Email:          action.Email,
+			EmailVerified:  action.EmailVerified,
+
+			CreateTime:     now,
+			LastUpdateTime: now,
+		}
+
+		err = repositories.AccountRepository.Save(account)
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+
+		// create binding (create internal identity for internalProvider)
+		return bindIdentity(repositories, accountId, InternalProviderId, fmt.Sprintf("%d", accountId), action.Secret)
+	})
 	if err != nil {
 		return nil, err
 	}
-	if isNameOccupied {
-		return nil, &AccountNameIsOccupied{}
-	}
+	return account, nil
+}
 
-	isEmailOccupied, err := manager.AccountRepository.IsEmailOccupied(action.Email)
+func (manager *AccountManagerImpl) AuthenticateInternalIdentity(organization, accountName, secret string) (*entity.Account, error) {
+	var account *entity.Account
+	// authentication may upgrade the hash of credential
+	err := manager.UnitOfWork.Do(func(repositories *Repositories) error {
+		organizationId, err := organizationIdOf(repositories, organization)
+		if err != nil {
+			return err
+		}
+		account, err = repositories.AccountRepository.FindByName(organizationId, accountName)
+		if err != nil {
+			return err
+		}
+		return repositories.InternalIdentityRepository.Authenticate(account.Id, secret)
+	})
 	if err != nil {
 		return nil, err
 	}
-	if isEmailOccupied {
-		return nil, &AccountEmailIsOccupied{}
+	return account, nil
+}
+
+func (manager *AccountManagerImpl) ChangeSecret(accountId uint64, secret, newSecret string, revoke func(accountId uint64) error) error {
+	return manager.UnitOfWork.Do(func(repositories *Repositories) error {
+		if err := repositories.InternalIdentityRepository.Authenticate(accountId, secret); err != nil {
+			return err
+		}
+		if err := repositories.InternalIdentityRepository.Save(accountId, newSecret); err != nil {
+			return err
+		}
+		if revoke == nil {
+			return nil
+		}
+		return revoke(accountId)
+	})
+}
+
+func (manager *AccountManagerImpl) AuthenticateExternalIdentity(identity entity.ExternalIdentity) (*entity.Account, error) {
+	if err := validator.New().Struct(identity); err != nil {
+		return nil, err
 	}
 
-	accountId, err := manager.AccountRepository.NextId()
+	var account *entity.Account
+	err := manager.UnitOfWork.Do(func(repositories *Repositories) error {
+		var err error
+		account, err = authenticateExternalIdentity(repositories, identity, false)
+		return err
+	})
 	if err != nil {
-		log.Println(err)
-		return nil, IdGenerateFailure
+		return nil, err
 	}
+	return account, nil
+}
 
-	now := time.Now()
-	account := &entity.Account{
-		Id:    accountId,
-		Name:  action.Name,
-		Email: action.Email,
-
-		CreateTime:     now,
-		LastUpdateTime: now,
+func (manager *AccountManagerImpl) AuthenticateDirectoryIdentity(accountName, secret string) (*entity.Account, error) {
+	if manager.Directory == nil {
+		return nil, &AccountAuthenticationFailure{}
+	}
+	identity, err := manager.Directory.Authenticate(accountName, secret)
+	if err != nil {
+		return nil, err
+	}
+	if err := validator.New().Struct(identity); err != nil {
+		return nil, err
 	}
 
-	err = manager.AccountRepository.Save(account)
+	var account *entity.Account
+	err = manager.UnitOfWork.Do(func(repositories *Repositories) error {
+		var err error
+		account, err = authenticateExternalIdentity(repositories, *identity, true)
+		return err
+	})
 	if err != nil {
-		log.Println(err)
 		return nil, err
 	}
+	return account, nil
+}
+
+func (manager *AccountManagerImpl) BindExternalIdentity(accountId uint64, identity entity.ExternalIdentity) error {
+	if err := validator.New().Struct(identity); err != nil {
+		return err
+	}
+
+	return manager.UnitOfWork.Do(func(repositories *Repositories) error {
+		binding, err := repositories.IdentityBindingRepository.FindByProviderAccountId(identity.ProviderId, identity.ProviderAccountId)
+		if err == nil {
+			if binding.AccountId == accountId {
+				return nil
+			}
+			return &IdentityIsBound{}
+		}
+		if !gorm.IsRecordNotFoundError(err) {
+			return err
+		}
+
+		if _, err := repositories.AccountRepository.FindById(accountId); err != nil {
+			return err
+		}
+		bindings, err := repositories.IdentityBindingRepository.FindByAccountId(accountId)
+		if err != nil {
+			return err
+		}
+		for _, bound := range bindings {
+			if bound.ProviderId == identity.ProviderId {
+				return &IdentityIsBound{}
+			}
+		}
+		return repositories.IdentityBindingRepository.Save(accountId, identity.ProviderId, identity.ProviderAccountId)
+	})
+}
+
+func (manager *AccountManagerImpl) UnbindIdentity(accountId uint64, providerId string) error {
+	return manager.UnitOfWork.Do(func(repositories *Repositories) error {
+		bindings, err := repositories.IdentityBindingRepository.FindByAccountId(accountId)
+		if err != nil {
+			return err
+		}
+		found := false
+		for _, binding := range bindings {
+			if binding.ProviderId == providerId {
+				found = true
+			}
+		}
+		if !found {
+			return gorm.ErrRecordNotFound
+		}
+		// the identity of a provider which is not configured any more is removed freely
+		if manager.isConfiguredProvider(providerId) {
+			if err := manager.requireOtherLoginMethod(repositories, accountId, providerId, ""); err != nil {
+				return err
+			}
+		}
+
+		if providerId == InternalProviderId {
+			if err := repositories.InternalIdentityRepository.Delete(accountId); err != nil {
+				return err
+			}
+		}
+		return repositories.IdentityBindingRepository.DeleteByProviderId(accountId, providerId)
+	})
+}
+
+func (manager *AccountManagerImpl) DeletePasskey(accountId uint64, credentialId string) error {
+	return manager.UnitOfWork.Do(func(repositories *Repositories) error {
+		credentials, err := repositories.WebAuthnCredentialRepository.FindByAccountId(accountId)
+		if err != nil {
+			return err
+		}
+		found := false
+		for _, credential := range credentials {
+			if credential.Id == credentialId {
+				found = true
+			}
+		}
+		if !found {
+			return gorm.ErrRecordNotFound
+		}
+		// the passkeys are removed freely when they are not login methods
+		if manager.PasskeyEnabled {
+			if err := manager.requireOtherLoginMethod(repositories, accountId, "", credentialId); err != nil {
+				return err
+			}
+		}
+		return repositories.WebAuthnCredentialRepository.Delete(accountId, credentialId)
+	})
+}
 
-	// create binding (create internal identity for internalProvider)
-	err = manager.bindIdentity(accountId, InternalProviderId, fmt.Sprintf("%d", accountId), action.Secret)
+// requireOtherLoginMethod returns LastIdentityIsRequired when the account is not able to sign in without the identity
+// of providerId and the passkey of credentialId, the login methods are the identities of configured providers and
+// the passkeys when PasskeyEnabled
+func (manager *AccountManagerImpl) requireOtherLoginMethod(repositories *Repositories, accountId uint64, providerId,
+	credentialId string) error {
+	bindings, err := repositories.IdentityBindingRepository.FindByAccountId(accountId)
 	if err != nil {
+		return err
+	}
+	for _, binding := range bindings {
+		if binding.ProviderId != providerId && manager.isConfiguredProvider(binding.ProviderId) {
+			return nil
+		}
+	}
+	if manager.PasskeyEnabled {
+		credentials, err := repositories.WebAuthnCredentialRepository.FindByAccountId(accountId)
+		if err != nil {
+			return err
+		}
+		for _, credential := range credentials {
+			if credential.Id != credentialId {
+				return nil
+			}
+		}
+	}
+	return &LastIdentityIsRequired{}
+}
+
+// isConfiguredProvider tells whether the identities of provider are able to sign in
+func (manager *AccountManagerImpl) isConfiguredProvider(providerId string) bool {
+	switch providerId {
+	case InternalProviderId:
+		return true
+	case LdapProviderId:
+		return manager.Directory != nil
+	}
+	for _, id := range manager.ExternalProviderIds {
+		if id == providerId {
+			return true
+		}
+	}
+	return false
+}
+
+func (manager *AccountManagerImpl) UpdateAccount(accountId uint64, action entity.AccountUpdateRequest) (*entity.Account, error) {
+	if err := validator.New().Struct(action); err != nil {
 		return nil, err
 	}
 
+	var account *entity.Account
+	err := manager.UnitOfWork.Do(func(repositories *Repositories) error {
+		var err error
+		account, err = repositories.AccountRepository.FindById(accountId)
+		if err != nil {
+			return err
+		}
+
+		if action.Name != "" && action.Name != account.Name {
+			isNameOccupied, err := repositories.AccountRepository.IsAccountNameOccupied(account.OrganizationId, action.Name)
+			if err != nil {
+				return err
+			}
+			if isNameOccupied {
+				return &AccountNameIsOccupied{}
+			}
+			account.Name = action.Name
+		}
+
+		if action.Email != "" && action.Email != account.Email {
+			isEmailOccupied, err := repositories.AccountRepository.IsEmailOccupied(action.Email)
+			if err != nil {
+				return err
+			}
+			if isEmailOccupied {
+				return &AccountEmailIsOccupied{}
+			}
+			account.Email = action.Email
+			account.EmailVerified = false
+		}
+
+		return repositories.AccountRepository.Update(account)
+	})
+	if err != nil {
+		return nil, err
+	}
 	return account, nil
 }
 
-func (manager *AccountManagerImpl) AuthenticateInternalIdentity(accountName, secret string) (*entity.Account, error) {
-	// TODO tx begin
-	account, err := manager.AccountRepository.FindByName(accountName)
+func (manager *AccountManagerImpl) DeleteAccount(accountId uint64) error {
+	return manager.UnitOfWork.Do(func(repositories *Repositories) error {
+		if err := repositories.InternalIdentityRepository.Delete(accountId); err != nil {
+			return err
+		}
+		if err := repositories.IdentityBindingRepository.DeleteByAccountId(accountId); err != nil {
+			return err
+		}
+		if err := repositories.TotpFactorRepository.Delete(accountId); err != nil {
+			return err
+		}
+		if err := repositories.RecoveryCodeRepository.DeleteByAccountId(accountId); err != nil {
+			return err
+		}
+		if err := repositories.WebAuthnCredentialRepository.DeleteByAccountId(accountId); err != nil {
+			return err
+		}
+		if err := repositories.PasswordResetTokenRepository.DeleteByAccountId(accountId); err != nil {
+			return err
+		}
+		if err := repositories.RoleRepository.RevokeByAccountId(accountId); err != nil {
+			return err
+		}
+		if err := repositories.GroupRepository.RemoveMembersByAccountId(accountId); err != nil {
+			return err
+		}
+		if err := repositories.OrganizationRepository.RemoveMembersByAccountId(accountId); err != nil {
+			return err
+		}
+		return repositories.AccountRepository.Delete(accountId)
+	})
+}
+
+// organizationIdOf returns zero for the default organization, which has empty name
+func organizationIdOf(repositories *Repositories, organization string) (uint64, error) {
+	if organization == "" {
+		return 0, nil
+	}
+	found, err := repositories.OrganizationRepository.FindByName(organization)
 	if err != nil {
+		return 0, err
+	}
+	return found.Id, nil
+}
+
+// authenticateExternalIdentity synchronizes the name and email of the bound account from identity when sync is true,
+// the ones occupied by other accounts are kept as they are
+func authenticateExternalIdentity(repositories *Repositories, identity entity.ExternalIdentity, sync bool) (*entity.Account, error) {
+	binding, err := repositories.IdentityBindingRepository.FindByProviderAccountId(identity.ProviderId, identity.ProviderAccountId)
+	if err == nil {
+		account, err := repositories.AccountRepository.FindById(binding.AccountId)
+		if err != nil || !sync {
+			return account, err
+		}
+		return account, syncExternalIdentity(repositories, account, identity)
+	}
+	if !gorm.IsRecordNotFoundError(err) {
 		return nil, err
 	}
-	err = manager.InternalIdentityRepository.Authenticate(account.Id, secret)
+
+	if identity.Email == "" {
+		return nil, &ExternalIdentityEmailMissing{}
+	}
+	account, err := repositories.AccountRepository.FindByEmail(identity.Email)
+	if err != nil && !gorm.IsRecordNotFoundError(err) {
+		return nil, err
+	}
+	if account != nil {
+		// the account is not taken over by the one who registers with an email of others
+		if !identity.EmailVerified || !account.EmailVerified {
+			return nil, &AccountEmailIsOccupied{}
+		}
+		return account, repositories.IdentityBindingRepository.Save(account.Id, identity.ProviderId, identity.ProviderAccountId)
+	}
+
+	name, err := availableAccountName(repositories, externalAccountName(identity))
 	if err != nil {
 		return nil, err
 	}
-	// TODO tx commit or rollback
-	return account, nil
+	accountId, err := repositories.AccountRepository.NextId()
+	if err != nil {
+		log.Println(err)
+		return nil, IdGenerateFailure
+	}
+	now := time.Now()
+	account = &entity.Account{
+		Id:            accountId,
+		Name:          name,
+		Email:         identity.Email,
+		EmailVerified: identity.EmailVerified,
+
+		CreateTime:     now,
+		LastUpdateTime: now,
+	}
+	if err := repositories.AccountRepository.Save(account); err != nil {
+		return nil, err
+	}
+	return account, bindIdentity(repositories, accountId, identity.ProviderId, identity.ProviderAccountId, "")
+}
+
+func syncExternalIdentity(repositories *Repositories, account *entity.Account, identity entity.ExternalIdentity) error {
+	changed := false
+	if identity.Name != "" && identity.Name != account.Name {
+		isNameOccupied, err := repositories.AccountRepository.IsAccountNameOccupied(account.OrganizationId, identity.Name)
+		if err != nil {
+			return err
+		}
+		if !isNameOccupied {
+			account.Name, changed = identity.Name, true
+		}
+	}
+	if identity.Email != "" && identity.Email != account.Email {
+		isEmailOccupied, err := repositories.AccountRepository.IsEmailOccupied(identity.Email)
+		if err != nil {
+			return err
+		}
+		if !isEmailOccupied {
+			account.Email, account.EmailVerified, changed = identity.Email, identity.EmailVerified, true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return repositories.AccountRepository.Update(account)
+}
+
+// externalAccountName falls back to the local part of email when the provider has no name of account
+func externalAccountName(identity entity.ExternalIdentity) string {
+	if identity.Name != "" {
+		return identity.Name
+	}
+	return strings.SplitN(identity.Email, "@", 2)[0]
+}
+
+// availableAccountName appends a number to name when it is occupied in the default organization
+func availableAccountName(repositories *Repositories, name string) (string, error) {
+	candidate := name
+	for i := 2; i <= 10; i++ {
+		isNameOccupied, err := repositories.AccountRepository.IsAccountNameOccupied(0, candidate)
+		if err != nil {
+			return "", err
+		}
+		if !isNameOccupied {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s-%d", name, i)
+	}
+	return "", &AccountNameIsOccupied{}
 }
 
-func (manager *AccountManagerImpl) bindIdentity(accountId uint64, providerId, providerAccountId, credential string) error {
+func bindIdentity(repositories *Repositories, accountId uint64, providerId, providerAccountId, credential string) error {
 	if providerId == InternalProviderId {
 		// accountId and providerAccountId are equals, but in different type
-		if err := manager.InternalIdentityRepository.Save(accountId, credential); err != nil {
+		if err := repositories.InternalIdentityRepository.Save(accountId, credential); err != nil {
 			return err
 		}
 	}
 
-	return manager.IdentityBindingRepository.Save(accountId, providerId, providerAccountId)
+	return repositories.IdentityBindingRepository.Save(accountId, providerId, providerAccountId)
 }
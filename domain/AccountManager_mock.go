@@ -33,19 +33,77 @@ func (m *MockAccountManager) EXPECT() *MockAccountManagerMockRecorder {
 	return m.recorder
 }
 
+// AuthenticateDirectoryIdentity mocks base method
+func (m *MockAccountManager) AuthenticateDirectoryIdentity(arg0, arg1 string) (*entity.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AuthenticateDirectoryIdentity", arg0, arg1)
+	ret0, _ := ret[0].(*entity.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AuthenticateDirectoryIdentity indicates an expected call of AuthenticateDirectoryIdentity
+func (mr *MockAccountManagerMockRecorder) AuthenticateDirectoryIdentity(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthenticateDirectoryIdentity", reflect.TypeOf((*MockAccountManager)(nil).AuthenticateDirectoryIdentity), arg0, arg1)
+}
+
+// AuthenticateExternalIdentity mocks base method
+func (m *MockAccountManager) AuthenticateExternalIdentity(arg0 entity.ExternalIdentity) (*entity.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AuthenticateExternalIdentity", arg0)
+	ret0, _ := ret[0].(*entity.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AuthenticateExternalIdentity indicates an expected call of AuthenticateExternalIdentity
+func (mr *MockAccountManagerMockRecorder) AuthenticateExternalIdentity(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthenticateExternalIdentity", reflect.TypeOf((*MockAccountManager)(nil).AuthenticateExternalIdentity), arg0)
+}
+
 // AuthenticateInternalIdentity mocks base method
-func (m *MockAccountManager) AuthenticateInternalIdentity(arg0, arg1 string) (*entity.Account, error) {
+func (m *MockAccountManager) AuthenticateInternalIdentity(arg0, arg1, arg2 string) (*entity.Account, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AuthenticateInternalIdentity", arg0, arg1)
+	ret := m.ctrl.Call(m, "AuthenticateInternalIdentity", arg0, arg1, arg2)
 	ret0, _ := ret[0].(*entity.Account)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // AuthenticateInternalIdentity indicates an expected call of AuthenticateInternalIdentity
-func (mr *MockAccountManagerMockRecorder) AuthenticateInternalIdentity(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockAccountManagerMockRecorder) AuthenticateInternalIdentity(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthenticateInternalIdentity", reflect.TypeOf((*MockAccountManager)(nil).AuthenticateInternalIdentity), arg0, arg1, arg2)
+}
+
+// BindExternalIdentity mocks base method
+func (m *MockAccountManager) BindExternalIdentity(arg0 uint64, arg1 entity.ExternalIdentity) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BindExternalIdentity", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BindExternalIdentity indicates an expected call of BindExternalIdentity
+func (mr *MockAccountManagerMockRecorder) BindExternalIdentity(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthenticateInternalIdentity", reflect.TypeOf((*MockAccountManager)(nil).AuthenticateInternalIdentity), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BindExternalIdentity", reflect.TypeOf((*MockAccountManager)(nil).BindExternalIdentity), arg0, arg1)
+}
+
+// ChangeSecret mocks base method
+func (m *MockAccountManager) ChangeSecret(arg0 uint64, arg1, arg2 string, arg3 func(uint64) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangeSecret", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ChangeSecret indicates an expected call of ChangeSecret
+func (mr *MockAccountManagerMockRecorder) ChangeSecret(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangeSecret", reflect.TypeOf((*MockAccountManager)(nil).ChangeSecret), arg0, arg1, arg2, arg3)
 }
 
 // CreateAccount mocks base method
@@ -62,3 +120,60 @@ func (mr *MockAccountManagerMockRecorder) CreateAccount(arg0 interface{}) *gomoc
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAccount", reflect.TypeOf((*MockAccountManager)(nil).CreateAccount), arg0)
 }
+
+// DeleteAccount mocks base method
+func (m *MockAccountManager) DeleteAccount(arg0 uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAccount", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAccount indicates an expected call of DeleteAccount
+func (mr *MockAccountManagerMockRecorder) DeleteAccount(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAccount", reflect.TypeOf((*MockAccountManager)(nil).DeleteAccount), arg0)
+}
+
+// DeletePasskey mocks base method
+func (m *MockAccountManager) DeletePasskey(arg0 uint64, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeletePasskey", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeletePasskey indicates an expected call of DeletePasskey
+func (mr *MockAccountManagerMockRecorder) DeletePasskey(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePasskey", reflect.TypeOf((*MockAccountManager)(nil).DeletePasskey), arg0, arg1)
+}
+
+// UnbindIdentity mocks base method
+func (m *MockAccountManager) UnbindIdentity(arg0 uint64, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnbindIdentity", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UnbindIdentity indicates an expected call of UnbindIdentity
+func (mr *MockAccountManagerMockRecorder) UnbindIdentity(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnbindIdentity", reflect.TypeOf((*MockAccountManager)(nil).UnbindIdentity), arg0, arg1)
+}
+
+// UpdateAccount mocks base method
+func (m *MockAccountManager) UpdateAccount(arg0 uint64, arg1 entity.AccountUpdateRequest) (*entity.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAccount", arg0, arg1)
+	ret0, _ := ret[0].(*entity.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateAccount indicates an expected call of UpdateAccount
+func (mr *MockAccountManagerMockRecorder) UpdateAccount(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAccount", reflect.TypeOf((*MockAccountManager)(nil).UpdateAccount), arg0, arg1)
+}
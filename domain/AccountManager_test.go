@@ -1,13 +1,17 @@
 package domain
 
 import (
+	"errors"
+	"github.com/golang/mock/gomock"
 	"github.com/google/uuid"
 	"github.com/jinzhu/gorm"
 	"github.com/stretchr/testify/assert"
 	"hallo/domain/entity"
+	"hallo/infra"
 	"hallo/testinfra"
 	"hallo/util"
 	"testing"
+	"time"
 )
 
 func TestAccountManager_CreateAccount(it *testing.T) {
@@ -19,12 +23,13 @@ func TestAccountManager_CreateAccount(it *testing.T) {
 			AccountRepository:          &DatabaseAccountRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database},
 			IdentityBindingRepository:  &DatabaseIdentityBindingRepository{Database: ds.Database},
 			InternalIdentityRepository: &DatabaseInternalIdentityRepository{Database: ds.Database},
+			UnitOfWork:                 &DatabaseUnitOfWork{TransactionSupport: &infra.GormTransactionSupport{Database: ds.Database}, IdWorker: util.DefaultIdWorker},
 		}
 
 		accountName := uuid.New().String()
 		accountSecret := uuid.New().String()
 
-		found, err := accountManager.AccountRepository.IsAccountNameOccupied(accountName)
+		found, err := accountManager.AccountRepository.IsAccountNameOccupied(0, accountName)
 		assert.False(t, found, err)
 		assert.Equal(t, nil, err)
 
@@ -36,7 +41,7 @@ func TestAccountManager_CreateAccount(it *testing.T) {
 		assert.Equal(t, accountName, account.Name)
 
 		// verify: account is created
-		found, err = accountManager.AccountRepository.IsAccountNameOccupied(accountName)
+		found, err = accountManager.AccountRepository.IsAccountNameOccupied(0, accountName)
 		assert.True(t, found, err)
 		assert.Equal(t, nil, err)
 
@@ -53,6 +58,7 @@ func TestAccountManager_CreateAccount(it *testing.T) {
 			AccountRepository:          &DatabaseAccountRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database},
 			IdentityBindingRepository:  &DatabaseIdentityBindingRepository{Database: ds.Database},
 			InternalIdentityRepository: &DatabaseInternalIdentityRepository{Database: ds.Database},
+			UnitOfWork:                 &DatabaseUnitOfWork{TransactionSupport: &infra.GormTransactionSupport{Database: ds.Database}, IdWorker: util.DefaultIdWorker},
 		}
 
 		accountName := uuid.New().String()
@@ -80,6 +86,131 @@ func TestAccountManager_CreateAccount(it *testing.T) {
 
 }
 
+func TestAccountManager_CreateAccount_Transaction(it *testing.T) {
+	it.Run("should rollback account and identity when binding failed", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		bindingFailure := errors.New("binding failure")
+		mockIdentityBindingRepository := NewMockIdentityBindingRepository(mockCtl)
+		mockIdentityBindingRepository.EXPECT().Save(gomock.Any(), InternalProviderId, gomock.Any()).Return(bindingFailure)
+
+		accountManager := AccountManagerImpl{
+			AccountRepository:          &DatabaseAccountRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database},
+			IdentityBindingRepository:  &DatabaseIdentityBindingRepository{Database: ds.Database},
+			InternalIdentityRepository: &DatabaseInternalIdentityRepository{Database: ds.Database},
+			UnitOfWork: &DatabaseUnitOfWork{
+				TransactionSupport: &infra.GormTransactionSupport{Database: ds.Database},
+				IdWorker:           util.DefaultIdWorker,
+				Decorate: func(repositories *Repositories) {
+					repositories.IdentityBindingRepository = mockIdentityBindingRepository
+				},
+			},
+		}
+
+		accountName := uuid.New().String()
+		account, err := accountManager.CreateAccount(entity.EmailAccountCreateRequest{
+			Name: accountName, Secret: uuid.New().String(), Email: accountName + "@test.fundwit.com",
+		})
+		assert.Equal(t, bindingFailure, err)
+		assert.Nil(t, account)
+
+		// verify: account is not created
+		found, err := accountManager.AccountRepository.IsAccountNameOccupied(0, accountName)
+		assert.Nil(t, err)
+		assert.False(t, found)
+
+		// verify: internal identity is not created
+		var count uint64
+		err = ds.Database.Model(&entity.InternalIdentity{}).Count(&count).Error
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(0), count)
+	})
+}
+
+func TestAccountManager_UpdateAccount(it *testing.T) {
+	it.Run("should update name and email respecting uniqueness", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		accountManager := AccountManagerImpl{
+			AccountRepository:          &DatabaseAccountRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database},
+			IdentityBindingRepository:  &DatabaseIdentityBindingRepository{Database: ds.Database},
+			InternalIdentityRepository: &DatabaseInternalIdentityRepository{Database: ds.Database},
+			UnitOfWork:                 &DatabaseUnitOfWork{TransactionSupport: &infra.GormTransactionSupport{Database: ds.Database}, IdWorker: util.DefaultIdWorker},
+		}
+
+		accountName := uuid.New().String()
+		account, err := accountManager.CreateAccount(entity.EmailAccountCreateRequest{
+			Name: accountName, Secret: "secret", Email: accountName + "@test.fundwit.com", EmailVerified: true,
+		})
+		assert.Nil(t, err)
+		other, err := accountManager.CreateAccount(entity.EmailAccountCreateRequest{
+			Name: accountName + "-other", Secret: "secret", Email: accountName + "-other@test.fundwit.com",
+		})
+		assert.Nil(t, err)
+
+		_, err = accountManager.UpdateAccount(account.Id, entity.AccountUpdateRequest{Name: other.Name})
+		assert.Equal(t, &AccountNameIsOccupied{}, err)
+		_, err = accountManager.UpdateAccount(account.Id, entity.AccountUpdateRequest{Email: other.Email})
+		assert.Equal(t, &AccountEmailIsOccupied{}, err)
+		_, err = accountManager.UpdateAccount(0, entity.AccountUpdateRequest{Name: accountName + "-new"})
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+
+		updated, err := accountManager.UpdateAccount(account.Id, entity.AccountUpdateRequest{Name: accountName + "-new"})
+		assert.Nil(t, err)
+		assert.Equal(t, accountName+"-new", updated.Name)
+		assert.Equal(t, account.Email, updated.Email)
+		assert.True(t, updated.LastUpdateTime.After(account.LastUpdateTime))
+
+		found, err := accountManager.AccountRepository.FindById(account.Id)
+		assert.Nil(t, err)
+		assert.Equal(t, accountName+"-new", found.Name)
+		assert.True(t, found.EmailVerified)
+
+		updated, err = accountManager.UpdateAccount(account.Id, entity.AccountUpdateRequest{Email: accountName + "-new@test.fundwit.com"})
+		assert.Nil(t, err)
+		assert.False(t, updated.EmailVerified)
+		found, err = accountManager.AccountRepository.FindById(account.Id)
+		assert.Nil(t, err)
+		assert.False(t, found.EmailVerified)
+	})
+}
+
+func TestAccountManager_DeleteAccount(it *testing.T) {
+	it.Run("should delete account with its identities", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		accountManager := AccountManagerImpl{
+			AccountRepository:          &DatabaseAccountRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database},
+			IdentityBindingRepository:  &DatabaseIdentityBindingRepository{Database: ds.Database},
+			InternalIdentityRepository: &DatabaseInternalIdentityRepository{Database: ds.Database},
+			UnitOfWork:                 &DatabaseUnitOfWork{TransactionSupport: &infra.GormTransactionSupport{Database: ds.Database}, IdWorker: util.DefaultIdWorker},
+		}
+
+		accountName := uuid.New().String()
+		account, err := accountManager.CreateAccount(entity.EmailAccountCreateRequest{
+			Name: accountName, Secret: "secret", Email: accountName + "@test.fundwit.com",
+		})
+		assert.Nil(t, err)
+
+		assert.Nil(t, accountManager.DeleteAccount(account.Id))
+
+		_, err = accountManager.AccountRepository.FindById(account.Id)
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+		var count int
+		ds.Database.Model(&entity.InternalIdentity{}).Where(entity.InternalIdentity{AccountId: account.Id}).Count(&count)
+		assert.Equal(t, 0, count)
+		ds.Database.Model(&entity.IdentityBinding{}).Where(entity.IdentityBinding{AccountId: account.Id}).Count(&count)
+		assert.Equal(t, 0, count)
+
+		assert.True(t, gorm.IsRecordNotFoundError(accountManager.DeleteAccount(account.Id)))
+	})
+}
+
 func TestAccountManager_AuthenticateInternalIdentity(it *testing.T) {
 	it.Run("should authenticate failed when account is not exist", func(t *testing.T) {
 		ds := testinfra.NewTemporaryDatabase()
@@ -89,11 +220,12 @@ func TestAccountManager_AuthenticateInternalIdentity(it *testing.T) {
 			AccountRepository:          &DatabaseAccountRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database},
 			IdentityBindingRepository:  &DatabaseIdentityBindingRepository{Database: ds.Database},
 			InternalIdentityRepository: &DatabaseInternalIdentityRepository{Database: ds.Database},
+			UnitOfWork:                 &DatabaseUnitOfWork{TransactionSupport: &infra.GormTransactionSupport{Database: ds.Database}, IdWorker: util.DefaultIdWorker},
 		}
 		accountName := uuid.New().String()
 		accountSecret := uuid.New().String()
 
-		account, err := accountManager.AuthenticateInternalIdentity(accountName, accountSecret)
+		account, err := accountManager.AuthenticateInternalIdentity("", accountName, accountSecret)
 		assert.Nil(t, account)
 		assert.True(t, gorm.IsRecordNotFoundError(err))
 	})
@@ -106,6 +238,7 @@ func TestAccountManager_AuthenticateInternalIdentity(it *testing.T) {
 			AccountRepository:          &DatabaseAccountRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database},
 			IdentityBindingRepository:  &DatabaseIdentityBindingRepository{Database: ds.Database},
 			InternalIdentityRepository: &DatabaseInternalIdentityRepository{Database: ds.Database},
+			UnitOfWork:                 &DatabaseUnitOfWork{TransactionSupport: &infra.GormTransactionSupport{Database: ds.Database}, IdWorker: util.DefaultIdWorker},
 		}
 
 		// create user
@@ -118,12 +251,283 @@ func TestAccountManager_AuthenticateInternalIdentity(it *testing.T) {
 			panic(err)
 		}
 
-		account, err := accountManager.AuthenticateInternalIdentity(accountName, accountSecret)
+		account, err := accountManager.AuthenticateInternalIdentity("", accountName, accountSecret)
 		assert.Equal(t, accountName, account.Name)
 		assert.Nil(t, err)
 
-		account, err = accountManager.AuthenticateInternalIdentity(accountName, accountSecret+"bad")
+		account, err = accountManager.AuthenticateInternalIdentity("", accountName, accountSecret+"bad")
+		assert.Nil(t, account)
+		assert.Equal(t, &AccountAuthenticationFailure{}, err)
+	})
+
+	it.Run("should authenticate within organization", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		unitOfWork := &DatabaseUnitOfWork{TransactionSupport: &infra.GormTransactionSupport{Database: ds.Database}, IdWorker: util.DefaultIdWorker}
+		accountManager := AccountManagerImpl{
+			AccountRepository:          &DatabaseAccountRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database},
+			IdentityBindingRepository:  &DatabaseIdentityBindingRepository{Database: ds.Database},
+			InternalIdentityRepository: &DatabaseInternalIdentityRepository{Database: ds.Database},
+			UnitOfWork:                 unitOfWork,
+		}
+		organization, err := (&OrganizationManagerImpl{UnitOfWork: unitOfWork}).CreateOrganization(
+			entity.OrganizationCreateRequest{Name: "acme"})
+		assert.Nil(t, err)
+
+		// the same name is allowed in different organizations
+		accountName := uuid.New().String()
+		_, err = accountManager.CreateAccount(entity.EmailAccountCreateRequest{
+			Name: accountName, Secret: "default-secret", Email: accountName + "@test.fundwit.com",
+		})
+		assert.Nil(t, err)
+		created, err := accountManager.CreateAccount(entity.EmailAccountCreateRequest{
+			Organization: "acme", Name: accountName, Secret: "acme-secret", Email: accountName + "@acme.fundwit.com",
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, organization.Id, created.OrganizationId)
+
+		_, err = accountManager.CreateAccount(entity.EmailAccountCreateRequest{
+			Organization: "acme", Name: accountName, Secret: "acme-secret", Email: accountName + "@other.fundwit.com",
+		})
+		assert.Equal(t, &AccountNameIsOccupied{}, err)
+
+		account, err := accountManager.AuthenticateInternalIdentity("acme", accountName, "acme-secret")
+		assert.Nil(t, err)
+		assert.Equal(t, created.Id, account.Id)
+		account, err = accountManager.AuthenticateInternalIdentity("", accountName, "acme-secret")
 		assert.Nil(t, account)
 		assert.Equal(t, &AccountAuthenticationFailure{}, err)
+		account, err = accountManager.AuthenticateInternalIdentity("unknown", accountName, "acme-secret")
+		assert.Nil(t, account)
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+	})
+}
+
+func TestAccountManager_ChangeSecret(it *testing.T) {
+	it.Run("should change secret and revoke sessions in the same transaction", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		internalIdentityRepository := &DatabaseInternalIdentityRepository{Database: ds.Database}
+		accountManager := AccountManagerImpl{
+			InternalIdentityRepository: internalIdentityRepository,
+			UnitOfWork:                 &DatabaseUnitOfWork{TransactionSupport: &infra.GormTransactionSupport{Database: ds.Database}, IdWorker: util.DefaultIdWorker},
+		}
+		assert.Nil(t, internalIdentityRepository.Save(123, "old"))
+
+		var failure *AccountAuthenticationFailure
+		assert.True(t, errors.As(accountManager.ChangeSecret(123, "bad", "new", nil), &failure))
+
+		// the new secret is discarded when the sessions fail to be revoked
+		revokeFailure := errors.New("revoke failure")
+		err := accountManager.ChangeSecret(123, "old", "new", func(accountId uint64) error { return revokeFailure })
+		assert.Equal(t, revokeFailure, err)
+		assert.Nil(t, internalIdentityRepository.Authenticate(123, "old"))
+
+		var revoked []uint64
+		err = accountManager.ChangeSecret(123, "old", "new", func(accountId uint64) error {
+			revoked = append(revoked, accountId)
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, []uint64{123}, revoked)
+		assert.Nil(t, internalIdentityRepository.Authenticate(123, "new"))
+		assert.Nil(t, accountManager.ChangeSecret(123, "new", "newer", nil))
+		assert.Nil(t, internalIdentityRepository.Authenticate(123, "newer"))
+	})
+}
+
+func TestAccountManager_AuthenticateExternalIdentity(it *testing.T) {
+	it.Run("should create, link and find account by external identity", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		accountManager := AccountManagerImpl{
+			AccountRepository:          &DatabaseAccountRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database},
+			IdentityBindingRepository:  &DatabaseIdentityBindingRepository{Database: ds.Database},
+			InternalIdentityRepository: &DatabaseInternalIdentityRepository{Database: ds.Database},
+			UnitOfWork:                 &DatabaseUnitOfWork{TransactionSupport: &infra.GormTransactionSupport{Database: ds.Database}, IdWorker: util.DefaultIdWorker},
+		}
+
+		// the name of provider is occupied by an internal account, and the email is not verified
+		accountName := uuid.New().String()
+		existing, err := accountManager.CreateAccount(entity.EmailAccountCreateRequest{
+			Name: accountName, Secret: "secret", Email: accountName + "@test.fundwit.com",
+		})
+		assert.Nil(t, err)
+
+		created, err := accountManager.AuthenticateExternalIdentity(entity.ExternalIdentity{ProviderId: "github",
+			ProviderAccountId: "1001", Name: accountName, Email: accountName + "@github.fundwit.com", EmailVerified: true})
+		assert.Nil(t, err)
+		assert.Equal(t, accountName+"-2", created.Name)
+		assert.True(t, created.EmailVerified)
+
+		found, err := accountManager.AuthenticateExternalIdentity(entity.ExternalIdentity{ProviderId: "github",
+			ProviderAccountId: "1001", Email: "changed@github.fundwit.com"})
+		assert.Nil(t, err)
+		assert.Equal(t, created.Id, found.Id)
+
+		// unverified email of the existing account is not linked
+		_, err = accountManager.AuthenticateExternalIdentity(entity.ExternalIdentity{ProviderId: "google",
+			ProviderAccountId: "g-1", Email: existing.Email, EmailVerified: true})
+		assert.Equal(t, &AccountEmailIsOccupied{}, err)
+
+		linked, err := accountManager.AuthenticateExternalIdentity(entity.ExternalIdentity{ProviderId: "google",
+			ProviderAccountId: "g-2", Email: created.Email, EmailVerified: true})
+		assert.Nil(t, err)
+		assert.Equal(t, created.Id, linked.Id)
+		bindings, err := accountManager.IdentityBindingRepository.FindByAccountId(created.Id)
+		assert.Nil(t, err)
+		assert.Len(t, bindings, 2)
+
+		_, err = accountManager.AuthenticateExternalIdentity(entity.ExternalIdentity{ProviderId: "google", ProviderAccountId: "g-3"})
+		assert.Equal(t, &ExternalIdentityEmailMissing{}, err)
+	})
+}
+
+func TestAccountManager_BindAndUnbindIdentity(it *testing.T) {
+	it.Run("should bind identity once and keep the last login method", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		accountManager := AccountManagerImpl{
+			AccountRepository:          &DatabaseAccountRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database},
+			IdentityBindingRepository:  &DatabaseIdentityBindingRepository{Database: ds.Database},
+			InternalIdentityRepository: &DatabaseInternalIdentityRepository{Database: ds.Database},
+			UnitOfWork:                 &DatabaseUnitOfWork{TransactionSupport: &infra.GormTransactionSupport{Database: ds.Database}, IdWorker: util.DefaultIdWorker},
+			ExternalProviderIds:        []string{"github", "google"},
+		}
+
+		accountName := uuid.New().String()
+		account, err := accountManager.CreateAccount(entity.EmailAccountCreateRequest{
+			Name: accountName, Secret: "secret", Email: accountName + "@test.fundwit.com",
+		})
+		assert.Nil(t, err)
+		other, err := accountManager.AuthenticateExternalIdentity(entity.ExternalIdentity{ProviderId: "github",
+			ProviderAccountId: "2002", Email: accountName + "@github.fundwit.com"})
+		assert.Nil(t, err)
+
+		github := entity.ExternalIdentity{ProviderId: "github", ProviderAccountId: "1001"}
+		assert.Nil(t, accountManager.BindExternalIdentity(account.Id, github))
+		assert.Nil(t, accountManager.BindExternalIdentity(account.Id, github))
+		assert.Equal(t, &IdentityIsBound{}, accountManager.BindExternalIdentity(other.Id, github))
+		assert.Equal(t, &IdentityIsBound{}, accountManager.BindExternalIdentity(account.Id,
+			entity.ExternalIdentity{ProviderId: "github", ProviderAccountId: "3003"}))
+		assert.True(t, gorm.IsRecordNotFoundError(accountManager.BindExternalIdentity(account.Id+1,
+			entity.ExternalIdentity{ProviderId: "google", ProviderAccountId: "g-1"})))
+
+		assert.True(t, gorm.IsRecordNotFoundError(accountManager.UnbindIdentity(account.Id, "google")))
+		assert.Nil(t, accountManager.UnbindIdentity(account.Id, InternalProviderId))
+		assert.Equal(t, &LastIdentityIsRequired{}, accountManager.UnbindIdentity(account.Id, "github"))
+		assert.Equal(t, &LastIdentityIsRequired{}, accountManager.UnbindIdentity(other.Id, "github"))
+
+		// the secret can not be used any more
+		_, err = accountManager.AuthenticateInternalIdentity("", accountName, "secret")
+		assert.NotNil(t, err)
+		bindings, err := accountManager.IdentityBindingRepository.FindByAccountId(account.Id)
+		assert.Nil(t, err)
+		assert.Len(t, bindings, 1)
+		assert.Equal(t, "github", bindings[0].ProviderId)
+	})
+
+	it.Run("should count passkeys and the identities of configured providers only as login methods", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		accountManager := AccountManagerImpl{
+			AccountRepository:          &DatabaseAccountRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database},
+			IdentityBindingRepository:  &DatabaseIdentityBindingRepository{Database: ds.Database},
+			InternalIdentityRepository: &DatabaseInternalIdentityRepository{Database: ds.Database},
+			UnitOfWork:                 &DatabaseUnitOfWork{TransactionSupport: &infra.GormTransactionSupport{Database: ds.Database}, IdWorker: util.DefaultIdWorker},
+			ExternalProviderIds:        []string{"google"},
+			PasskeyEnabled:             true,
+		}
+
+		accountName := uuid.New().String()
+		account, err := accountManager.CreateAccount(entity.EmailAccountCreateRequest{
+			Name: accountName, Secret: "secret", Email: accountName + "@test.fundwit.com",
+		})
+		assert.Nil(t, err)
+		// the provider of github is not configured any more
+		assert.Nil(t, accountManager.BindExternalIdentity(account.Id, entity.ExternalIdentity{ProviderId: "github", ProviderAccountId: "1001"}))
+		assert.Equal(t, &LastIdentityIsRequired{}, accountManager.UnbindIdentity(account.Id, InternalProviderId))
+
+		credentialRepository := &DatabaseWebAuthnCredentialRepository{Database: ds.Database}
+		credentialId := uuid.New().String()
+		assert.Nil(t, credentialRepository.Create(&entity.WebAuthnCredential{
+			Id: credentialId, AccountId: account.Id, CredentialId: "credential", PublicKey: []byte("key"), CreateTime: time.Now()}))
+		assert.Nil(t, accountManager.UnbindIdentity(account.Id, InternalProviderId))
+
+		// the identity of provider which is not configured is not a login method
+		assert.Nil(t, accountManager.UnbindIdentity(account.Id, "github"))
+		bindings, err := accountManager.IdentityBindingRepository.FindByAccountId(account.Id)
+		assert.Nil(t, err)
+		assert.Empty(t, bindings)
+
+		// the last passkey is the last login method
+		assert.True(t, gorm.IsRecordNotFoundError(accountManager.DeletePasskey(account.Id, "unknown")))
+		assert.Equal(t, &LastIdentityIsRequired{}, accountManager.DeletePasskey(account.Id, credentialId))
+		assert.Nil(t, accountManager.BindExternalIdentity(account.Id, entity.ExternalIdentity{ProviderId: "google", ProviderAccountId: "2001"}))
+		assert.Nil(t, accountManager.DeletePasskey(account.Id, credentialId))
+		credentials, err := credentialRepository.FindByAccountId(account.Id)
+		assert.Nil(t, err)
+		assert.Empty(t, credentials)
+	})
+}
+
+type testDirectory map[string]entity.ExternalIdentity
+
+func (directory testDirectory) Authenticate(accountName, secret string) (*entity.ExternalIdentity, error) {
+	identity, found := directory[accountName+":"+secret]
+	if !found {
+		return nil, &AccountAuthenticationFailure{}
+	}
+	return &identity, nil
+}
+
+func TestAccountManager_AuthenticateDirectoryIdentity(it *testing.T) {
+	it.Run("should provision account and synchronize it from directory", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		directory := testDirectory{}
+		accountManager := AccountManagerImpl{
+			AccountRepository:          &DatabaseAccountRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database},
+			IdentityBindingRepository:  &DatabaseIdentityBindingRepository{Database: ds.Database},
+			InternalIdentityRepository: &DatabaseInternalIdentityRepository{Database: ds.Database},
+			UnitOfWork:                 &DatabaseUnitOfWork{TransactionSupport: &infra.GormTransactionSupport{Database: ds.Database}, IdWorker: util.DefaultIdWorker},
+		}
+		_, err := accountManager.AuthenticateDirectoryIdentity("ann", "secret")
+		assert.Equal(t, &AccountAuthenticationFailure{}, err)
+		accountManager.Directory = directory
+
+		accountName := uuid.New().String()
+		directory["ann:secret"] = entity.ExternalIdentity{ProviderId: LdapProviderId, ProviderAccountId: "uuid-ann",
+			Name: accountName, Email: accountName + "@test.fundwit.com", EmailVerified: true}
+		created, err := accountManager.AuthenticateDirectoryIdentity("ann", "secret")
+		assert.Nil(t, err)
+		assert.Equal(t, accountName, created.Name)
+		_, err = accountManager.AuthenticateDirectoryIdentity("ann", "bad")
+		assert.Equal(t, &AccountAuthenticationFailure{}, err)
+
+		// the occupied name is not synchronized
+		occupied := uuid.New().String()
+		_, err = accountManager.CreateAccount(entity.EmailAccountCreateRequest{
+			Name: occupied, Secret: "secret", Email: occupied + "@test.fundwit.com",
+		})
+		assert.Nil(t, err)
+		directory["ann:secret"] = entity.ExternalIdentity{ProviderId: LdapProviderId, ProviderAccountId: "uuid-ann",
+			Name: occupied, Email: accountName + "@corp.fundwit.com", EmailVerified: true}
+		synced, err := accountManager.AuthenticateDirectoryIdentity("ann", "secret")
+		assert.Nil(t, err)
+		assert.Equal(t, created.Id, synced.Id)
+		assert.Equal(t, accountName, synced.Name)
+		assert.Equal(t, accountName+"@corp.fundwit.com", synced.Email)
+
+		found, err := accountManager.AccountRepository.FindById(created.Id)
+		assert.Nil(t, err)
+		assert.Equal(t, accountName+"@corp.fundwit.com", found.Email)
+		assert.True(t, found.EmailVerified)
 	})
 }
@@ -6,6 +6,8 @@ import (
 	"github.com/jinzhu/gorm"
 	"hallo/domain/entity"
 	"hallo/util"
+	"strings"
+	"time"
 )
 
 const AccountTableName = "accounts"
@@ -13,11 +15,20 @@ const AccountTableName = "accounts"
 //go:generate mockgen -destination AccountRepository_mock.go -package domain hallo/domain AccountRepository
 type AccountRepository interface {
 	NextId() (uint64, error)
-	IsAccountNameOccupied(accountName string) (bool, error)
+	// account names are unique in organization, zero organizationId is the default organization
+	IsAccountNameOccupied(organizationId uint64, accountName string) (bool, error)
 	IsEmailOccupied(accountName string) (bool, error)
-	FindByName(accountName string) (*entity.Account, error)
+	FindByName(organizationId uint64, accountName string) (*entity.Account, error)
+	FindByEmail(email string) (*entity.Account, error)
+	FindById(id uint64) (*entity.Account, error)
+	// Query returns accounts ordered by id, at most query.Limit of them when the limit is positive
+	Query(query entity.AccountQuery) ([]entity.Account, error)
+	// QueryCount counts the accounts matching the filters of query, the cursor, order and limit are ignored
+	QueryCount(query entity.AccountQuery) (uint64, error)
 	Count() (uint64, error)
 	Save(account *entity.Account) error
+	Update(account *entity.Account) error
+	Delete(id uint64) error
 }
 
 type DatabaseAccountRepository struct {
@@ -35,20 +46,91 @@ func (repository *DatabaseAccountRepository) Count() (uint64, error) {
 	return count, err
 }
 
+func (repository *DatabaseAccountRepository) Query(query entity.AccountQuery) ([]entity.Account, error) {
+	db := filterAccounts(repository.Database.Table(AccountTableName), query)
+	if query.Descending {
+		if query.Cursor > 0 {
+			db = db.Where("id < ?", query.Cursor)
+		}
+		db = db.Order("id DESC")
+	} else {
+		if query.Cursor > 0 {
+			db = db.Where("id > ?", query.Cursor)
+		}
+		db = db.Order("id ASC")
+	}
+
+	if query.Limit > 0 {
+		db = db.Limit(query.Limit)
+	}
+	var accounts []entity.Account
+	if err := db.Find(&accounts).Error; err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+func (repository *DatabaseAccountRepository) QueryCount(query entity.AccountQuery) (uint64, error) {
+	var count uint64
+	err := filterAccounts(repository.Database.Table(AccountTableName), query).Count(&count).Error
+	return count, err
+}
+
+func filterAccounts(db *gorm.DB, query entity.AccountQuery) *gorm.DB {
+	if query.NamePrefix != "" {
+		db = db.Where("name LIKE ?", escapeLike(query.NamePrefix)+"%")
+	}
+	if query.EmailPrefix != "" {
+		db = db.Where("email LIKE ?", escapeLike(query.EmailPrefix)+"%")
+	}
+	if !query.CreatedAfter.IsZero() {
+		db = db.Where("create_time >= ?", query.CreatedAfter)
+	}
+	if !query.CreatedBefore.IsZero() {
+		db = db.Where("create_time < ?", query.CreatedBefore)
+	}
+	return db
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(value)
+}
+
 // return (nil, gorm.ErrRecordNotFound) when account name is not found
-func (repository *DatabaseAccountRepository) FindByName(accountName string) (*entity.Account, error) {
+func (repository *DatabaseAccountRepository) FindByName(organizationId uint64, accountName string) (*entity.Account, error) {
 	account := &entity.Account{}
-	if err := repository.Database.Table(AccountTableName).First(account, entity.Account{Name: accountName}).Error; err != nil {
+	if err := repository.Database.Table(AccountTableName).
+		Where("organization_id = ? AND name = ?", organizationId, accountName).First(account).Error; err != nil {
 		return nil, err
 	}
 	return account, nil
 }
 
-func (repository *DatabaseAccountRepository) IsAccountNameOccupied(accountName string) (bool, error) {
-	rows, err := repository.Database.Table(AccountTableName).Select("id").Where(entity.Account{Name: accountName}).Limit(1).Rows()
+// return (nil, gorm.ErrRecordNotFound) when email is not found
+func (repository *DatabaseAccountRepository) FindByEmail(email string) (*entity.Account, error) {
+	account := &entity.Account{}
+	if err := repository.Database.Table(AccountTableName).First(account, entity.Account{Email: email}).Error; err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// return (nil, gorm.ErrRecordNotFound) when account is not found
+func (repository *DatabaseAccountRepository) FindById(id uint64) (*entity.Account, error) {
+	account := &entity.Account{}
+	if err := repository.Database.Table(AccountTableName).First(account, entity.Account{Id: id}).Error; err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+func (repository *DatabaseAccountRepository) IsAccountNameOccupied(organizationId uint64, accountName string) (bool, error) {
+	rows, err := repository.Database.Table(AccountTableName).Select("id").
+		Where("organization_id = ? AND name = ?", organizationId, accountName).Limit(1).Rows()
 	if err != nil {
 		return true, errors.New("failed to query")
 	}
+	defer rows.Close()
 	if rows.Next() {
 		return true, nil
 	}
@@ -63,6 +145,7 @@ func (repository *DatabaseAccountRepository) IsEmailOccupied(email string) (bool
 	if err != nil {
 		return true, err
 	}
+	defer rows.Close()
 	if rows.Next() {
 		return true, nil
 	}
@@ -79,3 +162,42 @@ func (repository *DatabaseAccountRepository) Save(account *entity.Account) error
 	}
 	return repository.Database.Save(account).Error
 }
+
+// Update refreshes LastUpdateTime of the account, return gorm.ErrRecordNotFound when account is not found
+func (repository *DatabaseAccountRepository) Update(account *entity.Account) error {
+	account.LastUpdateTime = time.Now()
+	validate := validator.New()
+	if err := validate.Struct(account); err != nil {
+		return err
+	}
+	db := repository.Database.Table(AccountTableName).Where(entity.Account{Id: account.Id}).
+		Updates(map[string]interface{}{"name": account.Name, "email": account.Email, "email_verified": account.EmailVerified,
+			"last_update_time": account.LastUpdateTime})
+	if db.Error != nil {
+		return db.Error
+	}
+	if db.RowsAffected > 0 {
+		return nil
+	}
+	// no row is affected when nothing is changed in the same second, so the existence is checked alone
+	var count uint64
+	if err := repository.Database.Model(&entity.Account{}).Where(entity.Account{Id: account.Id}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// return gorm.ErrRecordNotFound when account is not found
+func (repository *DatabaseAccountRepository) Delete(id uint64) error {
+	db := repository.Database.Where(entity.Account{Id: id}).Delete(&entity.Account{})
+	if db.Error != nil {
+		return db.Error
+	}
+	if db.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
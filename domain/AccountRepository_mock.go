@@ -48,34 +48,78 @@ func (mr *MockAccountRepositoryMockRecorder) Count() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Count", reflect.TypeOf((*MockAccountRepository)(nil).Count))
 }
 
+// Delete mocks base method
+func (m *MockAccountRepository) Delete(arg0 uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete
+func (mr *MockAccountRepositoryMockRecorder) Delete(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockAccountRepository)(nil).Delete), arg0)
+}
+
+// FindByEmail mocks base method
+func (m *MockAccountRepository) FindByEmail(arg0 string) (*entity.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByEmail", arg0)
+	ret0, _ := ret[0].(*entity.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByEmail indicates an expected call of FindByEmail
+func (mr *MockAccountRepositoryMockRecorder) FindByEmail(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByEmail", reflect.TypeOf((*MockAccountRepository)(nil).FindByEmail), arg0)
+}
+
+// FindById mocks base method
+func (m *MockAccountRepository) FindById(arg0 uint64) (*entity.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindById", arg0)
+	ret0, _ := ret[0].(*entity.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindById indicates an expected call of FindById
+func (mr *MockAccountRepositoryMockRecorder) FindById(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindById", reflect.TypeOf((*MockAccountRepository)(nil).FindById), arg0)
+}
+
 // FindByName mocks base method
-func (m *MockAccountRepository) FindByName(arg0 string) (*entity.Account, error) {
+func (m *MockAccountRepository) FindByName(arg0 uint64, arg1 string) (*entity.Account, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindByName", arg0)
+	ret := m.ctrl.Call(m, "FindByName", arg0, arg1)
 	ret0, _ := ret[0].(*entity.Account)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FindByName indicates an expected call of FindByName
-func (mr *MockAccountRepositoryMockRecorder) FindByName(arg0 interface{}) *gomock.Call {
+func (mr *MockAccountRepositoryMockRecorder) FindByName(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByName", reflect.TypeOf((*MockAccountRepository)(nil).FindByName), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByName", reflect.TypeOf((*MockAccountRepository)(nil).FindByName), arg0, arg1)
 }
 
 // IsAccountNameOccupied mocks base method
-func (m *MockAccountRepository) IsAccountNameOccupied(arg0 string) (bool, error) {
+func (m *MockAccountRepository) IsAccountNameOccupied(arg0 uint64, arg1 string) (bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "IsAccountNameOccupied", arg0)
+	ret := m.ctrl.Call(m, "IsAccountNameOccupied", arg0, arg1)
 	ret0, _ := ret[0].(bool)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // IsAccountNameOccupied indicates an expected call of IsAccountNameOccupied
-func (mr *MockAccountRepositoryMockRecorder) IsAccountNameOccupied(arg0 interface{}) *gomock.Call {
+func (mr *MockAccountRepositoryMockRecorder) IsAccountNameOccupied(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsAccountNameOccupied", reflect.TypeOf((*MockAccountRepository)(nil).IsAccountNameOccupied), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsAccountNameOccupied", reflect.TypeOf((*MockAccountRepository)(nil).IsAccountNameOccupied), arg0, arg1)
 }
 
 // IsEmailOccupied mocks base method
@@ -108,6 +152,36 @@ func (mr *MockAccountRepositoryMockRecorder) NextId() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NextId", reflect.TypeOf((*MockAccountRepository)(nil).NextId))
 }
 
+// Query mocks base method
+func (m *MockAccountRepository) Query(arg0 entity.AccountQuery) ([]entity.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Query", arg0)
+	ret0, _ := ret[0].([]entity.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Query indicates an expected call of Query
+func (mr *MockAccountRepositoryMockRecorder) Query(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Query", reflect.TypeOf((*MockAccountRepository)(nil).Query), arg0)
+}
+
+// QueryCount mocks base method
+func (m *MockAccountRepository) QueryCount(arg0 entity.AccountQuery) (uint64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QueryCount", arg0)
+	ret0, _ := ret[0].(uint64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// QueryCount indicates an expected call of QueryCount
+func (mr *MockAccountRepositoryMockRecorder) QueryCount(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryCount", reflect.TypeOf((*MockAccountRepository)(nil).QueryCount), arg0)
+}
+
 // Save mocks base method
 func (m *MockAccountRepository) Save(arg0 *entity.Account) error {
 	m.ctrl.T.Helper()
@@ -121,3 +195,17 @@ func (mr *MockAccountRepositoryMockRecorder) Save(arg0 interface{}) *gomock.Call
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockAccountRepository)(nil).Save), arg0)
 }
+
+// Update mocks base method
+func (m *MockAccountRepository) Update(arg0 *entity.Account) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Update", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Update indicates an expected call of Update
+func (mr *MockAccountRepositoryMockRecorder) Update(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockAccountRepository)(nil).Update), arg0)
+}
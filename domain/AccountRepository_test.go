@@ -124,7 +124,7 @@ func TestDatabaseAccountRepository_IsAccountNameOccupied(it *testing.T) {
 			Database: ds.Database,
 		}
 
-		occupied, err := repository.IsAccountNameOccupied(uuid.New().String())
+		occupied, err := repository.IsAccountNameOccupied(0, uuid.New().String())
 		assert.Equal(t, nil, err)
 		assert.Equal(t, false, occupied)
 	})
@@ -147,7 +147,7 @@ func TestDatabaseAccountRepository_IsAccountNameOccupied(it *testing.T) {
 			Database: ds.Database,
 		}
 
-		occupied, err := repository.IsAccountNameOccupied("test-occupied")
+		occupied, err := repository.IsAccountNameOccupied(0, "test-occupied")
 		assert.Equal(t, nil, err)
 		assert.Equal(t, true, occupied)
 	})
@@ -164,7 +164,7 @@ func TestDatabaseAccountRepository_FindByName(it *testing.T) {
 		}
 		accountName := uuid.New().String()
 
-		account, err := repository.FindByName(accountName)
+		account, err := repository.FindByName(0, accountName)
 		assert.Equal(t, true, gorm.IsRecordNotFoundError(err))
 		assert.Nil(t, account)
 	})
@@ -188,12 +188,147 @@ func TestDatabaseAccountRepository_FindByName(it *testing.T) {
 			Database: ds.Database,
 		}
 
-		found, err := repository.FindByName(account.Name)
+		found, err := repository.FindByName(0, account.Name)
 		assert.Equal(t, nil, err)
 		assert.Equal(t, account.Id, found.Id)
 	})
 }
 
+func TestDatabaseAccountRepository_FindByEmail(it *testing.T) {
+	it.Run("should return account if email is found", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		repository := &DatabaseAccountRepository{
+			IdWorker: util.DefaultIdWorker,
+			Database: ds.Database,
+		}
+
+		account, err := repository.FindByEmail("test-findByEmail@test.fundwit.com")
+		assert.Equal(t, true, gorm.IsRecordNotFoundError(err))
+		assert.Nil(t, account)
+
+		ds.Database.Save(entity.Account{
+			Id:             112,
+			Name:           "test-findByEmail",
+			Email:          "test-findByEmail@test.fundwit.com",
+			CreateTime:     time.Now(),
+			LastUpdateTime: time.Now(),
+		})
+		defer ds.Database.Delete(entity.Account{Id: 112})
+
+		found, err := repository.FindByEmail("test-findByEmail@test.fundwit.com")
+		assert.Equal(t, nil, err)
+		assert.Equal(t, uint64(112), found.Id)
+	})
+}
+
+func TestDatabaseAccountRepository_FindById(it *testing.T) {
+	it.Run("should update, find and delete account by id", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		repository := &DatabaseAccountRepository{
+			IdWorker: util.DefaultIdWorker,
+			Database: ds.Database,
+		}
+
+		account, err := repository.FindById(113)
+		assert.Equal(t, true, gorm.IsRecordNotFoundError(err))
+		assert.Nil(t, account)
+		assert.Equal(t, true, gorm.IsRecordNotFoundError(repository.Update(&entity.Account{
+			Id: 113, Name: "test-findById", Email: "test-findById@test.fundwit.com", CreateTime: time.Now()})))
+
+		createTime := time.Now().Add(-time.Hour)
+		ds.Database.Save(entity.Account{
+			Id:             113,
+			Name:           "test-findById",
+			Email:          "test-findById@test.fundwit.com",
+			CreateTime:     createTime,
+			LastUpdateTime: createTime,
+		})
+		defer ds.Database.Delete(entity.Account{Id: 113})
+
+		found, err := repository.FindById(113)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, "test-findById", found.Name)
+
+		found.Email = "test-findById-updated@test.fundwit.com"
+		assert.Nil(t, repository.Update(found))
+		assert.True(t, found.LastUpdateTime.After(createTime))
+		found, err = repository.FindById(113)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, "test-findById-updated@test.fundwit.com", found.Email)
+		// nothing is changed when it is updated twice in the same second
+		assert.Nil(t, repository.Update(found))
+		assert.Nil(t, repository.Update(found))
+
+		assert.Nil(t, repository.Delete(113))
+		assert.Equal(t, true, gorm.IsRecordNotFoundError(repository.Delete(113)))
+	})
+}
+
+func TestDatabaseAccountRepository_Query(it *testing.T) {
+	it.Run("should query accounts with filters and cursor", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		repository := &DatabaseAccountRepository{
+			IdWorker: util.DefaultIdWorker,
+			Database: ds.Database,
+		}
+		base := time.Now().Add(-time.Hour).Truncate(time.Second)
+		for i, name := range []string{"query_a", "query_b", "queryc", "other"} {
+			ds.Database.Save(entity.Account{
+				Id:             uint64(301 + i),
+				Name:           name,
+				Email:          name + "@test.fundwit.com",
+				CreateTime:     base.Add(time.Duration(i) * time.Minute),
+				LastUpdateTime: base,
+			})
+			defer ds.Database.Delete(entity.Account{Id: uint64(301 + i)})
+		}
+
+		idsOf := func(accounts []entity.Account) []uint64 {
+			var ids []uint64
+			for _, account := range accounts {
+				ids = append(ids, account.Id)
+			}
+			return ids
+		}
+
+		accounts, err := repository.Query(entity.AccountQuery{NamePrefix: "query"})
+		assert.Nil(t, err)
+		assert.Equal(t, []uint64{301, 302, 303}, idsOf(accounts))
+
+		// "_" is not a wildcard
+		accounts, err = repository.Query(entity.AccountQuery{NamePrefix: "query_"})
+		assert.Nil(t, err)
+		assert.Equal(t, []uint64{301, 302}, idsOf(accounts))
+
+		accounts, err = repository.Query(entity.AccountQuery{EmailPrefix: "query", Cursor: 301, Limit: 1})
+		assert.Nil(t, err)
+		assert.Equal(t, []uint64{302}, idsOf(accounts))
+
+		accounts, err = repository.Query(entity.AccountQuery{NamePrefix: "query", Cursor: 303, Descending: true})
+		assert.Nil(t, err)
+		assert.Equal(t, []uint64{302, 301}, idsOf(accounts))
+
+		accounts, err = repository.Query(entity.AccountQuery{
+			CreatedAfter: base.Add(time.Minute), CreatedBefore: base.Add(3 * time.Minute)})
+		assert.Nil(t, err)
+		assert.Equal(t, []uint64{302, 303}, idsOf(accounts))
+
+		// the count ignores the cursor and the limit of page
+		count, err := repository.QueryCount(entity.AccountQuery{EmailPrefix: "query", Cursor: 301, Limit: 1})
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(3), count)
+		count, err = repository.QueryCount(entity.AccountQuery{NamePrefix: "query_"})
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(2), count)
+	})
+}
+
 func TestDatabaseAccountRepository_Save(it *testing.T) {
 	it.Run("should save account successfully", func(t *testing.T) {
 		ds := testinfra.NewTemporaryDatabase()
@@ -205,7 +340,7 @@ func TestDatabaseAccountRepository_Save(it *testing.T) {
 		}
 
 		// 查询不到
-		occupied, err := repository.IsAccountNameOccupied("test-save")
+		occupied, err := repository.IsAccountNameOccupied(0, "test-save")
 		assert.Equal(t, nil, err)
 		assert.Equal(t, false, occupied)
 
@@ -220,7 +355,7 @@ func TestDatabaseAccountRepository_Save(it *testing.T) {
 		assert.Equal(t, nil, err)
 
 		// 可查询到
-		occupied, err = repository.IsAccountNameOccupied("test-save")
+		occupied, err = repository.IsAccountNameOccupied(0, "test-save")
 		assert.Equal(t, nil, err)
 		assert.Equal(t, true, occupied)
 	})
@@ -34,9 +34,130 @@ func (e *ErrUnauthorized) Error() string {
 	return "unauthorized"
 }
 
+type ErrForbidden struct {
+}
+
+func (e *ErrForbidden) Error() string {
+	return "forbidden"
+}
+
 type ErrRegisterTokenInvalid struct {
 }
 
 func (e *ErrRegisterTokenInvalid) Error() string {
 	return "register.token.is.invalid"
 }
+
+type ErrPasswordResetTokenInvalid struct {
+}
+
+func (e *ErrPasswordResetTokenInvalid) Error() string {
+	return "password_reset.token.is.invalid"
+}
+
+type GroupNameIsOccupied struct {
+}
+
+func (e *GroupNameIsOccupied) Error() string {
+	return "group name is occupied"
+}
+
+type ErrGroupCycle struct {
+}
+
+func (e *ErrGroupCycle) Error() string {
+	return "group can not be nested in itself or its descendants"
+}
+
+type ErrGroupHasChildren struct {
+}
+
+func (e *ErrGroupHasChildren) Error() string {
+	return "group has child groups"
+}
+
+type OrganizationNameIsOccupied struct {
+}
+
+func (e *OrganizationNameIsOccupied) Error() string {
+	return "organization name is occupied"
+}
+
+type ErrAccountOutsideOrganization struct {
+}
+
+func (e *ErrAccountOutsideOrganization) Error() string {
+	return "account does not belong to the organization"
+}
+
+type ErrRoleNotFound struct {
+	Role string
+}
+
+func (e *ErrRoleNotFound) Error() string {
+	return "role " + e.Role + " is not found"
+}
+
+type ErrUnknownPermission struct {
+	Permission string
+}
+
+func (e *ErrUnknownPermission) Error() string {
+	return "permission " + e.Permission + " is unknown"
+}
+
+type ErrOAuthClientAuthenticationFailure struct {
+}
+
+func (e *ErrOAuthClientAuthenticationFailure) Error() string {
+	return "client is not exist or secret is invalid"
+}
+
+type ServiceAccountNameIsOccupied struct {
+}
+
+func (e *ServiceAccountNameIsOccupied) Error() string {
+	return "service account name is occupied"
+}
+
+type ExternalIdentityEmailMissing struct {
+}
+
+func (e *ExternalIdentityEmailMissing) Error() string {
+	return "email of external identity is required"
+}
+
+type IdentityIsBound struct {
+}
+
+func (e *IdentityIsBound) Error() string {
+	return "identity is bound to another account, or account has been bound to the provider"
+}
+
+type LastIdentityIsRequired struct {
+}
+
+func (e *LastIdentityIsRequired) Error() string {
+	return "the last login method of account can not be removed"
+}
+
+type TotpIsEnrolled struct {
+}
+
+func (e *TotpIsEnrolled) Error() string {
+	return "totp is enrolled already"
+}
+
+type SecondFactorAuthenticationFailure struct {
+}
+
+func (e *SecondFactorAuthenticationFailure) Error() string {
+	return "verification code is not match"
+}
+
+type SecondFactorIsLocked struct {
+}
+
+func (e *SecondFactorIsLocked) Error() string {
+	return "second factor is locked by too many failed codes, try again later"
+}
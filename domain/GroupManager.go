@@ -0,0 +1,182 @@
+package domain
+
+import (
+	"github.com/go-playground/validator/v10"
+	"hallo/domain/entity"
+	"log"
+	"time"
+)
+
+//go:generate mockgen -destination GroupManager_mock.go -package domain hallo/domain GroupManager
+type GroupManager interface {
+	CreateGroup(action entity.GroupCreateRequest) (*entity.Group, error)
+	UpdateGroup(groupId uint64, action entity.GroupUpdateRequest) (*entity.Group, error)
+	// DeleteGroup refuses to delete group which has child groups
+	DeleteGroup(groupId uint64) error
+	AddMember(groupId, accountId uint64) error
+	RemoveMember(groupId, accountId uint64) error
+	// FindMemberships returns names of the groups the account belongs to,
+	// members of a group are members of all its ancestors as well
+	FindMemberships(accountId uint64) ([]string, error)
+}
+
+type GroupManagerImpl struct {
+	GroupRepository GroupRepository
+
+	UnitOfWork UnitOfWork
+}
+
+func (manager *GroupManagerImpl) CreateGroup(action entity.GroupCreateRequest) (*entity.Group, error) {
+	if err := validator.New().Struct(action); err != nil {
+		return nil, err
+	}
+
+	var group *entity.Group
+	err := manager.UnitOfWork.Do(func(repositories *Repositories) error {
+		isNameOccupied, err := repositories.GroupRepository.IsGroupNameOccupied(action.Name)
+		if err != nil {
+			return err
+		}
+		if isNameOccupied {
+			return &GroupNameIsOccupied{}
+		}
+
+		if action.ParentId != 0 {
+			if _, err := repositories.GroupRepository.FindById(action.ParentId); err != nil {
+				return err
+			}
+		}
+
+		groupId, err := repositories.GroupRepository.NextId()
+		if err != nil {
+			log.Println(err)
+			return IdGenerateFailure
+		}
+
+		now := time.Now()
+		group = &entity.Group{
+			Id:          groupId,
+			Name:        action.Name,
+			Description: action.Description,
+			ParentId:    action.ParentId,
+
+			CreateTime:     now,
+			LastUpdateTime: now,
+		}
+		return repositories.GroupRepository.Save(group)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+func (manager *GroupManagerImpl) UpdateGroup(groupId uint64, action entity.GroupUpdateRequest) (*entity.Group, error) {
+	var group *entity.Group
+	err := manager.UnitOfWork.Do(func(repositories *Repositories) error {
+		var err error
+		group, err = repositories.GroupRepository.FindById(groupId)
+		if err != nil {
+			return err
+		}
+
+		if action.Name != "" && action.Name != group.Name {
+			isNameOccupied, err := repositories.GroupRepository.IsGroupNameOccupied(action.Name)
+			if err != nil {
+				return err
+			}
+			if isNameOccupied {
+				return &GroupNameIsOccupied{}
+			}
+			group.Name = action.Name
+		}
+		if action.Description != nil {
+			group.Description = *action.Description
+		}
+		if action.ParentId != nil && *action.ParentId != group.ParentId {
+			if err := checkNoCycle(repositories.GroupRepository, groupId, *action.ParentId); err != nil {
+				return err
+			}
+			group.ParentId = *action.ParentId
+		}
+
+		group.LastUpdateTime = time.Now()
+		return repositories.GroupRepository.Save(group)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+func (manager *GroupManagerImpl) DeleteGroup(groupId uint64) error {
+	return manager.UnitOfWork.Do(func(repositories *Repositories) error {
+		hasChildren, err := repositories.GroupRepository.HasChildren(groupId)
+		if err != nil {
+			return err
+		}
+		if hasChildren {
+			return &ErrGroupHasChildren{}
+		}
+		return repositories.GroupRepository.Delete(groupId)
+	})
+}
+
+func (manager *GroupManagerImpl) AddMember(groupId, accountId uint64) error {
+	return manager.UnitOfWork.Do(func(repositories *Repositories) error {
+		if _, err := repositories.GroupRepository.FindById(groupId); err != nil {
+			return err
+		}
+		if _, err := repositories.AccountRepository.FindById(accountId); err != nil {
+			return err
+		}
+		return repositories.GroupRepository.AddMember(groupId, accountId)
+	})
+}
+
+func (manager *GroupManagerImpl) RemoveMember(groupId, accountId uint64) error {
+	return manager.GroupRepository.RemoveMember(groupId, accountId)
+}
+
+func (manager *GroupManagerImpl) FindMemberships(accountId uint64) ([]string, error) {
+	groupIds, err := manager.GroupRepository.FindGroupIdsByAccountId(accountId)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	visited := map[uint64]bool{}
+	for len(groupIds) > 0 {
+		groups, err := manager.GroupRepository.FindByIds(groupIds)
+		if err != nil {
+			return nil, err
+		}
+		groupIds = nil
+		for _, group := range groups {
+			if visited[group.Id] {
+				continue
+			}
+			visited[group.Id] = true
+			names = append(names, group.Name)
+			if group.ParentId != 0 && !visited[group.ParentId] {
+				groupIds = append(groupIds, group.ParentId)
+			}
+		}
+	}
+	return names, nil
+}
+
+// checkNoCycle makes sure the new parent exists and is not the group itself or one of its descendants
+func checkNoCycle(repository GroupRepository, groupId, parentId uint64) error {
+	for parentId != 0 {
+		if parentId == groupId {
+			return &ErrGroupCycle{}
+		}
+		parent, err := repository.FindById(parentId)
+		if err != nil {
+			return err
+		}
+		parentId = parent.ParentId
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: hallo/domain (interfaces: GroupManager)
+
+// Package domain is a generated GoMock package.
+package domain
+
+import (
+	gomock "github.com/golang/mock/gomock"
+	entity "hallo/domain/entity"
+	reflect "reflect"
+)
+
+// MockGroupManager is a mock of GroupManager interface
+type MockGroupManager struct {
+	ctrl     *gomock.Controller
+	recorder *MockGroupManagerMockRecorder
+}
+
+// MockGroupManagerMockRecorder is the mock recorder for MockGroupManager
+type MockGroupManagerMockRecorder struct {
+	mock *MockGroupManager
+}
+
+// NewMockGroupManager creates a new mock instance
+func NewMockGroupManager(ctrl *gomock.Controller) *MockGroupManager {
+	mock := &MockGroupManager{ctrl: ctrl}
+	mock.recorder = &MockGroupManagerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockGroupManager) EXPECT() *MockGroupManagerMockRecorder {
+	return m.recorder
+}
+
+// AddMember mocks base method
+func (m *MockGroupManager) AddMember(arg0, arg1 uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddMember", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddMember indicates an expected call of AddMember
+func (mr *MockGroupManagerMockRecorder) AddMember(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddMember", reflect.TypeOf((*MockGroupManager)(nil).AddMember), arg0, arg1)
+}
+
+// CreateGroup mocks base method
+func (m *MockGroupManager) CreateGroup(arg0 entity.GroupCreateRequest) (*entity.Group, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateGroup", arg0)
+	ret0, _ := ret[0].(*entity.Group)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateGroup indicates an expected call of CreateGroup
+func (mr *MockGroupManagerMockRecorder) CreateGroup(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateGroup", reflect.TypeOf((*MockGroupManager)(nil).CreateGroup), arg0)
+}
+
+// DeleteGroup mocks base method
+func (m *MockGroupManager) DeleteGroup(arg0 uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteGroup", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteGroup indicates an expected call of DeleteGroup
+func (mr *MockGroupManagerMockRecorder) DeleteGroup(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteGroup", reflect.TypeOf((*MockGroupManager)(nil).DeleteGroup), arg0)
+}
+
+// FindMemberships mocks base method
+func (m *MockGroupManager) FindMemberships(arg0 uint64) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindMemberships", arg0)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindMemberships indicates an expected call of FindMemberships
+func (mr *MockGroupManagerMockRecorder) FindMemberships(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindMemberships", reflect.TypeOf((*MockGroupManager)(nil).FindMemberships), arg0)
+}
+
+// RemoveMember mocks base method
+func (m *MockGroupManager) RemoveMember(arg0, arg1 uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveMember", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveMember indicates an expected call of RemoveMember
+func (mr *MockGroupManagerMockRecorder) RemoveMember(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveMember", reflect.TypeOf((*MockGroupManager)(nil).RemoveMember), arg0, arg1)
+}
+
+// UpdateGroup mocks base method
+func (m *MockGroupManager) UpdateGroup(arg0 uint64, arg1 entity.GroupUpdateRequest) (*entity.Group, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateGroup", arg0, arg1)
+	ret0, _ := ret[0].(*entity.Group)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateGroup indicates an expected call of UpdateGroup
+func (mr *MockGroupManagerMockRecorder) UpdateGroup(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateGroup", reflect.TypeOf((*MockGroupManager)(nil).UpdateGroup), arg0, arg1)
+}
@@ -0,0 +1,98 @@
+package domain
+
+import (
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"hallo/domain/entity"
+	"hallo/infra"
+	"hallo/testinfra"
+	"hallo/util"
+	"testing"
+)
+
+func TestGroupManager_FindMemberships(it *testing.T) {
+	it.Run("should include ancestors of the groups account belongs to", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		groupRepository := NewMockGroupRepository(mockCtl)
+		manager := &GroupManagerImpl{GroupRepository: groupRepository}
+
+		// engineering <- dev <- backend, ops; account is member of backend and dev
+		groupRepository.EXPECT().FindGroupIdsByAccountId(uint64(123)).Return([]uint64{3, 2}, nil)
+		groupRepository.EXPECT().FindByIds([]uint64{3, 2}).Return([]entity.Group{
+			{Id: 2, Name: "dev", ParentId: 1}, {Id: 3, Name: "backend", ParentId: 2}}, nil)
+		groupRepository.EXPECT().FindByIds([]uint64{1}).Return([]entity.Group{{Id: 1, Name: "engineering"}}, nil)
+
+		groups, err := manager.FindMemberships(123)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"dev", "backend", "engineering"}, groups)
+
+		groupRepository.EXPECT().FindGroupIdsByAccountId(uint64(456)).Return([]uint64{}, nil)
+		groups, err = manager.FindMemberships(456)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{}, groups)
+	})
+}
+
+func TestGroupManager(it *testing.T) {
+	it.Run("should manage nested groups and members", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		unitOfWork := &DatabaseUnitOfWork{TransactionSupport: &infra.GormTransactionSupport{Database: ds.Database}, IdWorker: util.DefaultIdWorker}
+		accountManager := AccountManagerImpl{
+			AccountRepository:          &DatabaseAccountRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database},
+			IdentityBindingRepository:  &DatabaseIdentityBindingRepository{Database: ds.Database},
+			InternalIdentityRepository: &DatabaseInternalIdentityRepository{Database: ds.Database},
+			UnitOfWork:                 unitOfWork,
+		}
+		manager := &GroupManagerImpl{
+			GroupRepository: &DatabaseGroupRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database},
+			UnitOfWork:      unitOfWork,
+		}
+
+		accountName := uuid.New().String()
+		account, err := accountManager.CreateAccount(entity.EmailAccountCreateRequest{
+			Name: accountName, Secret: "secret", Email: accountName + "@test.fundwit.com"})
+		assert.Nil(t, err)
+
+		engineering, err := manager.CreateGroup(entity.GroupCreateRequest{Name: "engineering"})
+		assert.Nil(t, err)
+		dev, err := manager.CreateGroup(entity.GroupCreateRequest{Name: "dev", ParentId: engineering.Id})
+		assert.Nil(t, err)
+		_, err = manager.CreateGroup(entity.GroupCreateRequest{Name: "dev"})
+		assert.Equal(t, &GroupNameIsOccupied{}, err)
+		_, err = manager.CreateGroup(entity.GroupCreateRequest{Name: "orphan", ParentId: 1})
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+
+		// cycle
+		_, err = manager.UpdateGroup(engineering.Id, entity.GroupUpdateRequest{ParentId: &dev.Id})
+		assert.Equal(t, &ErrGroupCycle{}, err)
+
+		description := "developers"
+		updated, err := manager.UpdateGroup(dev.Id, entity.GroupUpdateRequest{Description: &description})
+		assert.Nil(t, err)
+		assert.Equal(t, "developers", updated.Description)
+		assert.Equal(t, engineering.Id, updated.ParentId)
+
+		assert.Nil(t, manager.AddMember(dev.Id, account.Id))
+		assert.True(t, gorm.IsRecordNotFoundError(manager.AddMember(dev.Id, 1)))
+		groups, err := manager.FindMemberships(account.Id)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"dev", "engineering"}, groups)
+
+		assert.Equal(t, &ErrGroupHasChildren{}, manager.DeleteGroup(engineering.Id))
+		assert.Nil(t, manager.DeleteGroup(dev.Id))
+		groups, err = manager.FindMemberships(account.Id)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{}, groups)
+
+		assert.Nil(t, manager.AddMember(engineering.Id, account.Id))
+		assert.Nil(t, accountManager.DeleteAccount(account.Id))
+		groups, err = manager.FindMemberships(account.Id)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{}, groups)
+	})
+}
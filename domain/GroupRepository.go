@@ -0,0 +1,128 @@
+package domain
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/jinzhu/gorm"
+	"hallo/domain/entity"
+	"hallo/util"
+	"time"
+)
+
+const (
+	GroupTableName       = "user_groups"
+	GroupMemberTableName = "group_members"
+)
+
+//go:generate mockgen -destination GroupRepository_mock.go -package domain hallo/domain GroupRepository
+type GroupRepository interface {
+	NextId() (uint64, error)
+	IsGroupNameOccupied(name string) (bool, error)
+	// return (nil, gorm.ErrRecordNotFound) when group is not found
+	FindById(id uint64) (*entity.Group, error)
+	FindByIds(ids []uint64) ([]entity.Group, error)
+	FindAll() ([]entity.Group, error)
+	HasChildren(id uint64) (bool, error)
+	Save(group *entity.Group) error
+	// Delete deletes the group with its members, return gorm.ErrRecordNotFound when group is not found
+	Delete(id uint64) error
+
+	AddMember(groupId, accountId uint64) error
+	RemoveMember(groupId, accountId uint64) error
+	RemoveMembersByAccountId(accountId uint64) error
+	FindMemberIds(groupId uint64) ([]uint64, error)
+	// FindGroupIdsByAccountId returns ids of the groups which the account is a direct member of
+	FindGroupIdsByAccountId(accountId uint64) ([]uint64, error)
+}
+
+type DatabaseGroupRepository struct {
+	IdWorker *util.IdWorker
+	Database *gorm.DB
+}
+
+func (repository *DatabaseGroupRepository) NextId() (uint64, error) {
+	return repository.IdWorker.NextId()
+}
+
+func (repository *DatabaseGroupRepository) IsGroupNameOccupied(name string) (bool, error) {
+	var count int
+	err := repository.Database.Table(GroupTableName).Where(entity.Group{Name: name}).Count(&count).Error
+	return count > 0, err
+}
+
+func (repository *DatabaseGroupRepository) FindById(id uint64) (*entity.Group, error) {
+	group := &entity.Group{}
+	if err := repository.Database.Table(GroupTableName).First(group, entity.Group{Id: id}).Error; err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+func (repository *DatabaseGroupRepository) FindByIds(ids []uint64) ([]entity.Group, error) {
+	groups := []entity.Group{}
+	if len(ids) == 0 {
+		return groups, nil
+	}
+	err := repository.Database.Table(GroupTableName).Where("id IN (?)", ids).Order("id").Find(&groups).Error
+	return groups, err
+}
+
+func (repository *DatabaseGroupRepository) FindAll() ([]entity.Group, error) {
+	groups := []entity.Group{}
+	err := repository.Database.Table(GroupTableName).Order("id").Find(&groups).Error
+	return groups, err
+}
+
+func (repository *DatabaseGroupRepository) HasChildren(id uint64) (bool, error) {
+	var count int
+	err := repository.Database.Table(GroupTableName).Where("parent_id = ?", id).Count(&count).Error
+	return count > 0, err
+}
+
+func (repository *DatabaseGroupRepository) Save(group *entity.Group) error {
+	if err := validator.New().Struct(group); err != nil {
+		return err
+	}
+	return repository.Database.Save(group).Error
+}
+
+func (repository *DatabaseGroupRepository) Delete(id uint64) error {
+	if err := repository.Database.Where(entity.GroupMember{GroupId: id}).Delete(&entity.GroupMember{}).Error; err != nil {
+		return err
+	}
+	db := repository.Database.Where(entity.Group{Id: id}).Delete(&entity.Group{})
+	if db.Error != nil {
+		return db.Error
+	}
+	if db.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (repository *DatabaseGroupRepository) AddMember(groupId, accountId uint64) error {
+	member := entity.GroupMember{GroupId: groupId, AccountId: accountId, CreateTime: time.Now()}
+	if err := validator.New().Struct(member); err != nil {
+		return err
+	}
+	return repository.Database.Save(member).Error
+}
+
+func (repository *DatabaseGroupRepository) RemoveMember(groupId, accountId uint64) error {
+	return repository.Database.Where(entity.GroupMember{GroupId: groupId, AccountId: accountId}).Delete(&entity.GroupMember{}).Error
+}
+
+func (repository *DatabaseGroupRepository) RemoveMembersByAccountId(accountId uint64) error {
+	return repository.Database.Where(entity.GroupMember{AccountId: accountId}).Delete(&entity.GroupMember{}).Error
+}
+
+func (repository *DatabaseGroupRepository) FindMemberIds(groupId uint64) ([]uint64, error) {
+	ids := []uint64{}
+	err := repository.Database.Table(GroupMemberTableName).Where("group_id = ?", groupId).Order("account_id").Pluck("account_id", &ids).Error
+	return ids, err
+}
+
+func (repository *DatabaseGroupRepository) FindGroupIdsByAccountId(accountId uint64) ([]uint64, error) {
+	ids := []uint64{}
+	err := repository.Database.Table(GroupMemberTableName).Where("account_id = ?", accountId).Order("group_id").Pluck("group_id", &ids).Error
+	return ids, err
+}
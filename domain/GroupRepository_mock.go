@@ -0,0 +1,224 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: hallo/domain (interfaces: GroupRepository)
+
+// Package domain is a generated GoMock package.
+package domain
+
+import (
+	gomock "github.com/golang/mock/gomock"
+	entity "hallo/domain/entity"
+	reflect "reflect"
+)
+
+// MockGroupRepository is a mock of GroupRepository interface
+type MockGroupRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockGroupRepositoryMockRecorder
+}
+
+// MockGroupRepositoryMockRecorder is the mock recorder for MockGroupRepository
+type MockGroupRepositoryMockRecorder struct {
+	mock *MockGroupRepository
+}
+
+// NewMockGroupRepository creates a new mock instance
+func NewMockGroupRepository(ctrl *gomock.Controller) *MockGroupRepository {
+	mock := &MockGroupRepository{ctrl: ctrl}
+	mock.recorder = &MockGroupRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockGroupRepository) EXPECT() *MockGroupRepositoryMockRecorder {
+	return m.recorder
+}
+
+// AddMember mocks base method
+func (m *MockGroupRepository) AddMember(arg0, arg1 uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddMember", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddMember indicates an expected call of AddMember
+func (mr *MockGroupRepositoryMockRecorder) AddMember(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddMember", reflect.TypeOf((*MockGroupRepository)(nil).AddMember), arg0, arg1)
+}
+
+// Delete mocks base method
+func (m *MockGroupRepository) Delete(arg0 uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete
+func (mr *MockGroupRepositoryMockRecorder) Delete(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockGroupRepository)(nil).Delete), arg0)
+}
+
+// FindAll mocks base method
+func (m *MockGroupRepository) FindAll() ([]entity.Group, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindAll")
+	ret0, _ := ret[0].([]entity.Group)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindAll indicates an expected call of FindAll
+func (mr *MockGroupRepositoryMockRecorder) FindAll() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAll", reflect.TypeOf((*MockGroupRepository)(nil).FindAll))
+}
+
+// FindById mocks base method
+func (m *MockGroupRepository) FindById(arg0 uint64) (*entity.Group, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindById", arg0)
+	ret0, _ := ret[0].(*entity.Group)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindById indicates an expected call of FindById
+func (mr *MockGroupRepositoryMockRecorder) FindById(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindById", reflect.TypeOf((*MockGroupRepository)(nil).FindById), arg0)
+}
+
+// FindByIds mocks base method
+func (m *MockGroupRepository) FindByIds(arg0 []uint64) ([]entity.Group, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByIds", arg0)
+	ret0, _ := ret[0].([]entity.Group)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByIds indicates an expected call of FindByIds
+func (mr *MockGroupRepositoryMockRecorder) FindByIds(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByIds", reflect.TypeOf((*MockGroupRepository)(nil).FindByIds), arg0)
+}
+
+// FindGroupIdsByAccountId mocks base method
+func (m *MockGroupRepository) FindGroupIdsByAccountId(arg0 uint64) ([]uint64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindGroupIdsByAccountId", arg0)
+	ret0, _ := ret[0].([]uint64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindGroupIdsByAccountId indicates an expected call of FindGroupIdsByAccountId
+func (mr *MockGroupRepositoryMockRecorder) FindGroupIdsByAccountId(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindGroupIdsByAccountId", reflect.TypeOf((*MockGroupRepository)(nil).FindGroupIdsByAccountId), arg0)
+}
+
+// FindMemberIds mocks base method
+func (m *MockGroupRepository) FindMemberIds(arg0 uint64) ([]uint64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindMemberIds", arg0)
+	ret0, _ := ret[0].([]uint64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindMemberIds indicates an expected call of FindMemberIds
+func (mr *MockGroupRepositoryMockRecorder) FindMemberIds(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindMemberIds", reflect.TypeOf((*MockGroupRepository)(nil).FindMemberIds), arg0)
+}
+
+// HasChildren mocks base method
+func (m *MockGroupRepository) HasChildren(arg0 uint64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HasChildren", arg0)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HasChildren indicates an expected call of HasChildren
+func (mr *MockGroupRepositoryMockRecorder) HasChildren(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HasChildren", reflect.TypeOf((*MockGroupRepository)(nil).HasChildren), arg0)
+}
+
+// IsGroupNameOccupied mocks base method
+func (m *MockGroupRepository) IsGroupNameOccupied(arg0 string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsGroupNameOccupied", arg0)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsGroupNameOccupied indicates an expected call of IsGroupNameOccupied
+func (mr *MockGroupRepositoryMockRecorder) IsGroupNameOccupied(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsGroupNameOccupied", reflect.TypeOf((*MockGroupRepository)(nil).IsGroupNameOccupied), arg0)
+}
+
+// NextId mocks base method
+func (m *MockGroupRepository) NextId() (uint64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NextId")
+	ret0, _ := ret[0].(uint64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// NextId indicates an expected call of NextId
+func (mr *MockGroupRepositoryMockRecorder) NextId() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NextId", reflect.TypeOf((*MockGroupRepository)(nil).NextId))
+}
+
+// RemoveMember mocks base method
+func (m *MockGroupRepository) RemoveMember(arg0, arg1 uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveMember", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveMember indicates an expected call of RemoveMember
+func (mr *MockGroupRepositoryMockRecorder) RemoveMember(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveMember", reflect.TypeOf((*MockGroupRepository)(nil).RemoveMember), arg0, arg1)
+}
+
+// RemoveMembersByAccountId mocks base method
+func (m *MockGroupRepository) RemoveMembersByAccountId(arg0 uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveMembersByAccountId", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveMembersByAccountId indicates an expected call of RemoveMembersByAccountId
+func (mr *MockGroupRepositoryMockRecorder) RemoveMembersByAccountId(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveMembersByAccountId", reflect.TypeOf((*MockGroupRepository)(nil).RemoveMembersByAccountId), arg0)
+}
+
+// Save mocks base method
+func (m *MockGroupRepository) Save(arg0 *entity.Group) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Save", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Save indicates an expected call of Save
+func (mr *MockGroupRepositoryMockRecorder) Save(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockGroupRepository)(nil).Save), arg0)
+}
@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"hallo/domain/entity"
+	"hallo/testinfra"
+	"hallo/util"
+	"testing"
+	"time"
+)
+
+func TestDatabaseGroupRepository(it *testing.T) {
+	it.Run("should save, find and delete groups with members", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		repository := &DatabaseGroupRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database}
+		now := time.Now()
+		parent := &entity.Group{Id: 501, Name: "parent", CreateTime: now, LastUpdateTime: now}
+		child := &entity.Group{Id: 502, Name: "child", ParentId: 501, CreateTime: now, LastUpdateTime: now}
+		assert.Nil(t, repository.Save(parent))
+		assert.Nil(t, repository.Save(child))
+
+		occupied, err := repository.IsGroupNameOccupied("child")
+		assert.Nil(t, err)
+		assert.True(t, occupied)
+		hasChildren, err := repository.HasChildren(501)
+		assert.Nil(t, err)
+		assert.True(t, hasChildren)
+		groups, err := repository.FindByIds([]uint64{502, 501})
+		assert.Nil(t, err)
+		assert.Len(t, groups, 2)
+
+		assert.Nil(t, repository.AddMember(502, 123))
+		assert.Nil(t, repository.AddMember(501, 123))
+		assert.Nil(t, repository.AddMember(502, 456))
+		groupIds, err := repository.FindGroupIdsByAccountId(123)
+		assert.Nil(t, err)
+		assert.Equal(t, []uint64{501, 502}, groupIds)
+		accountIds, err := repository.FindMemberIds(502)
+		assert.Nil(t, err)
+		assert.Equal(t, []uint64{123, 456}, accountIds)
+
+		assert.Nil(t, repository.RemoveMember(502, 456))
+		assert.Nil(t, repository.RemoveMembersByAccountId(123))
+		accountIds, err = repository.FindMemberIds(502)
+		assert.Nil(t, err)
+		assert.Equal(t, []uint64{}, accountIds)
+
+		assert.Nil(t, repository.Delete(502))
+		assert.True(t, gorm.IsRecordNotFoundError(repository.Delete(502)))
+		_, err = repository.FindById(502)
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+	})
+}
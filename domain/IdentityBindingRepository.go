@@ -12,6 +12,12 @@ const IdentityBindingTableName = "identity_bindings"
 //go:generate mockgen -destination IdentityBindingRepository_mock.go -package domain hallo/domain IdentityBindingRepository
 type IdentityBindingRepository interface {
 	Save(accountId uint64, providerId, providerAccountId string) error
+	// return (nil, gorm.ErrRecordNotFound) when the identity is not bound
+	FindByProviderAccountId(providerId, providerAccountId string) (*entity.IdentityBinding, error)
+	FindByAccountId(accountId uint64) ([]entity.IdentityBinding, error)
+	DeleteByAccountId(accountId uint64) error
+	// DeleteByProviderId returns gorm.ErrRecordNotFound when the account is not bound to the provider
+	DeleteByProviderId(accountId uint64, providerId string) error
 }
 
 type DatabaseIdentityBindingRepository struct {
@@ -33,3 +39,35 @@ func (repository *DatabaseIdentityBindingRepository) Save(accountId uint64, prov
 
 	return repository.Database.Save(identityBinding).Error
 }
+
+func (repository *DatabaseIdentityBindingRepository) FindByProviderAccountId(providerId, providerAccountId string) (*entity.IdentityBinding, error) {
+	identityBinding := &entity.IdentityBinding{}
+	err := repository.Database.Table(IdentityBindingTableName).
+		Where("provider_id = ? AND provider_account_id = ?", providerId, providerAccountId).First(identityBinding).Error
+	if err != nil {
+		return nil, err
+	}
+	return identityBinding, nil
+}
+
+func (repository *DatabaseIdentityBindingRepository) FindByAccountId(accountId uint64) ([]entity.IdentityBinding, error) {
+	identityBindings := []entity.IdentityBinding{}
+	err := repository.Database.Table(IdentityBindingTableName).Where("account_id = ?", accountId).
+		Order("create_time").Find(&identityBindings).Error
+	return identityBindings, err
+}
+
+func (repository *DatabaseIdentityBindingRepository) DeleteByAccountId(accountId uint64) error {
+	return repository.Database.Where(entity.IdentityBinding{AccountId: accountId}).Delete(&entity.IdentityBinding{}).Error
+}
+
+func (repository *DatabaseIdentityBindingRepository) DeleteByProviderId(accountId uint64, providerId string) error {
+	db := repository.Database.Where("account_id = ? AND provider_id = ?", accountId, providerId).Delete(&entity.IdentityBinding{})
+	if db.Error != nil {
+		return db.Error
+	}
+	if db.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
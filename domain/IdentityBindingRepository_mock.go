@@ -6,6 +6,7 @@ package domain
 
 import (
 	gomock "github.com/golang/mock/gomock"
+	entity "hallo/domain/entity"
 	reflect "reflect"
 )
 
@@ -32,6 +33,64 @@ func (m *MockIdentityBindingRepository) EXPECT() *MockIdentityBindingRepositoryM
 	return m.recorder
 }
 
+// DeleteByAccountId mocks base method
+func (m *MockIdentityBindingRepository) DeleteByAccountId(arg0 uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteByAccountId", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteByAccountId indicates an expected call of DeleteByAccountId
+func (mr *MockIdentityBindingRepositoryMockRecorder) DeleteByAccountId(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteByAccountId", reflect.TypeOf((*MockIdentityBindingRepository)(nil).DeleteByAccountId), arg0)
+}
+
+// DeleteByProviderId mocks base method
+func (m *MockIdentityBindingRepository) DeleteByProviderId(arg0 uint64, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteByProviderId", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteByProviderId indicates an expected call of DeleteByProviderId
+func (mr *MockIdentityBindingRepositoryMockRecorder) DeleteByProviderId(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteByProviderId", reflect.TypeOf((*MockIdentityBindingRepository)(nil).DeleteByProviderId), arg0, arg1)
+}
+
+// FindByAccountId mocks base method
+func (m *MockIdentityBindingRepository) FindByAccountId(arg0 uint64) ([]entity.IdentityBinding, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByAccountId", arg0)
+	ret0, _ := ret[0].([]entity.IdentityBinding)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByAccountId indicates an expected call of FindByAccountId
+func (mr *MockIdentityBindingRepositoryMockRecorder) FindByAccountId(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByAccountId", reflect.TypeOf((*MockIdentityBindingRepository)(nil).FindByAccountId), arg0)
+}
+
+// FindByProviderAccountId mocks base method
+func (m *MockIdentityBindingRepository) FindByProviderAccountId(arg0, arg1 string) (*entity.IdentityBinding, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByProviderAccountId", arg0, arg1)
+	ret0, _ := ret[0].(*entity.IdentityBinding)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByProviderAccountId indicates an expected call of FindByProviderAccountId
+func (mr *MockIdentityBindingRepositoryMockRecorder) FindByProviderAccountId(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByProviderAccountId", reflect.TypeOf((*MockIdentityBindingRepository)(nil).FindByProviderAccountId), arg0, arg1)
+}
+
 // Save mocks base method
 func (m *MockIdentityBindingRepository) Save(arg0 uint64, arg1, arg2 string) error {
 	m.ctrl.T.Helper()
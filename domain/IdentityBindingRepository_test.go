@@ -3,6 +3,7 @@ package domain
 import (
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
 	"github.com/stretchr/testify/assert"
 	"hallo/domain/entity"
 	"hallo/testinfra"
@@ -76,3 +77,35 @@ func TestDatabaseIdentityBindingRepository_Save(it *testing.T) {
 		assert.Equal(t, "Key: 'IdentityBinding.ProviderAccountId' Error:Field validation for 'ProviderAccountId' failed on the 'required' tag", fmt.Sprintf("%s", err))
 	})
 }
+
+func TestDatabaseIdentityBindingRepository_Find(it *testing.T) {
+	it.Run("should find and delete identity bindings by provider", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		store := &DatabaseIdentityBindingRepository{Database: ds.Database}
+		accountId := util.DefaultIdWorker.NextIdOrFail()
+		assert.Nil(t, store.Save(accountId, "github", "1001"))
+		assert.Nil(t, store.Save(accountId, "google", "g-1"))
+
+		binding, err := store.FindByProviderAccountId("github", "1001")
+		assert.Nil(t, err)
+		assert.Equal(t, accountId, binding.AccountId)
+		binding, err = store.FindByProviderAccountId("google", "1001")
+		assert.Nil(t, binding)
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+
+		bindings, err := store.FindByAccountId(accountId)
+		assert.Nil(t, err)
+		assert.Len(t, bindings, 2)
+		bindings, err = store.FindByAccountId(accountId + 1)
+		assert.Nil(t, err)
+		assert.Empty(t, bindings)
+
+		assert.Nil(t, store.DeleteByProviderId(accountId, "github"))
+		assert.True(t, gorm.IsRecordNotFoundError(store.DeleteByProviderId(accountId, "github")))
+		bindings, err = store.FindByAccountId(accountId)
+		assert.Nil(t, err)
+		assert.Len(t, bindings, 1)
+	})
+}
@@ -4,7 +4,6 @@ import (
 	"github.com/go-playground/validator/v10"
 	"github.com/jinzhu/gorm"
 	"hallo/domain/entity"
-	"hallo/util"
 	"log"
 	"time"
 )
@@ -13,10 +12,13 @@ import (
 type InternalIdentityRepository interface {
 	Save(accountId uint64, credential string) error
 	Authenticate(accountId uint64, credential string) error
+	Delete(accountId uint64) error
 }
 
+// DatabaseInternalIdentityRepository uses DefaultPasswordHasher when PasswordHasher is nil
 type DatabaseInternalIdentityRepository struct {
-	Database *gorm.DB
+	Database       *gorm.DB
+	PasswordHasher PasswordHasher
 }
 
 func (repository *DatabaseInternalIdentityRepository) Save(accountId uint64, credential string) error {
@@ -25,9 +27,13 @@ func (repository *DatabaseInternalIdentityRepository) Save(accountId uint64, cre
 		return err
 	}
 
+	hashedIdentity, err := repository.passwordHasher().Hash(credential)
+	if err != nil {
+		return err
+	}
 	internalIdentity := entity.InternalIdentity{
 		AccountId:      accountId,
-		HashedIdentity: repository.hashCredential(credential),
+		HashedIdentity: hashedIdentity,
 		CreateTime:     time.Now(),
 	}
 
@@ -38,18 +44,32 @@ func (repository *DatabaseInternalIdentityRepository) Save(accountId uint64, cre
 	return repository.Database.Save(internalIdentity).Error
 }
 
+// the hash is upgraded transparently when it is not created by the preferred algorithm and parameters
 func (repository *DatabaseInternalIdentityRepository) Authenticate(accountId uint64, credential string) error {
-	hashedIdentity := repository.hashCredential(credential)
-	rows, err := repository.Database.Model(&entity.InternalIdentity{}).Select("1").
-		Where(entity.InternalIdentity{AccountId: accountId, HashedIdentity: hashedIdentity}).Limit(1).Rows()
+	internalIdentity := entity.InternalIdentity{}
+	err := repository.Database.Where(entity.InternalIdentity{AccountId: accountId}).First(&internalIdentity).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return &AccountAuthenticationFailure{}
+	}
+	if err != nil {
+		return err
+	}
+
+	hasher := repository.passwordHasher()
+	matched, err := hasher.Verify(internalIdentity.HashedIdentity, credential)
 	if err != nil {
 		return err
 	}
-	if rows.Next() {
-		return nil
-	} else {
+	if !matched {
 		return &AccountAuthenticationFailure{}
 	}
+
+	if hasher.NeedsRehash(internalIdentity.HashedIdentity) {
+		if err := repository.rehash(accountId, credential); err != nil {
+			log.Printf("failed to rehash identity of account [%d]: %v\n", accountId, err)
+		}
+	}
+	return nil
 }
 
 func (repository *DatabaseInternalIdentityRepository) Delete(accountId uint64) error {
@@ -58,6 +78,18 @@ func (repository *DatabaseInternalIdentityRepository) Delete(accountId uint64) e
 	return db.Error
 }
 
-func (repository *DatabaseInternalIdentityRepository) hashCredential(credential string) string {
-	return util.HashSha1Hex([]byte(credential))
+func (repository *DatabaseInternalIdentityRepository) rehash(accountId uint64, credential string) error {
+	hashedIdentity, err := repository.passwordHasher().Hash(credential)
+	if err != nil {
+		return err
+	}
+	return repository.Database.Model(&entity.InternalIdentity{}).Where(entity.InternalIdentity{AccountId: accountId}).
+		Update("hashed_identity", hashedIdentity).Error
+}
+
+func (repository *DatabaseInternalIdentityRepository) passwordHasher() PasswordHasher {
+	if repository.PasswordHasher == nil {
+		return DefaultPasswordHasher
+	}
+	return repository.PasswordHasher
 }
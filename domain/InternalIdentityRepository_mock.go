@@ -46,6 +46,20 @@ func (mr *MockInternalIdentityRepositoryMockRecorder) Authenticate(arg0, arg1 in
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Authenticate", reflect.TypeOf((*MockInternalIdentityRepository)(nil).Authenticate), arg0, arg1)
 }
 
+// Delete mocks base method
+func (m *MockInternalIdentityRepository) Delete(arg0 uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete
+func (mr *MockInternalIdentityRepositoryMockRecorder) Delete(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockInternalIdentityRepository)(nil).Delete), arg0)
+}
+
 // Save mocks base method
 func (m *MockInternalIdentityRepository) Save(arg0 uint64, arg1 string) error {
 	m.ctrl.T.Helper()
@@ -6,17 +6,11 @@ import (
 	"hallo/domain/entity"
 	"hallo/testinfra"
 	"hallo/util"
+	"strings"
 	"testing"
+	"time"
 )
 
-func TestDatabaseInternalIdentityRepository_hashCredential(t *testing.T) {
-	t.Run("should hash credential successfully", func(t *testing.T) {
-		// prepare test data
-		store := &DatabaseInternalIdentityRepository{}
-		assert.Equal(t, store.hashCredential("12345密码"), "b40a2ae84db3800da91c40ba920808cc4942b929")
-	})
-}
-
 func TestDatabaseInternalIdentityRepository_Authenticate(t *testing.T) {
 	//os.Setenv("DOCKER_HOST", "tcp://192.168.2.108:2375")
 	//mysqlService, err := testinfra.NewMysqlContainer()
@@ -39,7 +33,7 @@ func TestDatabaseInternalIdentityRepository_Authenticate(t *testing.T) {
 
 		// pre-assertion: not exist
 		rows, err := store.Database.Model(&entity.InternalIdentity{}).Select("1").
-			Where(entity.InternalIdentity{AccountId: accountId, HashedIdentity: util.HashSha1Hex([]byte(credential))}).Limit(1).Rows()
+			Where(entity.InternalIdentity{AccountId: accountId}).Limit(1).Rows()
 		assert.Equal(t, err, nil)
 		assert.Equal(t, rows.Next(), false)
 
@@ -60,6 +54,34 @@ func TestDatabaseInternalIdentityRepository_Authenticate(t *testing.T) {
 		// do assertion
 		assert.Nil(t, err)
 	})
+
+	t.Run("it should upgrade legacy sha1 hash when authenticated", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		store := &DatabaseInternalIdentityRepository{
+			Database:       ds.Database,
+			PasswordHasher: &DelegatingPasswordHasher{Preferred: &BcryptPasswordHasher{Cost: 4}},
+		}
+		accountId := uint64(123)
+		credential := "123456"
+		ds.Database.Save(entity.InternalIdentity{AccountId: accountId, HashedIdentity: util.HashSha1Hex([]byte(credential)), CreateTime: time.Now()})
+
+		// bad credential doesn't upgrade hash
+		err := store.Authenticate(accountId, credential+"bad")
+		assert.Equal(t, err, &AccountAuthenticationFailure{})
+		assert.Equal(t, util.HashSha1Hex([]byte(credential)), findHashedIdentity(store, accountId))
+
+		err = store.Authenticate(accountId, credential)
+		assert.Nil(t, err)
+		hashedIdentity := findHashedIdentity(store, accountId)
+		assert.True(t, strings.HasPrefix(hashedIdentity, "$2a$04$"), hashedIdentity)
+
+		// authenticate with the upgraded hash
+		err = store.Authenticate(accountId, credential)
+		assert.Nil(t, err)
+		assert.Equal(t, hashedIdentity, findHashedIdentity(store, accountId))
+	})
 }
 
 func TestDatabaseInternalIdentityRepository_Delete(t *testing.T) {
@@ -77,7 +99,7 @@ func TestDatabaseInternalIdentityRepository_Delete(t *testing.T) {
 
 		// pre-assertion
 		rows, err := realm.Database.Model(&entity.InternalIdentity{}).
-			Select("1").Where(entity.InternalIdentity{AccountId: accountId, HashedIdentity: util.HashSha1Hex([]byte(credential))}).Limit(1).Rows()
+			Select("1").Where(entity.InternalIdentity{AccountId: accountId}).Limit(1).Rows()
 		assert.Equal(t, err, nil)
 		assert.Equal(t, rows.Next(), true)
 
@@ -88,7 +110,7 @@ func TestDatabaseInternalIdentityRepository_Delete(t *testing.T) {
 			t.Errorf("unepxected error occured when do delete %v", err)
 		}
 		rows, err = realm.Database.Model(&entity.InternalIdentity{}).Select("1").
-			Where(entity.InternalIdentity{AccountId: accountId, HashedIdentity: util.HashSha1Hex([]byte(credential))}).Limit(1).Rows()
+			Where(entity.InternalIdentity{AccountId: accountId}).Limit(1).Rows()
 		assert.Equal(t, err, nil)
 		assert.Equal(t, rows.Next(), false)
 	})
@@ -107,7 +129,7 @@ func TestDatabaseInternalIdentityRepository_Save(t *testing.T) {
 		credential := "123456"
 
 		rows, err := realm.Database.Model(&entity.InternalIdentity{}).Select("1").
-			Where(entity.InternalIdentity{AccountId: accountId, HashedIdentity: util.HashSha1Hex([]byte(credential))}).Limit(1).Rows()
+			Where(entity.InternalIdentity{AccountId: accountId}).Limit(1).Rows()
 		assert.Equal(t, err, nil)
 		assert.Equal(t, rows.Next(), false)
 
@@ -118,10 +140,9 @@ func TestDatabaseInternalIdentityRepository_Save(t *testing.T) {
 		if err != nil {
 			t.Errorf("unepxected error occured when do save %v", err)
 		}
-		rows, err = realm.Database.Model(&entity.InternalIdentity{}).Select("1").
-			Where(entity.InternalIdentity{AccountId: accountId, HashedIdentity: util.HashSha1Hex([]byte(credential))}).Limit(1).Rows()
-		assert.Equal(t, err, nil)
-		assert.Equal(t, rows.Next(), true)
+		hashedIdentity := findHashedIdentity(realm, accountId)
+		assert.True(t, strings.HasPrefix(hashedIdentity, "$argon2id$v=19$m=19456,t=2,p=1$"), hashedIdentity)
+		assert.Nil(t, realm.Authenticate(accountId, credential))
 
 		newCredential := "654321"
 		// do update
@@ -130,15 +151,8 @@ func TestDatabaseInternalIdentityRepository_Save(t *testing.T) {
 			t.Errorf("unepxected error occured when do save %v", err)
 		}
 
-		rows, err = realm.Database.Model(&entity.InternalIdentity{}).Select("1").
-			Where(entity.InternalIdentity{AccountId: accountId, HashedIdentity: util.HashSha1Hex([]byte(credential))}).Limit(1).Rows()
-		assert.Equal(t, err, nil)
-		assert.Equal(t, rows.Next(), false)
-
-		rows, err = realm.Database.Model(&entity.InternalIdentity{}).Select("1").
-			Where(entity.InternalIdentity{AccountId: accountId, HashedIdentity: util.HashSha1Hex([]byte(newCredential))}).Limit(1).Rows()
-		assert.Equal(t, err, nil)
-		assert.Equal(t, rows.Next(), true)
+		assert.Equal(t, &AccountAuthenticationFailure{}, realm.Authenticate(accountId, credential))
+		assert.Nil(t, realm.Authenticate(accountId, newCredential))
 	})
 
 	t.Run("should save failed when validate not pass", func(t *testing.T) {
@@ -156,3 +170,11 @@ func TestDatabaseInternalIdentityRepository_Save(t *testing.T) {
 		assert.Equal(t, "Key: '' Error:Field validation for '' failed on the 'required' tag", fmt.Sprintf("%s", err))
 	})
 }
+
+func findHashedIdentity(repository *DatabaseInternalIdentityRepository, accountId uint64) string {
+	internalIdentity := entity.InternalIdentity{}
+	if err := repository.Database.Where(entity.InternalIdentity{AccountId: accountId}).First(&internalIdentity).Error; err != nil {
+		panic(err)
+	}
+	return internalIdentity.HashedIdentity
+}
@@ -0,0 +1,89 @@
+package domain
+
+import (
+	"crypto/subtle"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"hallo/domain/entity"
+	"hallo/util"
+	"strings"
+	"time"
+)
+
+//go:generate mockgen -destination OAuthClientManager_mock.go -package domain hallo/domain OAuthClientManager
+type OAuthClientManager interface {
+	// CreateClient returns the client with its secret, which is empty for public client and is not kept in plain text.
+	// ErrUnknownPermission is returned when any scope is not a known permission
+	CreateClient(action entity.OAuthClientCreateRequest) (*entity.OAuthClient, string, error)
+	// AuthenticateClient verifies the secret of confidential client, secret is ignored for public client.
+	// return ErrOAuthClientAuthenticationFailure when client is not found or secret is not match
+	AuthenticateClient(clientId, secret string) (*entity.OAuthClient, error)
+}
+
+type OAuthClientManagerImpl struct {
+	OAuthClientRepository OAuthClientRepository
+}
+
+// HashClientSecret is the stored form of secret. The secrets are random tokens, they are hashed without salt and cost,
+// so that clients are authenticated cheaply on every request
+func HashClientSecret(secret string) string {
+	return util.HashSha256Hex([]byte(secret))
+}
+
+func matchClientSecret(hashedSecret, secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(hashedSecret), []byte(HashClientSecret(secret))) == 1
+}
+
+func (manager *OAuthClientManagerImpl) CreateClient(action entity.OAuthClientCreateRequest) (*entity.OAuthClient, string, error) {
+	if err := validator.New().Struct(action); err != nil {
+		return nil, "", err
+	}
+	for _, scope := range action.Scopes {
+		if !IsKnownPermission(scope) {
+			return nil, "", &ErrUnknownPermission{Permission: scope}
+		}
+	}
+
+	now := time.Now()
+	client := &entity.OAuthClient{
+		Id:           uuid.New().String(),
+		Name:         action.Name,
+		RedirectUris: strings.Join(action.RedirectUris, " "),
+		Scopes:       strings.Join(action.Scopes, " "),
+
+		CreateTime:     now,
+		LastUpdateTime: now,
+	}
+	secret := ""
+	if !action.Public {
+		var err error
+		if secret, err = util.RandomToken(32); err != nil {
+			return nil, "", err
+		}
+		client.HashedSecret = HashClientSecret(secret)
+	}
+
+	if err := manager.OAuthClientRepository.Save(client); err != nil {
+		return nil, "", err
+	}
+	return client, secret, nil
+}
+
+func (manager *OAuthClientManagerImpl) AuthenticateClient(clientId, secret string) (*entity.OAuthClient, error) {
+	client, err := manager.OAuthClientRepository.FindById(clientId)
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, &ErrOAuthClientAuthenticationFailure{}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if client.IsPublic() {
+		return client, nil
+	}
+
+	if !matchClientSecret(client.HashedSecret, secret) {
+		return nil, &ErrOAuthClientAuthenticationFailure{}
+	}
+	return client, nil
+}
@@ -0,0 +1,65 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: hallo/domain (interfaces: OAuthClientManager)
+
+// Package domain is a generated GoMock package.
+package domain
+
+import (
+	gomock "github.com/golang/mock/gomock"
+	entity "hallo/domain/entity"
+	reflect "reflect"
+)
+
+// MockOAuthClientManager is a mock of OAuthClientManager interface
+type MockOAuthClientManager struct {
+	ctrl     *gomock.Controller
+	recorder *MockOAuthClientManagerMockRecorder
+}
+
+// MockOAuthClientManagerMockRecorder is the mock recorder for MockOAuthClientManager
+type MockOAuthClientManagerMockRecorder struct {
+	mock *MockOAuthClientManager
+}
+
+// NewMockOAuthClientManager creates a new mock instance
+func NewMockOAuthClientManager(ctrl *gomock.Controller) *MockOAuthClientManager {
+	mock := &MockOAuthClientManager{ctrl: ctrl}
+	mock.recorder = &MockOAuthClientManagerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockOAuthClientManager) EXPECT() *MockOAuthClientManagerMockRecorder {
+	return m.recorder
+}
+
+// AuthenticateClient mocks base method
+func (m *MockOAuthClientManager) AuthenticateClient(arg0, arg1 string) (*entity.OAuthClient, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AuthenticateClient", arg0, arg1)
+	ret0, _ := ret[0].(*entity.OAuthClient)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AuthenticateClient indicates an expected call of AuthenticateClient
+func (mr *MockOAuthClientManagerMockRecorder) AuthenticateClient(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthenticateClient", reflect.TypeOf((*MockOAuthClientManager)(nil).AuthenticateClient), arg0, arg1)
+}
+
+// CreateClient mocks base method
+func (m *MockOAuthClientManager) CreateClient(arg0 entity.OAuthClientCreateRequest) (*entity.OAuthClient, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateClient", arg0)
+	ret0, _ := ret[0].(*entity.OAuthClient)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// CreateClient indicates an expected call of CreateClient
+func (mr *MockOAuthClientManagerMockRecorder) CreateClient(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateClient", reflect.TypeOf((*MockOAuthClientManager)(nil).CreateClient), arg0)
+}
@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"errors"
+	"github.com/go-playground/validator/v10"
+	"github.com/golang/mock/gomock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"hallo/domain/entity"
+	"hallo/util"
+	"testing"
+)
+
+func TestOAuthClientManager(it *testing.T) {
+	it.Run("should create confidential client with hashed secret and authenticate it", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		repository := NewMockOAuthClientRepository(mockCtl)
+		manager := &OAuthClientManagerImpl{OAuthClientRepository: repository}
+
+		var saved *entity.OAuthClient
+		repository.EXPECT().Save(gomock.Any()).DoAndReturn(func(client *entity.OAuthClient) error {
+			saved = client
+			return nil
+		})
+		client, secret, err := manager.CreateClient(entity.OAuthClientCreateRequest{
+			Name: "Web App", RedirectUris: []string{"https://app.test/callback", "https://app.test/other"}})
+		assert.Nil(t, err)
+		assert.NotEmpty(t, secret)
+		assert.Equal(t, util.HashSha256Hex([]byte(secret)), client.HashedSecret)
+		assert.False(t, client.IsPublic())
+		assert.Equal(t, []string{"https://app.test/callback", "https://app.test/other"}, client.RedirectUriList())
+
+		repository.EXPECT().FindById(client.Id).Return(saved, nil).Times(2)
+		repository.EXPECT().FindById("unknown").Return(nil, gorm.ErrRecordNotFound)
+		authenticated, err := manager.AuthenticateClient(client.Id, secret)
+		assert.Nil(t, err)
+		assert.Equal(t, client.Id, authenticated.Id)
+		_, err = manager.AuthenticateClient(client.Id, "bad")
+		assert.Equal(t, &ErrOAuthClientAuthenticationFailure{}, err)
+		_, err = manager.AuthenticateClient("unknown", secret)
+		assert.Equal(t, &ErrOAuthClientAuthenticationFailure{}, err)
+	})
+
+	it.Run("should create public client without secret", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		repository := NewMockOAuthClientRepository(mockCtl)
+		manager := &OAuthClientManagerImpl{OAuthClientRepository: repository}
+
+		repository.EXPECT().Save(gomock.Any()).Return(nil)
+		client, secret, err := manager.CreateClient(entity.OAuthClientCreateRequest{
+			Name: "Mobile App", RedirectUris: []string{"com.example.app:/callback"}, Public: true,
+			Scopes: []string{PermissionAccountRead, PermissionGroupRead}})
+		assert.Nil(t, err)
+		assert.Empty(t, secret)
+		assert.True(t, client.IsPublic())
+		assert.Equal(t, []string{PermissionAccountRead, PermissionGroupRead}, client.ScopeList())
+
+		repository.EXPECT().FindById(client.Id).Return(client, nil)
+		_, err = manager.AuthenticateClient(client.Id, "")
+		assert.Nil(t, err)
+	})
+
+	it.Run("should reject bad redirect uris", func(t *testing.T) {
+		manager := &OAuthClientManagerImpl{}
+		_, _, err := manager.CreateClient(entity.OAuthClientCreateRequest{Name: "Web App", RedirectUris: []string{"not a uri"}})
+		var validationErrs validator.ValidationErrors
+		assert.True(t, errors.As(err, &validationErrs))
+		_, _, err = manager.CreateClient(entity.OAuthClientCreateRequest{Name: "Web App"})
+		assert.True(t, errors.As(err, &validationErrs))
+	})
+
+	it.Run("should reject unknown scopes", func(t *testing.T) {
+		manager := &OAuthClientManagerImpl{}
+		_, _, err := manager.CreateClient(entity.OAuthClientCreateRequest{Name: "Web App",
+			RedirectUris: []string{"https://app.test/callback"}, Scopes: []string{PermissionAccountRead, "accounts:*"}})
+		assert.Equal(t, &ErrUnknownPermission{Permission: "accounts:*"}, err)
+	})
+}
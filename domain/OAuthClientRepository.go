@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/jinzhu/gorm"
+	"hallo/domain/entity"
+)
+
+const OAuthClientTableName = "oauth_clients"
+
+//go:generate mockgen -destination OAuthClientRepository_mock.go -package domain hallo/domain OAuthClientRepository
+type OAuthClientRepository interface {
+	// return (nil, gorm.ErrRecordNotFound) when client is not found
+	FindById(clientId string) (*entity.OAuthClient, error)
+	FindAll() ([]entity.OAuthClient, error)
+	Save(client *entity.OAuthClient) error
+}
+
+type DatabaseOAuthClientRepository struct {
+	Database *gorm.DB
+}
+
+func (repository *DatabaseOAuthClientRepository) FindById(clientId string) (*entity.OAuthClient, error) {
+	client := &entity.OAuthClient{}
+	if err := repository.Database.Table(OAuthClientTableName).Where("id = ?", clientId).First(client).Error; err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+func (repository *DatabaseOAuthClientRepository) FindAll() ([]entity.OAuthClient, error) {
+	clients := []entity.OAuthClient{}
+	err := repository.Database.Table(OAuthClientTableName).Order("create_time").Find(&clients).Error
+	return clients, err
+}
+
+func (repository *DatabaseOAuthClientRepository) Save(client *entity.OAuthClient) error {
+	if err := validator.New().Struct(client); err != nil {
+		return err
+	}
+	return repository.Database.Save(client).Error
+}
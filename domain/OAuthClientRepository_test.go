@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"hallo/domain/entity"
+	"hallo/testinfra"
+	"testing"
+	"time"
+)
+
+func TestDatabaseOAuthClientRepository(it *testing.T) {
+	it.Run("should save and find clients", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		repository := &DatabaseOAuthClientRepository{Database: ds.Database}
+		now := time.Now()
+		client := &entity.OAuthClient{Id: "web", Name: "Web App", RedirectUris: "https://app.test/callback",
+			CreateTime: now, LastUpdateTime: now}
+		assert.Nil(t, repository.Save(client))
+
+		found, err := repository.FindById("web")
+		assert.Nil(t, err)
+		assert.Equal(t, "Web App", found.Name)
+		assert.True(t, found.AllowsRedirectUri("https://app.test/callback"))
+		assert.False(t, found.AllowsRedirectUri("https://app.test/callback/"))
+
+		_, err = repository.FindById("unknown")
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+		clients, err := repository.FindAll()
+		assert.Nil(t, err)
+		assert.Len(t, clients, 1)
+	})
+}
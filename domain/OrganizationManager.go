@@ -0,0 +1,88 @@
+package domain
+
+import (
+	"github.com/go-playground/validator/v10"
+	"hallo/domain/entity"
+	"log"
+	"time"
+)
+
+//go:generate mockgen -destination OrganizationManager_mock.go -package domain hallo/domain OrganizationManager
+type OrganizationManager interface {
+	CreateOrganization(action entity.OrganizationCreateRequest) (*entity.Organization, error)
+	// SetMemberRoles adds account into organization with the roles, or removes it from organization when roles is empty.
+	// ErrAccountOutsideOrganization is returned when the account belongs to another organization, ErrRoleNotFound is
+	// returned when any role is not existed
+	SetMemberRoles(organizationId, accountId uint64, roles []string) error
+}
+
+type OrganizationManagerImpl struct {
+	UnitOfWork UnitOfWork
+}
+
+func (manager *OrganizationManagerImpl) CreateOrganization(action entity.OrganizationCreateRequest) (*entity.Organization, error) {
+	if err := validator.New().Struct(action); err != nil {
+		return nil, err
+	}
+
+	var organization *entity.Organization
+	err := manager.UnitOfWork.Do(func(repositories *Repositories) error {
+		isNameOccupied, err := repositories.OrganizationRepository.IsOrganizationNameOccupied(action.Name)
+		if err != nil {
+			return err
+		}
+		if isNameOccupied {
+			return &OrganizationNameIsOccupied{}
+		}
+
+		organizationId, err := repositories.OrganizationRepository.NextId()
+		if err != nil {
+			log.Println(err)
+			return IdGenerateFailure
+		}
+
+		now := time.Now()
+		organization = &entity.Organization{
+			Id:          organizationId,
+			Name:        action.Name,
+			DisplayName: action.DisplayName,
+
+			CreateTime:     now,
+			LastUpdateTime: now,
+		}
+		return repositories.OrganizationRepository.Save(organization)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return organization, nil
+}
+
+func (manager *OrganizationManagerImpl) SetMemberRoles(organizationId, accountId uint64, roles []string) error {
+	return manager.UnitOfWork.Do(func(repositories *Repositories) error {
+		if _, err := repositories.OrganizationRepository.FindById(organizationId); err != nil {
+			return err
+		}
+		account, err := repositories.AccountRepository.FindById(accountId)
+		if err != nil {
+			return err
+		}
+		// the members are removed anyway, so that the grants made before the validation are able to be cleaned
+		if len(roles) == 0 {
+			return repositories.OrganizationRepository.SetMemberRoles(organizationId, accountId, nil)
+		}
+		if account.OrganizationId != organizationId {
+			return &ErrAccountOutsideOrganization{}
+		}
+		for _, role := range roles {
+			existed, err := repositories.RoleRepository.IsRoleExisted(role)
+			if err != nil {
+				return err
+			}
+			if !existed {
+				return &ErrRoleNotFound{Role: role}
+			}
+		}
+		return repositories.OrganizationRepository.SetMemberRoles(organizationId, accountId, roles)
+	})
+}
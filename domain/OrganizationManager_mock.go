@@ -0,0 +1,63 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: hallo/domain (interfaces: OrganizationManager)
+
+// Package domain is a generated GoMock package.
+package domain
+
+import (
+	gomock "github.com/golang/mock/gomock"
+	entity "hallo/domain/entity"
+	reflect "reflect"
+)
+
+// MockOrganizationManager is a mock of OrganizationManager interface
+type MockOrganizationManager struct {
+	ctrl     *gomock.Controller
+	recorder *MockOrganizationManagerMockRecorder
+}
+
+// MockOrganizationManagerMockRecorder is the mock recorder for MockOrganizationManager
+type MockOrganizationManagerMockRecorder struct {
+	mock *MockOrganizationManager
+}
+
+// NewMockOrganizationManager creates a new mock instance
+func NewMockOrganizationManager(ctrl *gomock.Controller) *MockOrganizationManager {
+	mock := &MockOrganizationManager{ctrl: ctrl}
+	mock.recorder = &MockOrganizationManagerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockOrganizationManager) EXPECT() *MockOrganizationManagerMockRecorder {
+	return m.recorder
+}
+
+// CreateOrganization mocks base method
+func (m *MockOrganizationManager) CreateOrganization(arg0 entity.OrganizationCreateRequest) (*entity.Organization, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateOrganization", arg0)
+	ret0, _ := ret[0].(*entity.Organization)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateOrganization indicates an expected call of CreateOrganization
+func (mr *MockOrganizationManagerMockRecorder) CreateOrganization(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOrganization", reflect.TypeOf((*MockOrganizationManager)(nil).CreateOrganization), arg0)
+}
+
+// SetMemberRoles mocks base method
+func (m *MockOrganizationManager) SetMemberRoles(arg0, arg1 uint64, arg2 []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetMemberRoles", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetMemberRoles indicates an expected call of SetMemberRoles
+func (mr *MockOrganizationManagerMockRecorder) SetMemberRoles(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetMemberRoles", reflect.TypeOf((*MockOrganizationManager)(nil).SetMemberRoles), arg0, arg1, arg2)
+}
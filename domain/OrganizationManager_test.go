@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"hallo/domain/entity"
+	"hallo/infra"
+	"hallo/testinfra"
+	"hallo/util"
+	"testing"
+)
+
+func TestOrganizationManager_SetMemberRoles(it *testing.T) {
+	it.Run("should grant existing roles to accounts of organization only", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		unitOfWork := &DatabaseUnitOfWork{TransactionSupport: &infra.GormTransactionSupport{Database: ds.Database}, IdWorker: util.DefaultIdWorker}
+		accountManager := AccountManagerImpl{
+			AccountRepository:          &DatabaseAccountRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database},
+			IdentityBindingRepository:  &DatabaseIdentityBindingRepository{Database: ds.Database},
+			InternalIdentityRepository: &DatabaseInternalIdentityRepository{Database: ds.Database},
+			UnitOfWork:                 unitOfWork,
+		}
+		organizationManager := &OrganizationManagerImpl{UnitOfWork: unitOfWork}
+		acme, err := organizationManager.CreateOrganization(entity.OrganizationCreateRequest{Name: "acme"})
+		assert.Nil(t, err)
+		_, err = organizationManager.CreateOrganization(entity.OrganizationCreateRequest{Name: "globex"})
+		assert.Nil(t, err)
+		assert.Nil(t, (&DatabaseRoleRepository{Database: ds.Database}).Save("viewer", []string{PermissionAccountRead}))
+
+		accountName := uuid.New().String()
+		member, err := accountManager.CreateAccount(entity.EmailAccountCreateRequest{
+			Organization: "acme", Name: accountName, Secret: "secret", Email: accountName + "@acme.fundwit.com"})
+		assert.Nil(t, err)
+		outsider, err := accountManager.CreateAccount(entity.EmailAccountCreateRequest{
+			Organization: "globex", Name: accountName, Secret: "secret", Email: accountName + "@globex.fundwit.com"})
+		assert.Nil(t, err)
+
+		assert.Nil(t, organizationManager.SetMemberRoles(acme.Id, member.Id, []string{"viewer"}))
+		assert.Equal(t, &ErrRoleNotFound{Role: "owner"}, organizationManager.SetMemberRoles(acme.Id, member.Id, []string{"viewer", "owner"}))
+		assert.Equal(t, &ErrAccountOutsideOrganization{}, organizationManager.SetMemberRoles(acme.Id, outsider.Id, []string{"viewer"}))
+
+		organizationRepository := &DatabaseOrganizationRepository{Database: ds.Database}
+		roles, err := organizationRepository.FindMemberRoles(acme.Id, member.Id)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"viewer"}, roles)
+		roles, err = organizationRepository.FindMemberRoles(acme.Id, outsider.Id)
+		assert.Nil(t, err)
+		assert.Empty(t, roles)
+
+		assert.Nil(t, organizationManager.SetMemberRoles(acme.Id, member.Id, nil))
+		roles, err = organizationRepository.FindMemberRoles(acme.Id, member.Id)
+		assert.Nil(t, err)
+		assert.Empty(t, roles)
+	})
+}
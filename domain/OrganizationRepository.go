@@ -0,0 +1,115 @@
+package domain
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/jinzhu/gorm"
+	"hallo/domain/entity"
+	"hallo/util"
+	"time"
+)
+
+const (
+	OrganizationTableName       = "organizations"
+	OrganizationMemberTableName = "organization_members"
+)
+
+//go:generate mockgen -destination OrganizationRepository_mock.go -package domain hallo/domain OrganizationRepository
+type OrganizationRepository interface {
+	NextId() (uint64, error)
+	IsOrganizationNameOccupied(name string) (bool, error)
+	// return (nil, gorm.ErrRecordNotFound) when organization is not found
+	FindById(id uint64) (*entity.Organization, error)
+	// return (nil, gorm.ErrRecordNotFound) when organization is not found
+	FindByName(name string) (*entity.Organization, error)
+	FindAll() ([]entity.Organization, error)
+	Save(organization *entity.Organization) error
+
+	// SetMemberRoles replaces the roles of the member, the account is not a member any more when roles is empty
+	SetMemberRoles(organizationId, accountId uint64, roles []string) error
+	RemoveMembersByAccountId(accountId uint64) error
+	FindMembers(organizationId uint64) ([]entity.OrganizationMember, error)
+	FindMemberRoles(organizationId, accountId uint64) ([]string, error)
+}
+
+type DatabaseOrganizationRepository struct {
+	IdWorker *util.IdWorker
+	Database *gorm.DB
+}
+
+func (repository *DatabaseOrganizationRepository) NextId() (uint64, error) {
+	return repository.IdWorker.NextId()
+}
+
+func (repository *DatabaseOrganizationRepository) IsOrganizationNameOccupied(name string) (bool, error) {
+	var count int
+	err := repository.Database.Table(OrganizationTableName).Where("name = ?", name).Count(&count).Error
+	return count > 0, err
+}
+
+func (repository *DatabaseOrganizationRepository) FindById(id uint64) (*entity.Organization, error) {
+	organization := &entity.Organization{}
+	if err := repository.Database.Table(OrganizationTableName).Where("id = ?", id).First(organization).Error; err != nil {
+		return nil, err
+	}
+	return organization, nil
+}
+
+func (repository *DatabaseOrganizationRepository) FindByName(name string) (*entity.Organization, error) {
+	organization := &entity.Organization{}
+	if err := repository.Database.Table(OrganizationTableName).Where("name = ?", name).First(organization).Error; err != nil {
+		return nil, err
+	}
+	return organization, nil
+}
+
+func (repository *DatabaseOrganizationRepository) FindAll() ([]entity.Organization, error) {
+	organizations := []entity.Organization{}
+	err := repository.Database.Table(OrganizationTableName).Order("id").Find(&organizations).Error
+	return organizations, err
+}
+
+func (repository *DatabaseOrganizationRepository) Save(organization *entity.Organization) error {
+	if err := validator.New().Struct(organization); err != nil {
+		return err
+	}
+	return repository.Database.Save(organization).Error
+}
+
+func (repository *DatabaseOrganizationRepository) SetMemberRoles(organizationId, accountId uint64, roles []string) error {
+	if err := repository.Database.Where("organization_id = ? AND account_id = ?", organizationId, accountId).
+		Delete(&entity.OrganizationMember{}).Error; err != nil {
+		return err
+	}
+
+	validate := validator.New()
+	now := time.Now()
+	for _, role := range roles {
+		member := entity.OrganizationMember{OrganizationId: organizationId, AccountId: accountId, RoleName: role, CreateTime: now}
+		if err := validate.Struct(member); err != nil {
+			return err
+		}
+		if err := repository.Database.Create(&member).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (repository *DatabaseOrganizationRepository) RemoveMembersByAccountId(accountId uint64) error {
+	return repository.Database.Where("account_id = ?", accountId).Delete(&entity.OrganizationMember{}).Error
+}
+
+func (repository *DatabaseOrganizationRepository) FindMembers(organizationId uint64) ([]entity.OrganizationMember, error) {
+	members := []entity.OrganizationMember{}
+	err := repository.Database.Table(OrganizationMemberTableName).Where("organization_id = ?", organizationId).
+		Order("account_id, role_name").Find(&members).Error
+	return members, err
+}
+
+func (repository *DatabaseOrganizationRepository) FindMemberRoles(organizationId, accountId uint64) ([]string, error) {
+	roles := []string{}
+	err := repository.Database.Table(OrganizationMemberTableName).
+		Where("organization_id = ? AND account_id = ?", organizationId, accountId).
+		Order("role_name").Pluck("role_name", &roles).Error
+	return roles, err
+}
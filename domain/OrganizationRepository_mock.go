@@ -0,0 +1,181 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: hallo/domain (interfaces: OrganizationRepository)
+
+// Package domain is a generated GoMock package.
+package domain
+
+import (
+	gomock "github.com/golang/mock/gomock"
+	entity "hallo/domain/entity"
+	reflect "reflect"
+)
+
+// MockOrganizationRepository is a mock of OrganizationRepository interface
+type MockOrganizationRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockOrganizationRepositoryMockRecorder
+}
+
+// MockOrganizationRepositoryMockRecorder is the mock recorder for MockOrganizationRepository
+type MockOrganizationRepositoryMockRecorder struct {
+	mock *MockOrganizationRepository
+}
+
+// NewMockOrganizationRepository creates a new mock instance
+func NewMockOrganizationRepository(ctrl *gomock.Controller) *MockOrganizationRepository {
+	mock := &MockOrganizationRepository{ctrl: ctrl}
+	mock.recorder = &MockOrganizationRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockOrganizationRepository) EXPECT() *MockOrganizationRepositoryMockRecorder {
+	return m.recorder
+}
+
+// FindAll mocks base method
+func (m *MockOrganizationRepository) FindAll() ([]entity.Organization, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindAll")
+	ret0, _ := ret[0].([]entity.Organization)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindAll indicates an expected call of FindAll
+func (mr *MockOrganizationRepositoryMockRecorder) FindAll() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAll", reflect.TypeOf((*MockOrganizationRepository)(nil).FindAll))
+}
+
+// FindById mocks base method
+func (m *MockOrganizationRepository) FindById(arg0 uint64) (*entity.Organization, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindById", arg0)
+	ret0, _ := ret[0].(*entity.Organization)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindById indicates an expected call of FindById
+func (mr *MockOrganizationRepositoryMockRecorder) FindById(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindById", reflect.TypeOf((*MockOrganizationRepository)(nil).FindById), arg0)
+}
+
+// FindByName mocks base method
+func (m *MockOrganizationRepository) FindByName(arg0 string) (*entity.Organization, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByName", arg0)
+	ret0, _ := ret[0].(*entity.Organization)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByName indicates an expected call of FindByName
+func (mr *MockOrganizationRepositoryMockRecorder) FindByName(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByName", reflect.TypeOf((*MockOrganizationRepository)(nil).FindByName), arg0)
+}
+
+// FindMemberRoles mocks base method
+func (m *MockOrganizationRepository) FindMemberRoles(arg0, arg1 uint64) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindMemberRoles", arg0, arg1)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindMemberRoles indicates an expected call of FindMemberRoles
+func (mr *MockOrganizationRepositoryMockRecorder) FindMemberRoles(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindMemberRoles", reflect.TypeOf((*MockOrganizationRepository)(nil).FindMemberRoles), arg0, arg1)
+}
+
+// FindMembers mocks base method
+func (m *MockOrganizationRepository) FindMembers(arg0 uint64) ([]entity.OrganizationMember, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindMembers", arg0)
+	ret0, _ := ret[0].([]entity.OrganizationMember)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindMembers indicates an expected call of FindMembers
+func (mr *MockOrganizationRepositoryMockRecorder) FindMembers(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindMembers", reflect.TypeOf((*MockOrganizationRepository)(nil).FindMembers), arg0)
+}
+
+// IsOrganizationNameOccupied mocks base method
+func (m *MockOrganizationRepository) IsOrganizationNameOccupied(arg0 string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsOrganizationNameOccupied", arg0)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsOrganizationNameOccupied indicates an expected call of IsOrganizationNameOccupied
+func (mr *MockOrganizationRepositoryMockRecorder) IsOrganizationNameOccupied(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsOrganizationNameOccupied", reflect.TypeOf((*MockOrganizationRepository)(nil).IsOrganizationNameOccupied), arg0)
+}
+
+// NextId mocks base method
+func (m *MockOrganizationRepository) NextId() (uint64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NextId")
+	ret0, _ := ret[0].(uint64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// NextId indicates an expected call of NextId
+func (mr *MockOrganizationRepositoryMockRecorder) NextId() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NextId", reflect.TypeOf((*MockOrganizationRepository)(nil).NextId))
+}
+
+// RemoveMembersByAccountId mocks base method
+func (m *MockOrganizationRepository) RemoveMembersByAccountId(arg0 uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveMembersByAccountId", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveMembersByAccountId indicates an expected call of RemoveMembersByAccountId
+func (mr *MockOrganizationRepositoryMockRecorder) RemoveMembersByAccountId(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveMembersByAccountId", reflect.TypeOf((*MockOrganizationRepository)(nil).RemoveMembersByAccountId), arg0)
+}
+
+// Save mocks base method
+func (m *MockOrganizationRepository) Save(arg0 *entity.Organization) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Save", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Save indicates an expected call of Save
+func (mr *MockOrganizationRepositoryMockRecorder) Save(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockOrganizationRepository)(nil).Save), arg0)
+}
+
+// SetMemberRoles mocks base method
+func (m *MockOrganizationRepository) SetMemberRoles(arg0, arg1 uint64, arg2 []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetMemberRoles", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetMemberRoles indicates an expected call of SetMemberRoles
+func (mr *MockOrganizationRepositoryMockRecorder) SetMemberRoles(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetMemberRoles", reflect.TypeOf((*MockOrganizationRepository)(nil).SetMemberRoles), arg0, arg1, arg2)
+}
@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"hallo/domain/entity"
+	"hallo/testinfra"
+	"hallo/util"
+	"testing"
+	"time"
+)
+
+func TestDatabaseOrganizationRepository(it *testing.T) {
+	it.Run("should save and find organizations with member roles", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		repository := &DatabaseOrganizationRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database}
+		now := time.Now()
+		assert.Nil(t, repository.Save(&entity.Organization{Id: 601, Name: "acme", DisplayName: "Acme", CreateTime: now, LastUpdateTime: now}))
+
+		occupied, err := repository.IsOrganizationNameOccupied("acme")
+		assert.Nil(t, err)
+		assert.True(t, occupied)
+		found, err := repository.FindByName("acme")
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(601), found.Id)
+		_, err = repository.FindById(602)
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+
+		assert.Nil(t, repository.SetMemberRoles(601, 123, []string{"viewer", "editor"}))
+		assert.Nil(t, repository.SetMemberRoles(601, 123, []string{"owner", "editor"}))
+		roles, err := repository.FindMemberRoles(601, 123)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"editor", "owner"}, roles)
+		members, err := repository.FindMembers(601)
+		assert.Nil(t, err)
+		assert.Len(t, members, 2)
+
+		assert.Nil(t, repository.SetMemberRoles(601, 123, nil))
+		roles, err = repository.FindMemberRoles(601, 123)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{}, roles)
+
+		assert.Nil(t, repository.SetMemberRoles(601, 456, []string{"viewer"}))
+		assert.Nil(t, repository.RemoveMembersByAccountId(456))
+		members, err = repository.FindMembers(601)
+		assert.Nil(t, err)
+		assert.Equal(t, []entity.OrganizationMember{}, members)
+	})
+}
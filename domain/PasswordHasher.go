@@ -0,0 +1,213 @@
+package domain
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"hallo/util"
+	"regexp"
+	"strings"
+)
+
+// PasswordHasher hashes secrets into self-describing encoded strings,
+// the algorithm and its parameters are recorded in the encoded hash.
+type PasswordHasher interface {
+	Hash(secret string) (string, error)
+	Verify(encodedHash, secret string) (bool, error)
+	// whether the encoded hash should be replaced by a new hash of the preferred algorithm and parameters
+	NeedsRehash(encodedHash string) bool
+}
+
+var DefaultPasswordHasher PasswordHasher = &DelegatingPasswordHasher{Preferred: &Argon2idPasswordHasher{}}
+
+// NewPasswordHasher creates hasher which prefers the named algorithm ("argon2id" or "bcrypt"), default is argon2id.
+// Hashes of all the supported algorithms, including the legacy unsalted SHA-1, are still verifiable.
+func NewPasswordHasher(algorithm string) (PasswordHasher, error) {
+	switch strings.ToLower(algorithm) {
+	case "", "argon2id":
+		return &DelegatingPasswordHasher{Preferred: &Argon2idPasswordHasher{}}, nil
+	case "bcrypt":
+		return &DelegatingPasswordHasher{Preferred: &BcryptPasswordHasher{}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported password hash algorithm '%s'", algorithm)
+	}
+}
+
+type DelegatingPasswordHasher struct {
+	Preferred PasswordHasher
+}
+
+func (hasher *DelegatingPasswordHasher) Hash(secret string) (string, error) {
+	return hasher.Preferred.Hash(secret)
+}
+
+func (hasher *DelegatingPasswordHasher) Verify(encodedHash, secret string) (bool, error) {
+	delegate := hasherOf(encodedHash)
+	if delegate == nil {
+		return false, errors.New("unknown password hash format")
+	}
+	return delegate.Verify(encodedHash, secret)
+}
+
+func (hasher *DelegatingPasswordHasher) NeedsRehash(encodedHash string) bool {
+	return hasher.Preferred.NeedsRehash(encodedHash)
+}
+
+func hasherOf(encodedHash string) PasswordHasher {
+	switch {
+	case strings.HasPrefix(encodedHash, argon2idPrefix):
+		return &Argon2idPasswordHasher{}
+	case bcryptPattern.MatchString(encodedHash):
+		return &BcryptPasswordHasher{}
+	case sha1HexPattern.MatchString(encodedHash):
+		return &LegacySha1PasswordHasher{}
+	default:
+		return nil
+	}
+}
+
+var bcryptPattern = regexp.MustCompile(`^\$2[aby]?\$\d\d\$`)
+
+// BcryptPasswordHasher uses bcrypt.DefaultCost when Cost is zero
+type BcryptPasswordHasher struct {
+	Cost int
+}
+
+func (hasher *BcryptPasswordHasher) Hash(secret string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(secret), hasher.cost())
+	return string(hashed), err
+}
+
+func (hasher *BcryptPasswordHasher) Verify(encodedHash, secret string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encodedHash), []byte(secret))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (hasher *BcryptPasswordHasher) NeedsRehash(encodedHash string) bool {
+	cost, err := bcrypt.Cost([]byte(encodedHash))
+	return err != nil || cost != hasher.cost()
+}
+
+func (hasher *BcryptPasswordHasher) cost() int {
+	if hasher.Cost == 0 {
+		return bcrypt.DefaultCost
+	}
+	return hasher.Cost
+}
+
+const argon2idPrefix = "$argon2id$"
+
+// Argon2idPasswordHasher encodes hashes in the PHC string format: $argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>.
+// Zero valued parameters take the defaults recommended by OWASP.
+type Argon2idPasswordHasher struct {
+	Memory      uint32 // in KiB
+	Iterations  uint32
+	Parallelism uint8
+}
+
+const (
+	argon2idSaltLength = 16
+	argon2idKeyLength  = 32
+)
+
+func (hasher *Argon2idPasswordHasher) Hash(secret string) (string, error) {
+	salt := make([]byte, argon2idSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	memory, iterations, parallelism := hasher.params()
+	key := argon2.IDKey([]byte(secret), salt, iterations, memory, parallelism, argon2idKeyLength)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version, memory, iterations, parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (hasher *Argon2idPasswordHasher) Verify(encodedHash, secret string) (bool, error) {
+	decoded, err := decodeArgon2id(encodedHash)
+	if err != nil {
+		return false, err
+	}
+	key := argon2.IDKey([]byte(secret), decoded.salt, decoded.iterations, decoded.memory, decoded.parallelism, uint32(len(decoded.key)))
+	return subtle.ConstantTimeCompare(key, decoded.key) == 1, nil
+}
+
+func (hasher *Argon2idPasswordHasher) NeedsRehash(encodedHash string) bool {
+	decoded, err := decodeArgon2id(encodedHash)
+	if err != nil {
+		return true
+	}
+	memory, iterations, parallelism := hasher.params()
+	return decoded.memory != memory || decoded.iterations != iterations || decoded.parallelism != parallelism
+}
+
+func (hasher *Argon2idPasswordHasher) params() (memory uint32, iterations uint32, parallelism uint8) {
+	memory, iterations, parallelism = hasher.Memory, hasher.Iterations, hasher.Parallelism
+	if memory == 0 {
+		memory = 19 * 1024
+	}
+	if iterations == 0 {
+		iterations = 2
+	}
+	if parallelism == 0 {
+		parallelism = 1
+	}
+	return
+}
+
+type argon2idHash struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+func decodeArgon2id(encodedHash string) (*argon2idHash, error) {
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, errors.New("bad argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, errors.New("unsupported argon2id version")
+	}
+
+	decoded := &argon2idHash{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &decoded.memory, &decoded.iterations, &decoded.parallelism); err != nil {
+		return nil, errors.New("bad argon2id parameters")
+	}
+
+	var err error
+	if decoded.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, errors.New("bad argon2id salt")
+	}
+	if decoded.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return nil, errors.New("bad argon2id key")
+	}
+	return decoded, nil
+}
+
+var sha1HexPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// LegacySha1PasswordHasher verifies the unsalted SHA-1 hashes created by early versions, it always requires rehash
+type LegacySha1PasswordHasher struct {
+}
+
+func (hasher *LegacySha1PasswordHasher) Hash(secret string) (string, error) {
+	return util.HashSha1Hex([]byte(secret)), nil
+}
+
+func (hasher *LegacySha1PasswordHasher) Verify(encodedHash, secret string) (bool, error) {
+	return subtle.ConstantTimeCompare([]byte(encodedHash), []byte(util.HashSha1Hex([]byte(secret)))) == 1, nil
+}
+
+func (hasher *LegacySha1PasswordHasher) NeedsRehash(encodedHash string) bool {
+	return true
+}
@@ -0,0 +1,88 @@
+package domain
+
+import (
+	"github.com/stretchr/testify/assert"
+	"hallo/util"
+	"strings"
+	"testing"
+)
+
+func TestPasswordHasher(it *testing.T) {
+	it.Run("should hash and verify secret with argon2id", func(t *testing.T) {
+		hasher := &Argon2idPasswordHasher{Memory: 1024, Iterations: 1, Parallelism: 2}
+		hashed, err := hasher.Hash("12345密码")
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(hashed, "$argon2id$v=19$m=1024,t=1,p=2$"), hashed)
+
+		another, err := hasher.Hash("12345密码")
+		assert.Nil(t, err)
+		assert.NotEqual(t, hashed, another, "salted")
+
+		matched, err := hasher.Verify(hashed, "12345密码")
+		assert.Nil(t, err)
+		assert.True(t, matched)
+		matched, err = hasher.Verify(hashed, "12345")
+		assert.Nil(t, err)
+		assert.False(t, matched)
+
+		assert.False(t, hasher.NeedsRehash(hashed))
+		assert.True(t, (&Argon2idPasswordHasher{}).NeedsRehash(hashed))
+
+		_, err = hasher.Verify("$argon2id$v=19$m=1024$bad", "12345")
+		assert.NotNil(t, err)
+	})
+
+	it.Run("should hash and verify secret with bcrypt", func(t *testing.T) {
+		hasher := &BcryptPasswordHasher{Cost: 4}
+		hashed, err := hasher.Hash("12345密码")
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(hashed, "$2a$04$"), hashed)
+
+		matched, err := hasher.Verify(hashed, "12345密码")
+		assert.Nil(t, err)
+		assert.True(t, matched)
+		matched, err = hasher.Verify(hashed, "12345")
+		assert.Nil(t, err)
+		assert.False(t, matched)
+
+		assert.False(t, hasher.NeedsRehash(hashed))
+		assert.True(t, (&BcryptPasswordHasher{Cost: 5}).NeedsRehash(hashed))
+	})
+
+	it.Run("should verify hashes of all algorithms and require rehash of others", func(t *testing.T) {
+		legacy := util.HashSha1Hex([]byte("12345密码"))
+		assert.Equal(t, "b40a2ae84db3800da91c40ba920808cc4942b929", legacy)
+		bcrypted, _ := (&BcryptPasswordHasher{Cost: 4}).Hash("12345密码")
+		argon2ided, _ := (&Argon2idPasswordHasher{Memory: 1024, Iterations: 1, Parallelism: 1}).Hash("12345密码")
+
+		hasher := &DelegatingPasswordHasher{Preferred: &Argon2idPasswordHasher{Memory: 1024, Iterations: 1, Parallelism: 1}}
+		for _, hashed := range []string{legacy, bcrypted, argon2ided} {
+			matched, err := hasher.Verify(hashed, "12345密码")
+			assert.Nil(t, err)
+			assert.True(t, matched, hashed)
+			matched, err = hasher.Verify(hashed, "12345")
+			assert.Nil(t, err)
+			assert.False(t, matched, hashed)
+		}
+
+		assert.True(t, hasher.NeedsRehash(legacy))
+		assert.True(t, hasher.NeedsRehash(bcrypted))
+		assert.False(t, hasher.NeedsRehash(argon2ided))
+
+		_, err := hasher.Verify("unknown", "12345")
+		assert.NotNil(t, err)
+	})
+
+	it.Run("should create hasher by algorithm name", func(t *testing.T) {
+		hasher, err := NewPasswordHasher("bcrypt")
+		assert.Nil(t, err)
+		assert.Equal(t, &BcryptPasswordHasher{}, hasher.(*DelegatingPasswordHasher).Preferred)
+
+		hasher, err = NewPasswordHasher("")
+		assert.Nil(t, err)
+		assert.Equal(t, &Argon2idPasswordHasher{}, hasher.(*DelegatingPasswordHasher).Preferred)
+
+		_, err = NewPasswordHasher("md5")
+		assert.NotNil(t, err)
+	})
+}
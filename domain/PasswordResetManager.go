@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"hallo/domain/entity"
+	"hallo/util"
+	"time"
+)
+
+const PasswordResetTokenExpiration = 30 * time.Minute
+
+//go:generate mockgen -destination PasswordResetManager_mock.go -package domain hallo/domain PasswordResetManager
+type PasswordResetManager interface {
+	// IssueToken returns the token which resets the secret of account once, only the hash of it is kept
+	IssueToken(accountId uint64) (string, error)
+	// ResetSecret takes the token, saves the secret of its account and revokes the sessions of the account by revoke
+	// in the same transaction, so that the token is kept for retry when the secret fails to be saved or the sessions
+	// fail to be revoked. ErrPasswordResetTokenInvalid is returned when the token is unknown, expired or used
+	ResetSecret(token, secret string, revoke func(accountId uint64) error) error
+}
+
+type PasswordResetManagerImpl struct {
+	PasswordResetTokenRepository PasswordResetTokenRepository
+	UnitOfWork                   UnitOfWork
+}
+
+func (manager *PasswordResetManagerImpl) IssueToken(accountId uint64) (string, error) {
+	token, err := util.RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	if err := manager.PasswordResetTokenRepository.DeleteExpired(now); err != nil {
+		return "", err
+	}
+	err = manager.PasswordResetTokenRepository.Save(&entity.PasswordResetToken{
+		HashedToken: util.HashSha256Hex([]byte(token)),
+		AccountId:   accountId,
+		ExpireTime:  now.Add(PasswordResetTokenExpiration),
+		CreateTime:  now,
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (manager *PasswordResetManagerImpl) ResetSecret(token, secret string, revoke func(accountId uint64) error) error {
+	return manager.UnitOfWork.Do(func(repositories *Repositories) error {
+		taken, err := repositories.PasswordResetTokenRepository.Take(util.HashSha256Hex([]byte(token)), time.Now())
+		if err != nil {
+			return err
+		}
+		if taken == nil {
+			return &ErrPasswordResetTokenInvalid{}
+		}
+		if err := repositories.InternalIdentityRepository.Save(taken.AccountId, secret); err != nil {
+			return err
+		}
+		return revoke(taken.AccountId)
+	})
+}
@@ -0,0 +1,62 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: hallo/domain (interfaces: PasswordResetManager)
+
+// Package domain is a generated GoMock package.
+package domain
+
+import (
+	gomock "github.com/golang/mock/gomock"
+	reflect "reflect"
+)
+
+// MockPasswordResetManager is a mock of PasswordResetManager interface
+type MockPasswordResetManager struct {
+	ctrl     *gomock.Controller
+	recorder *MockPasswordResetManagerMockRecorder
+}
+
+// MockPasswordResetManagerMockRecorder is the mock recorder for MockPasswordResetManager
+type MockPasswordResetManagerMockRecorder struct {
+	mock *MockPasswordResetManager
+}
+
+// NewMockPasswordResetManager creates a new mock instance
+func NewMockPasswordResetManager(ctrl *gomock.Controller) *MockPasswordResetManager {
+	mock := &MockPasswordResetManager{ctrl: ctrl}
+	mock.recorder = &MockPasswordResetManagerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockPasswordResetManager) EXPECT() *MockPasswordResetManagerMockRecorder {
+	return m.recorder
+}
+
+// IssueToken mocks base method
+func (m *MockPasswordResetManager) IssueToken(arg0 uint64) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IssueToken", arg0)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IssueToken indicates an expected call of IssueToken
+func (mr *MockPasswordResetManagerMockRecorder) IssueToken(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IssueToken", reflect.TypeOf((*MockPasswordResetManager)(nil).IssueToken), arg0)
+}
+
+// ResetSecret mocks base method
+func (m *MockPasswordResetManager) ResetSecret(arg0, arg1 string, arg2 func(uint64) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetSecret", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResetSecret indicates an expected call of ResetSecret
+func (mr *MockPasswordResetManagerMockRecorder) ResetSecret(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetSecret", reflect.TypeOf((*MockPasswordResetManager)(nil).ResetSecret), arg0, arg1, arg2)
+}
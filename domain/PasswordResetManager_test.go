@@ -0,0 +1,100 @@
+package domain
+
+import (
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"hallo/domain/entity"
+	"hallo/infra"
+	"hallo/testinfra"
+	"hallo/util"
+	"testing"
+)
+
+func TestPasswordResetManager(it *testing.T) {
+	it.Run("should reset secret by token only once", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		passwordHasher := &BcryptPasswordHasher{Cost: 4}
+		manager := &PasswordResetManagerImpl{
+			PasswordResetTokenRepository: &DatabasePasswordResetTokenRepository{Database: ds.Database},
+			UnitOfWork: &DatabaseUnitOfWork{TransactionSupport: &infra.GormTransactionSupport{Database: ds.Database},
+				IdWorker: util.DefaultIdWorker, PasswordHasher: passwordHasher},
+		}
+		internalIdentityRepository := &DatabaseInternalIdentityRepository{Database: ds.Database, PasswordHasher: passwordHasher}
+		assert.Nil(t, internalIdentityRepository.Save(123, "old-secret"))
+
+		token, err := manager.IssueToken(123)
+		assert.Nil(t, err)
+		// only the hash of token is kept
+		var stored entity.PasswordResetToken
+		assert.Nil(t, ds.Database.First(&stored).Error)
+		assert.Equal(t, util.HashSha256Hex([]byte(token)), stored.HashedToken)
+
+		var revoked []uint64
+		revoke := func(accountId uint64) error {
+			revoked = append(revoked, accountId)
+			return nil
+		}
+		assert.Nil(t, manager.ResetSecret(token, "new-secret", revoke))
+		assert.Equal(t, []uint64{123}, revoked)
+		assert.Nil(t, internalIdentityRepository.Authenticate(123, "new-secret"))
+
+		assert.Equal(t, &ErrPasswordResetTokenInvalid{}, manager.ResetSecret(token, "another-secret", revoke))
+		assert.Equal(t, &ErrPasswordResetTokenInvalid{}, manager.ResetSecret("unknown", "another-secret", revoke))
+		assert.Equal(t, []uint64{123}, revoked)
+	})
+
+	it.Run("should keep token when secret fails to be saved", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+
+		saveFailure := errors.New("save failure")
+		mockInternalIdentityRepository := NewMockInternalIdentityRepository(mockCtl)
+		mockInternalIdentityRepository.EXPECT().Save(uint64(123), "new-secret").Return(saveFailure)
+		unitOfWork := &DatabaseUnitOfWork{TransactionSupport: &infra.GormTransactionSupport{Database: ds.Database},
+			IdWorker: util.DefaultIdWorker}
+		manager := &PasswordResetManagerImpl{
+			PasswordResetTokenRepository: &DatabasePasswordResetTokenRepository{Database: ds.Database},
+			UnitOfWork:                   unitOfWork,
+		}
+		token, err := manager.IssueToken(123)
+		assert.Nil(t, err)
+
+		revoke := func(accountId uint64) error { return nil }
+		unitOfWork.Decorate = func(repositories *Repositories) {
+			repositories.InternalIdentityRepository = mockInternalIdentityRepository
+		}
+		assert.Equal(t, saveFailure, manager.ResetSecret(token, "new-secret", revoke))
+
+		unitOfWork.Decorate = nil
+		assert.Nil(t, manager.ResetSecret(token, "new-secret", revoke))
+	})
+
+	it.Run("should keep token and secret when sessions fail to be revoked", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		passwordHasher := &BcryptPasswordHasher{Cost: 4}
+		manager := &PasswordResetManagerImpl{
+			PasswordResetTokenRepository: &DatabasePasswordResetTokenRepository{Database: ds.Database},
+			UnitOfWork: &DatabaseUnitOfWork{TransactionSupport: &infra.GormTransactionSupport{Database: ds.Database},
+				IdWorker: util.DefaultIdWorker, PasswordHasher: passwordHasher},
+		}
+		internalIdentityRepository := &DatabaseInternalIdentityRepository{Database: ds.Database, PasswordHasher: passwordHasher}
+		assert.Nil(t, internalIdentityRepository.Save(123, "old-secret"))
+		token, err := manager.IssueToken(123)
+		assert.Nil(t, err)
+
+		revokeFailure := errors.New("revoke failure")
+		err = manager.ResetSecret(token, "new-secret", func(accountId uint64) error { return revokeFailure })
+		assert.Equal(t, revokeFailure, err)
+		assert.Nil(t, internalIdentityRepository.Authenticate(123, "old-secret"))
+
+		assert.Nil(t, manager.ResetSecret(token, "new-secret", func(accountId uint64) error { return nil }))
+		assert.Nil(t, internalIdentityRepository.Authenticate(123, "new-secret"))
+	})
+}
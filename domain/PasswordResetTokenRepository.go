@@ -0,0 +1,54 @@
+package domain
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/jinzhu/gorm"
+	"hallo/domain/entity"
+	"time"
+)
+
+//go:generate mockgen -destination PasswordResetTokenRepository_mock.go -package domain hallo/domain PasswordResetTokenRepository
+type PasswordResetTokenRepository interface {
+	Save(token *entity.PasswordResetToken) error
+	// Take deletes the token which has not expired and returns it, return (nil, nil) when there is not one,
+	// e.g. the token has been taken concurrently
+	Take(hashedToken string, now time.Time) (*entity.PasswordResetToken, error)
+	DeleteByAccountId(accountId uint64) error
+	DeleteExpired(now time.Time) error
+}
+
+type DatabasePasswordResetTokenRepository struct {
+	Database *gorm.DB
+}
+
+func (repository *DatabasePasswordResetTokenRepository) Save(token *entity.PasswordResetToken) error {
+	if err := validator.New().Struct(token); err != nil {
+		return err
+	}
+	return repository.Database.Save(token).Error
+}
+
+func (repository *DatabasePasswordResetTokenRepository) Take(hashedToken string, now time.Time) (*entity.PasswordResetToken, error) {
+	token := &entity.PasswordResetToken{}
+	err := repository.Database.Where("hashed_token = ? AND expire_time > ?", hashedToken, now).First(token).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// conditional delete, only one of concurrent takes wins
+	db := repository.Database.Where("hashed_token = ?", hashedToken).Delete(&entity.PasswordResetToken{})
+	if db.Error != nil || db.RowsAffected == 0 {
+		return nil, db.Error
+	}
+	return token, nil
+}
+
+func (repository *DatabasePasswordResetTokenRepository) DeleteByAccountId(accountId uint64) error {
+	return repository.Database.Where("account_id = ?", accountId).Delete(&entity.PasswordResetToken{}).Error
+}
+
+func (repository *DatabasePasswordResetTokenRepository) DeleteExpired(now time.Time) error {
+	return repository.Database.Where("expire_time <= ?", now).Delete(&entity.PasswordResetToken{}).Error
+}
@@ -0,0 +1,39 @@
+package domain
+
+import (
+	"github.com/stretchr/testify/assert"
+	"hallo/domain/entity"
+	"hallo/testinfra"
+	"testing"
+	"time"
+)
+
+func TestDatabasePasswordResetTokenRepository(it *testing.T) {
+	it.Run("should take unexpired token only once", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		repository := &DatabasePasswordResetTokenRepository{Database: ds.Database}
+		now := time.Now()
+		assert.Nil(t, repository.Save(&entity.PasswordResetToken{HashedToken: "h1", AccountId: 123,
+			ExpireTime: now.Add(time.Minute), CreateTime: now}))
+		assert.Nil(t, repository.Save(&entity.PasswordResetToken{HashedToken: "h2", AccountId: 123,
+			ExpireTime: now.Add(-time.Minute), CreateTime: now}))
+
+		token, err := repository.Take("h1", now)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(123), token.AccountId)
+		token, err = repository.Take("h1", now)
+		assert.Nil(t, err)
+		assert.Nil(t, token)
+
+		// expired
+		token, err = repository.Take("h2", now)
+		assert.Nil(t, err)
+		assert.Nil(t, token)
+		assert.Nil(t, repository.DeleteExpired(now))
+		var count uint64
+		assert.Nil(t, ds.Database.Model(&entity.PasswordResetToken{}).Count(&count).Error)
+		assert.Equal(t, uint64(0), count)
+	})
+}
@@ -0,0 +1,38 @@
+package domain
+
+// permissions are named as "<resource>:<action>", "*" and "<resource>:*" grant all the matched permissions
+const (
+	AllPermissions         = "*"
+	PermissionAccountRead  = "accounts:read"
+	PermissionAccountWrite = "accounts:write"
+	PermissionGroupRead    = "groups:read"
+	PermissionGroupWrite   = "groups:write"
+
+	PermissionOrganizationRead  = "organizations:read"
+	PermissionOrganizationWrite = "organizations:write"
+
+	PermissionClientRead  = "clients:read"
+	PermissionClientWrite = "clients:write"
+
+	PermissionServiceAccountRead  = "service_accounts:read"
+	PermissionServiceAccountWrite = "service_accounts:write"
+)
+
+// KnownPermissions are all the permissions checked by hallo, the wildcards are not included
+var KnownPermissions = []string{
+	PermissionAccountRead, PermissionAccountWrite, PermissionGroupRead, PermissionGroupWrite,
+	PermissionOrganizationRead, PermissionOrganizationWrite, PermissionClientRead, PermissionClientWrite,
+	PermissionServiceAccountRead, PermissionServiceAccountWrite,
+}
+
+func IsKnownPermission(permission string) bool {
+	for _, known := range KnownPermissions {
+		if known == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// SuperAdminRole is granted all permissions, it is granted to the account created on bootstrap
+const SuperAdminRole = "super-admin"
@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// RecoveryCodeCount is the number of codes generated in a set
+const RecoveryCodeCount = 10
+
+// RecoveryCodeLength is the number of characters in a code, the codes carry 50 random bits
+const RecoveryCodeLength = 10
+
+// GenerateRecoveryCodes returns RecoveryCodeCount codes of lower case base32 characters, they are formatted in
+// two groups like "abcde-fghij" to be written down easily
+func GenerateRecoveryCodes() ([]string, error) {
+	codes := make([]string, 0, RecoveryCodeCount)
+	for i := 0; i < RecoveryCodeCount; i++ {
+		random := make([]byte, 7)
+		if _, err := rand.Read(random); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(totpEncoding.EncodeToString(random))[:RecoveryCodeLength]
+		codes = append(codes, code[:RecoveryCodeLength/2]+"-"+code[RecoveryCodeLength/2:])
+	}
+	return codes, nil
+}
+
+// NormalizeRecoveryCode removes the separators and white spaces and lowers the case of code
+func NormalizeRecoveryCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' || r == '\t' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(code)))
+}
+
+// HashRecoveryCode is the stored form of code, the codes are random enough to be hashed without salt
+func HashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(NormalizeRecoveryCode(code)))
+	return hex.EncodeToString(sum[:])
+}
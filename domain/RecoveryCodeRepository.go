@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"github.com/jinzhu/gorm"
+	"hallo/domain/entity"
+	"time"
+)
+
+//go:generate mockgen -destination RecoveryCodeRepository_mock.go -package domain hallo/domain RecoveryCodeRepository
+type RecoveryCodeRepository interface {
+	// Replace removes the codes of account and saves the new set
+	Replace(accountId uint64, codeHashes []string) error
+	// Use deletes the code of account, return false when it is absent, e.g. the code has been used already
+	Use(accountId uint64, codeHash string) (bool, error)
+	CountByAccountId(accountId uint64) (int, error)
+	DeleteByAccountId(accountId uint64) error
+}
+
+type DatabaseRecoveryCodeRepository struct {
+	Database *gorm.DB
+}
+
+func (repository *DatabaseRecoveryCodeRepository) Replace(accountId uint64, codeHashes []string) error {
+	return repository.Database.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("account_id = ?", accountId).Delete(&entity.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, codeHash := range codeHashes {
+			if err := tx.Create(&entity.RecoveryCode{AccountId: accountId, CodeHash: codeHash, CreateTime: now}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (repository *DatabaseRecoveryCodeRepository) Use(accountId uint64, codeHash string) (bool, error) {
+	db := repository.Database.Where("account_id = ? AND code_hash = ?", accountId, codeHash).Delete(&entity.RecoveryCode{})
+	return db.RowsAffected > 0, db.Error
+}
+
+func (repository *DatabaseRecoveryCodeRepository) CountByAccountId(accountId uint64) (int, error) {
+	count := 0
+	err := repository.Database.Model(&entity.RecoveryCode{}).Where("account_id = ?", accountId).Count(&count).Error
+	return count, err
+}
+
+func (repository *DatabaseRecoveryCodeRepository) DeleteByAccountId(accountId uint64) error {
+	return repository.Database.Where("account_id = ?", accountId).Delete(&entity.RecoveryCode{}).Error
+}
@@ -0,0 +1,91 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: hallo/domain (interfaces: RecoveryCodeRepository)
+
+// Package domain is a generated GoMock package.
+package domain
+
+import (
+	gomock "github.com/golang/mock/gomock"
+	reflect "reflect"
+)
+
+// MockRecoveryCodeRepository is a mock of RecoveryCodeRepository interface
+type MockRecoveryCodeRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockRecoveryCodeRepositoryMockRecorder
+}
+
+// MockRecoveryCodeRepositoryMockRecorder is the mock recorder for MockRecoveryCodeRepository
+type MockRecoveryCodeRepositoryMockRecorder struct {
+	mock *MockRecoveryCodeRepository
+}
+
+// NewMockRecoveryCodeRepository creates a new mock instance
+func NewMockRecoveryCodeRepository(ctrl *gomock.Controller) *MockRecoveryCodeRepository {
+	mock := &MockRecoveryCodeRepository{ctrl: ctrl}
+	mock.recorder = &MockRecoveryCodeRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockRecoveryCodeRepository) EXPECT() *MockRecoveryCodeRepositoryMockRecorder {
+	return m.recorder
+}
+
+// CountByAccountId mocks base method
+func (m *MockRecoveryCodeRepository) CountByAccountId(arg0 uint64) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountByAccountId", arg0)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountByAccountId indicates an expected call of CountByAccountId
+func (mr *MockRecoveryCodeRepositoryMockRecorder) CountByAccountId(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountByAccountId", reflect.TypeOf((*MockRecoveryCodeRepository)(nil).CountByAccountId), arg0)
+}
+
+// DeleteByAccountId mocks base method
+func (m *MockRecoveryCodeRepository) DeleteByAccountId(arg0 uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteByAccountId", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteByAccountId indicates an expected call of DeleteByAccountId
+func (mr *MockRecoveryCodeRepositoryMockRecorder) DeleteByAccountId(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteByAccountId", reflect.TypeOf((*MockRecoveryCodeRepository)(nil).DeleteByAccountId), arg0)
+}
+
+// Replace mocks base method
+func (m *MockRecoveryCodeRepository) Replace(arg0 uint64, arg1 []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Replace", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Replace indicates an expected call of Replace
+func (mr *MockRecoveryCodeRepositoryMockRecorder) Replace(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Replace", reflect.TypeOf((*MockRecoveryCodeRepository)(nil).Replace), arg0, arg1)
+}
+
+// Use mocks base method
+func (m *MockRecoveryCodeRepository) Use(arg0 uint64, arg1 string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Use", arg0, arg1)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Use indicates an expected call of Use
+func (mr *MockRecoveryCodeRepositoryMockRecorder) Use(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Use", reflect.TypeOf((*MockRecoveryCodeRepository)(nil).Use), arg0, arg1)
+}
@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"github.com/stretchr/testify/assert"
+	"hallo/testinfra"
+	"testing"
+)
+
+func TestDatabaseRecoveryCodeRepository(it *testing.T) {
+	it.Run("should use each code once and replace the set", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		repository := &DatabaseRecoveryCodeRepository{Database: ds.Database}
+		assert.Nil(t, repository.Replace(123, []string{HashRecoveryCode("aaaaa-aaaaa"), HashRecoveryCode("bbbbb-bbbbb")}))
+		assert.Nil(t, repository.Replace(456, []string{HashRecoveryCode("aaaaa-aaaaa")}))
+
+		used, err := repository.Use(123, HashRecoveryCode("aaaaa-aaaaa"))
+		assert.Nil(t, err)
+		assert.True(t, used)
+		used, err = repository.Use(123, HashRecoveryCode("aaaaa-aaaaa"))
+		assert.Nil(t, err)
+		assert.False(t, used)
+		count, err := repository.CountByAccountId(123)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, count)
+
+		// the previous set is invalidated
+		assert.Nil(t, repository.Replace(123, []string{HashRecoveryCode("ccccc-ccccc")}))
+		used, err = repository.Use(123, HashRecoveryCode("bbbbb-bbbbb"))
+		assert.Nil(t, err)
+		assert.False(t, used)
+		count, err = repository.CountByAccountId(123)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, count)
+
+		assert.Nil(t, repository.DeleteByAccountId(123))
+		count, err = repository.CountByAccountId(123)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, count)
+		count, err = repository.CountByAccountId(456)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, count)
+	})
+}
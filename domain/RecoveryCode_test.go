@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+)
+
+func TestGenerateRecoveryCodes(it *testing.T) {
+	it.Run("should generate distinct codes in groups", func(t *testing.T) {
+		codes, err := GenerateRecoveryCodes()
+		assert.Nil(t, err)
+		assert.Len(t, codes, RecoveryCodeCount)
+		distinct := map[string]bool{}
+		for _, code := range codes {
+			assert.Regexp(t, regexp.MustCompile(`^[a-z2-7]{5}-[a-z2-7]{5}$`), code)
+			distinct[code] = true
+		}
+		assert.Len(t, distinct, RecoveryCodeCount)
+	})
+
+	it.Run("should hash codes ignoring case and separators", func(t *testing.T) {
+		assert.Equal(t, "abcdefghij", NormalizeRecoveryCode(" ABCDE-fghij "))
+		assert.Equal(t, HashRecoveryCode("abcde-fghij"), HashRecoveryCode("ABCDE FGHIJ"))
+		assert.NotEqual(t, HashRecoveryCode("abcde-fghij"), HashRecoveryCode("abcde-fghik"))
+		assert.Len(t, HashRecoveryCode("abcde-fghij"), 64)
+	})
+}
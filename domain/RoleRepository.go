@@ -0,0 +1,109 @@
+package domain
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/jinzhu/gorm"
+	"hallo/domain/entity"
+	"time"
+)
+
+const (
+	RoleTableName           = "roles"
+	RolePermissionTableName = "role_permissions"
+	AccountRoleTableName    = "account_roles"
+)
+
+//go:generate mockgen -destination RoleRepository_mock.go -package domain hallo/domain RoleRepository
+type RoleRepository interface {
+	// Save creates the role or replaces its permissions
+	Save(role string, permissions []string) error
+	IsRoleExisted(role string) (bool, error)
+	Grant(accountId uint64, role string) error
+	RevokeByAccountId(accountId uint64) error
+	// FindGrantsByAccountId returns the roles granted to the account and the distinct permissions of them
+	FindGrantsByAccountId(accountId uint64) (roles []string, permissions []string, err error)
+	// FindPermissionsByRoles returns the distinct permissions of the roles
+	FindPermissionsByRoles(roles []string) ([]string, error)
+}
+
+type DatabaseRoleRepository struct {
+	Database *gorm.DB
+}
+
+func (repository *DatabaseRoleRepository) Save(role string, permissions []string) error {
+	validate := validator.New()
+	roleEntity := entity.Role{Name: role, CreateTime: time.Now()}
+	if err := validate.Struct(roleEntity); err != nil {
+		return err
+	}
+
+	existed, err := repository.IsRoleExisted(role)
+	if err != nil {
+		return err
+	}
+	if !existed {
+		if err := repository.Database.Create(&roleEntity).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := repository.Database.Where(entity.RolePermission{RoleName: role}).Delete(&entity.RolePermission{}).Error; err != nil {
+		return err
+	}
+	for _, permission := range permissions {
+		rolePermission := entity.RolePermission{RoleName: role, Permission: permission}
+		if err := validate.Struct(rolePermission); err != nil {
+			return err
+		}
+		if err := repository.Database.Create(&rolePermission).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (repository *DatabaseRoleRepository) IsRoleExisted(role string) (bool, error) {
+	var count int
+	if err := repository.Database.Table(RoleTableName).Where(entity.Role{Name: role}).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (repository *DatabaseRoleRepository) Grant(accountId uint64, role string) error {
+	accountRole := entity.AccountRole{AccountId: accountId, RoleName: role, CreateTime: time.Now()}
+	if err := validator.New().Struct(accountRole); err != nil {
+		return err
+	}
+	return repository.Database.Save(accountRole).Error
+}
+
+func (repository *DatabaseRoleRepository) RevokeByAccountId(accountId uint64) error {
+	return repository.Database.Where(entity.AccountRole{AccountId: accountId}).Delete(&entity.AccountRole{}).Error
+}
+
+func (repository *DatabaseRoleRepository) FindGrantsByAccountId(accountId uint64) ([]string, []string, error) {
+	var roles []string
+	if err := repository.Database.Table(AccountRoleTableName).Where("account_id = ?", accountId).
+		Order("role_name").Pluck("role_name", &roles).Error; err != nil {
+		return nil, nil, err
+	}
+	permissions, err := repository.FindPermissionsByRoles(roles)
+	if err != nil {
+		return nil, nil, err
+	}
+	return roles, permissions, nil
+}
+
+func (repository *DatabaseRoleRepository) FindPermissionsByRoles(roles []string) ([]string, error) {
+	if len(roles) == 0 {
+		return []string{}, nil
+	}
+
+	var permissions []string
+	if err := repository.Database.Table(RolePermissionTableName).Where("role_name IN (?)", roles).
+		Order("permission").Pluck("DISTINCT permission", &permissions).Error; err != nil {
+		return nil, err
+	}
+	return permissions, nil
+}
@@ -0,0 +1,121 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: hallo/domain (interfaces: RoleRepository)
+
+// Package domain is a generated GoMock package.
+package domain
+
+import (
+	gomock "github.com/golang/mock/gomock"
+	reflect "reflect"
+)
+
+// MockRoleRepository is a mock of RoleRepository interface
+type MockRoleRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockRoleRepositoryMockRecorder
+}
+
+// MockRoleRepositoryMockRecorder is the mock recorder for MockRoleRepository
+type MockRoleRepositoryMockRecorder struct {
+	mock *MockRoleRepository
+}
+
+// NewMockRoleRepository creates a new mock instance
+func NewMockRoleRepository(ctrl *gomock.Controller) *MockRoleRepository {
+	mock := &MockRoleRepository{ctrl: ctrl}
+	mock.recorder = &MockRoleRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockRoleRepository) EXPECT() *MockRoleRepositoryMockRecorder {
+	return m.recorder
+}
+
+// FindGrantsByAccountId mocks base method
+func (m *MockRoleRepository) FindGrantsByAccountId(arg0 uint64) ([]string, []string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindGrantsByAccountId", arg0)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].([]string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// FindGrantsByAccountId indicates an expected call of FindGrantsByAccountId
+func (mr *MockRoleRepositoryMockRecorder) FindGrantsByAccountId(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindGrantsByAccountId", reflect.TypeOf((*MockRoleRepository)(nil).FindGrantsByAccountId), arg0)
+}
+
+// FindPermissionsByRoles mocks base method
+func (m *MockRoleRepository) FindPermissionsByRoles(arg0 []string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindPermissionsByRoles", arg0)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindPermissionsByRoles indicates an expected call of FindPermissionsByRoles
+func (mr *MockRoleRepositoryMockRecorder) FindPermissionsByRoles(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindPermissionsByRoles", reflect.TypeOf((*MockRoleRepository)(nil).FindPermissionsByRoles), arg0)
+}
+
+// Grant mocks base method
+func (m *MockRoleRepository) Grant(arg0 uint64, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Grant", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Grant indicates an expected call of Grant
+func (mr *MockRoleRepositoryMockRecorder) Grant(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Grant", reflect.TypeOf((*MockRoleRepository)(nil).Grant), arg0, arg1)
+}
+
+// IsRoleExisted mocks base method
+func (m *MockRoleRepository) IsRoleExisted(arg0 string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsRoleExisted", arg0)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsRoleExisted indicates an expected call of IsRoleExisted
+func (mr *MockRoleRepositoryMockRecorder) IsRoleExisted(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsRoleExisted", reflect.TypeOf((*MockRoleRepository)(nil).IsRoleExisted), arg0)
+}
+
+// RevokeByAccountId mocks base method
+func (m *MockRoleRepository) RevokeByAccountId(arg0 uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeByAccountId", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeByAccountId indicates an expected call of RevokeByAccountId
+func (mr *MockRoleRepositoryMockRecorder) RevokeByAccountId(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeByAccountId", reflect.TypeOf((*MockRoleRepository)(nil).RevokeByAccountId), arg0)
+}
+
+// Save mocks base method
+func (m *MockRoleRepository) Save(arg0 string, arg1 []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Save", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Save indicates an expected call of Save
+func (mr *MockRoleRepositoryMockRecorder) Save(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockRoleRepository)(nil).Save), arg0, arg1)
+}
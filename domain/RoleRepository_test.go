@@ -0,0 +1,50 @@
+package domain
+
+import (
+	"github.com/stretchr/testify/assert"
+	"hallo/testinfra"
+	"testing"
+)
+
+func TestDatabaseRoleRepository(it *testing.T) {
+	it.Run("should save roles, grant them and find grants of account", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		repository := &DatabaseRoleRepository{Database: ds.Database}
+
+		roles, permissions, err := repository.FindGrantsByAccountId(123)
+		assert.Nil(t, err)
+		assert.Empty(t, roles)
+		assert.Empty(t, permissions)
+
+		assert.Nil(t, repository.Save("reader", []string{"accounts:read", "groups:read"}))
+		assert.Nil(t, repository.Save("writer", []string{"accounts:read"}))
+		// replace permissions
+		assert.Nil(t, repository.Save("writer", []string{"accounts:read", "accounts:write"}))
+		existed, err := repository.IsRoleExisted("writer")
+		assert.Nil(t, err)
+		assert.True(t, existed)
+		existed, err = repository.IsRoleExisted("unknown")
+		assert.Nil(t, err)
+		assert.False(t, existed)
+
+		assert.Nil(t, repository.Grant(123, "reader"))
+		assert.Nil(t, repository.Grant(123, "writer"))
+		assert.Nil(t, repository.Grant(123, "writer"))
+		assert.Nil(t, repository.Grant(456, "reader"))
+
+		roles, permissions, err = repository.FindGrantsByAccountId(123)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"reader", "writer"}, roles)
+		assert.Equal(t, []string{"accounts:read", "accounts:write", "groups:read"}, permissions)
+
+		assert.Nil(t, repository.RevokeByAccountId(123))
+		roles, _, err = repository.FindGrantsByAccountId(123)
+		assert.Nil(t, err)
+		assert.Empty(t, roles)
+		roles, _, err = repository.FindGrantsByAccountId(456)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"reader"}, roles)
+	})
+}
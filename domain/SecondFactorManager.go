@@ -0,0 +1,215 @@
+package domain
+
+import (
+	"errors"
+	"github.com/jinzhu/gorm"
+	"hallo/domain/entity"
+	"time"
+)
+
+const DefaultTotpIssuer = "hallo"
+
+// the second factors of account are locked for SecondFactorLockout after SecondFactorAttempts failed codes,
+// which limits the guessing across the challenges of all sign in endpoints
+const (
+	SecondFactorAttempts = 10
+	SecondFactorLockout  = 15 * time.Minute
+)
+
+//go:generate mockgen -destination SecondFactorManager_mock.go -package domain hallo/domain SecondFactorManager
+type SecondFactorManager interface {
+	// EnrollTotp generates a new secret for account, which replaces the unconfirmed one.
+	// return TotpIsEnrolled when the account has confirmed a TOTP factor
+	EnrollTotp(account *entity.Account) (*entity.TotpEnrollment, error)
+	// ConfirmTotp enables the factor enrolled by EnrollTotp with a code generated from it, and returns a new set of
+	// recovery codes. return gorm.ErrRecordNotFound when nothing is enrolled, SecondFactorAuthenticationFailure when
+	// code is not match
+	ConfirmTotp(accountId uint64, code string) ([]string, error)
+	// HasSecondFactor is true when the account has confirmed a second factor, it is required to sign in by secret
+	HasSecondFactor(accountId uint64) (bool, error)
+	// VerifySecondFactor accepts a TOTP code or a recovery code, which is used up by it.
+	// return SecondFactorAuthenticationFailure when code is not match or account has no second factor,
+	// SecondFactorIsLocked when the account has failed SecondFactorAttempts codes in a row
+	VerifySecondFactor(accountId uint64, code string) error
+	// RegenerateRecoveryCodes returns a new set of recovery codes, which invalidates the previous set.
+	// return gorm.ErrRecordNotFound when account has no second factor
+	RegenerateRecoveryCodes(accountId uint64) ([]string, error)
+	// CountRecoveryCodes returns the number of unused recovery codes
+	CountRecoveryCodes(accountId uint64) (int, error)
+	// DeleteTotp deletes the recovery codes as well, return gorm.ErrRecordNotFound when account has no TOTP factor
+	DeleteTotp(accountId uint64) error
+}
+
+// SecondFactorManagerImpl uses DefaultTotpIssuer when Issuer is empty. The factor and its recovery codes are
+// changed together in UnitOfWork
+type SecondFactorManagerImpl struct {
+	TotpFactorRepository   TotpFactorRepository
+	RecoveryCodeRepository RecoveryCodeRepository
+	UnitOfWork             UnitOfWork
+	Issuer                 string
+}
+
+func (manager *SecondFactorManagerImpl) EnrollTotp(account *entity.Account) (*entity.TotpEnrollment, error) {
+	factor, err := manager.TotpFactorRepository.FindByAccountId(account.Id)
+	if err == nil && factor.Confirmed {
+		return nil, &TotpIsEnrolled{}
+	}
+	if err != nil && !gorm.IsRecordNotFoundError(err) {
+		return nil, err
+	}
+
+	secret, err := GenerateTotpSecret()
+	if err != nil {
+		return nil, err
+	}
+	factor = &entity.TotpFactor{AccountId: account.Id, Secret: secret, CreateTime: time.Now()}
+	if err := manager.TotpFactorRepository.Save(factor); err != nil {
+		return nil, err
+	}
+	return &entity.TotpEnrollment{Secret: secret, Uri: TotpUri(manager.issuer(), account.Name, secret)}, nil
+}
+
+func (manager *SecondFactorManagerImpl) ConfirmTotp(accountId uint64, code string) ([]string, error) {
+	var recoveryCodes []string
+	err := manager.UnitOfWork.Do(func(repositories *Repositories) error {
+		factor, err := repositories.TotpFactorRepository.FindByAccountId(accountId)
+		if err != nil {
+			return err
+		}
+		if err := acceptTotp(repositories.TotpFactorRepository, factor, code); err != nil {
+			return err
+		}
+		recoveryCodes, err = replaceRecoveryCodes(repositories.RecoveryCodeRepository, accountId)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return recoveryCodes, nil
+}
+
+func (manager *SecondFactorManagerImpl) HasSecondFactor(accountId uint64) (bool, error) {
+	factor, err := manager.TotpFactorRepository.FindByAccountId(accountId)
+	if gorm.IsRecordNotFoundError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return factor.Confirmed, nil
+}
+
+func (manager *SecondFactorManagerImpl) VerifySecondFactor(accountId uint64, code string) error {
+	factor, err := manager.TotpFactorRepository.FindByAccountId(accountId)
+	if gorm.IsRecordNotFoundError(err) || (err == nil && !factor.Confirmed) {
+		return &SecondFactorAuthenticationFailure{}
+	}
+	if err != nil {
+		return err
+	}
+	if factor.LockedUntil != nil && factor.LockedUntil.After(time.Now()) {
+		return &SecondFactorIsLocked{}
+	}
+
+	err = manager.verifyCode(factor, code)
+	var failure *SecondFactorAuthenticationFailure
+	if errors.As(err, &failure) {
+		if err := manager.TotpFactorRepository.Fail(accountId, SecondFactorAttempts, time.Now().Add(SecondFactorLockout)); err != nil {
+			return err
+		}
+		return failure
+	}
+	if err == nil && factor.FailedAttempts > 0 {
+		return manager.TotpFactorRepository.ResetFailures(accountId)
+	}
+	return err
+}
+
+// verifyCode accepts the TOTP code or recovery code of the confirmed factor
+func (manager *SecondFactorManagerImpl) verifyCode(factor *entity.TotpFactor, code string) error {
+	if len(code) == TotpDigits {
+		return acceptTotp(manager.TotpFactorRepository, factor, code)
+	}
+
+	used, err := manager.RecoveryCodeRepository.Use(factor.AccountId, HashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return &SecondFactorAuthenticationFailure{}
+	}
+	return nil
+}
+
+func (manager *SecondFactorManagerImpl) RegenerateRecoveryCodes(accountId uint64) ([]string, error) {
+	var recoveryCodes []string
+	err := manager.UnitOfWork.Do(func(repositories *Repositories) error {
+		factor, err := repositories.TotpFactorRepository.FindByAccountId(accountId)
+		if err != nil {
+			return err
+		}
+		if !factor.Confirmed {
+			return gorm.ErrRecordNotFound
+		}
+		recoveryCodes, err = replaceRecoveryCodes(repositories.RecoveryCodeRepository, accountId)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return recoveryCodes, nil
+}
+
+func (manager *SecondFactorManagerImpl) CountRecoveryCodes(accountId uint64) (int, error) {
+	return manager.RecoveryCodeRepository.CountByAccountId(accountId)
+}
+
+func (manager *SecondFactorManagerImpl) DeleteTotp(accountId uint64) error {
+	return manager.UnitOfWork.Do(func(repositories *Repositories) error {
+		if _, err := repositories.TotpFactorRepository.FindByAccountId(accountId); err != nil {
+			return err
+		}
+		if err := repositories.RecoveryCodeRepository.DeleteByAccountId(accountId); err != nil {
+			return err
+		}
+		return repositories.TotpFactorRepository.Delete(accountId)
+	})
+}
+
+func replaceRecoveryCodes(repository RecoveryCodeRepository, accountId uint64) ([]string, error) {
+	codes, err := GenerateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	codeHashes := make([]string, 0, len(codes))
+	for _, code := range codes {
+		codeHashes = append(codeHashes, HashRecoveryCode(code))
+	}
+	if err := repository.Replace(accountId, codeHashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// acceptTotp confirms the factor as well when it is unconfirmed
+func acceptTotp(repository TotpFactorRepository, factor *entity.TotpFactor, code string) error {
+	step, matched := MatchTotp(factor.Secret, code, time.Now(), factor.LastStep)
+	if !matched {
+		return &SecondFactorAuthenticationFailure{}
+	}
+	accepted, err := repository.Accept(factor.AccountId, step, !factor.Confirmed)
+	if err != nil {
+		return err
+	}
+	if !accepted {
+		return &SecondFactorAuthenticationFailure{}
+	}
+	return nil
+}
+
+func (manager *SecondFactorManagerImpl) issuer() string {
+	if manager.Issuer == "" {
+		return DefaultTotpIssuer
+	}
+	return manager.Issuer
+}
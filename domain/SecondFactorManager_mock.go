@@ -0,0 +1,137 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: hallo/domain (interfaces: SecondFactorManager)
+
+// Package domain is a generated GoMock package.
+package domain
+
+import (
+	gomock "github.com/golang/mock/gomock"
+	entity "hallo/domain/entity"
+	reflect "reflect"
+)
+
+// MockSecondFactorManager is a mock of SecondFactorManager interface
+type MockSecondFactorManager struct {
+	ctrl     *gomock.Controller
+	recorder *MockSecondFactorManagerMockRecorder
+}
+
+// MockSecondFactorManagerMockRecorder is the mock recorder for MockSecondFactorManager
+type MockSecondFactorManagerMockRecorder struct {
+	mock *MockSecondFactorManager
+}
+
+// NewMockSecondFactorManager creates a new mock instance
+func NewMockSecondFactorManager(ctrl *gomock.Controller) *MockSecondFactorManager {
+	mock := &MockSecondFactorManager{ctrl: ctrl}
+	mock.recorder = &MockSecondFactorManagerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockSecondFactorManager) EXPECT() *MockSecondFactorManagerMockRecorder {
+	return m.recorder
+}
+
+// ConfirmTotp mocks base method
+func (m *MockSecondFactorManager) ConfirmTotp(arg0 uint64, arg1 string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmTotp", arg0, arg1)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConfirmTotp indicates an expected call of ConfirmTotp
+func (mr *MockSecondFactorManagerMockRecorder) ConfirmTotp(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmTotp", reflect.TypeOf((*MockSecondFactorManager)(nil).ConfirmTotp), arg0, arg1)
+}
+
+// CountRecoveryCodes mocks base method
+func (m *MockSecondFactorManager) CountRecoveryCodes(arg0 uint64) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountRecoveryCodes", arg0)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountRecoveryCodes indicates an expected call of CountRecoveryCodes
+func (mr *MockSecondFactorManagerMockRecorder) CountRecoveryCodes(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountRecoveryCodes", reflect.TypeOf((*MockSecondFactorManager)(nil).CountRecoveryCodes), arg0)
+}
+
+// DeleteTotp mocks base method
+func (m *MockSecondFactorManager) DeleteTotp(arg0 uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteTotp", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteTotp indicates an expected call of DeleteTotp
+func (mr *MockSecondFactorManagerMockRecorder) DeleteTotp(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTotp", reflect.TypeOf((*MockSecondFactorManager)(nil).DeleteTotp), arg0)
+}
+
+// EnrollTotp mocks base method
+func (m *MockSecondFactorManager) EnrollTotp(arg0 *entity.Account) (*entity.TotpEnrollment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnrollTotp", arg0)
+	ret0, _ := ret[0].(*entity.TotpEnrollment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EnrollTotp indicates an expected call of EnrollTotp
+func (mr *MockSecondFactorManagerMockRecorder) EnrollTotp(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnrollTotp", reflect.TypeOf((*MockSecondFactorManager)(nil).EnrollTotp), arg0)
+}
+
+// HasSecondFactor mocks base method
+func (m *MockSecondFactorManager) HasSecondFactor(arg0 uint64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HasSecondFactor", arg0)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HasSecondFactor indicates an expected call of HasSecondFactor
+func (mr *MockSecondFactorManagerMockRecorder) HasSecondFactor(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HasSecondFactor", reflect.TypeOf((*MockSecondFactorManager)(nil).HasSecondFactor), arg0)
+}
+
+// RegenerateRecoveryCodes mocks base method
+func (m *MockSecondFactorManager) RegenerateRecoveryCodes(arg0 uint64) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RegenerateRecoveryCodes", arg0)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RegenerateRecoveryCodes indicates an expected call of RegenerateRecoveryCodes
+func (mr *MockSecondFactorManagerMockRecorder) RegenerateRecoveryCodes(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegenerateRecoveryCodes", reflect.TypeOf((*MockSecondFactorManager)(nil).RegenerateRecoveryCodes), arg0)
+}
+
+// VerifySecondFactor mocks base method
+func (m *MockSecondFactorManager) VerifySecondFactor(arg0 uint64, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifySecondFactor", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// VerifySecondFactor indicates an expected call of VerifySecondFactor
+func (mr *MockSecondFactorManagerMockRecorder) VerifySecondFactor(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifySecondFactor", reflect.TypeOf((*MockSecondFactorManager)(nil).VerifySecondFactor), arg0, arg1)
+}
@@ -0,0 +1,321 @@
+package domain
+
+import (
+	"errors"
+	"github.com/golang/mock/gomock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"hallo/domain/entity"
+	"hallo/infra"
+	"hallo/testinfra"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSecondFactorManager(it *testing.T) {
+	it.Run("should enroll totp and confirm it by code", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		repository := NewMockTotpFactorRepository(mockCtl)
+		recoveryCodeRepository := NewMockRecoveryCodeRepository(mockCtl)
+		manager := newSecondFactorManager(repository, recoveryCodeRepository)
+
+		var saved *entity.TotpFactor
+		repository.EXPECT().FindByAccountId(uint64(123)).Return(nil, gorm.ErrRecordNotFound)
+		repository.EXPECT().Save(gomock.Any()).DoAndReturn(func(factor *entity.TotpFactor) error {
+			saved = factor
+			return nil
+		})
+		enrollment, err := manager.EnrollTotp(&entity.Account{Id: 123, Name: "ann"})
+		assert.Nil(t, err)
+		assert.Equal(t, saved.Secret, enrollment.Secret)
+		assert.False(t, saved.Confirmed)
+		assert.True(t, strings.HasPrefix(enrollment.Uri, "otpauth://totp/hallo:ann?"))
+
+		// the unconfirmed factor is not required to sign in
+		repository.EXPECT().FindByAccountId(uint64(123)).Return(saved, nil).Times(2)
+		hasSecondFactor, err := manager.HasSecondFactor(123)
+		assert.Nil(t, err)
+		assert.False(t, hasSecondFactor)
+		assert.Equal(t, &SecondFactorAuthenticationFailure{}, manager.VerifySecondFactor(123, "000000"))
+
+		step := TotpStep(time.Now())
+		code, err := TotpCode(saved.Secret, step)
+		assert.Nil(t, err)
+		repository.EXPECT().FindByAccountId(uint64(123)).Return(saved, nil).Times(2)
+		_, err = manager.ConfirmTotp(123, wrongCode(code))
+		assert.Equal(t, &SecondFactorAuthenticationFailure{}, err)
+		repository.EXPECT().Accept(uint64(123), step, true).Return(true, nil)
+		var codeHashes []string
+		recoveryCodeRepository.EXPECT().Replace(uint64(123), gomock.Any()).DoAndReturn(func(accountId uint64, hashes []string) error {
+			codeHashes = hashes
+			return nil
+		})
+		recoveryCodes, err := manager.ConfirmTotp(123, code)
+		assert.Nil(t, err)
+		assert.Len(t, recoveryCodes, RecoveryCodeCount)
+		assert.Len(t, codeHashes, RecoveryCodeCount)
+		assert.Equal(t, HashRecoveryCode(recoveryCodes[0]), codeHashes[0])
+
+		// a confirmed factor is not replaced
+		confirmed := &entity.TotpFactor{AccountId: 123, Secret: saved.Secret, Confirmed: true, LastStep: step}
+		repository.EXPECT().FindByAccountId(uint64(123)).Return(confirmed, nil).Times(2)
+		_, err = manager.EnrollTotp(&entity.Account{Id: 123, Name: "ann"})
+		assert.Equal(t, &TotpIsEnrolled{}, err)
+		hasSecondFactor, err = manager.HasSecondFactor(123)
+		assert.Nil(t, err)
+		assert.True(t, hasSecondFactor)
+	})
+
+	it.Run("should verify code of confirmed factor once", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		repository := NewMockTotpFactorRepository(mockCtl)
+		manager := newSecondFactorManager(repository, nil)
+
+		secret, err := GenerateTotpSecret()
+		assert.Nil(t, err)
+		step := TotpStep(time.Now())
+		code, err := TotpCode(secret, step)
+		assert.Nil(t, err)
+
+		repository.EXPECT().FindByAccountId(uint64(123)).Return(&entity.TotpFactor{AccountId: 123, Secret: secret, Confirmed: true}, nil)
+		repository.EXPECT().Accept(uint64(123), step, false).Return(true, nil)
+		assert.Nil(t, manager.VerifySecondFactor(123, code))
+
+		// the code was accepted concurrently
+		repository.EXPECT().FindByAccountId(uint64(123)).Return(&entity.TotpFactor{AccountId: 123, Secret: secret, Confirmed: true}, nil)
+		repository.EXPECT().Accept(uint64(123), step, false).Return(false, nil)
+		repository.EXPECT().Fail(uint64(123), SecondFactorAttempts, gomock.Any()).Return(nil).Times(2)
+		assert.Equal(t, &SecondFactorAuthenticationFailure{}, manager.VerifySecondFactor(123, code))
+
+		repository.EXPECT().FindByAccountId(uint64(123)).Return(&entity.TotpFactor{AccountId: 123, Secret: secret, Confirmed: true, LastStep: step}, nil)
+		assert.Equal(t, &SecondFactorAuthenticationFailure{}, manager.VerifySecondFactor(123, code))
+
+		repository.EXPECT().FindByAccountId(uint64(456)).Return(nil, gorm.ErrRecordNotFound).Times(2)
+		assert.Equal(t, &SecondFactorAuthenticationFailure{}, manager.VerifySecondFactor(456, code))
+		assert.Equal(t, gorm.ErrRecordNotFound, manager.DeleteTotp(456))
+	})
+
+	it.Run("should accept recovery code once and regenerate the set", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		repository := NewMockTotpFactorRepository(mockCtl)
+		recoveryCodeRepository := NewMockRecoveryCodeRepository(mockCtl)
+		manager := newSecondFactorManager(repository, recoveryCodeRepository)
+
+		confirmed := &entity.TotpFactor{AccountId: 123, Secret: "JBSWY3DPEHPK3PXP", Confirmed: true}
+		repository.EXPECT().FindByAccountId(uint64(123)).Return(confirmed, nil).Times(2)
+		recoveryCodeRepository.EXPECT().Use(uint64(123), HashRecoveryCode("abcde-fghij")).Return(true, nil)
+		assert.Nil(t, manager.VerifySecondFactor(123, "ABCDE FGHIJ"))
+		recoveryCodeRepository.EXPECT().Use(uint64(123), HashRecoveryCode("abcde-fghij")).Return(false, nil)
+		repository.EXPECT().Fail(uint64(123), SecondFactorAttempts, gomock.Any()).Return(nil)
+		assert.Equal(t, &SecondFactorAuthenticationFailure{}, manager.VerifySecondFactor(123, "abcde-fghij"))
+
+		repository.EXPECT().FindByAccountId(uint64(123)).Return(confirmed, nil)
+		recoveryCodeRepository.EXPECT().Replace(uint64(123), gomock.Len(RecoveryCodeCount)).Return(nil)
+		recoveryCodes, err := manager.RegenerateRecoveryCodes(123)
+		assert.Nil(t, err)
+		assert.Len(t, recoveryCodes, RecoveryCodeCount)
+
+		// the codes are not generated without a confirmed factor
+		repository.EXPECT().FindByAccountId(uint64(456)).Return(&entity.TotpFactor{AccountId: 456, Secret: "JBSWY3DPEHPK3PXP"}, nil).Times(2)
+		_, err = manager.RegenerateRecoveryCodes(456)
+		assert.Equal(t, gorm.ErrRecordNotFound, err)
+		assert.Equal(t, &SecondFactorAuthenticationFailure{}, manager.VerifySecondFactor(456, "abcde-fghij"))
+
+		repository.EXPECT().FindByAccountId(uint64(123)).Return(confirmed, nil)
+		recoveryCodeRepository.EXPECT().DeleteByAccountId(uint64(123)).Return(nil)
+		repository.EXPECT().Delete(uint64(123)).Return(nil)
+		assert.Nil(t, manager.DeleteTotp(123))
+	})
+
+	it.Run("should refuse replayed totp codes on confirmation and verification", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		recoveryCodeRepository := NewMockRecoveryCodeRepository(mockCtl)
+		repository := memoryTotpFactorRepository{}
+		manager := newSecondFactorManager(repository, recoveryCodeRepository)
+
+		enrollment, err := manager.EnrollTotp(&entity.Account{Id: 123, Name: "ann"})
+		assert.Nil(t, err)
+		step := TotpStep(time.Now())
+		codeOf := func(step int64) string {
+			code, err := TotpCode(enrollment.Secret, step)
+			assert.Nil(t, err)
+			return code
+		}
+
+		recoveryCodeRepository.EXPECT().Replace(uint64(123), gomock.Any()).Return(nil)
+		_, err = manager.ConfirmTotp(123, codeOf(step))
+		assert.Nil(t, err)
+		// the code of confirmation and the earlier ones are not accepted by verification
+		assert.Equal(t, &SecondFactorAuthenticationFailure{}, manager.VerifySecondFactor(123, codeOf(step)))
+		assert.Equal(t, &SecondFactorAuthenticationFailure{}, manager.VerifySecondFactor(123, codeOf(step-1)))
+
+		assert.Nil(t, manager.VerifySecondFactor(123, codeOf(step+1)))
+		assert.Equal(t, step+1, repository[123].LastStep)
+		assert.Equal(t, 0, repository[123].FailedAttempts)
+		assert.Equal(t, &SecondFactorAuthenticationFailure{}, manager.VerifySecondFactor(123, codeOf(step+1)))
+		assert.Equal(t, 1, repository[123].FailedAttempts)
+	})
+
+	it.Run("should lock second factors after failed codes in a row", func(t *testing.T) {
+		repository := memoryTotpFactorRepository{}
+		manager := &SecondFactorManagerImpl{TotpFactorRepository: repository}
+		secret, err := GenerateTotpSecret()
+		assert.Nil(t, err)
+		assert.Nil(t, repository.Save(&entity.TotpFactor{AccountId: 123, Secret: secret, Confirmed: true, CreateTime: time.Now()}))
+		code, err := TotpCode(secret, TotpStep(time.Now()))
+		assert.Nil(t, err)
+
+		for i := 0; i < SecondFactorAttempts; i++ {
+			assert.Equal(t, &SecondFactorAuthenticationFailure{}, manager.VerifySecondFactor(123, wrongCode(code)))
+		}
+		assert.Equal(t, &SecondFactorIsLocked{}, manager.VerifySecondFactor(123, code))
+
+		// the lockout expires
+		expired := time.Now().Add(-time.Second)
+		repository[123].LockedUntil = &expired
+		assert.Nil(t, manager.VerifySecondFactor(123, code))
+	})
+}
+
+func TestSecondFactorManager_UnitOfWork(it *testing.T) {
+	it.Run("should keep factor and recovery codes when one of them fails to be changed", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		failure := errors.New("some error")
+		failing := false
+		manager := &SecondFactorManagerImpl{
+			TotpFactorRepository:   &DatabaseTotpFactorRepository{Database: ds.Database},
+			RecoveryCodeRepository: &DatabaseRecoveryCodeRepository{Database: ds.Database},
+			UnitOfWork: &DatabaseUnitOfWork{
+				TransactionSupport: &infra.GormTransactionSupport{Database: ds.Database},
+				Decorate: func(repositories *Repositories) {
+					if failing {
+						repositories.TotpFactorRepository = failingTotpFactorRepository{repositories.TotpFactorRepository, failure}
+						repositories.RecoveryCodeRepository = failingRecoveryCodeRepository{repositories.RecoveryCodeRepository, failure}
+					}
+				},
+			},
+		}
+
+		enrollment, err := manager.EnrollTotp(&entity.Account{Id: 123, Name: "ann"})
+		assert.Nil(t, err)
+		code, err := TotpCode(enrollment.Secret, TotpStep(time.Now()))
+		assert.Nil(t, err)
+		failing = true
+		_, err = manager.ConfirmTotp(123, code)
+		assert.Equal(t, failure, err)
+		hasSecondFactor, err := manager.HasSecondFactor(123)
+		assert.Nil(t, err)
+		assert.False(t, hasSecondFactor)
+
+		failing = false
+		recoveryCodes, err := manager.ConfirmTotp(123, code)
+		assert.Nil(t, err)
+		failing = true
+		assert.Equal(t, failure, manager.DeleteTotp(123))
+		hasSecondFactor, err = manager.HasSecondFactor(123)
+		assert.Nil(t, err)
+		assert.True(t, hasSecondFactor)
+		count, err := manager.CountRecoveryCodes(123)
+		assert.Nil(t, err)
+		assert.Equal(t, len(recoveryCodes), count)
+	})
+}
+
+// failingTotpFactorRepository fails to delete factors
+type failingTotpFactorRepository struct {
+	TotpFactorRepository
+	err error
+}
+
+func (repository failingTotpFactorRepository) Delete(accountId uint64) error {
+	return repository.err
+}
+
+// failingRecoveryCodeRepository fails to replace recovery codes
+type failingRecoveryCodeRepository struct {
+	RecoveryCodeRepository
+	err error
+}
+
+func (repository failingRecoveryCodeRepository) Replace(accountId uint64, codeHashes []string) error {
+	return repository.err
+}
+
+// newSecondFactorManager changes the repositories directly in its unit of work, which is not atomic
+func newSecondFactorManager(repository TotpFactorRepository, recoveryCodeRepository RecoveryCodeRepository) *SecondFactorManagerImpl {
+	return &SecondFactorManagerImpl{TotpFactorRepository: repository, RecoveryCodeRepository: recoveryCodeRepository,
+		UnitOfWork: repositoriesUnitOfWork{TotpFactorRepository: repository, RecoveryCodeRepository: recoveryCodeRepository}}
+}
+
+type repositoriesUnitOfWork Repositories
+
+func (unitOfWork repositoriesUnitOfWork) Do(work func(repositories *Repositories) error) error {
+	repositories := Repositories(unitOfWork)
+	return work(&repositories)
+}
+
+// memoryTotpFactorRepository accepts the steps conditionally as DatabaseTotpFactorRepository does
+type memoryTotpFactorRepository map[uint64]*entity.TotpFactor
+
+func (repository memoryTotpFactorRepository) FindByAccountId(accountId uint64) (*entity.TotpFactor, error) {
+	factor, found := repository[accountId]
+	if !found {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *factor
+	return &copied, nil
+}
+
+func (repository memoryTotpFactorRepository) Save(factor *entity.TotpFactor) error {
+	copied := *factor
+	repository[factor.AccountId] = &copied
+	return nil
+}
+
+func (repository memoryTotpFactorRepository) Accept(accountId uint64, step int64, confirm bool) (bool, error) {
+	factor, found := repository[accountId]
+	if !found || factor.LastStep >= step {
+		return false, nil
+	}
+	factor.LastStep = step
+	factor.Confirmed = factor.Confirmed || confirm
+	return true, nil
+}
+
+func (repository memoryTotpFactorRepository) Fail(accountId uint64, limit int, lockUntil time.Time) error {
+	if factor, found := repository[accountId]; found {
+		factor.FailedAttempts++
+		if factor.FailedAttempts >= limit {
+			factor.FailedAttempts = 0
+			factor.LockedUntil = &lockUntil
+		}
+	}
+	return nil
+}
+
+func (repository memoryTotpFactorRepository) ResetFailures(accountId uint64) error {
+	if factor, found := repository[accountId]; found {
+		factor.FailedAttempts = 0
+	}
+	return nil
+}
+
+func (repository memoryTotpFactorRepository) Delete(accountId uint64) error {
+	delete(repository, accountId)
+	return nil
+}
+
+// wrongCode returns another code of the same length
+func wrongCode(code string) string {
+	if code[0] == '0' {
+		return "1" + code[1:]
+	}
+	return "0" + code[1:]
+}
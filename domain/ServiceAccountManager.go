@@ -0,0 +1,165 @@
+package domain
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"hallo/domain/entity"
+	"hallo/util"
+	"log"
+	"strings"
+	"time"
+)
+
+//go:generate mockgen -destination ServiceAccountManager_mock.go -package domain hallo/domain ServiceAccountManager
+type ServiceAccountManager interface {
+	// CreateServiceAccount returns the service account with its first secret, which is not kept in plain text.
+	// ErrUnknownPermission is returned when any scope is not a known permission
+	CreateServiceAccount(action entity.ServiceAccountCreateRequest) (*entity.ServiceAccount, string, error)
+	DeleteServiceAccount(serviceAccountId uint64) error
+	// RotateSecret adds a new secret, the existing secrets are still valid in the overlap after rotation.
+	// The secrets which expire earlier are not prolonged, and the expired ones are deleted.
+	RotateSecret(serviceAccountId uint64, overlap time.Duration) (*entity.ServiceAccountSecret, string, error)
+	// Authenticate return ErrOAuthClientAuthenticationFailure when client is not found or secret is not match any valid secret
+	Authenticate(clientId, secret string) (*entity.ServiceAccount, error)
+}
+
+// ServiceAccountManagerImpl keeps the secrets by HashClientSecret, as OAuthClientManagerImpl does
+type ServiceAccountManagerImpl struct {
+	UnitOfWork UnitOfWork
+}
+
+func (manager *ServiceAccountManagerImpl) CreateServiceAccount(action entity.ServiceAccountCreateRequest) (*entity.ServiceAccount, string, error) {
+	if err := validator.New().Struct(action); err != nil {
+		return nil, "", err
+	}
+	for _, scope := range action.Scopes {
+		if !IsKnownPermission(scope) {
+			return nil, "", &ErrUnknownPermission{Permission: scope}
+		}
+	}
+
+	var serviceAccount *entity.ServiceAccount
+	var secret string
+	err := manager.UnitOfWork.Do(func(repositories *Repositories) error {
+		isNameOccupied, err := repositories.ServiceAccountRepository.IsServiceAccountNameOccupied(action.Name)
+		if err != nil {
+			return err
+		}
+		if isNameOccupied {
+			return &ServiceAccountNameIsOccupied{}
+		}
+
+		serviceAccountId, err := repositories.ServiceAccountRepository.NextId()
+		if err != nil {
+			log.Println(err)
+			return IdGenerateFailure
+		}
+		now := time.Now()
+		serviceAccount = &entity.ServiceAccount{
+			Id:          serviceAccountId,
+			ClientId:    uuid.New().String(),
+			Name:        action.Name,
+			Description: action.Description,
+			Scopes:      strings.Join(action.Scopes, " "),
+
+			CreateTime:     now,
+			LastUpdateTime: now,
+		}
+		if err := repositories.ServiceAccountRepository.Save(serviceAccount); err != nil {
+			return err
+		}
+
+		_, secret, err = manager.addSecret(repositories, serviceAccountId)
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return serviceAccount, secret, nil
+}
+
+func (manager *ServiceAccountManagerImpl) DeleteServiceAccount(serviceAccountId uint64) error {
+	return manager.UnitOfWork.Do(func(repositories *Repositories) error {
+		return repositories.ServiceAccountRepository.Delete(serviceAccountId)
+	})
+}
+
+func (manager *ServiceAccountManagerImpl) RotateSecret(serviceAccountId uint64, overlap time.Duration) (*entity.ServiceAccountSecret, string, error) {
+	var secret *entity.ServiceAccountSecret
+	var plainSecret string
+	err := manager.UnitOfWork.Do(func(repositories *Repositories) error {
+		if _, err := repositories.ServiceAccountRepository.FindById(serviceAccountId); err != nil {
+			return err
+		}
+		now := time.Now()
+		if err := repositories.ServiceAccountRepository.DeleteExpiredSecrets(serviceAccountId, now); err != nil {
+			return err
+		}
+		// DATETIME keeps seconds only, the expire time is truncated rather than rounded up
+		expireTime := now.Add(overlap).Truncate(time.Second)
+		if err := repositories.ServiceAccountRepository.ExpireSecrets(serviceAccountId, expireTime); err != nil {
+			return err
+		}
+		var err error
+		secret, plainSecret, err = manager.addSecret(repositories, serviceAccountId)
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return secret, plainSecret, nil
+}
+
+func (manager *ServiceAccountManagerImpl) Authenticate(clientId, secret string) (*entity.ServiceAccount, error) {
+	var serviceAccount *entity.ServiceAccount
+	err := manager.UnitOfWork.Do(func(repositories *Repositories) error {
+		var err error
+		serviceAccount, err = repositories.ServiceAccountRepository.FindByClientId(clientId)
+		if gorm.IsRecordNotFoundError(err) {
+			return &ErrOAuthClientAuthenticationFailure{}
+		}
+		if err != nil {
+			return err
+		}
+
+		secrets, err := repositories.ServiceAccountRepository.FindSecrets(serviceAccount.Id)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, candidate := range secrets {
+			if candidate.IsValidAt(now) && matchClientSecret(candidate.HashedSecret, secret) {
+				return nil
+			}
+		}
+		return &ErrOAuthClientAuthenticationFailure{}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return serviceAccount, nil
+}
+
+func (manager *ServiceAccountManagerImpl) addSecret(repositories *Repositories, serviceAccountId uint64) (*entity.ServiceAccountSecret, string, error) {
+	plainSecret, err := util.RandomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	secretId, err := repositories.ServiceAccountRepository.NextId()
+	if err != nil {
+		log.Println(err)
+		return nil, "", IdGenerateFailure
+	}
+
+	secret := &entity.ServiceAccountSecret{
+		Id:               secretId,
+		ServiceAccountId: serviceAccountId,
+		HashedSecret:     HashClientSecret(plainSecret),
+		CreateTime:       time.Now(),
+	}
+	if err := repositories.ServiceAccountRepository.SaveSecret(secret); err != nil {
+		return nil, "", err
+	}
+	return secret, plainSecret, nil
+}
@@ -0,0 +1,96 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: hallo/domain (interfaces: ServiceAccountManager)
+
+// Package domain is a generated GoMock package.
+package domain
+
+import (
+	gomock "github.com/golang/mock/gomock"
+	entity "hallo/domain/entity"
+	reflect "reflect"
+	time "time"
+)
+
+// MockServiceAccountManager is a mock of ServiceAccountManager interface
+type MockServiceAccountManager struct {
+	ctrl     *gomock.Controller
+	recorder *MockServiceAccountManagerMockRecorder
+}
+
+// MockServiceAccountManagerMockRecorder is the mock recorder for MockServiceAccountManager
+type MockServiceAccountManagerMockRecorder struct {
+	mock *MockServiceAccountManager
+}
+
+// NewMockServiceAccountManager creates a new mock instance
+func NewMockServiceAccountManager(ctrl *gomock.Controller) *MockServiceAccountManager {
+	mock := &MockServiceAccountManager{ctrl: ctrl}
+	mock.recorder = &MockServiceAccountManagerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockServiceAccountManager) EXPECT() *MockServiceAccountManagerMockRecorder {
+	return m.recorder
+}
+
+// Authenticate mocks base method
+func (m *MockServiceAccountManager) Authenticate(arg0, arg1 string) (*entity.ServiceAccount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Authenticate", arg0, arg1)
+	ret0, _ := ret[0].(*entity.ServiceAccount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Authenticate indicates an expected call of Authenticate
+func (mr *MockServiceAccountManagerMockRecorder) Authenticate(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Authenticate", reflect.TypeOf((*MockServiceAccountManager)(nil).Authenticate), arg0, arg1)
+}
+
+// CreateServiceAccount mocks base method
+func (m *MockServiceAccountManager) CreateServiceAccount(arg0 entity.ServiceAccountCreateRequest) (*entity.ServiceAccount, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateServiceAccount", arg0)
+	ret0, _ := ret[0].(*entity.ServiceAccount)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// CreateServiceAccount indicates an expected call of CreateServiceAccount
+func (mr *MockServiceAccountManagerMockRecorder) CreateServiceAccount(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateServiceAccount", reflect.TypeOf((*MockServiceAccountManager)(nil).CreateServiceAccount), arg0)
+}
+
+// DeleteServiceAccount mocks base method
+func (m *MockServiceAccountManager) DeleteServiceAccount(arg0 uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteServiceAccount", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteServiceAccount indicates an expected call of DeleteServiceAccount
+func (mr *MockServiceAccountManagerMockRecorder) DeleteServiceAccount(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteServiceAccount", reflect.TypeOf((*MockServiceAccountManager)(nil).DeleteServiceAccount), arg0)
+}
+
+// RotateSecret mocks base method
+func (m *MockServiceAccountManager) RotateSecret(arg0 uint64, arg1 time.Duration) (*entity.ServiceAccountSecret, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RotateSecret", arg0, arg1)
+	ret0, _ := ret[0].(*entity.ServiceAccountSecret)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// RotateSecret indicates an expected call of RotateSecret
+func (mr *MockServiceAccountManagerMockRecorder) RotateSecret(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RotateSecret", reflect.TypeOf((*MockServiceAccountManager)(nil).RotateSecret), arg0, arg1)
+}
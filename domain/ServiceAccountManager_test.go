@@ -0,0 +1,82 @@
+package domain
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"hallo/domain/entity"
+	"hallo/infra"
+	"hallo/testinfra"
+	"hallo/util"
+	"testing"
+	"time"
+)
+
+func TestServiceAccountManager(it *testing.T) {
+	it.Run("should reject scopes which are not known permissions", func(t *testing.T) {
+		manager := &ServiceAccountManagerImpl{}
+		_, _, err := manager.CreateServiceAccount(entity.ServiceAccountCreateRequest{
+			Name: "nightly-job", Scopes: []string{"accounts:read", "*"}})
+		assert.Equal(t, &ErrUnknownPermission{Permission: "*"}, err)
+	})
+
+	it.Run("should create service account and authenticate old secret in overlap after rotation", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		manager := &ServiceAccountManagerImpl{
+			UnitOfWork: &DatabaseUnitOfWork{TransactionSupport: &infra.GormTransactionSupport{Database: ds.Database}, IdWorker: util.DefaultIdWorker},
+		}
+		serviceAccount, secret, err := manager.CreateServiceAccount(entity.ServiceAccountCreateRequest{
+			Name: "nightly-job", Scopes: []string{"accounts:read", "groups:read"}})
+		assert.Nil(t, err)
+		assert.NotEmpty(t, serviceAccount.ClientId)
+		assert.Equal(t, []string{"accounts:read", "groups:read"}, serviceAccount.ScopeList())
+
+		_, _, err = manager.CreateServiceAccount(entity.ServiceAccountCreateRequest{Name: "nightly-job"})
+		assert.Equal(t, &ServiceAccountNameIsOccupied{}, err)
+
+		authenticated, err := manager.Authenticate(serviceAccount.ClientId, secret)
+		assert.Nil(t, err)
+		assert.Equal(t, serviceAccount.Id, authenticated.Id)
+		_, err = manager.Authenticate(serviceAccount.ClientId, "bad")
+		assert.Equal(t, &ErrOAuthClientAuthenticationFailure{}, err)
+		_, err = manager.Authenticate("unknown", secret)
+		assert.Equal(t, &ErrOAuthClientAuthenticationFailure{}, err)
+
+		_, newSecret, err := manager.RotateSecret(serviceAccount.Id, time.Hour)
+		assert.Nil(t, err)
+		_, err = manager.Authenticate(serviceAccount.ClientId, secret)
+		assert.Nil(t, err)
+		_, err = manager.Authenticate(serviceAccount.ClientId, newSecret)
+		assert.Nil(t, err)
+
+		// the old secrets expire immediately without overlap
+		latestSecret, latestPlainSecret, err := manager.RotateSecret(serviceAccount.Id, 0)
+		assert.Nil(t, err)
+		_, err = manager.Authenticate(serviceAccount.ClientId, secret)
+		assert.Equal(t, &ErrOAuthClientAuthenticationFailure{}, err)
+		_, err = manager.Authenticate(serviceAccount.ClientId, newSecret)
+		assert.Equal(t, &ErrOAuthClientAuthenticationFailure{}, err)
+		_, err = manager.Authenticate(serviceAccount.ClientId, latestPlainSecret)
+		assert.Nil(t, err)
+		assert.Nil(t, latestSecret.ExpireTime)
+
+		// the expired secrets are deleted on the next rotation, the secrets are hashed by SHA-256
+		_, _, err = manager.RotateSecret(serviceAccount.Id, time.Hour)
+		assert.Nil(t, err)
+		repository := &DatabaseServiceAccountRepository{Database: ds.Database}
+		secrets, err := repository.FindSecrets(serviceAccount.Id)
+		assert.Nil(t, err)
+		assert.Len(t, secrets, 2)
+		assert.Equal(t, latestSecret.Id, secrets[0].Id)
+		assert.Equal(t, util.HashSha256Hex([]byte(latestPlainSecret)), secrets[0].HashedSecret)
+
+		_, _, err = manager.RotateSecret(1, time.Hour)
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+
+		assert.Nil(t, manager.DeleteServiceAccount(serviceAccount.Id))
+		_, err = manager.Authenticate(serviceAccount.ClientId, latestPlainSecret)
+		assert.Equal(t, &ErrOAuthClientAuthenticationFailure{}, err)
+		assert.True(t, gorm.IsRecordNotFoundError(manager.DeleteServiceAccount(serviceAccount.Id)))
+	})
+}
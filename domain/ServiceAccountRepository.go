@@ -0,0 +1,132 @@
+package domain
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/jinzhu/gorm"
+	"hallo/domain/entity"
+	"hallo/util"
+	"time"
+)
+
+const (
+	ServiceAccountTableName       = "service_accounts"
+	ServiceAccountSecretTableName = "service_account_secrets"
+)
+
+//go:generate mockgen -destination ServiceAccountRepository_mock.go -package domain hallo/domain ServiceAccountRepository
+type ServiceAccountRepository interface {
+	NextId() (uint64, error)
+	IsServiceAccountNameOccupied(name string) (bool, error)
+	// return (nil, gorm.ErrRecordNotFound) when service account is not found
+	FindById(id uint64) (*entity.ServiceAccount, error)
+	// return (nil, gorm.ErrRecordNotFound) when service account is not found
+	FindByClientId(clientId string) (*entity.ServiceAccount, error)
+	FindAll() ([]entity.ServiceAccount, error)
+	Save(serviceAccount *entity.ServiceAccount) error
+	// Delete deletes the service account with its secrets, return gorm.ErrRecordNotFound when it is not found
+	Delete(id uint64) error
+
+	SaveSecret(secret *entity.ServiceAccountSecret) error
+	FindSecrets(serviceAccountId uint64) ([]entity.ServiceAccountSecret, error)
+	// ExpireSecrets sets the expire time of the secrets which are valid after expireTime
+	ExpireSecrets(serviceAccountId uint64, expireTime time.Time) error
+	// DeleteExpiredSecrets deletes the secrets which are not valid at now
+	DeleteExpiredSecrets(serviceAccountId uint64, now time.Time) error
+	// DeleteSecret return gorm.ErrRecordNotFound when the secret is not found
+	DeleteSecret(serviceAccountId, secretId uint64) error
+}
+
+type DatabaseServiceAccountRepository struct {
+	IdWorker *util.IdWorker
+	Database *gorm.DB
+}
+
+func (repository *DatabaseServiceAccountRepository) NextId() (uint64, error) {
+	return repository.IdWorker.NextId()
+}
+
+func (repository *DatabaseServiceAccountRepository) IsServiceAccountNameOccupied(name string) (bool, error) {
+	var count int
+	err := repository.Database.Table(ServiceAccountTableName).Where("name = ?", name).Count(&count).Error
+	return count > 0, err
+}
+
+func (repository *DatabaseServiceAccountRepository) FindById(id uint64) (*entity.ServiceAccount, error) {
+	serviceAccount := &entity.ServiceAccount{}
+	if err := repository.Database.Table(ServiceAccountTableName).Where("id = ?", id).First(serviceAccount).Error; err != nil {
+		return nil, err
+	}
+	return serviceAccount, nil
+}
+
+func (repository *DatabaseServiceAccountRepository) FindByClientId(clientId string) (*entity.ServiceAccount, error) {
+	serviceAccount := &entity.ServiceAccount{}
+	if err := repository.Database.Table(ServiceAccountTableName).Where("client_id = ?", clientId).First(serviceAccount).Error; err != nil {
+		return nil, err
+	}
+	return serviceAccount, nil
+}
+
+func (repository *DatabaseServiceAccountRepository) FindAll() ([]entity.ServiceAccount, error) {
+	serviceAccounts := []entity.ServiceAccount{}
+	err := repository.Database.Table(ServiceAccountTableName).Order("id").Find(&serviceAccounts).Error
+	return serviceAccounts, err
+}
+
+func (repository *DatabaseServiceAccountRepository) Save(serviceAccount *entity.ServiceAccount) error {
+	if err := validator.New().Struct(serviceAccount); err != nil {
+		return err
+	}
+	return repository.Database.Save(serviceAccount).Error
+}
+
+func (repository *DatabaseServiceAccountRepository) Delete(id uint64) error {
+	if err := repository.Database.Where("service_account_id = ?", id).Delete(&entity.ServiceAccountSecret{}).Error; err != nil {
+		return err
+	}
+	db := repository.Database.Where("id = ?", id).Delete(&entity.ServiceAccount{})
+	if db.Error != nil {
+		return db.Error
+	}
+	if db.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (repository *DatabaseServiceAccountRepository) SaveSecret(secret *entity.ServiceAccountSecret) error {
+	if err := validator.New().Struct(secret); err != nil {
+		return err
+	}
+	return repository.Database.Save(secret).Error
+}
+
+func (repository *DatabaseServiceAccountRepository) FindSecrets(serviceAccountId uint64) ([]entity.ServiceAccountSecret, error) {
+	secrets := []entity.ServiceAccountSecret{}
+	err := repository.Database.Table(ServiceAccountSecretTableName).Where("service_account_id = ?", serviceAccountId).
+		Order("id").Find(&secrets).Error
+	return secrets, err
+}
+
+func (repository *DatabaseServiceAccountRepository) ExpireSecrets(serviceAccountId uint64, expireTime time.Time) error {
+	return repository.Database.Table(ServiceAccountSecretTableName).
+		Where("service_account_id = ? AND (expire_time IS NULL OR expire_time > ?)", serviceAccountId, expireTime).
+		Update("expire_time", expireTime).Error
+}
+
+func (repository *DatabaseServiceAccountRepository) DeleteExpiredSecrets(serviceAccountId uint64, now time.Time) error {
+	return repository.Database.Where("service_account_id = ? AND expire_time <= ?", serviceAccountId, now).
+		Delete(&entity.ServiceAccountSecret{}).Error
+}
+
+func (repository *DatabaseServiceAccountRepository) DeleteSecret(serviceAccountId, secretId uint64) error {
+	db := repository.Database.Where("service_account_id = ? AND id = ?", serviceAccountId, secretId).
+		Delete(&entity.ServiceAccountSecret{})
+	if db.Error != nil {
+		return db.Error
+	}
+	if db.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: hallo/domain (interfaces: ServiceAccountRepository)
+
+// Package domain is a generated GoMock package.
+package domain
+
+import (
+	gomock "github.com/golang/mock/gomock"
+	entity "hallo/domain/entity"
+	reflect "reflect"
+	time "time"
+)
+
+// MockServiceAccountRepository is a mock of ServiceAccountRepository interface
+type MockServiceAccountRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockServiceAccountRepositoryMockRecorder
+}
+
+// MockServiceAccountRepositoryMockRecorder is the mock recorder for MockServiceAccountRepository
+type MockServiceAccountRepositoryMockRecorder struct {
+	mock *MockServiceAccountRepository
+}
+
+// NewMockServiceAccountRepository creates a new mock instance
+func NewMockServiceAccountRepository(ctrl *gomock.Controller) *MockServiceAccountRepository {
+	mock := &MockServiceAccountRepository{ctrl: ctrl}
+	mock.recorder = &MockServiceAccountRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockServiceAccountRepository) EXPECT() *MockServiceAccountRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Delete mocks base method
+func (m *MockServiceAccountRepository) Delete(arg0 uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete
+func (mr *MockServiceAccountRepositoryMockRecorder) Delete(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockServiceAccountRepository)(nil).Delete), arg0)
+}
+
+// DeleteExpiredSecrets mocks base method
+func (m *MockServiceAccountRepository) DeleteExpiredSecrets(arg0 uint64, arg1 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredSecrets", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpiredSecrets indicates an expected call of DeleteExpiredSecrets
+func (mr *MockServiceAccountRepositoryMockRecorder) DeleteExpiredSecrets(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredSecrets", reflect.TypeOf((*MockServiceAccountRepository)(nil).DeleteExpiredSecrets), arg0, arg1)
+}
+
+// DeleteSecret mocks base method
+func (m *MockServiceAccountRepository) DeleteSecret(arg0, arg1 uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteSecret", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteSecret indicates an expected call of DeleteSecret
+func (mr *MockServiceAccountRepositoryMockRecorder) DeleteSecret(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSecret", reflect.TypeOf((*MockServiceAccountRepository)(nil).DeleteSecret), arg0, arg1)
+}
+
+// ExpireSecrets mocks base method
+func (m *MockServiceAccountRepository) ExpireSecrets(arg0 uint64, arg1 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpireSecrets", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExpireSecrets indicates an expected call of ExpireSecrets
+func (mr *MockServiceAccountRepositoryMockRecorder) ExpireSecrets(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpireSecrets", reflect.TypeOf((*MockServiceAccountRepository)(nil).ExpireSecrets), arg0, arg1)
+}
+
+// FindAll mocks base method
+func (m *MockServiceAccountRepository) FindAll() ([]entity.ServiceAccount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindAll")
+	ret0, _ := ret[0].([]entity.ServiceAccount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindAll indicates an expected call of FindAll
+func (mr *MockServiceAccountRepositoryMockRecorder) FindAll() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAll", reflect.TypeOf((*MockServiceAccountRepository)(nil).FindAll))
+}
+
+// FindByClientId mocks base method
+func (m *MockServiceAccountRepository) FindByClientId(arg0 string) (*entity.ServiceAccount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByClientId", arg0)
+	ret0, _ := ret[0].(*entity.ServiceAccount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByClientId indicates an expected call of FindByClientId
+func (mr *MockServiceAccountRepositoryMockRecorder) FindByClientId(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByClientId", reflect.TypeOf((*MockServiceAccountRepository)(nil).FindByClientId), arg0)
+}
+
+// FindById mocks base method
+func (m *MockServiceAccountRepository) FindById(arg0 uint64) (*entity.ServiceAccount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindById", arg0)
+	ret0, _ := ret[0].(*entity.ServiceAccount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindById indicates an expected call of FindById
+func (mr *MockServiceAccountRepositoryMockRecorder) FindById(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindById", reflect.TypeOf((*MockServiceAccountRepository)(nil).FindById), arg0)
+}
+
+// FindSecrets mocks base method
+func (m *MockServiceAccountRepository) FindSecrets(arg0 uint64) ([]entity.ServiceAccountSecret, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindSecrets", arg0)
+	ret0, _ := ret[0].([]entity.ServiceAccountSecret)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindSecrets indicates an expected call of FindSecrets
+func (mr *MockServiceAccountRepositoryMockRecorder) FindSecrets(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindSecrets", reflect.TypeOf((*MockServiceAccountRepository)(nil).FindSecrets), arg0)
+}
+
+// IsServiceAccountNameOccupied mocks base method
+func (m *MockServiceAccountRepository) IsServiceAccountNameOccupied(arg0 string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsServiceAccountNameOccupied", arg0)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsServiceAccountNameOccupied indicates an expected call of IsServiceAccountNameOccupied
+func (mr *MockServiceAccountRepositoryMockRecorder) IsServiceAccountNameOccupied(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsServiceAccountNameOccupied", reflect.TypeOf((*MockServiceAccountRepository)(nil).IsServiceAccountNameOccupied), arg0)
+}
+
+// NextId mocks base method
+func (m *MockServiceAccountRepository) NextId() (uint64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NextId")
+	ret0, _ := ret[0].(uint64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// NextId indicates an expected call of NextId
+func (mr *MockServiceAccountRepositoryMockRecorder) NextId() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NextId", reflect.TypeOf((*MockServiceAccountRepository)(nil).NextId))
+}
+
+// Save mocks base method
+func (m *MockServiceAccountRepository) Save(arg0 *entity.ServiceAccount) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Save", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Save indicates an expected call of Save
+func (mr *MockServiceAccountRepositoryMockRecorder) Save(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockServiceAccountRepository)(nil).Save), arg0)
+}
+
+// SaveSecret mocks base method
+func (m *MockServiceAccountRepository) SaveSecret(arg0 *entity.ServiceAccountSecret) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveSecret", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveSecret indicates an expected call of SaveSecret
+func (mr *MockServiceAccountRepositoryMockRecorder) SaveSecret(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveSecret", reflect.TypeOf((*MockServiceAccountRepository)(nil).SaveSecret), arg0)
+}
@@ -0,0 +1,101 @@
+package domain
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// the parameters of RFC 6238 which are supported by the common authenticator apps
+const (
+	TotpPeriod = 30 * time.Second
+	TotpDigits = 6
+	// TotpSkew is the number of steps before and after the current one in which the codes are accepted as well,
+	// it tolerates the clock drift of devices
+	TotpSkew = 1
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTotpSecret returns the base32 encoding of 160 random bits, as RFC 4226 recommends for HMAC-SHA1
+func GenerateTotpSecret() (string, error) {
+	key := make([]byte, 20)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(key), nil
+}
+
+// TotpUri is the key URI of authenticator apps, it is usually shown as QR code.
+// See https://github.com/google/google-authenticator/wiki/Key-Uri-Format
+func TotpUri(issuer, accountName, secret string) string {
+	query := url.Values{
+		"secret":    {secret},
+		"issuer":    {issuer},
+		"algorithm": {"SHA1"},
+		"digits":    {fmt.Sprint(TotpDigits)},
+		"period":    {fmt.Sprint(int(TotpPeriod.Seconds()))},
+	}
+	label := url.PathEscape(issuer + ":" + accountName)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// TotpStep is the time step of RFC 6238 at t
+func TotpStep(t time.Time) int64 {
+	return t.Unix() / int64(TotpPeriod.Seconds())
+}
+
+// TotpCode returns the code of secret at the time step
+func TotpCode(secret string, step int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, uint64(step), TotpDigits), nil
+}
+
+// MatchTotp returns the step of code when it is the code of secret in the steps around t, only the steps after
+// lastStep are accepted so that each code is used once
+func MatchTotp(secret, code string, t time.Time, lastStep int64) (int64, bool) {
+	if len(code) != TotpDigits {
+		return 0, false
+	}
+	current := TotpStep(t)
+	for step := current - TotpSkew; step <= current+TotpSkew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		expected, err := TotpCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// hotp is the HMAC-SHA1 one time password of RFC 4226
+func hotp(key []byte, counter uint64, digits int) string {
+	message := make([]byte, 8)
+	binary.BigEndian.PutUint64(message, counter)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(message)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	modulo := uint32(1)
+	for i := 0; i < digits; i++ {
+		modulo *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%modulo)
+}
@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/jinzhu/gorm"
+	"hallo/domain/entity"
+	"time"
+)
+
+//go:generate mockgen -destination TotpFactorRepository_mock.go -package domain hallo/domain TotpFactorRepository
+type TotpFactorRepository interface {
+	// return (nil, gorm.ErrRecordNotFound) when account has no TOTP factor
+	FindByAccountId(accountId uint64) (*entity.TotpFactor, error)
+	// Save replaces the factor of account
+	Save(factor *entity.TotpFactor) error
+	// Accept moves LastStep of the factor forward to step, return false when the factor is absent or
+	// the step has been accepted already, e.g. the same code is used concurrently
+	Accept(accountId uint64, step int64, confirm bool) (bool, error)
+	// Fail counts a refused code, the factor is locked until lockUntil and the count restarts when it reaches limit
+	Fail(accountId uint64, limit int, lockUntil time.Time) error
+	// ResetFailures clears the count of refused codes
+	ResetFailures(accountId uint64) error
+	Delete(accountId uint64) error
+}
+
+type DatabaseTotpFactorRepository struct {
+	Database *gorm.DB
+}
+
+func (repository *DatabaseTotpFactorRepository) FindByAccountId(accountId uint64) (*entity.TotpFactor, error) {
+	factor := &entity.TotpFactor{}
+	if err := repository.Database.Where("account_id = ?", accountId).First(factor).Error; err != nil {
+		return nil, err
+	}
+	return factor, nil
+}
+
+func (repository *DatabaseTotpFactorRepository) Save(factor *entity.TotpFactor) error {
+	if err := validator.New().Struct(factor); err != nil {
+		return err
+	}
+	return repository.Database.Save(factor).Error
+}
+
+func (repository *DatabaseTotpFactorRepository) Accept(accountId uint64, step int64, confirm bool) (bool, error) {
+	fields := map[string]interface{}{"last_step": step}
+	if confirm {
+		fields["confirmed"] = true
+	}
+	db := repository.Database.Model(&entity.TotpFactor{}).Where("account_id = ? AND last_step < ?", accountId, step).
+		Updates(fields)
+	return db.RowsAffected > 0, db.Error
+}
+
+func (repository *DatabaseTotpFactorRepository) Fail(accountId uint64, limit int, lockUntil time.Time) error {
+	if err := repository.Database.Model(&entity.TotpFactor{}).Where("account_id = ?", accountId).
+		Update("failed_attempts", gorm.Expr("failed_attempts + 1")).Error; err != nil {
+		return err
+	}
+	return repository.Database.Model(&entity.TotpFactor{}).Where("account_id = ? AND failed_attempts >= ?", accountId, limit).
+		Updates(map[string]interface{}{"failed_attempts": 0, "locked_until": lockUntil}).Error
+}
+
+func (repository *DatabaseTotpFactorRepository) ResetFailures(accountId uint64) error {
+	return repository.Database.Model(&entity.TotpFactor{}).Where("account_id = ?", accountId).
+		Update("failed_attempts", 0).Error
+}
+
+func (repository *DatabaseTotpFactorRepository) Delete(accountId uint64) error {
+	return repository.Database.Where("account_id = ?", accountId).Delete(&entity.TotpFactor{}).Error
+}
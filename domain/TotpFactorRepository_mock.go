@@ -0,0 +1,121 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: hallo/domain (interfaces: TotpFactorRepository)
+
+// Package domain is a generated GoMock package.
+package domain
+
+import (
+	gomock "github.com/golang/mock/gomock"
+	entity "hallo/domain/entity"
+	reflect "reflect"
+	time "time"
+)
+
+// MockTotpFactorRepository is a mock of TotpFactorRepository interface
+type MockTotpFactorRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockTotpFactorRepositoryMockRecorder
+}
+
+// MockTotpFactorRepositoryMockRecorder is the mock recorder for MockTotpFactorRepository
+type MockTotpFactorRepositoryMockRecorder struct {
+	mock *MockTotpFactorRepository
+}
+
+// NewMockTotpFactorRepository creates a new mock instance
+func NewMockTotpFactorRepository(ctrl *gomock.Controller) *MockTotpFactorRepository {
+	mock := &MockTotpFactorRepository{ctrl: ctrl}
+	mock.recorder = &MockTotpFactorRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockTotpFactorRepository) EXPECT() *MockTotpFactorRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Accept mocks base method
+func (m *MockTotpFactorRepository) Accept(arg0 uint64, arg1 int64, arg2 bool) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Accept", arg0, arg1, arg2)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Accept indicates an expected call of Accept
+func (mr *MockTotpFactorRepositoryMockRecorder) Accept(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Accept", reflect.TypeOf((*MockTotpFactorRepository)(nil).Accept), arg0, arg1, arg2)
+}
+
+// Delete mocks base method
+func (m *MockTotpFactorRepository) Delete(arg0 uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete
+func (mr *MockTotpFactorRepositoryMockRecorder) Delete(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockTotpFactorRepository)(nil).Delete), arg0)
+}
+
+// Fail mocks base method
+func (m *MockTotpFactorRepository) Fail(arg0 uint64, arg1 int, arg2 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Fail", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Fail indicates an expected call of Fail
+func (mr *MockTotpFactorRepositoryMockRecorder) Fail(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Fail", reflect.TypeOf((*MockTotpFactorRepository)(nil).Fail), arg0, arg1, arg2)
+}
+
+// FindByAccountId mocks base method
+func (m *MockTotpFactorRepository) FindByAccountId(arg0 uint64) (*entity.TotpFactor, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByAccountId", arg0)
+	ret0, _ := ret[0].(*entity.TotpFactor)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByAccountId indicates an expected call of FindByAccountId
+func (mr *MockTotpFactorRepositoryMockRecorder) FindByAccountId(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByAccountId", reflect.TypeOf((*MockTotpFactorRepository)(nil).FindByAccountId), arg0)
+}
+
+// ResetFailures mocks base method
+func (m *MockTotpFactorRepository) ResetFailures(arg0 uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetFailures", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResetFailures indicates an expected call of ResetFailures
+func (mr *MockTotpFactorRepositoryMockRecorder) ResetFailures(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetFailures", reflect.TypeOf((*MockTotpFactorRepository)(nil).ResetFailures), arg0)
+}
+
+// Save mocks base method
+func (m *MockTotpFactorRepository) Save(arg0 *entity.TotpFactor) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Save", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Save indicates an expected call of Save
+func (mr *MockTotpFactorRepositoryMockRecorder) Save(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockTotpFactorRepository)(nil).Save), arg0)
+}
@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"hallo/domain/entity"
+	"hallo/testinfra"
+	"testing"
+	"time"
+)
+
+func TestDatabaseTotpFactorRepository(it *testing.T) {
+	it.Run("should accept each step once", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		repository := &DatabaseTotpFactorRepository{Database: ds.Database}
+		_, err := repository.FindByAccountId(123)
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+		assert.Nil(t, repository.Save(&entity.TotpFactor{AccountId: 123, Secret: "JBSWY3DPEHPK3PXP", CreateTime: time.Now()}))
+
+		accepted, err := repository.Accept(123, 100, true)
+		assert.Nil(t, err)
+		assert.True(t, accepted)
+		accepted, err = repository.Accept(123, 100, false)
+		assert.Nil(t, err)
+		assert.False(t, accepted)
+		accepted, err = repository.Accept(456, 100, false)
+		assert.Nil(t, err)
+		assert.False(t, accepted)
+
+		found, err := repository.FindByAccountId(123)
+		assert.Nil(t, err)
+		assert.True(t, found.Confirmed)
+		assert.Equal(t, int64(100), found.LastStep)
+
+		assert.Nil(t, repository.Fail(123, 2, time.Now().Add(time.Minute)))
+		found, err = repository.FindByAccountId(123)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, found.FailedAttempts)
+		assert.Nil(t, found.LockedUntil)
+		assert.Nil(t, repository.Fail(123, 2, time.Now().Add(time.Minute)))
+		found, err = repository.FindByAccountId(123)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, found.FailedAttempts)
+		assert.True(t, found.LockedUntil.After(time.Now()))
+		assert.Nil(t, repository.Fail(123, 2, time.Now().Add(time.Minute)))
+		assert.Nil(t, repository.ResetFailures(123))
+		found, err = repository.FindByAccountId(123)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, found.FailedAttempts)
+
+		assert.Nil(t, repository.Delete(123))
+		_, err = repository.FindByAccountId(123)
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+	})
+}
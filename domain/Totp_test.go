@@ -0,0 +1,68 @@
+package domain
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestHotp(it *testing.T) {
+	it.Run("should generate codes of RFC 6238 test vectors", func(t *testing.T) {
+		key := []byte("12345678901234567890")
+		cases := map[int64]string{
+			59:          "94287082",
+			1111111109:  "07081804",
+			1111111111:  "14050471",
+			1234567890:  "89005924",
+			2000000000:  "69279037",
+			20000000000: "65353130",
+		}
+		for seconds, code := range cases {
+			assert.Equal(t, code, hotp(key, uint64(TotpStep(time.Unix(seconds, 0))), 8))
+		}
+	})
+}
+
+func TestMatchTotp(it *testing.T) {
+	it.Run("should match codes in the steps around now once", func(t *testing.T) {
+		secret, err := GenerateTotpSecret()
+		assert.Nil(t, err)
+		assert.Len(t, secret, 32)
+
+		now := time.Now()
+		step := TotpStep(now)
+		code, err := TotpCode(secret, step)
+		assert.Nil(t, err)
+		matched, ok := MatchTotp(secret, code, now, 0)
+		assert.True(t, ok)
+		assert.Equal(t, step, matched)
+		_, ok = MatchTotp(secret, code, now, step)
+		assert.False(t, ok)
+
+		previous, err := TotpCode(secret, step-1)
+		assert.Nil(t, err)
+		matched, ok = MatchTotp(secret, previous, now, 0)
+		assert.True(t, ok)
+		assert.Equal(t, step-1, matched)
+
+		stale, err := TotpCode(secret, step-2)
+		assert.Nil(t, err)
+		_, ok = MatchTotp(secret, stale, now, 0)
+		assert.False(t, ok)
+		_, ok = MatchTotp(secret, "12345", now, 0)
+		assert.False(t, ok)
+	})
+
+	it.Run("should build key uri of authenticator apps", func(t *testing.T) {
+		uri, err := url.Parse(TotpUri("hallo", "ann", "JBSWY3DPEHPK3PXP"))
+		assert.Nil(t, err)
+		assert.Equal(t, "otpauth", uri.Scheme)
+		assert.Equal(t, "totp", uri.Host)
+		assert.Equal(t, "/hallo:ann", uri.Path)
+		assert.Equal(t, "JBSWY3DPEHPK3PXP", uri.Query().Get("secret"))
+		assert.Equal(t, "hallo", uri.Query().Get("issuer"))
+		assert.Equal(t, "6", uri.Query().Get("digits"))
+		assert.Equal(t, "30", uri.Query().Get("period"))
+	})
+}
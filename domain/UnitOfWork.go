@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"github.com/jinzhu/gorm"
+	"hallo/infra"
+	"hallo/util"
+)
+
+// Repositories taking part in the same unit of work
+type Repositories struct {
+	AccountRepository            AccountRepository
+	IdentityBindingRepository    IdentityBindingRepository
+	InternalIdentityRepository   InternalIdentityRepository
+	RoleRepository               RoleRepository
+	GroupRepository              GroupRepository
+	OrganizationRepository       OrganizationRepository
+	ServiceAccountRepository     ServiceAccountRepository
+	TotpFactorRepository         TotpFactorRepository
+	RecoveryCodeRepository       RecoveryCodeRepository
+	WebAuthnCredentialRepository WebAuthnCredentialRepository
+	PasswordResetTokenRepository PasswordResetTokenRepository
+}
+
+type UnitOfWork interface {
+	// Do runs work atomically, all changes made through the repositories are discarded when work returns an error
+	Do(work func(repositories *Repositories) error) error
+}
+
+// DatabaseUnitOfWork hands repositories bound to a database transaction
+type DatabaseUnitOfWork struct {
+	TransactionSupport infra.TransactionSupport
+	IdWorker           *util.IdWorker
+	PasswordHasher     PasswordHasher
+
+	// Decorate is able to replace the repositories bound to the transaction, e.g. inject failures in tests
+	Decorate func(repositories *Repositories)
+}
+
+func (unitOfWork *DatabaseUnitOfWork) Do(work func(repositories *Repositories) error) error {
+	return unitOfWork.TransactionSupport.Transactional(func(tx *gorm.DB) error {
+		repositories := &Repositories{
+			AccountRepository:            &DatabaseAccountRepository{IdWorker: unitOfWork.IdWorker, Database: tx},
+			IdentityBindingRepository:    &DatabaseIdentityBindingRepository{Database: tx},
+			InternalIdentityRepository:   &DatabaseInternalIdentityRepository{Database: tx, PasswordHasher: unitOfWork.PasswordHasher},
+			RoleRepository:               &DatabaseRoleRepository{Database: tx},
+			GroupRepository:              &DatabaseGroupRepository{IdWorker: unitOfWork.IdWorker, Database: tx},
+			OrganizationRepository:       &DatabaseOrganizationRepository{IdWorker: unitOfWork.IdWorker, Database: tx},
+			ServiceAccountRepository:     &DatabaseServiceAccountRepository{IdWorker: unitOfWork.IdWorker, Database: tx},
+			TotpFactorRepository:         &DatabaseTotpFactorRepository{Database: tx},
+			RecoveryCodeRepository:       &DatabaseRecoveryCodeRepository{Database: tx},
+			WebAuthnCredentialRepository: &DatabaseWebAuthnCredentialRepository{Database: tx},
+			PasswordResetTokenRepository: &DatabasePasswordResetTokenRepository{Database: tx},
+		}
+		if unitOfWork.Decorate != nil {
+			unitOfWork.Decorate(repositories)
+		}
+		return work(repositories)
+	})
+}
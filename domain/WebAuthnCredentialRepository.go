@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/jinzhu/gorm"
+	"hallo/domain/entity"
+	"time"
+)
+
+//go:generate mockgen -destination WebAuthnCredentialRepository_mock.go -package domain hallo/domain WebAuthnCredentialRepository
+type WebAuthnCredentialRepository interface {
+	// return (nil, gorm.ErrRecordNotFound) when credential is not found
+	FindById(id string) (*entity.WebAuthnCredential, error)
+	FindByAccountId(accountId uint64) ([]entity.WebAuthnCredential, error)
+	Create(credential *entity.WebAuthnCredential) error
+	// UpdateSignCount sets the sign count of credential when it is still previous, return false when the count has
+	// been changed by another assertion
+	UpdateSignCount(id string, previous, current uint32, usedTime time.Time) (bool, error)
+	// Delete return gorm.ErrRecordNotFound when the account has no credential of id
+	Delete(accountId uint64, id string) error
+	DeleteByAccountId(accountId uint64) error
+}
+
+type DatabaseWebAuthnCredentialRepository struct {
+	Database *gorm.DB
+}
+
+func (repository *DatabaseWebAuthnCredentialRepository) FindById(id string) (*entity.WebAuthnCredential, error) {
+	credential := &entity.WebAuthnCredential{}
+	if err := repository.Database.Where("id = ?", id).First(credential).Error; err != nil {
+		return nil, err
+	}
+	return credential, nil
+}
+
+func (repository *DatabaseWebAuthnCredentialRepository) FindByAccountId(accountId uint64) ([]entity.WebAuthnCredential, error) {
+	credentials := []entity.WebAuthnCredential{}
+	err := repository.Database.Where("account_id = ?", accountId).Order("create_time").Find(&credentials).Error
+	return credentials, err
+}
+
+func (repository *DatabaseWebAuthnCredentialRepository) Create(credential *entity.WebAuthnCredential) error {
+	if err := validator.New().Struct(credential); err != nil {
+		return err
+	}
+	return repository.Database.Create(credential).Error
+}
+
+func (repository *DatabaseWebAuthnCredentialRepository) UpdateSignCount(id string, previous, current uint32, usedTime time.Time) (bool, error) {
+	// the authenticators without counter always sign zero, the update changes no row of them when it is used twice in
+	// the same second, so the count is not compared by RowsAffected but by the row found in the transaction
+	updated := false
+	err := repository.Database.Transaction(func(tx *gorm.DB) error {
+		credential := entity.WebAuthnCredential{}
+		err := tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", id).First(&credential).Error
+		if gorm.IsRecordNotFoundError(err) || (err == nil && credential.SignCount != previous) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&entity.WebAuthnCredential{}).Where("id = ?", id).
+			Updates(map[string]interface{}{"sign_count": current, "last_used_time": usedTime}).Error; err != nil {
+			return err
+		}
+		updated = true
+		return nil
+	})
+	return updated, err
+}
+
+func (repository *DatabaseWebAuthnCredentialRepository) Delete(accountId uint64, id string) error {
+	db := repository.Database.Where("account_id = ? AND id = ?", accountId, id).Delete(&entity.WebAuthnCredential{})
+	if db.Error != nil {
+		return db.Error
+	}
+	if db.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (repository *DatabaseWebAuthnCredentialRepository) DeleteByAccountId(accountId uint64) error {
+	return repository.Database.Where("account_id = ?", accountId).Delete(&entity.WebAuthnCredential{}).Error
+}
@@ -0,0 +1,122 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: hallo/domain (interfaces: WebAuthnCredentialRepository)
+
+// Package domain is a generated GoMock package.
+package domain
+
+import (
+	gomock "github.com/golang/mock/gomock"
+	entity "hallo/domain/entity"
+	reflect "reflect"
+	time "time"
+)
+
+// MockWebAuthnCredentialRepository is a mock of WebAuthnCredentialRepository interface
+type MockWebAuthnCredentialRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockWebAuthnCredentialRepositoryMockRecorder
+}
+
+// MockWebAuthnCredentialRepositoryMockRecorder is the mock recorder for MockWebAuthnCredentialRepository
+type MockWebAuthnCredentialRepositoryMockRecorder struct {
+	mock *MockWebAuthnCredentialRepository
+}
+
+// NewMockWebAuthnCredentialRepository creates a new mock instance
+func NewMockWebAuthnCredentialRepository(ctrl *gomock.Controller) *MockWebAuthnCredentialRepository {
+	mock := &MockWebAuthnCredentialRepository{ctrl: ctrl}
+	mock.recorder = &MockWebAuthnCredentialRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockWebAuthnCredentialRepository) EXPECT() *MockWebAuthnCredentialRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method
+func (m *MockWebAuthnCredentialRepository) Create(arg0 *entity.WebAuthnCredential) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Create indicates an expected call of Create
+func (mr *MockWebAuthnCredentialRepositoryMockRecorder) Create(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockWebAuthnCredentialRepository)(nil).Create), arg0)
+}
+
+// Delete mocks base method
+func (m *MockWebAuthnCredentialRepository) Delete(arg0 uint64, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete
+func (mr *MockWebAuthnCredentialRepositoryMockRecorder) Delete(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockWebAuthnCredentialRepository)(nil).Delete), arg0, arg1)
+}
+
+// DeleteByAccountId mocks base method
+func (m *MockWebAuthnCredentialRepository) DeleteByAccountId(arg0 uint64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteByAccountId", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteByAccountId indicates an expected call of DeleteByAccountId
+func (mr *MockWebAuthnCredentialRepositoryMockRecorder) DeleteByAccountId(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteByAccountId", reflect.TypeOf((*MockWebAuthnCredentialRepository)(nil).DeleteByAccountId), arg0)
+}
+
+// FindByAccountId mocks base method
+func (m *MockWebAuthnCredentialRepository) FindByAccountId(arg0 uint64) ([]entity.WebAuthnCredential, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByAccountId", arg0)
+	ret0, _ := ret[0].([]entity.WebAuthnCredential)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByAccountId indicates an expected call of FindByAccountId
+func (mr *MockWebAuthnCredentialRepositoryMockRecorder) FindByAccountId(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByAccountId", reflect.TypeOf((*MockWebAuthnCredentialRepository)(nil).FindByAccountId), arg0)
+}
+
+// FindById mocks base method
+func (m *MockWebAuthnCredentialRepository) FindById(arg0 string) (*entity.WebAuthnCredential, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindById", arg0)
+	ret0, _ := ret[0].(*entity.WebAuthnCredential)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindById indicates an expected call of FindById
+func (mr *MockWebAuthnCredentialRepositoryMockRecorder) FindById(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindById", reflect.TypeOf((*MockWebAuthnCredentialRepository)(nil).FindById), arg0)
+}
+
+// UpdateSignCount mocks base method
+func (m *MockWebAuthnCredentialRepository) UpdateSignCount(arg0 string, arg1, arg2 uint32, arg3 time.Time) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateSignCount", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateSignCount indicates an expected call of UpdateSignCount
+func (mr *MockWebAuthnCredentialRepositoryMockRecorder) UpdateSignCount(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSignCount", reflect.TypeOf((*MockWebAuthnCredentialRepository)(nil).UpdateSignCount), arg0, arg1, arg2, arg3)
+}
@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"hallo/domain/entity"
+	"hallo/testinfra"
+	"testing"
+	"time"
+)
+
+func TestDatabaseWebAuthnCredentialRepository(it *testing.T) {
+	it.Run("should create credentials and update sign count only from the previous one", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		repository := &DatabaseWebAuthnCredentialRepository{Database: ds.Database}
+		credential := &entity.WebAuthnCredential{Id: "c1", AccountId: 123, Name: "laptop", CredentialId: "AQID",
+			PublicKey: []byte{1, 2, 3}, AttestationType: "none", SignCount: 1, CreateTime: time.Now()}
+		assert.Nil(t, repository.Create(credential))
+		assert.NotNil(t, repository.Create(credential))
+
+		updated, err := repository.UpdateSignCount("c1", 1, 2, time.Now())
+		assert.Nil(t, err)
+		assert.True(t, updated)
+		updated, err = repository.UpdateSignCount("c1", 1, 3, time.Now())
+		assert.Nil(t, err)
+		assert.False(t, updated)
+
+		found, err := repository.FindById("c1")
+		assert.Nil(t, err)
+		assert.Equal(t, uint32(2), found.SignCount)
+		assert.NotNil(t, found.LastUsedTime)
+		credentials, err := repository.FindByAccountId(123)
+		assert.Nil(t, err)
+		assert.Len(t, credentials, 1)
+
+		assert.True(t, gorm.IsRecordNotFoundError(repository.Delete(456, "c1")))
+		assert.Nil(t, repository.Delete(123, "c1"))
+		_, err = repository.FindById("c1")
+		assert.True(t, gorm.IsRecordNotFoundError(err))
+	})
+	it.Run("should update the credential of which sign count is always zero in the same second", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		repository := &DatabaseWebAuthnCredentialRepository{Database: ds.Database}
+		credential := &entity.WebAuthnCredential{Id: "c1", AccountId: 123, Name: "key", CredentialId: "AQID",
+			PublicKey: []byte{1, 2, 3}, AttestationType: "none", CreateTime: time.Now()}
+		assert.Nil(t, repository.Create(credential))
+
+		usedTime := time.Now().Truncate(time.Second)
+		for i := 0; i < 2; i++ {
+			updated, err := repository.UpdateSignCount("c1", 0, 0, usedTime)
+			assert.Nil(t, err)
+			assert.True(t, updated)
+		}
+		updated, err := repository.UpdateSignCount("c2", 0, 0, usedTime)
+		assert.Nil(t, err)
+		assert.False(t, updated)
+	})
+}
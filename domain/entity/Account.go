@@ -2,17 +2,45 @@ package entity
 
 import "time"
 
+// Account belongs to the organization of OrganizationId, zero is the default organization. Name is unique in organization.
+// EmailVerified is true when the account has proven the ownership of Email, e.g. by the register token sent to it
 type Account struct {
-	Id    uint64 `json:"id"    validate:"required"         gorm:"type:bigint;primary_key"                     pact:"example=10"`
-	Name  string `json:"name"  validate:"required"         gorm:"type:nvarchar(127);unique;not null"          pact:"example=Sally"`
-	Email string `json:"email" validate:"required,email"   gorm:"type:varchar(127);unique;not null"                    pact:"example=ann@test.com"`
+	Id             uint64 `json:"id"             validate:"required"         gorm:"type:bigint;primary_key"                                         pact:"example=10"`
+	OrganizationId uint64 `json:"organizationId"                             gorm:"type:bigint;not null;default:0;unique_index:idx_organization_name"`
+	Name           string `json:"name"           validate:"required"         gorm:"type:nvarchar(127);not null;unique_index:idx_organization_name"  pact:"example=Sally"`
+	Email          string `json:"email"          validate:"required,email"   gorm:"type:varchar(127);unique;not null"                               pact:"example=ann@test.com"`
+	EmailVerified  bool   `json:"emailVerified"                              gorm:"not null;default:false"`
 
 	CreateTime     time.Time `json:"createTime"     validate:"required"    gorm:"type:DATETIME;not null"`
 	LastUpdateTime time.Time `json:"lastUpdateTime" validate:"required"    grom:"type:DATETIME;not null"`
 }
 
+// EmailAccountCreateRequest creates account in the organization of name Organization, empty for the default organization
 type EmailAccountCreateRequest struct {
-	Email  string `json:"email"   validate:"required,email"  pact:"example=ann@test.com"`
-	Name   string `json:"name"    validate:"required"        pact:"example=Sally"`
-	Secret string `json:"secret"  validate:"required"   binding:"required"`
+	Email        string `json:"email"   validate:"required,email"  pact:"example=ann@test.com"`
+	Name         string `json:"name"    validate:"required"        pact:"example=Sally"`
+	Secret       string `json:"secret"  validate:"required"   binding:"required"`
+	Organization string `json:"organization"`
+	// EmailVerified should be true only when the ownership of Email has been proven
+	EmailVerified bool `json:"emailVerified"`
+}
+
+// AccountUpdateRequest changes the non-empty fields only, the changed email is not verified any more
+type AccountUpdateRequest struct {
+	Name  string `json:"name"    validate:"omitempty"`
+	Email string `json:"email"   validate:"omitempty,email"`
+}
+
+// AccountQuery filters accounts and pages them by id, which grows with creation time.
+// Zero valued fields are ignored.
+type AccountQuery struct {
+	NamePrefix    string
+	EmailPrefix   string
+	CreatedAfter  time.Time // inclusive
+	CreatedBefore time.Time // exclusive
+
+	// Cursor is the id of the last account of the previous page
+	Cursor     uint64
+	Descending bool
+	Limit      int
 }
@@ -0,0 +1,20 @@
+package entity
+
+import "time"
+
+// DeviceAuthorization is the request of device authorization grant (RFC 8628), the device code is kept by its hash.
+// Principal is the JSON of the principal approved, PollInterval is the least seconds between two polls of the device
+type DeviceAuthorization struct {
+	HashedDeviceCode string     `validate:"required" gorm:"type:varchar(64);primary_key"`
+	UserCode         string     `validate:"required" gorm:"type:varchar(16);unique;not null"`
+	ClientId         string     `validate:"required" gorm:"type:varchar(64);not null"`
+	Scope            string     `gorm:"type:text;not null"`
+	Status           string     `validate:"required" gorm:"type:varchar(16);not null"`
+	Principal        string     `gorm:"type:text;not null"`
+	PollInterval     int        `validate:"required" gorm:"not null"`
+	LastPollTime     *time.Time `gorm:"type:DATETIME"`
+	DecideTime       *time.Time `gorm:"type:DATETIME"`
+
+	ExpireTime time.Time `validate:"required" gorm:"type:DATETIME;index;not null"`
+	CreateTime time.Time `validate:"required" gorm:"type:DATETIME;not null"`
+}
@@ -0,0 +1,10 @@
+package entity
+
+// ExternalIdentity is the account of upstream identity provider, Email is trusted only when EmailVerified
+type ExternalIdentity struct {
+	ProviderId        string `validate:"required"`
+	ProviderAccountId string `validate:"required"`
+	Name              string
+	Email             string
+	EmailVerified     bool
+}
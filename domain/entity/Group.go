@@ -0,0 +1,38 @@
+package entity
+
+import "time"
+
+// Group is nested under the group of ParentId, top level groups have zero ParentId
+type Group struct {
+	Id          uint64 `json:"id"          validate:"required"   gorm:"type:bigint;primary_key"`
+	Name        string `json:"name"        validate:"required"   gorm:"type:nvarchar(127);unique;not null"`
+	Description string `json:"description"                       gorm:"type:nvarchar(255);not null"`
+	ParentId    uint64 `json:"parentId"                          gorm:"type:bigint;index;not null"`
+
+	CreateTime     time.Time `json:"createTime"     validate:"required"    gorm:"type:DATETIME;not null"`
+	LastUpdateTime time.Time `json:"lastUpdateTime" validate:"required"    gorm:"type:DATETIME;not null"`
+}
+
+// TableName avoids "groups", which is a reserved word of MySQL 8
+func (Group) TableName() string {
+	return "user_groups"
+}
+
+type GroupMember struct {
+	GroupId    uint64    `validate:"required" gorm:"type:bigint;primary_key"`
+	AccountId  uint64    `validate:"required" gorm:"type:bigint;primary_key;index"`
+	CreateTime time.Time `validate:"required" gorm:"type:DATETIME;not null"`
+}
+
+type GroupCreateRequest struct {
+	Name        string `json:"name"         validate:"required"`
+	Description string `json:"description"`
+	ParentId    uint64 `json:"parentId"`
+}
+
+// GroupUpdateRequest changes the non-empty fields only, zero ParentId moves the group to top level
+type GroupUpdateRequest struct {
+	Name        string  `json:"name"`
+	Description *string `json:"description"`
+	ParentId    *uint64 `json:"parentId"`
+}
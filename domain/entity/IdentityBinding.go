@@ -3,9 +3,9 @@ package entity
 import "time"
 
 type IdentityBinding struct {
-	ProviderAccountId string `validate:"required" gorm:"type:nvarchar(127);primary_key"`
-	ProviderId        string `validate:"required" gorm:"type:nvarchar(127);primary_key"`
-	AccountId         uint64 `validate:"required" gorm:"type:bigint;primary_key"`
+	ProviderAccountId string `json:"providerAccountId" validate:"required" gorm:"type:nvarchar(127);primary_key"`
+	ProviderId        string `json:"providerId"        validate:"required" gorm:"type:nvarchar(127);primary_key"`
+	AccountId         uint64 `json:"accountId"         validate:"required" gorm:"type:bigint;primary_key"`
 
-	CreateTime time.Time `validate:"required" grom:"type:DATETIME;not null"`
+	CreateTime time.Time `json:"createTime" validate:"required" grom:"type:DATETIME;not null"`
 }
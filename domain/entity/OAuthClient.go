@@ -0,0 +1,53 @@
+package entity
+
+import (
+	"strings"
+	"time"
+)
+
+// OAuthClient is an application registered for OAuth2, public clients such as mobile apps have no secret.
+// RedirectUris are separated by space, which is not allowed in URIs.
+// Scopes are the space separated permissions which the client is allowed to request
+type OAuthClient struct {
+	Id           string `json:"id"           validate:"required"   gorm:"type:varchar(64);primary_key"`
+	Name         string `json:"name"         validate:"required"   gorm:"type:nvarchar(127);not null"`
+	HashedSecret string `json:"-"                                  gorm:"type:varchar(255);not null"`
+	RedirectUris string `json:"-"            validate:"required"   gorm:"type:text;not null"`
+	Scopes       string `json:"-"                                  gorm:"type:text;not null"`
+
+	CreateTime     time.Time `json:"createTime"     validate:"required"    gorm:"type:DATETIME;not null"`
+	LastUpdateTime time.Time `json:"lastUpdateTime" validate:"required"    gorm:"type:DATETIME;not null"`
+}
+
+func (OAuthClient) TableName() string {
+	return "oauth_clients"
+}
+
+func (client *OAuthClient) IsPublic() bool {
+	return client.HashedSecret == ""
+}
+
+func (client *OAuthClient) RedirectUriList() []string {
+	return strings.Fields(client.RedirectUris)
+}
+
+// AllowsRedirectUri compares the uri with the registered ones exactly
+func (client *OAuthClient) AllowsRedirectUri(uri string) bool {
+	for _, registered := range client.RedirectUriList() {
+		if registered == uri {
+			return true
+		}
+	}
+	return false
+}
+
+func (client *OAuthClient) ScopeList() []string {
+	return strings.Fields(client.Scopes)
+}
+
+type OAuthClientCreateRequest struct {
+	Name         string   `json:"name"         validate:"required"`
+	RedirectUris []string `json:"redirectUris" validate:"required,min=1,dive,url,excludes= "`
+	Public       bool     `json:"public"`
+	Scopes       []string `json:"scopes"       validate:"dive,required,excludes= "`
+}
@@ -0,0 +1,14 @@
+package entity
+
+import "time"
+
+// OneTimeToken keeps the state of a short-lived flow by the SHA-256 hex of its token, e.g. authorization codes.
+// Kind tells the flows apart, Payload is the JSON of the state. It is deleted when it is taken
+type OneTimeToken struct {
+	HashedToken string `validate:"required" gorm:"type:varchar(64);primary_key"`
+	Kind        string `validate:"required" gorm:"type:varchar(32);not null"`
+	Payload     string `gorm:"type:text;not null"`
+
+	ExpireTime time.Time `validate:"required" gorm:"type:DATETIME;index;not null"`
+	CreateTime time.Time `validate:"required" gorm:"type:DATETIME;not null"`
+}
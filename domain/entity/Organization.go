@@ -0,0 +1,25 @@
+package entity
+
+import "time"
+
+type Organization struct {
+	Id          uint64 `json:"id"          validate:"required"   gorm:"type:bigint;primary_key"`
+	Name        string `json:"name"        validate:"required"   gorm:"type:varchar(127);unique;not null"`
+	DisplayName string `json:"displayName"                       gorm:"type:nvarchar(255);not null"`
+
+	CreateTime     time.Time `json:"createTime"     validate:"required"    gorm:"type:DATETIME;not null"`
+	LastUpdateTime time.Time `json:"lastUpdateTime" validate:"required"    gorm:"type:DATETIME;not null"`
+}
+
+// OrganizationMember grants the roles of RoleName to the account within the organization, one row for each role
+type OrganizationMember struct {
+	OrganizationId uint64    `json:"organizationId" validate:"required" gorm:"type:bigint;primary_key"`
+	AccountId      uint64    `json:"accountId"      validate:"required" gorm:"type:bigint;primary_key;index"`
+	RoleName       string    `json:"roleName"       validate:"required" gorm:"type:varchar(127);primary_key"`
+	CreateTime     time.Time `json:"createTime"     validate:"required" gorm:"type:DATETIME;not null"`
+}
+
+type OrganizationCreateRequest struct {
+	Name        string `json:"name"        validate:"required"`
+	DisplayName string `json:"displayName"`
+}
@@ -0,0 +1,12 @@
+package entity
+
+import "time"
+
+// PasswordResetToken is kept by the SHA-256 hex of the token sent by email, it is deleted when it is used
+type PasswordResetToken struct {
+	HashedToken string `validate:"required" gorm:"type:varchar(64);primary_key"`
+	AccountId   uint64 `validate:"required" gorm:"type:bigint;index;not null"`
+
+	ExpireTime time.Time `validate:"required" gorm:"type:DATETIME;index;not null"`
+	CreateTime time.Time `validate:"required" gorm:"type:DATETIME;not null"`
+}
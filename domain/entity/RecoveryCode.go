@@ -0,0 +1,12 @@
+package entity
+
+import "time"
+
+// RecoveryCode passes the second factor once when the device of it is lost. CodeHash is the SHA-256 hex of
+// the normalized code, the codes themselves are shown to the account only when they are generated
+type RecoveryCode struct {
+	AccountId uint64 `validate:"required" gorm:"type:bigint;primary_key"`
+	CodeHash  string `validate:"required" gorm:"type:varchar(64);primary_key"`
+
+	CreateTime time.Time `validate:"required" gorm:"type:DATETIME;not null"`
+}
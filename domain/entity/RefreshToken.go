@@ -0,0 +1,17 @@
+package entity
+
+import "time"
+
+// RefreshToken is kept by its hash, FamilyCreateTime is the time of the login which starts the family of rotations
+type RefreshToken struct {
+	HashedToken string `validate:"required" gorm:"type:varchar(64);primary_key"`
+	FamilyId    string `validate:"required" gorm:"type:varchar(64);index;not null"`
+	AccountId   uint64 `validate:"required" gorm:"type:bigint;index;not null"`
+	Principal   string `validate:"required" gorm:"type:text;not null"`
+	Rotated     bool   `gorm:"not null"`
+	Revoked     bool   `gorm:"not null"`
+
+	FamilyCreateTime time.Time `validate:"required" gorm:"type:DATETIME;not null;default:CURRENT_TIMESTAMP"`
+	ExpireTime       time.Time `validate:"required" gorm:"type:DATETIME;not null"`
+	CreateTime       time.Time `validate:"required" gorm:"type:DATETIME;not null"`
+}
@@ -0,0 +1,18 @@
+package entity
+
+import "time"
+
+// RevokedToken denies the JWT of TokenId (the "jti" claim), it is kept until the token expires
+type RevokedToken struct {
+	TokenId    string    `validate:"required" gorm:"type:varchar(64);primary_key"`
+	ExpireTime time.Time `validate:"required" gorm:"type:DATETIME;index;not null"`
+}
+
+// AccountTokenRevocation denies the JWTs of account issued before RevokeTime, except the one of ExceptTokenId.
+// It is kept until the last token denied expires
+type AccountTokenRevocation struct {
+	AccountId     uint64    `validate:"required" gorm:"type:bigint;primary_key;auto_increment:false"`
+	ExceptTokenId string    `gorm:"type:varchar(64);not null"`
+	RevokeTime    time.Time `validate:"required" gorm:"type:DATETIME;not null"`
+	ExpireTime    time.Time `validate:"required" gorm:"type:DATETIME;index;not null"`
+}
@@ -0,0 +1,19 @@
+package entity
+
+import "time"
+
+type Role struct {
+	Name       string    `validate:"required" gorm:"type:varchar(127);primary_key"`
+	CreateTime time.Time `validate:"required" gorm:"type:DATETIME;not null"`
+}
+
+type RolePermission struct {
+	RoleName   string `validate:"required" gorm:"type:varchar(127);primary_key"`
+	Permission string `validate:"required" gorm:"type:varchar(127);primary_key"`
+}
+
+type AccountRole struct {
+	AccountId  uint64    `validate:"required" gorm:"type:bigint;primary_key"`
+	RoleName   string    `validate:"required" gorm:"type:varchar(127);primary_key"`
+	CreateTime time.Time `validate:"required" gorm:"type:DATETIME;not null"`
+}
@@ -0,0 +1,42 @@
+package entity
+
+import (
+	"strings"
+	"time"
+)
+
+// ServiceAccount is the machine identity of backend jobs, it authenticates by ClientId and one of its secrets.
+// Scopes are the space separated permissions which are allowed to be granted to its access tokens
+type ServiceAccount struct {
+	Id          uint64 `json:"id"          validate:"required"   gorm:"type:bigint;primary_key"`
+	ClientId    string `json:"clientId"    validate:"required"   gorm:"type:varchar(64);unique;not null"`
+	Name        string `json:"name"        validate:"required"   gorm:"type:nvarchar(127);unique;not null"`
+	Description string `json:"description"                       gorm:"type:nvarchar(255);not null"`
+	Scopes      string `json:"scopes"                            gorm:"type:text;not null"`
+
+	CreateTime     time.Time `json:"createTime"     validate:"required"    gorm:"type:DATETIME;not null"`
+	LastUpdateTime time.Time `json:"lastUpdateTime" validate:"required"    gorm:"type:DATETIME;not null"`
+}
+
+func (account *ServiceAccount) ScopeList() []string {
+	return strings.Fields(account.Scopes)
+}
+
+// ServiceAccountSecret never expires when ExpireTime is nil, the old secrets are kept valid for a while on rotation
+type ServiceAccountSecret struct {
+	Id               uint64     `json:"id"               validate:"required" gorm:"type:bigint;primary_key"`
+	ServiceAccountId uint64     `json:"serviceAccountId" validate:"required" gorm:"type:bigint;index;not null"`
+	HashedSecret     string     `json:"-"                validate:"required" gorm:"type:varchar(255);not null"`
+	ExpireTime       *time.Time `json:"expireTime"                           gorm:"type:DATETIME"`
+	CreateTime       time.Time  `json:"createTime"       validate:"required" gorm:"type:DATETIME;not null"`
+}
+
+func (secret *ServiceAccountSecret) IsValidAt(t time.Time) bool {
+	return secret.ExpireTime == nil || secret.ExpireTime.After(t)
+}
+
+type ServiceAccountCreateRequest struct {
+	Name        string   `json:"name"         validate:"required"`
+	Description string   `json:"description"`
+	Scopes      []string `json:"scopes"       validate:"dive,required,excludes= "`
+}
@@ -0,0 +1,11 @@
+package entity
+
+import "time"
+
+type Session struct {
+	Token      string    `validate:"required" gorm:"type:varchar(64);primary_key"`
+	AccountId  uint64    `validate:"required" gorm:"type:bigint;index;not null"`
+	Principal  string    `validate:"required" gorm:"type:text;not null"`
+	ExpireTime time.Time `validate:"required" gorm:"type:DATETIME;index;not null"`
+	CreateTime time.Time `validate:"required" gorm:"type:DATETIME;not null"`
+}
@@ -0,0 +1,23 @@
+package entity
+
+import "time"
+
+// TotpFactor is the TOTP second factor of account, it takes effect after the account confirms it by a code.
+// LastStep is the time step of the last accepted code, the codes of it and earlier steps are refused.
+// FailedAttempts counts the refused codes of second factors, which are locked until LockedUntil when it reaches the limit
+type TotpFactor struct {
+	AccountId      uint64     `validate:"required" gorm:"type:bigint;primary_key"`
+	Secret         string     `validate:"required" gorm:"type:varchar(64);not null"`
+	Confirmed      bool       `gorm:"not null"`
+	LastStep       int64      `gorm:"type:bigint;not null"`
+	FailedAttempts int        `gorm:"not null;default:0"`
+	LockedUntil    *time.Time `gorm:"type:DATETIME"`
+
+	CreateTime time.Time `validate:"required" gorm:"type:DATETIME;not null"`
+}
+
+// TotpEnrollment is shown to the account once, Uri is the key URI of authenticator apps
+type TotpEnrollment struct {
+	Secret string `json:"secret"`
+	Uri    string `json:"uri"`
+}
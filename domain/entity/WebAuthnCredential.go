@@ -0,0 +1,20 @@
+package entity
+
+import "time"
+
+// WebAuthnCredential is the public key credential (passkey) registered by account. Id is the SHA-256 hex of the raw
+// credential id, CredentialId is the base64url encoding of the raw one. SignCount is the signature counter of
+// authenticator, it keeps zero when the authenticator does not support counters
+type WebAuthnCredential struct {
+	Id              string `json:"id"              validate:"required" gorm:"type:varchar(64);primary_key"`
+	AccountId       uint64 `json:"accountId"       validate:"required" gorm:"type:bigint;index;not null"`
+	Name            string `json:"name"                                gorm:"type:nvarchar(127);not null"`
+	CredentialId    string `json:"credentialId"    validate:"required" gorm:"type:text;not null"`
+	PublicKey       []byte `json:"-"               validate:"required" gorm:"type:blob;not null"`
+	AttestationType string `json:"attestationType"                     gorm:"type:varchar(32);not null"`
+	AAGUID          string `json:"aaguid"                              gorm:"type:varchar(32);not null"`
+	SignCount       uint32 `json:"signCount"                           gorm:"not null"`
+
+	CreateTime   time.Time  `json:"createTime"   validate:"required" gorm:"type:DATETIME;not null"`
+	LastUsedTime *time.Time `json:"lastUsedTime"                     gorm:"type:DATETIME"`
+}
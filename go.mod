@@ -3,23 +3,27 @@ module hallo
 go 1.13
 
 require (
+	github.com/crewjam/saml v0.4.13
 	github.com/docker/go-connections v0.4.0
+	github.com/duo-labs/webauthn v0.0.0-20220330035159-03696f3d4499
 	github.com/gin-gonic/gin v1.6.3
+	github.com/go-ldap/ldap/v3 v3.2.4
 	github.com/go-playground/validator/v10 v10.2.0
-	github.com/go-sql-driver/mysql v1.5.0
-	github.com/golang/mock v1.4.4
-	github.com/google/uuid v1.1.2
+	github.com/go-sql-driver/mysql v1.6.0
+	github.com/golang-jwt/jwt/v4 v4.5.2
+	github.com/golang/mock v1.5.0
+	github.com/google/uuid v1.3.0
 	github.com/hashicorp/go-version v1.2.0 // indirect
 	github.com/hashicorp/logutils v1.0.0 // indirect
 	github.com/jinzhu/gorm v1.9.16
-	github.com/mattn/go-sqlite3 v1.14.1 // indirect
-	github.com/pact-foundation/pact-go v1.0.2
+	github.com/lor00x/goldap v0.0.0-20180618054307-a546dffdd1a3
+	github.com/pact-foundation/pact-go v1.0.4
 	github.com/patrickmn/go-cache v2.1.0+incompatible
 	github.com/satori/go.uuid v1.2.0
-	github.com/stretchr/testify v1.6.1
+	github.com/stretchr/testify v1.8.1
 	github.com/testcontainers/testcontainers-go v0.9.0
-	golang.org/x/net v0.0.0-20201021035429-f5854403a974 // indirect
-	golang.org/x/sync v0.0.0-20201020160332-67f06af15bc9 // indirect
+	github.com/vjeantet/ldapserver v1.0.1
+	golang.org/x/crypto v0.0.0-20220128200615-198e4374d7ed
 )
 
 replace golang.org/x/sys => golang.org/x/sys v0.0.0-20190830141801-acfa387b8d69
@@ -6,7 +6,31 @@ import (
 )
 
 func Migrate(db *gorm.DB) {
+	// account names were unique globally before organizations, they are unique in organization now
+	if db.Dialect().HasIndex("accounts", "name") {
+		db.Model(&entity.Account{}).RemoveIndex("name")
+	}
 	db.AutoMigrate(&entity.Account{})
 	db.AutoMigrate(&entity.InternalIdentity{})
 	db.AutoMigrate(&entity.IdentityBinding{})
+	db.AutoMigrate(&entity.TotpFactor{})
+	db.AutoMigrate(&entity.RecoveryCode{})
+	db.AutoMigrate(&entity.WebAuthnCredential{})
+	db.AutoMigrate(&entity.PasswordResetToken{})
+	db.AutoMigrate(&entity.Session{})
+	db.AutoMigrate(&entity.RefreshToken{})
+	db.AutoMigrate(&entity.OneTimeToken{})
+	db.AutoMigrate(&entity.RevokedToken{})
+	db.AutoMigrate(&entity.AccountTokenRevocation{})
+	db.AutoMigrate(&entity.Role{})
+	db.AutoMigrate(&entity.RolePermission{})
+	db.AutoMigrate(&entity.AccountRole{})
+	db.AutoMigrate(&entity.Group{})
+	db.AutoMigrate(&entity.GroupMember{})
+	db.AutoMigrate(&entity.Organization{})
+	db.AutoMigrate(&entity.OrganizationMember{})
+	db.AutoMigrate(&entity.OAuthClient{})
+	db.AutoMigrate(&entity.ServiceAccount{})
+	db.AutoMigrate(&entity.ServiceAccountSecret{})
+	db.AutoMigrate(&entity.DeviceAuthorization{})
 }
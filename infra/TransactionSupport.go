@@ -1,7 +1,17 @@
 package infra
 
+import "github.com/jinzhu/gorm"
+
 type TransactionSupport interface {
-	TxBegin()
-	TxCommit()
-	TxRollback()
+	// Transactional runs work in a transaction, which is committed when work returns nil and rolled back otherwise.
+	// Work joins the current transaction if there is one.
+	Transactional(work func(tx *gorm.DB) error) error
+}
+
+type GormTransactionSupport struct {
+	Database *gorm.DB
+}
+
+func (support *GormTransactionSupport) Transactional(work func(tx *gorm.DB) error) error {
+	return support.Database.Transaction(work)
 }
@@ -6,11 +6,20 @@ import (
 	"hallo/bootstrap"
 	"hallo/dataSource"
 	"hallo/domain"
+	"hallo/infra"
 	"hallo/meta"
 	"hallo/serveHttp"
 	"hallo/service/auth"
+	"hallo/service/connector"
+	"hallo/service/directory"
+	"hallo/service/mail"
+	"hallo/service/passkey"
+	"hallo/service/saml"
 	"hallo/util"
 	"log"
+	"os"
+	"strings"
+	"time"
 )
 
 func main() {
@@ -20,32 +29,219 @@ func main() {
 	}
 	defer ds.Stop()
 
+	passwordHasher, err := domain.NewPasswordHasher(os.Getenv("PASSWORD_HASH_ALGORITHM"))
+	if err != nil {
+		panic(err)
+	}
+
 	accountRepository := &domain.DatabaseAccountRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database}
+	roleRepository := &domain.DatabaseRoleRepository{Database: ds.Database}
+	internalIdentityRepository := &domain.DatabaseInternalIdentityRepository{Database: ds.Database, PasswordHasher: passwordHasher}
+	identityBindingRepository := &domain.DatabaseIdentityBindingRepository{Database: ds.Database}
 	accountManager := &domain.AccountManagerImpl{
 		AccountRepository:          accountRepository,
-		IdentityBindingRepository:  &domain.DatabaseIdentityBindingRepository{Database: ds.Database},
-		InternalIdentityRepository: &domain.DatabaseInternalIdentityRepository{Database: ds.Database},
+		IdentityBindingRepository:  identityBindingRepository,
+		InternalIdentityRepository: internalIdentityRepository,
+		UnitOfWork: &domain.DatabaseUnitOfWork{
+			TransactionSupport: &infra.GormTransactionSupport{Database: ds.Database},
+			IdWorker:           util.DefaultIdWorker,
+			PasswordHasher:     passwordHasher,
+		},
+	}
+
+	secondFactorManager := &domain.SecondFactorManagerImpl{
+		TotpFactorRepository:   &domain.DatabaseTotpFactorRepository{Database: ds.Database},
+		RecoveryCodeRepository: &domain.DatabaseRecoveryCodeRepository{Database: ds.Database},
+		UnitOfWork:             accountManager.UnitOfWork,
+		Issuer:                 os.Getenv("TOTP_ISSUER"),
 	}
 
-	sessionHandler := serveHttp.SessionHandler{AccountManager: accountManager}
+	ldapDirectory, err := directory.LoadLdapDirectory()
+	if err != nil {
+		panic(fmt.Errorf("failed to load ldap directory. %w", err))
+	}
+	if ldapDirectory != nil {
+		accountManager.Directory = ldapDirectory
+	}
+
+	jwtIssuer, err := auth.LoadJwtIssuer()
+	if err != nil {
+		panic(fmt.Errorf("failed to load jwt signing keys. %w", err))
+	}
+	tokenService := &auth.TokenService{
+		SessionStore:      &auth.DatabaseSessionStore{Database: ds.Database},
+		JwtIssuer:         jwtIssuer,
+		RefreshTokenStore: &auth.DatabaseRefreshTokenStore{Database: ds.Database},
+		RevocationStore:   &auth.DatabaseTokenRevocationStore{Database: ds.Database},
+		OneTimeTokenStore: &auth.DatabaseOneTimeTokenStore{Database: ds.Database},
+	}
+	stopSessionSweeper := tokenService.StartSessionSweeper(time.Minute)
+	defer stopSessionSweeper()
+	stopRefreshTokenSweeper := tokenService.StartRefreshTokenSweeper(time.Minute)
+	defer stopRefreshTokenSweeper()
+	stopRevocationSweeper := tokenService.StartRevocationSweeper(time.Minute)
+	defer stopRevocationSweeper()
+	stopOneTimeTokenSweeper := tokenService.StartOneTimeTokenSweeper(time.Minute)
+	defer stopOneTimeTokenSweeper()
+
+	groupRepository := &domain.DatabaseGroupRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database}
+	groupManager := &domain.GroupManagerImpl{
+		GroupRepository: groupRepository,
+		UnitOfWork:      accountManager.UnitOfWork,
+	}
+	organizationRepository := &domain.DatabaseOrganizationRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database}
+	organizationManager := &domain.OrganizationManagerImpl{UnitOfWork: accountManager.UnitOfWork}
+	oauthClientRepository := &domain.DatabaseOAuthClientRepository{Database: ds.Database}
+	oauthClientManager := &domain.OAuthClientManagerImpl{OAuthClientRepository: oauthClientRepository}
+	serviceAccountRepository := &domain.DatabaseServiceAccountRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database}
+	serviceAccountManager := &domain.ServiceAccountManagerImpl{UnitOfWork: accountManager.UnitOfWork}
+	deviceFlow := &auth.DeviceFlow{Store: &auth.DatabaseDeviceAuthorizationStore{Database: ds.Database}}
+	stopDeviceSweeper := deviceFlow.StartSweeper(time.Minute)
+	defer stopDeviceSweeper()
+
+	// BASE_URL is the external URL of hallo, e.g. https://hallo.example.com
+	baseUrl := strings.TrimSuffix(os.Getenv("BASE_URL"), "/")
+	connectors, err := connector.LoadConnectors()
+	if err != nil {
+		panic(fmt.Errorf("failed to load identity providers. %w", err))
+	}
+	if len(connectors) > 0 && baseUrl == "" {
+		panic(fmt.Errorf("BASE_URL is required by identity providers"))
+	}
+	samlProviders, err := saml.LoadProviders()
+	if err != nil {
+		panic(fmt.Errorf("failed to load saml providers. %w", err))
+	}
+	// identities are bound to accounts by the id of provider, so it is unique among connectors and saml providers
+	for _, provider := range samlProviders {
+		if connector.Find(connectors, provider.Id) != nil {
+			panic(fmt.Errorf("id %s of saml provider is used by identity provider", provider.Id))
+		}
+	}
+	// LOGIN_REDIRECT_URI is the page of application which exchanges the code of sign in by identity providers
+	loginRedirectUri := os.Getenv("LOGIN_REDIRECT_URI")
+	if (len(connectors) > 0 || len(samlProviders) > 0) && loginRedirectUri == "" {
+		panic(fmt.Errorf("LOGIN_REDIRECT_URI is required by identity providers"))
+	}
+	relyingParty, err := passkey.LoadRelyingParty()
+	if err != nil {
+		panic(fmt.Errorf("failed to load webauthn relying party. %w", err))
+	}
+	for _, upstream := range connectors {
+		accountManager.ExternalProviderIds = append(accountManager.ExternalProviderIds, upstream.Id())
+	}
+	for _, provider := range samlProviders {
+		accountManager.ExternalProviderIds = append(accountManager.ExternalProviderIds, provider.Id)
+	}
+	accountManager.PasskeyEnabled = relyingParty != nil
+	webAuthnCredentialRepository := &domain.DatabaseWebAuthnCredentialRepository{Database: ds.Database}
+
+	mailer := mail.LoadMailer()
+
+	sessionHandler := serveHttp.SessionHandler{
+		AccountManager:         accountManager,
+		RoleRepository:         roleRepository,
+		GroupManager:           groupManager,
+		OrganizationRepository: organizationRepository,
+		TokenService:           tokenService,
+		SecondFactorManager:    secondFactorManager,
+
+		RelyingParty:                 relyingParty,
+		WebAuthnCredentialRepository: webAuthnCredentialRepository,
+		AccountRepository:            accountRepository,
+	}
 	accountHandler := serveHttp.AccountHandler{
-		AccountManager:    accountManager,
+		AccountManager:             accountManager,
+		AccountRepository:          accountRepository,
+		OrganizationRepository:     organizationRepository,
+		InternalIdentityRepository: internalIdentityRepository,
+		IdentityBindingRepository:  identityBindingRepository,
+		SecondFactorManager:        secondFactorManager,
+		TokenService:               tokenService,
+
+		RelyingParty:                 relyingParty,
+		WebAuthnCredentialRepository: webAuthnCredentialRepository,
+	}
+	registryHandler := serveHttp.RegistryHandler{
+		AccountRepository:      accountRepository,
+		OrganizationRepository: organizationRepository,
+		Mailer:                 mailer,
+	}
+	passwordResetHandler := serveHttp.PasswordResetHandler{
 		AccountRepository: accountRepository,
+		PasswordResetManager: &domain.PasswordResetManagerImpl{
+			PasswordResetTokenRepository: &domain.DatabasePasswordResetTokenRepository{Database: ds.Database},
+			UnitOfWork:                   accountManager.UnitOfWork,
+		},
+		TokenService: tokenService,
+		Mailer:       mailer,
+	}
+	groupHandler := serveHttp.GroupHandler{GroupManager: groupManager, GroupRepository: groupRepository, TokenService: tokenService}
+	organizationHandler := serveHttp.OrganizationHandler{
+		OrganizationManager:    organizationManager,
+		OrganizationRepository: organizationRepository,
+		TokenService:           tokenService,
+	}
+	principalLoader := &serveHttp.PrincipalLoader{
+		AccountRepository:      accountRepository,
+		RoleRepository:         roleRepository,
+		GroupManager:           groupManager,
+		OrganizationRepository: organizationRepository,
+	}
+	tokenService.PrincipalReloader = principalLoader
+	oauth2Handler := serveHttp.OAuth2Handler{
+		AccountManager:        accountManager,
+		AccountRepository:     accountRepository,
+		PrincipalLoader:       principalLoader,
+		OAuthClientManager:    oauthClientManager,
+		OAuthClientRepository: oauthClientRepository,
+		ServiceAccountManager: serviceAccountManager,
+		TokenService:          tokenService,
+		DeviceFlow:            deviceFlow,
+		VerificationUri:       os.Getenv("DEVICE_VERIFICATION_URI"),
+		BaseUrl:               baseUrl,
+		SecondFactorManager:   secondFactorManager,
+	}
+	serviceAccountHandler := serveHttp.ServiceAccountHandler{
+		ServiceAccountManager:    serviceAccountManager,
+		ServiceAccountRepository: serviceAccountRepository,
+		TokenService:             tokenService,
+	}
+	connectorHandler := serveHttp.ConnectorHandler{
+		Connectors:       connectors,
+		BaseUrl:          baseUrl,
+		LoginRedirectUri: loginRedirectUri,
+		AccountManager:   accountManager,
+		TokenService:     tokenService,
+	}
+	samlHandler := serveHttp.SamlHandler{
+		Providers:        samlProviders,
+		LoginRedirectUri: loginRedirectUri,
+		AccountManager:   accountManager,
+		TokenService:     tokenService,
 	}
-	registryHandler := serveHttp.RegistryHandler{AccountRepository: accountRepository}
+	wellKnownHandler := serveHttp.WellKnownHandler{JwtIssuer: jwtIssuer}
 
-	_, err = bootstrap.CreateInitialAccount(accountManager, accountRepository)
+	_, err = bootstrap.CreateInitialAccount(accountManager, accountRepository, roleRepository)
 	if err != nil {
 		panic(fmt.Errorf("failed to check and prepare default admin account. %w", err))
 	}
 
 	engine := gin.Default()
-	engine.Use(auth.AuthenticateByToken())
+	engine.Use(auth.AuthenticateByToken(tokenService))
 
 	meta.Routes(engine.Group("/"))
 	sessionHandler.RegisterRoutes(engine.Group("/sessions"))
 	accountHandler.RegisterRoutes(engine.Group("/accounts"))
 	registryHandler.RegisterRoutes(engine.Group("/registry"))
+	passwordResetHandler.RegisterRoutes(engine.Group("/password_resets"))
+	groupHandler.RegisterRoutes(engine.Group("/groups"))
+	organizationHandler.RegisterRoutes(engine.Group("/organizations"))
+	oauth2Handler.RegisterRoutes(engine.Group("/oauth2"))
+	serviceAccountHandler.RegisterRoutes(engine.Group("/service_accounts"))
+	connectorHandler.RegisterRoutes(engine.Group("/connectors"))
+	samlHandler.RegisterRoutes(engine.Group("/saml"))
+	wellKnownHandler.RegisterRoutes(engine.Group("/.well-known"))
 
 	log.Println("service start")
 	err = engine.Run(":80")
@@ -14,6 +14,7 @@ import (
 	"hallo/meta"
 	"hallo/serveHttp"
 	"hallo/service/auth"
+	"hallo/service/mail"
 	"os"
 	"testing"
 	"time"
@@ -26,6 +27,21 @@ var port, _ = utils.GetFreePort()
 
 var mockAccountManager *domain.MockAccountManager
 var mockAccountRepository *domain.MockAccountRepository
+var mockInternalIdentityRepository *domain.MockInternalIdentityRepository
+var mockIdentityBindingRepository *domain.MockIdentityBindingRepository
+var mockRoleRepository *domain.MockRoleRepository
+var mockGroupManager *domain.MockGroupManager
+var mockGroupRepository *domain.MockGroupRepository
+var mockOrganizationManager *domain.MockOrganizationManager
+var mockOrganizationRepository *domain.MockOrganizationRepository
+var mockOAuthClientManager *domain.MockOAuthClientManager
+var mockOAuthClientRepository *domain.MockOAuthClientRepository
+var mockServiceAccountManager *domain.MockServiceAccountManager
+var mockServiceAccountRepository *domain.MockServiceAccountRepository
+var mockPasswordResetManager *domain.MockPasswordResetManager
+var sessionStore = auth.NewMemorySessionStore()
+var tokenService = &auth.TokenService{SessionStore: sessionStore, RefreshTokenStore: auth.NewMemoryRefreshTokenStore(),
+	OneTimeTokenStore: auth.NewMemoryOneTimeTokenStore()}
 
 // The Provider verification
 func TestPactProvider(t *testing.T) {
@@ -34,6 +50,18 @@ func TestPactProvider(t *testing.T) {
 	defer mockCtl.Finish()
 	mockAccountManager = domain.NewMockAccountManager(mockCtl)
 	mockAccountRepository = domain.NewMockAccountRepository(mockCtl)
+	mockInternalIdentityRepository = domain.NewMockInternalIdentityRepository(mockCtl)
+	mockIdentityBindingRepository = domain.NewMockIdentityBindingRepository(mockCtl)
+	mockRoleRepository = domain.NewMockRoleRepository(mockCtl)
+	mockGroupManager = domain.NewMockGroupManager(mockCtl)
+	mockGroupRepository = domain.NewMockGroupRepository(mockCtl)
+	mockOrganizationManager = domain.NewMockOrganizationManager(mockCtl)
+	mockOrganizationRepository = domain.NewMockOrganizationRepository(mockCtl)
+	mockOAuthClientManager = domain.NewMockOAuthClientManager(mockCtl)
+	mockOAuthClientRepository = domain.NewMockOAuthClientRepository(mockCtl)
+	mockServiceAccountManager = domain.NewMockServiceAccountManager(mockCtl)
+	mockServiceAccountRepository = domain.NewMockServiceAccountRepository(mockCtl)
+	mockPasswordResetManager = domain.NewMockPasswordResetManager(mockCtl)
 
 	go startInstrumentedProvider()
 
@@ -89,51 +117,50 @@ var stateHandlers = types.StateHandlers{
 		return nil
 	},
 	"account name [Ann] not occupied": func() error {
-		mockAccountRepository.EXPECT().IsAccountNameOccupied("Ann").Return(false, nil)
+		mockAccountRepository.EXPECT().IsAccountNameOccupied(uint64(0), "Ann").Return(false, nil)
 		return nil
 	},
 	"account name [Bob] occupied": func() error {
-		mockAccountRepository.EXPECT().IsAccountNameOccupied("Bob").Return(true, nil)
+		mockAccountRepository.EXPECT().IsAccountNameOccupied(uint64(0), "Bob").Return(true, nil)
 		return nil
 	},
 
 	"success login with credential [Ann, correctSecret]": func() error {
-		mockAccountManager.EXPECT().AuthenticateInternalIdentity("Ann", "correctSecret").Return(
+		mockAccountManager.EXPECT().AuthenticateInternalIdentity("", "Ann", "correctSecret").Return(
 			&entity.Account{Name: "Ann", Email: "ann@test.fundwit.com", Id: 123, CreateTime: time.Now(), LastUpdateTime: time.Now()}, nil)
+		mockRoleRepository.EXPECT().FindGrantsByAccountId(uint64(123)).Return([]string{}, []string{}, nil)
+		mockGroupManager.EXPECT().FindMemberships(uint64(123)).Return([]string{}, nil)
 		return nil
 	},
 	"failed login with credential [Ann, badSecret]": func() error {
-		mockAccountManager.EXPECT().AuthenticateInternalIdentity("Ann", "badSecret").Return(nil, &domain.AccountAuthenticationFailure{})
+		mockAccountManager.EXPECT().AuthenticateInternalIdentity("", "Ann", "badSecret").Return(nil, &domain.AccountAuthenticationFailure{})
 		return nil
 	},
 	"success logout": func() error {
 		securityContext := &auth.SecurityContext{Token: "correctToken", Principal: auth.Principal{Name: "Ann"}}
-		auth.TokenCache.Set("correctToken", securityContext, cache.DefaultExpiration)
-		return nil
+		return sessionStore.Save(securityContext)
 	},
 	"failed logout": func() error {
-		auth.TokenCache.Delete("badToken")
-		return nil
+		return sessionStore.Delete("badToken")
 	},
 	"already login for session info": func() error {
 		securityContext := &auth.SecurityContext{Token: "correctToken", Principal: auth.Principal{Name: "Ann"}}
-		auth.TokenCache.Set("correctToken", securityContext, cache.DefaultExpiration)
-		return nil
+		return sessionStore.Save(securityContext)
 	},
 	"un-login for session info": func() error {
-		// clean cache
-		auth.TokenCache = cache.New(24*time.Hour, 1*time.Minute)
+		// clean sessions
+		sessionStore.Flush()
 		return nil
 	},
 	"session info - bad token": func() error {
-		// clean cache
-		auth.TokenCache = cache.New(24*time.Hour, 1*time.Minute)
+		// clean sessions
+		sessionStore.Flush()
 		return nil
 	},
 
 	"sign up success with parameters [Ann, email-sign-up@test.fundwit.com, correct_register_token, correctSecret]": func() error {
 		auth.RegisterTokenCache.Set("email-sign-up@test.fundwit.com", "correct_register_token", cache.DefaultExpiration)
-		mockAccountManager.EXPECT().CreateAccount(entity.EmailAccountCreateRequest{Name: "Ann", Secret: "correctSecret", Email: "email-sign-up@test.fundwit.com"}).
+		mockAccountManager.EXPECT().CreateAccount(entity.EmailAccountCreateRequest{Name: "Ann", Secret: "correctSecret", Email: "email-sign-up@test.fundwit.com", EmailVerified: true}).
 			Return(&entity.Account{Name: "Ann", Email: "email-sign-up@test.fundwit.com", Id: 123, CreateTime: time.Now(), LastUpdateTime: time.Now()}, nil)
 		return nil
 	},
@@ -146,20 +173,76 @@ var stateHandlers = types.StateHandlers{
 // Starts the provider API with hooks for provider states.
 // This essentially mirrors the main.go file, with extra routes added.
 func startInstrumentedProvider() {
-	sessionHandler := serveHttp.SessionHandler{AccountManager: mockAccountManager}
+	sessionHandler := serveHttp.SessionHandler{
+		AccountManager:         mockAccountManager,
+		RoleRepository:         mockRoleRepository,
+		GroupManager:           mockGroupManager,
+		OrganizationRepository: mockOrganizationRepository,
+		TokenService:           tokenService,
+	}
 	accountHandler := serveHttp.AccountHandler{
+		AccountManager:             mockAccountManager,
+		AccountRepository:          mockAccountRepository,
+		OrganizationRepository:     mockOrganizationRepository,
+		InternalIdentityRepository: mockInternalIdentityRepository,
+		IdentityBindingRepository:  mockIdentityBindingRepository,
+		TokenService:               tokenService,
+	}
+	registryHandler := serveHttp.RegistryHandler{
+		AccountRepository:      mockAccountRepository,
+		OrganizationRepository: mockOrganizationRepository,
+		Mailer:                 &mail.LogMailer{},
+	}
+	passwordResetHandler := serveHttp.PasswordResetHandler{
+		AccountRepository:    mockAccountRepository,
+		PasswordResetManager: mockPasswordResetManager,
+		TokenService:         tokenService,
+		Mailer:               &mail.LogMailer{},
+	}
+	groupHandler := serveHttp.GroupHandler{GroupManager: mockGroupManager, GroupRepository: mockGroupRepository, TokenService: tokenService}
+	organizationHandler := serveHttp.OrganizationHandler{
+		OrganizationManager:    mockOrganizationManager,
+		OrganizationRepository: mockOrganizationRepository,
+		TokenService:           tokenService,
+	}
+	oauth2Handler := serveHttp.OAuth2Handler{
 		AccountManager:    mockAccountManager,
 		AccountRepository: mockAccountRepository,
+		PrincipalLoader: &serveHttp.PrincipalLoader{
+			RoleRepository:         mockRoleRepository,
+			GroupManager:           mockGroupManager,
+			OrganizationRepository: mockOrganizationRepository,
+		},
+		OAuthClientManager:    mockOAuthClientManager,
+		OAuthClientRepository: mockOAuthClientRepository,
+		ServiceAccountManager: mockServiceAccountManager,
+		TokenService:          tokenService,
+		DeviceFlow:            &auth.DeviceFlow{Store: auth.NewMemoryDeviceAuthorizationStore()},
+	}
+	serviceAccountHandler := serveHttp.ServiceAccountHandler{
+		ServiceAccountManager:    mockServiceAccountManager,
+		ServiceAccountRepository: mockServiceAccountRepository,
+		TokenService:             tokenService,
 	}
-	registryHandler := serveHttp.RegistryHandler{AccountRepository: mockAccountRepository}
+	connectorHandler := serveHttp.ConnectorHandler{AccountManager: mockAccountManager, TokenService: tokenService}
+	samlHandler := serveHttp.SamlHandler{AccountManager: mockAccountManager, TokenService: tokenService}
+	wellKnownHandler := serveHttp.WellKnownHandler{}
 
 	engine := gin.Default()
-	engine.Use(auth.AuthenticateByToken())
+	engine.Use(auth.AuthenticateByToken(tokenService))
 
 	meta.Routes(engine.Group("/"))
 	sessionHandler.RegisterRoutes(engine.Group("/sessions"))
 	accountHandler.RegisterRoutes(engine.Group("/accounts"))
 	registryHandler.RegisterRoutes(engine.Group("/registry"))
+	passwordResetHandler.RegisterRoutes(engine.Group("/password_resets"))
+	groupHandler.RegisterRoutes(engine.Group("/groups"))
+	organizationHandler.RegisterRoutes(engine.Group("/organizations"))
+	oauth2Handler.RegisterRoutes(engine.Group("/oauth2"))
+	serviceAccountHandler.RegisterRoutes(engine.Group("/service_accounts"))
+	connectorHandler.RegisterRoutes(engine.Group("/connectors"))
+	samlHandler.RegisterRoutes(engine.Group("/saml"))
+	wellKnownHandler.RegisterRoutes(engine.Group("/.well-known"))
 
 	engine.Run(fmt.Sprintf(":%d", port))
 }
@@ -4,27 +4,94 @@ import (
 	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"github.com/jinzhu/gorm"
 	"hallo/domain"
 	"hallo/domain/entity"
 	"hallo/service/auth"
+	"hallo/service/passkey"
 	"log"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 type AccountHandler struct {
-	AccountManager    domain.AccountManager
-	AccountRepository domain.AccountRepository
+	AccountManager             domain.AccountManager
+	AccountRepository          domain.AccountRepository
+	OrganizationRepository     domain.OrganizationRepository
+	InternalIdentityRepository domain.InternalIdentityRepository
+	IdentityBindingRepository  domain.IdentityBindingRepository
+	SecondFactorManager        domain.SecondFactorManager
+	TokenService               *auth.TokenService
+	// RelyingParty is optional, passkeys are registered with it
+	RelyingParty                 *passkey.RelyingParty
+	WebAuthnCredentialRepository domain.WebAuthnCredentialRepository
 }
 
+// AccountCreateForm creates account in the organization, the default organization is used when Organization is empty.
+// Only the principals granted accounts:write in the organization are able to create accounts in other organizations
 type AccountCreateForm struct {
+	Organization  string `json:"organization"`
 	Name          string `json:"name" binding:"required"`
 	Email         string `json:"email" binding:"required,email"`
 	Secret        string `json:"secret"   binding:"required"`
 	RegisterToken string `json:"register_token" binding:"required"`
 }
 
+// AccountPatchForm changes the non-empty fields only
+type AccountPatchForm struct {
+	Name  string `json:"name"`
+	Email string `json:"email" binding:"omitempty,email"`
+}
+
+// AccountListQuery pages accounts by cursor, which is the id of the last account of previous page
+type AccountListQuery struct {
+	NamePrefix    string    `form:"name_prefix"`
+	EmailPrefix   string    `form:"email_prefix"`
+	CreatedAfter  time.Time `form:"created_after"  time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedBefore time.Time `form:"created_before" time_format:"2006-01-02T15:04:05Z07:00"`
+	Cursor        uint64    `form:"cursor"`
+	Order         string    `form:"order"  binding:"omitempty,oneof=asc desc"`
+	Limit         int       `form:"limit"  binding:"omitempty,min=1,max=100"`
+}
+
+const defaultAccountListLimit = 20
+
+// TotpCodeForm carries the current code of authenticator app, or an unused recovery code
+type TotpCodeForm struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// SecretChangeForm revokes the other sessions of the account when RevokeOtherSessions is true
+type SecretChangeForm struct {
+	Secret              string `json:"secret" binding:"required"`
+	NewSecret           string `json:"new_secret" binding:"required"`
+	RevokeOtherSessions bool   `json:"revoke_other_sessions"`
+}
+
 func (handler *AccountHandler) RegisterRoutes(r *gin.RouterGroup) {
-	r.POST("", handler.createAccount)
+	r.POST("", auth.AuthenticateByToken(handler.TokenService), handler.createAccount)
+	r.GET("", auth.AuthenticateByToken(handler.TokenService), auth.AuthenticatedCheck(), auth.RequirePermission(domain.PermissionAccountRead), handler.listAccounts)
+	// the id "me" refers to the account of current session
+	r.GET("/:id", auth.AuthenticateByToken(handler.TokenService), auth.AuthenticatedCheck(), handler.getAccount)
+	// the account manages its own profile, secret and factors by the sessions of hallo only, not by OAuth2 clients
+	r.PATCH("/me", auth.AuthenticateByToken(handler.TokenService), auth.AuthenticatedCheck(), auth.FirstPartyCheck(), handler.updateAccount)
+	r.DELETE("/:id", auth.AuthenticateByToken(handler.TokenService), auth.AuthenticatedCheck(), handler.deleteAccount)
+	r.PUT("/me/secret", auth.AuthenticateByToken(handler.TokenService), auth.AuthenticatedCheck(), auth.FirstPartyCheck(), handler.changeSecret)
+	// the identities are linked by ConnectorHandler
+	r.GET("/:id/identities", auth.AuthenticateByToken(handler.TokenService), auth.AuthenticatedCheck(), handler.listIdentities)
+	r.DELETE("/:id/identities/:provider", auth.AuthenticateByToken(handler.TokenService), auth.AuthenticatedCheck(), handler.unbindIdentity)
+	r.POST("/:id/totp", auth.AuthenticateByToken(handler.TokenService), auth.AuthenticatedCheck(), auth.FirstPartyCheck(), handler.enrollTotp)
+	r.POST("/:id/totp/confirm", auth.AuthenticateByToken(handler.TokenService), auth.AuthenticatedCheck(), auth.FirstPartyCheck(), handler.confirmTotp)
+	r.DELETE("/:id/totp", auth.AuthenticateByToken(handler.TokenService), auth.AuthenticatedCheck(), handler.deleteTotp)
+	r.POST("/:id/recovery-codes", auth.AuthenticateByToken(handler.TokenService), auth.AuthenticatedCheck(), auth.FirstPartyCheck(), handler.regenerateRecoveryCodes)
+	r.GET("/:id/recovery-codes", auth.AuthenticateByToken(handler.TokenService), auth.AuthenticatedCheck(), handler.countRecoveryCodes)
+	if handler.RelyingParty != nil {
+		r.POST("/:id/passkeys/options", auth.AuthenticateByToken(handler.TokenService), auth.AuthenticatedCheck(), auth.FirstPartyCheck(), handler.beginPasskeyRegistration)
+		r.POST("/:id/passkeys", auth.AuthenticateByToken(handler.TokenService), auth.AuthenticatedCheck(), auth.FirstPartyCheck(), handler.registerPasskey)
+		r.GET("/:id/passkeys", auth.AuthenticateByToken(handler.TokenService), auth.AuthenticatedCheck(), handler.listPasskeys)
+		r.DELETE("/:id/passkeys/:credential", auth.AuthenticateByToken(handler.TokenService), auth.AuthenticatedCheck(), handler.deletePasskey)
+	}
 }
 
 func (handler *AccountHandler) createAccount(c *gin.Context) {
@@ -35,6 +102,9 @@ func (handler *AccountHandler) createAccount(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "bad request body"})
 		return
 	}
+	if form.Organization != "" && !handler.authorizeOrganization(c, form.Organization) {
+		return
+	}
 
 	// clean
 	token, found := auth.RegisterTokenCache.Get(form.Email)
@@ -44,8 +114,9 @@ func (handler *AccountHandler) createAccount(c *gin.Context) {
 	}
 	auth.RegisterTokenCache.Delete(form.Email)
 
+	// the register token has been sent to the email
 	account, err := handler.AccountManager.CreateAccount(entity.EmailAccountCreateRequest{
-		Name: form.Name, Email: form.Email, Secret: form.Secret})
+		Organization: form.Organization, Name: form.Name, Email: form.Email, Secret: form.Secret, EmailVerified: true})
 	if err != nil {
 		log.Printf("error: %v\n", err)
 
@@ -59,9 +130,441 @@ func (handler *AccountHandler) createAccount(c *gin.Context) {
 		} else if errors.Is(err, &domain.AccountEmailIsOccupied{}) {
 			c.JSON(http.StatusConflict, gin.H{"error": (&domain.AccountEmailIsOccupied{}).Error()})
 			return
+		} else if gorm.IsRecordNotFoundError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "organization not found"})
+			return
 		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create account"})
+		return
 	}
 
 	c.JSON(http.StatusCreated, gin.H{"user": account})
 }
+
+// authorizeOrganization allows the principals granted accounts:write in the organization to create accounts in it,
+// the accounts are not able to register themselves into organizations
+func (handler *AccountHandler) authorizeOrganization(c *gin.Context, organizationName string) bool {
+	sc := auth.LoadFromRequestContext(c)
+	if sc == nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": (&domain.ErrForbidden{}).Error()})
+		return false
+	}
+	organization, err := handler.OrganizationRepository.FindByName(organizationName)
+	if gorm.IsRecordNotFoundError(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "organization not found"})
+		return false
+	}
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create account"})
+		return false
+	}
+	if !sc.Principal.HasOrganizationPermission(organization.Id, domain.PermissionAccountWrite) {
+		c.JSON(http.StatusForbidden, gin.H{"error": (&domain.ErrForbidden{}).Error()})
+		return false
+	}
+	return true
+}
+
+// total is the number of accounts matching the filters, of all pages
+func (handler *AccountHandler) listAccounts(c *gin.Context) {
+	var query AccountListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bad query parameters"})
+		return
+	}
+	if query.Limit == 0 {
+		query.Limit = defaultAccountListLimit
+	}
+
+	// one more account is queried to tell whether there is a next page
+	accountQuery := entity.AccountQuery{
+		NamePrefix:    query.NamePrefix,
+		EmailPrefix:   query.EmailPrefix,
+		CreatedAfter:  query.CreatedAfter,
+		CreatedBefore: query.CreatedBefore,
+		Cursor:        query.Cursor,
+		Descending:    query.Order == "desc",
+		Limit:         query.Limit + 1,
+	}
+	accounts, err := handler.AccountRepository.Query(accountQuery)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list accounts"})
+		return
+	}
+	total, err := handler.AccountRepository.QueryCount(accountQuery)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list accounts"})
+		return
+	}
+
+	nextCursor := ""
+	if len(accounts) > query.Limit {
+		accounts = accounts[:query.Limit]
+		nextCursor = strconv.FormatUint(accounts[query.Limit-1].Id, 10)
+	}
+	if accounts == nil {
+		accounts = []entity.Account{}
+	}
+	c.JSON(http.StatusOK, gin.H{"items": accounts, "total": total, "next_cursor": nextCursor})
+}
+
+// getAccount responds the account to itself or the principals granted accounts:read
+func (handler *AccountHandler) getAccount(c *gin.Context) {
+	accountId, ok := accountIdParam(c)
+	if !ok {
+		return
+	}
+	if !handler.authorizeAccount(c, accountId, domain.PermissionAccountRead) {
+		return
+	}
+
+	account, err := handler.AccountRepository.FindById(accountId)
+	if gorm.IsRecordNotFoundError(err) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get account"})
+		return
+	}
+	c.JSON(http.StatusOK, account)
+}
+
+func (handler *AccountHandler) updateAccount(c *gin.Context) {
+	var form AccountPatchForm
+	if err := c.ShouldBindJSON(&form); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bad request body"})
+		return
+	}
+
+	sc := auth.LoadFromRequestContext(c)
+	account, err := handler.AccountManager.UpdateAccount(sc.Principal.Id, entity.AccountUpdateRequest{Name: form.Name, Email: form.Email})
+	if err != nil {
+		log.Printf("error: %v\n", err)
+
+		var validationErrs validator.ValidationErrors
+		var nameOccupied *domain.AccountNameIsOccupied
+		var emailOccupied *domain.AccountEmailIsOccupied
+		if errors.As(err, &validationErrs) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "bad request body"})
+		} else if errors.As(err, &nameOccupied) {
+			c.JSON(http.StatusConflict, gin.H{"error": nameOccupied.Error()})
+		} else if errors.As(err, &emailOccupied) {
+			c.JSON(http.StatusConflict, gin.H{"error": emailOccupied.Error()})
+		} else if gorm.IsRecordNotFoundError(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update account"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, account)
+}
+
+// accounts can delete themselves, deleting other accounts requires permission of writing accounts
+func (handler *AccountHandler) deleteAccount(c *gin.Context) {
+	accountId, ok := accountIdParam(c)
+	if !ok {
+		return
+	}
+	if !handler.authorizeAccount(c, accountId, domain.PermissionAccountWrite) {
+		return
+	}
+
+	err := handler.AccountManager.DeleteAccount(accountId)
+	if gorm.IsRecordNotFoundError(err) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete account"})
+		return
+	}
+
+	if err := handler.TokenService.RevokeByAccountId(accountId, ""); err != nil {
+		log.Printf("failed to revoke sessions of account [%d]: %v\n", accountId, err)
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// the current token is kept when other sessions are revoked,
+// all refresh tokens of the account are revoked and a new one is returned for the current session
+func (handler *AccountHandler) changeSecret(c *gin.Context) {
+	var form SecretChangeForm
+	if err := c.ShouldBindJSON(&form); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bad request body"})
+		return
+	}
+
+	sc := auth.LoadFromRequestContext(c)
+	var revoke func(accountId uint64) error
+	var refreshToken string
+	if form.RevokeOtherSessions {
+		revoke = func(accountId uint64) error {
+			if err := handler.TokenService.RevokeByAccountId(accountId, sc.Token); err != nil {
+				return err
+			}
+			var err error
+			refreshToken, err = handler.TokenService.IssueRefreshToken(sc.Principal)
+			return err
+		}
+	}
+	if err := handler.AccountManager.ChangeSecret(sc.Principal.Id, form.Secret, form.NewSecret, revoke); err != nil {
+		log.Println(err)
+		var authenticationFailure *domain.AccountAuthenticationFailure
+		if errors.As(err, &authenticationFailure) {
+			c.JSON(http.StatusForbidden, gin.H{"error": (&domain.AccountAuthenticationFailure{}).Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to change secret"})
+		return
+	}
+
+	if !form.RevokeOtherSessions {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"refresh_token": refreshToken})
+}
+
+func (handler *AccountHandler) listIdentities(c *gin.Context) {
+	accountId, ok := accountIdParam(c)
+	if !ok {
+		return
+	}
+	if !handler.authorizeAccount(c, accountId, domain.PermissionAccountRead) {
+		return
+	}
+
+	bindings, err := handler.IdentityBindingRepository.FindByAccountId(accountId)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list identities"})
+		return
+	}
+	c.JSON(http.StatusOK, bindings)
+}
+
+func (handler *AccountHandler) unbindIdentity(c *gin.Context) {
+	accountId, ok := accountIdParam(c)
+	if !ok {
+		return
+	}
+	if !handler.authorizeAccount(c, accountId, domain.PermissionAccountWrite) {
+		return
+	}
+
+	err := handler.AccountManager.UnbindIdentity(accountId, c.Param("provider"))
+	if err != nil {
+		log.Println(err)
+		var lastIdentity *domain.LastIdentityIsRequired
+		if errors.As(err, &lastIdentity) {
+			c.JSON(http.StatusConflict, gin.H{"error": lastIdentity.Error()})
+		} else if gorm.IsRecordNotFoundError(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "identity not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unbind identity"})
+		}
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// enrollTotp responds the secret of a new TOTP factor, which is required to sign in after it is confirmed.
+// Only the account itself is able to enroll
+func (handler *AccountHandler) enrollTotp(c *gin.Context) {
+	accountId, ok := accountIdParam(c)
+	if !ok {
+		return
+	}
+	if sc := auth.LoadFromRequestContext(c); sc.Principal.Id != accountId {
+		c.JSON(http.StatusForbidden, gin.H{"error": (&domain.ErrForbidden{}).Error()})
+		return
+	}
+
+	account, err := handler.AccountRepository.FindById(accountId)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to enroll totp"})
+		return
+	}
+	enrollment, err := handler.SecondFactorManager.EnrollTotp(account)
+	if err != nil {
+		log.Println(err)
+		var enrolled *domain.TotpIsEnrolled
+		if errors.As(err, &enrolled) {
+			c.JSON(http.StatusConflict, gin.H{"error": enrolled.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to enroll totp"})
+		}
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, enrollment)
+}
+
+// confirmTotp responds the recovery codes of account, they are shown only once
+func (handler *AccountHandler) confirmTotp(c *gin.Context) {
+	accountId, ok := accountIdParam(c)
+	if !ok {
+		return
+	}
+	if sc := auth.LoadFromRequestContext(c); sc.Principal.Id != accountId {
+		c.JSON(http.StatusForbidden, gin.H{"error": (&domain.ErrForbidden{}).Error()})
+		return
+	}
+	var form TotpCodeForm
+	if err := c.ShouldBindJSON(&form); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bad request body"})
+		return
+	}
+
+	recoveryCodes, err := handler.SecondFactorManager.ConfirmTotp(accountId, form.Code)
+	if err != nil {
+		respondTotpError(c, err)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": recoveryCodes})
+}
+
+// deleteTotp requires the current code when the account deletes its own factor,
+// the accounts with permission accounts:write are able to delete the factors of others, e.g. the device is lost
+func (handler *AccountHandler) deleteTotp(c *gin.Context) {
+	accountId, ok := accountIdParam(c)
+	if !ok {
+		return
+	}
+	sc := auth.LoadFromRequestContext(c)
+	if sc.Principal.IsFirstParty() && sc.Principal.Id == accountId {
+		var form TotpCodeForm
+		if err := c.ShouldBindJSON(&form); err != nil {
+			log.Println(err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "bad request body"})
+			return
+		}
+		if err := handler.SecondFactorManager.VerifySecondFactor(accountId, form.Code); err != nil {
+			respondTotpError(c, err)
+			return
+		}
+	} else if !handler.authorizeAccount(c, accountId, domain.PermissionAccountWrite) {
+		return
+	}
+
+	if err := handler.SecondFactorManager.DeleteTotp(accountId); err != nil {
+		respondTotpError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// regenerateRecoveryCodes requires the current code as deleteTotp does, the unused codes of the previous set are
+// invalidated
+func (handler *AccountHandler) regenerateRecoveryCodes(c *gin.Context) {
+	accountId, ok := accountIdParam(c)
+	if !ok {
+		return
+	}
+	if sc := auth.LoadFromRequestContext(c); sc.Principal.Id != accountId {
+		c.JSON(http.StatusForbidden, gin.H{"error": (&domain.ErrForbidden{}).Error()})
+		return
+	}
+	var form TotpCodeForm
+	if err := c.ShouldBindJSON(&form); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bad request body"})
+		return
+	}
+	if err := handler.SecondFactorManager.VerifySecondFactor(accountId, form.Code); err != nil {
+		respondTotpError(c, err)
+		return
+	}
+
+	recoveryCodes, err := handler.SecondFactorManager.RegenerateRecoveryCodes(accountId)
+	if err != nil {
+		respondTotpError(c, err)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, gin.H{"recovery_codes": recoveryCodes})
+}
+
+// countRecoveryCodes responds the number of unused recovery codes, the codes themselves are not stored
+func (handler *AccountHandler) countRecoveryCodes(c *gin.Context) {
+	accountId, ok := accountIdParam(c)
+	if !ok {
+		return
+	}
+	if !handler.authorizeAccount(c, accountId, domain.PermissionAccountRead) {
+		return
+	}
+
+	remaining, err := handler.SecondFactorManager.CountRecoveryCodes(accountId)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count recovery codes"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"remaining": remaining})
+}
+
+func respondTotpError(c *gin.Context, err error) {
+	log.Println(err)
+	var failure *domain.SecondFactorAuthenticationFailure
+	var locked *domain.SecondFactorIsLocked
+	if errors.As(err, &failure) {
+		c.JSON(http.StatusForbidden, gin.H{"error": failure.Error()})
+	} else if errors.As(err, &locked) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": locked.Error()})
+	} else if gorm.IsRecordNotFoundError(err) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "totp not found"})
+	} else {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify code"})
+	}
+}
+
+// authorizeAccount allows the account itself and the principals granted the permission globally or in the organization
+// of the account, the forbidden response is written otherwise. OAuth2 clients and service accounts are never the account
+// itself, they are authorized by permissions only
+func (handler *AccountHandler) authorizeAccount(c *gin.Context, accountId uint64, permission string) bool {
+	principal := auth.LoadFromRequestContext(c).Principal
+	if (principal.IsFirstParty() && principal.Id == accountId) || principal.HasPermission(permission) {
+		return true
+	}
+	if len(principal.OrganizationPermissions) > 0 {
+		account, err := handler.AccountRepository.FindById(accountId)
+		if err != nil && !gorm.IsRecordNotFoundError(err) {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to authorize"})
+			return false
+		}
+		if err == nil && principal.HasOrganizationPermission(account.OrganizationId, permission) {
+			return true
+		}
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": (&domain.ErrForbidden{}).Error()})
+	return false
+}
+
+// accountIdParam resolves the id parameter, "me" is resolved as the account of current session.
+// The bad request is responded when id is invalid, and the forbidden when service account refers to "me".
+func accountIdParam(c *gin.Context) (uint64, bool) {
+	if c.Param("id") == "me" {
+		principal := auth.LoadFromRequestContext(c).Principal
+		if principal.ServiceAccount {
+			c.JSON(http.StatusForbidden, gin.H{"error": (&domain.ErrForbidden{}).Error()})
+			return 0, false
+		}
+		return principal.Id, true
+	}
+	return idParam(c, "id")
+}
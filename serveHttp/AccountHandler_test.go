@@ -3,13 +3,18 @@ package serveHttp
 import (
 	bytes2 "bytes"
 	"encoding/json"
+	"errors"
 	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
 	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
 	"github.com/patrickmn/go-cache"
 	"github.com/stretchr/testify/assert"
 	"hallo/domain"
 	"hallo/domain/entity"
+	"hallo/infra"
 	"hallo/service/auth"
+	"hallo/service/passkey"
 	"hallo/testinfra"
 	"hallo/util"
 	"io/ioutil"
@@ -17,6 +22,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestAccountHandler_createUser(it *testing.T) {
@@ -30,6 +36,7 @@ func TestAccountHandler_createUser(it *testing.T) {
 				AccountRepository:          accountRepository,
 				IdentityBindingRepository:  &domain.DatabaseIdentityBindingRepository{Database: ds.Database},
 				InternalIdentityRepository: &domain.DatabaseInternalIdentityRepository{Database: ds.Database},
+				UnitOfWork:                 &domain.DatabaseUnitOfWork{TransactionSupport: &infra.GormTransactionSupport{Database: ds.Database}, IdWorker: util.DefaultIdWorker},
 			},
 			AccountRepository: accountRepository,
 		}
@@ -127,3 +134,527 @@ func TestAccountHandler_createUser(it *testing.T) {
 		assert.False(t, found)
 	})
 }
+
+func TestAccountHandler_createOrganizationAccount(it *testing.T) {
+	it.Run("should create accounts in organization by its administrators only", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		accountManager := domain.NewMockAccountManager(mockCtl)
+		organizationRepository := domain.NewMockOrganizationRepository(mockCtl)
+		tokenService := &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), RefreshTokenStore: auth.NewMemoryRefreshTokenStore()}
+		accountHandler := AccountHandler{AccountManager: accountManager, OrganizationRepository: organizationRepository, TokenService: tokenService}
+
+		engine := gin.Default()
+		accountHandler.RegisterRoutes(engine.Group("/accounts"))
+
+		admin, _ := tokenService.Issue(auth.Principal{Id: 1, Name: "ann", OrganizationId: 601, OrganizationPermissions: []string{"accounts:*"}})
+		outsider, _ := tokenService.Issue(auth.Principal{Id: 2, Name: "bob", OrganizationId: 602, OrganizationPermissions: []string{"accounts:*"}})
+		registerEmail := uuid.New().String() + "@test.fundwit.com"
+		auth.RegisterTokenCache.Set(registerEmail, "register-token", cache.DefaultExpiration)
+		doRequest := func(token string) *httptest.ResponseRecorder {
+			requestBody, _ := json.Marshal(AccountCreateForm{Organization: "acme", Name: "carl", Email: registerEmail,
+				Secret: "secret", RegisterToken: "register-token"})
+			req := httptest.NewRequest(http.MethodPost, "/accounts", bytes2.NewReader(requestBody))
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			return w
+		}
+
+		// accounts are not able to register themselves into organizations
+		w := doRequest("")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		organizationRepository.EXPECT().FindByName("acme").Return(&entity.Organization{Id: 601, Name: "acme"}, nil).Times(3)
+		w = doRequest(outsider.Token)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		_, found := auth.RegisterTokenCache.Get(registerEmail)
+		assert.True(t, found)
+
+		createRequest := entity.EmailAccountCreateRequest{Organization: "acme", Name: "carl", Email: registerEmail,
+			Secret: "secret", EmailVerified: true}
+		accountManager.EXPECT().CreateAccount(createRequest).Return(nil, errors.New("some failure"))
+		w = doRequest(admin.Token)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.JSONEq(t, `{"error": "failed to create account"}`, w.Body.String())
+
+		auth.RegisterTokenCache.Set(registerEmail, "register-token", cache.DefaultExpiration)
+		accountManager.EXPECT().CreateAccount(createRequest).Return(&entity.Account{Id: 123, OrganizationId: 601, Name: "carl"}, nil)
+		w = doRequest(admin.Token)
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+}
+
+func TestAccountHandler_changeSecret(it *testing.T) {
+	it.Run("should change secret and revoke other sessions", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		accountManager := domain.NewMockAccountManager(mockCtl)
+		tokenService := &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), RefreshTokenStore: auth.NewMemoryRefreshTokenStore()}
+		accountHandler := AccountHandler{AccountManager: accountManager, TokenService: tokenService}
+		changeSecret := func(accountId uint64, secret, newSecret string, revoke func(accountId uint64) error) error {
+			if revoke == nil {
+				return nil
+			}
+			return revoke(accountId)
+		}
+
+		engine := gin.Default()
+		accountHandler.RegisterRoutes(engine.Group("/accounts"))
+
+		current, _ := tokenService.Issue(auth.Principal{Id: 123, Name: "ann"})
+		other, _ := tokenService.Issue(auth.Principal{Id: 123, Name: "ann"})
+
+		// --- without token ---
+		req := httptest.NewRequest(http.MethodPut, "/accounts/me/secret", strings.NewReader(`{"secret": "old", "new_secret": "new"}`))
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.JSONEq(t, `{"error": "authentication is required"}`, w.Body.String())
+
+		// --- current secret is not match ---
+		accountManager.EXPECT().ChangeSecret(uint64(123), "bad", "new", gomock.Nil()).Return(&domain.AccountAuthenticationFailure{})
+		req = httptest.NewRequest(http.MethodPut, "/accounts/me/secret", strings.NewReader(`{"secret": "bad", "new_secret": "new"}`))
+		req.Header.Set("Authorization", "Bearer "+current.Token)
+		w = httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		// --- change secret and keep other sessions ---
+		accountManager.EXPECT().ChangeSecret(uint64(123), "old", "new", gomock.Nil()).DoAndReturn(changeSecret)
+		req = httptest.NewRequest(http.MethodPut, "/accounts/me/secret", strings.NewReader(`{"secret": "old", "new_secret": "new"}`))
+		req.Header.Set("Authorization", "Bearer "+current.Token)
+		w = httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		found, _ := tokenService.Authenticate(other.Token)
+		assert.Equal(t, other, found)
+
+		// --- change secret and revoke other sessions ---
+		accountManager.EXPECT().ChangeSecret(uint64(123), "new", "newer", gomock.Not(gomock.Nil())).DoAndReturn(changeSecret)
+		req = httptest.NewRequest(http.MethodPut, "/accounts/me/secret",
+			strings.NewReader(`{"secret": "new", "new_secret": "newer", "revoke_other_sessions": true}`))
+		req.Header.Set("Authorization", "Bearer "+current.Token)
+		w = httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		body := map[string]string{}
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.NotEmpty(t, body["refresh_token"])
+
+		found, _ = tokenService.Authenticate(other.Token)
+		assert.Nil(t, found)
+		found, _ = tokenService.Authenticate(current.Token)
+		assert.Equal(t, current, found)
+		_, _, err := tokenService.Refresh(body["refresh_token"], "")
+		assert.Nil(t, err)
+	})
+}
+
+func TestAccountHandler_manageAccount(it *testing.T) {
+	it.Run("should get, update and delete account", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		accountManager := domain.NewMockAccountManager(mockCtl)
+		accountRepository := domain.NewMockAccountRepository(mockCtl)
+		tokenService := &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), RefreshTokenStore: auth.NewMemoryRefreshTokenStore()}
+		accountHandler := AccountHandler{AccountManager: accountManager, AccountRepository: accountRepository, TokenService: tokenService}
+
+		engine := gin.Default()
+		accountHandler.RegisterRoutes(engine.Group("/accounts"))
+
+		sc, _ := tokenService.Issue(auth.Principal{Id: 123, Name: "ann"})
+		account := &entity.Account{Id: 123, Name: "ann", Email: "ann@test.fundwit.com"}
+		doRequest := func(method, path, body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, path, strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+sc.Token)
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			return w
+		}
+
+		// --- get ---
+		accountRepository.EXPECT().FindById(uint64(123)).Return(account, nil).Times(2)
+		w := doRequest(http.MethodGet, "/accounts/me", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		wantedBody, _ := json.Marshal(account)
+		assert.JSONEq(t, string(wantedBody), w.Body.String())
+		w = doRequest(http.MethodGet, "/accounts/123", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = doRequest(http.MethodGet, "/accounts/456", "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = doRequest(http.MethodGet, "/accounts/abc", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		// --- update ---
+		accountManager.EXPECT().UpdateAccount(uint64(123), entity.AccountUpdateRequest{Name: "bob"}).
+			Return(nil, &domain.AccountNameIsOccupied{})
+		accountManager.EXPECT().UpdateAccount(uint64(123), entity.AccountUpdateRequest{Email: "ann2@test.fundwit.com"}).
+			Return(&entity.Account{Id: 123, Name: "ann", Email: "ann2@test.fundwit.com"}, nil)
+		w = doRequest(http.MethodPatch, "/accounts/me", `{"name": "bob"}`)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		w = doRequest(http.MethodPatch, "/accounts/me", `{"email": "bad-email"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w = doRequest(http.MethodPatch, "/accounts/me", `{"email": "ann2@test.fundwit.com"}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"email":"ann2@test.fundwit.com"`)
+
+		// --- delete ---
+		w = doRequest(http.MethodDelete, "/accounts/456", "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		accountManager.EXPECT().DeleteAccount(uint64(123)).Return(nil)
+		w = doRequest(http.MethodDelete, "/accounts/me", "")
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		found, _ := tokenService.Authenticate(sc.Token)
+		assert.Nil(t, found)
+	})
+
+	it.Run("should get accounts of organization with permission accounts:read in it", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		accountRepository := domain.NewMockAccountRepository(mockCtl)
+		tokenService := &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), RefreshTokenStore: auth.NewMemoryRefreshTokenStore()}
+		accountHandler := AccountHandler{AccountRepository: accountRepository, TokenService: tokenService}
+
+		engine := gin.Default()
+		accountHandler.RegisterRoutes(engine.Group("/accounts"))
+
+		sc, _ := tokenService.Issue(auth.Principal{Id: 1, Name: "ann", OrganizationId: 601, OrganizationPermissions: []string{"accounts:read"}})
+		accountRepository.EXPECT().FindById(uint64(123)).Return(&entity.Account{Id: 123, OrganizationId: 601, Name: "bob"}, nil).Times(3)
+		accountRepository.EXPECT().FindById(uint64(456)).Return(&entity.Account{Id: 456, OrganizationId: 602, Name: "bob"}, nil)
+		for path, status := range map[string]int{"/accounts/123": http.StatusOK, "/accounts/456": http.StatusForbidden} {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("Authorization", "Bearer "+sc.Token)
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			assert.Equal(t, status, w.Code, path)
+		}
+		// permission to read is not permission to write
+		req := httptest.NewRequest(http.MethodDelete, "/accounts/123", nil)
+		req.Header.Set("Authorization", "Bearer "+sc.Token)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	it.Run("should get other accounts with permission accounts:read", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		accountRepository := domain.NewMockAccountRepository(mockCtl)
+		tokenService := &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), RefreshTokenStore: auth.NewMemoryRefreshTokenStore()}
+		accountHandler := AccountHandler{AccountRepository: accountRepository, TokenService: tokenService}
+
+		engine := gin.Default()
+		accountHandler.RegisterRoutes(engine.Group("/accounts"))
+
+		sc, _ := tokenService.Issue(auth.Principal{Id: 1, Name: "admin", Permissions: []string{domain.PermissionAccountRead}})
+		accountRepository.EXPECT().FindById(uint64(123)).Return(&entity.Account{Id: 123, Name: "ann"}, nil)
+		accountRepository.EXPECT().FindById(uint64(456)).Return(nil, gorm.ErrRecordNotFound)
+		for path, status := range map[string]int{"/accounts/123": http.StatusOK, "/accounts/456": http.StatusNotFound} {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("Authorization", "Bearer "+sc.Token)
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			assert.Equal(t, status, w.Code, path)
+		}
+	})
+}
+
+func TestAccountHandler_manageIdentities(it *testing.T) {
+	it.Run("should list and unbind identities of current account", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		accountManager := domain.NewMockAccountManager(mockCtl)
+		identityBindingRepository := domain.NewMockIdentityBindingRepository(mockCtl)
+		tokenService := &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), RefreshTokenStore: auth.NewMemoryRefreshTokenStore()}
+		accountHandler := AccountHandler{AccountManager: accountManager, IdentityBindingRepository: identityBindingRepository,
+			TokenService: tokenService}
+
+		engine := gin.Default()
+		accountHandler.RegisterRoutes(engine.Group("/accounts"))
+
+		sc, _ := tokenService.Issue(auth.Principal{Id: 123, Name: "ann"})
+		doRequest := func(method, path string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, path, nil)
+			req.Header.Set("Authorization", "Bearer "+sc.Token)
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			return w
+		}
+
+		identityBindingRepository.EXPECT().FindByAccountId(uint64(123)).Return([]entity.IdentityBinding{
+			{AccountId: 123, ProviderId: domain.InternalProviderId, ProviderAccountId: "123"},
+			{AccountId: 123, ProviderId: "github", ProviderAccountId: "1001"},
+		}, nil)
+		w := doRequest(http.MethodGet, "/accounts/me/identities")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"providerId":"github","accountId":123`)
+		w = doRequest(http.MethodGet, "/accounts/456/identities")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		accountManager.EXPECT().UnbindIdentity(uint64(123), "github").Return(nil)
+		accountManager.EXPECT().UnbindIdentity(uint64(123), "google").Return(gorm.ErrRecordNotFound)
+		accountManager.EXPECT().UnbindIdentity(uint64(123), domain.InternalProviderId).Return(&domain.LastIdentityIsRequired{})
+		w = doRequest(http.MethodDelete, "/accounts/me/identities/github")
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		w = doRequest(http.MethodDelete, "/accounts/me/identities/google")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w = doRequest(http.MethodDelete, "/accounts/me/identities/"+domain.InternalProviderId)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		w = doRequest(http.MethodDelete, "/accounts/456/identities/github")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
+
+func TestAccountHandler_manageTotp(it *testing.T) {
+	it.Run("should enroll, confirm and delete totp and recovery codes of current account", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		accountRepository := domain.NewMockAccountRepository(mockCtl)
+		secondFactorManager := domain.NewMockSecondFactorManager(mockCtl)
+		tokenService := &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), RefreshTokenStore: auth.NewMemoryRefreshTokenStore()}
+		accountHandler := AccountHandler{AccountRepository: accountRepository, SecondFactorManager: secondFactorManager,
+			TokenService: tokenService}
+
+		engine := gin.Default()
+		accountHandler.RegisterRoutes(engine.Group("/accounts"))
+
+		admin, _ := tokenService.Issue(auth.Principal{Id: 1, Name: "admin", Roles: []string{domain.SuperAdminRole}, Permissions: []string{domain.AllPermissions}})
+		user, _ := tokenService.Issue(auth.Principal{Id: 123, Name: "ann"})
+		doRequest := func(method, path, token, body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, path, strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			return w
+		}
+
+		account := &entity.Account{Id: 123, Name: "ann"}
+		accountRepository.EXPECT().FindById(uint64(123)).Return(account, nil).Times(2)
+		secondFactorManager.EXPECT().EnrollTotp(account).Return(&entity.TotpEnrollment{Secret: "JBSWY3DPEHPK3PXP",
+			Uri: "otpauth://totp/hallo:ann?secret=JBSWY3DPEHPK3PXP"}, nil)
+		w := doRequest(http.MethodPost, "/accounts/me/totp", user.Token, "")
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+		assert.JSONEq(t, `{"secret": "JBSWY3DPEHPK3PXP", "uri": "otpauth://totp/hallo:ann?secret=JBSWY3DPEHPK3PXP"}`, w.Body.String())
+		secondFactorManager.EXPECT().EnrollTotp(account).Return(nil, &domain.TotpIsEnrolled{})
+		w = doRequest(http.MethodPost, "/accounts/me/totp", user.Token, "")
+		assert.Equal(t, http.StatusConflict, w.Code)
+		w = doRequest(http.MethodPost, "/accounts/123/totp", admin.Token, "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		secondFactorManager.EXPECT().ConfirmTotp(uint64(123), "000000").Return(nil, &domain.SecondFactorAuthenticationFailure{})
+		secondFactorManager.EXPECT().ConfirmTotp(uint64(123), "123456").Return([]string{"abcde-fghij", "klmno-pqrst"}, nil)
+		w = doRequest(http.MethodPost, "/accounts/me/totp/confirm", user.Token, `{"code": "000000"}`)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = doRequest(http.MethodPost, "/accounts/me/totp/confirm", user.Token, `{}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w = doRequest(http.MethodPost, "/accounts/me/totp/confirm", user.Token, `{"code": "123456"}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+		assert.JSONEq(t, `{"recovery_codes": ["abcde-fghij", "klmno-pqrst"]}`, w.Body.String())
+
+		// the recovery codes are regenerated by a code, the number of unused codes is shown to admin as well
+		secondFactorManager.EXPECT().VerifySecondFactor(uint64(123), "000000").Return(&domain.SecondFactorAuthenticationFailure{})
+		w = doRequest(http.MethodPost, "/accounts/me/recovery-codes", user.Token, `{"code": "000000"}`)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		secondFactorManager.EXPECT().VerifySecondFactor(uint64(123), "123456").Return(nil)
+		secondFactorManager.EXPECT().RegenerateRecoveryCodes(uint64(123)).Return([]string{"uvwxy-z2345"}, nil)
+		w = doRequest(http.MethodPost, "/accounts/me/recovery-codes", user.Token, `{"code": "123456"}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+		assert.JSONEq(t, `{"recovery_codes": ["uvwxy-z2345"]}`, w.Body.String())
+		w = doRequest(http.MethodPost, "/accounts/123/recovery-codes", admin.Token, `{"code": "123456"}`)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		secondFactorManager.EXPECT().CountRecoveryCodes(uint64(123)).Return(1, nil).Times(2)
+		w = doRequest(http.MethodGet, "/accounts/me/recovery-codes", user.Token, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"remaining": 1}`, w.Body.String())
+		w = doRequest(http.MethodGet, "/accounts/123/recovery-codes", admin.Token, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = doRequest(http.MethodGet, "/accounts/456/recovery-codes", user.Token, "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		// the account deletes its factor by a code, admin deletes the factors of others without code
+		secondFactorManager.EXPECT().VerifySecondFactor(uint64(123), "000000").Return(&domain.SecondFactorAuthenticationFailure{})
+		w = doRequest(http.MethodDelete, "/accounts/me/totp", user.Token, `{"code": "000000"}`)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		secondFactorManager.EXPECT().VerifySecondFactor(uint64(123), "123456").Return(nil)
+		secondFactorManager.EXPECT().DeleteTotp(uint64(123)).Return(nil)
+		w = doRequest(http.MethodDelete, "/accounts/me/totp", user.Token, `{"code": "123456"}`)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		w = doRequest(http.MethodDelete, "/accounts/456/totp", user.Token, "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		secondFactorManager.EXPECT().DeleteTotp(uint64(123)).Return(gorm.ErrRecordNotFound)
+		w = doRequest(http.MethodDelete, "/accounts/123/totp", admin.Token, "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestAccountHandler_managePasskeys(it *testing.T) {
+	it.Run("should register, list and delete passkeys of current account", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		accountRepository := domain.NewMockAccountRepository(mockCtl)
+		credentialRepository := domain.NewMockWebAuthnCredentialRepository(mockCtl)
+		internalIdentityRepository := domain.NewMockInternalIdentityRepository(mockCtl)
+		secondFactorManager := domain.NewMockSecondFactorManager(mockCtl)
+		accountManager := domain.NewMockAccountManager(mockCtl)
+		relyingParty, err := passkey.NewRelyingParty(passkey.Config{Id: "hallo.test.fundwit.com", Origin: "https://hallo.test.fundwit.com"})
+		assert.Nil(t, err)
+		tokenService := &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), OneTimeTokenStore: auth.NewMemoryOneTimeTokenStore(), RefreshTokenStore: auth.NewMemoryRefreshTokenStore()}
+		accountHandler := AccountHandler{AccountRepository: accountRepository, InternalIdentityRepository: internalIdentityRepository,
+			SecondFactorManager: secondFactorManager, TokenService: tokenService, RelyingParty: relyingParty,
+			WebAuthnCredentialRepository: credentialRepository, AccountManager: accountManager}
+
+		engine := gin.Default()
+		accountHandler.RegisterRoutes(engine.Group("/accounts"))
+
+		admin, _ := tokenService.Issue(auth.Principal{Id: 1, Name: "admin", Roles: []string{domain.SuperAdminRole}, Permissions: []string{domain.AllPermissions}})
+		user, _ := tokenService.Issue(auth.Principal{Id: 123, Name: "ann"})
+		doRequest := func(method, path, token string, body []byte) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, path, bytes2.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			return w
+		}
+		authenticator := testinfra.NewWebAuthnAuthenticator("https://hallo.test.fundwit.com")
+
+		account := &entity.Account{Id: 123, Name: "ann"}
+		accountRepository.EXPECT().FindById(uint64(123)).Return(account, nil).Times(4)
+		credentialRepository.EXPECT().FindByAccountId(uint64(123)).Return([]entity.WebAuthnCredential{}, nil).Times(3)
+		w := doRequest(http.MethodPost, "/accounts/123/passkeys/options", admin.Token, []byte(`{"secret": "secret"}`))
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		// the account is confirmed again by its secret or the code of second factor
+		w = doRequest(http.MethodPost, "/accounts/me/passkeys/options", user.Token, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w = doRequest(http.MethodPost, "/accounts/me/passkeys/options", user.Token, []byte("{}"))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		internalIdentityRepository.EXPECT().Authenticate(uint64(123), "wrong").Return(&domain.AccountAuthenticationFailure{})
+		w = doRequest(http.MethodPost, "/accounts/me/passkeys/options", user.Token, []byte(`{"secret": "wrong"}`))
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		secondFactorManager.EXPECT().VerifySecondFactor(uint64(123), "000000").Return(&domain.SecondFactorAuthenticationFailure{})
+		w = doRequest(http.MethodPost, "/accounts/me/passkeys/options", user.Token, []byte(`{"secret": "secret", "code": "000000"}`))
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		secondFactorManager.EXPECT().VerifySecondFactor(uint64(123), "123456").Return(&domain.SecondFactorIsLocked{})
+		w = doRequest(http.MethodPost, "/accounts/me/passkeys/options", user.Token, []byte(`{"code": "123456"}`))
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		secondFactorManager.EXPECT().VerifySecondFactor(uint64(123), "123456").Return(nil)
+		w = doRequest(http.MethodPost, "/accounts/me/passkeys/options", user.Token, []byte(`{"code": "123456"}`))
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		internalIdentityRepository.EXPECT().Authenticate(uint64(123), "secret").Return(nil).Times(2)
+		createByOptions := func() []byte {
+			w := doRequest(http.MethodPost, "/accounts/me/passkeys/options", user.Token, []byte(`{"secret": "secret"}`))
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+			response, err := authenticator.Create(w.Body.Bytes())
+			assert.Nil(t, err)
+			return response
+		}
+
+		// the ceremony of another account is refused
+		w = doRequest(http.MethodPost, "/accounts/me/passkeys", admin.Token, createByOptions())
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		response := createByOptions()
+
+		var saved *entity.WebAuthnCredential
+		credentialRepository.EXPECT().Create(gomock.Any()).DoAndReturn(func(credential *entity.WebAuthnCredential) error {
+			saved = credential
+			return nil
+		})
+		w = doRequest(http.MethodPost, "/accounts/me/passkeys?name=laptop", user.Token, response)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, uint64(123), saved.AccountId)
+		assert.Equal(t, "laptop", saved.Name)
+		assert.Contains(t, w.Body.String(), `"id":"`+saved.Id+`"`)
+		assert.NotContains(t, w.Body.String(), "publicKey")
+
+		// the challenge is answered only once
+		w = doRequest(http.MethodPost, "/accounts/me/passkeys", user.Token, response)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w = doRequest(http.MethodPost, "/accounts/me/passkeys", user.Token, []byte("{}"))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		credentialRepository.EXPECT().FindByAccountId(uint64(123)).Return([]entity.WebAuthnCredential{*saved}, nil).Times(2)
+		w = doRequest(http.MethodGet, "/accounts/me/passkeys", user.Token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = doRequest(http.MethodGet, "/accounts/123/passkeys", admin.Token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = doRequest(http.MethodGet, "/accounts/456/passkeys", user.Token, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = doRequest(http.MethodDelete, "/accounts/456/passkeys/"+saved.Id, user.Token, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		accountManager.EXPECT().DeletePasskey(uint64(123), saved.Id).Return(nil)
+		w = doRequest(http.MethodDelete, "/accounts/me/passkeys/"+saved.Id, user.Token, nil)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		accountManager.EXPECT().DeletePasskey(uint64(123), saved.Id).Return(gorm.ErrRecordNotFound)
+		w = doRequest(http.MethodDelete, "/accounts/123/passkeys/"+saved.Id, admin.Token, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		// the last login method of account is kept
+		accountManager.EXPECT().DeletePasskey(uint64(123), saved.Id).Return(&domain.LastIdentityIsRequired{})
+		w = doRequest(http.MethodDelete, "/accounts/me/passkeys/"+saved.Id, user.Token, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+}
+
+func TestAccountHandler_listAccounts(it *testing.T) {
+	it.Run("should list accounts page by page for admin only", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		accountRepository := domain.NewMockAccountRepository(mockCtl)
+		tokenService := &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), RefreshTokenStore: auth.NewMemoryRefreshTokenStore()}
+		accountHandler := AccountHandler{AccountRepository: accountRepository, TokenService: tokenService}
+
+		engine := gin.Default()
+		accountHandler.RegisterRoutes(engine.Group("/accounts"))
+
+		admin, _ := tokenService.Issue(auth.Principal{Id: 1, Name: "admin", Roles: []string{domain.SuperAdminRole}, Permissions: []string{domain.AllPermissions}})
+		user, _ := tokenService.Issue(auth.Principal{Id: 123, Name: "ann"})
+		doRequest := func(path, token string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			return w
+		}
+
+		w := doRequest("/accounts", user.Token)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = doRequest("/accounts?limit=1000", admin.Token)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		createdAfter, _ := time.Parse(time.RFC3339, "2020-01-01T00:00:00Z")
+		accountRepository.EXPECT().Query(entity.AccountQuery{NamePrefix: "a", CreatedAfter: createdAfter, Cursor: 10, Limit: 3}).
+			Return([]entity.Account{{Id: 11, Name: "a1"}, {Id: 12, Name: "a2"}, {Id: 13, Name: "a3"}}, nil)
+		accountRepository.EXPECT().Query(entity.AccountQuery{NamePrefix: "a", CreatedAfter: createdAfter, Cursor: 12, Limit: 3}).
+			Return([]entity.Account{{Id: 13, Name: "a3"}}, nil)
+		accountRepository.EXPECT().QueryCount(entity.AccountQuery{NamePrefix: "a", CreatedAfter: createdAfter, Cursor: 10, Limit: 3}).
+			Return(uint64(3), nil)
+		accountRepository.EXPECT().QueryCount(entity.AccountQuery{NamePrefix: "a", CreatedAfter: createdAfter, Cursor: 12, Limit: 3}).
+			Return(uint64(3), nil)
+
+		w = doRequest("/accounts?name_prefix=a&created_after=2020-01-01T00:00:00Z&cursor=10&limit=2", admin.Token)
+		assert.Equal(t, http.StatusOK, w.Code)
+		body := struct {
+			Items      []entity.Account `json:"items"`
+			Total      uint64           `json:"total"`
+			NextCursor string           `json:"next_cursor"`
+		}{}
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Len(t, body.Items, 2)
+		assert.Equal(t, uint64(3), body.Total)
+		assert.Equal(t, "12", body.NextCursor)
+
+		w = doRequest("/accounts?name_prefix=a&created_after=2020-01-01T00:00:00Z&cursor="+body.NextCursor+"&limit=2", admin.Token)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Len(t, body.Items, 1)
+		assert.Equal(t, "", body.NextCursor)
+	})
+}
@@ -0,0 +1,226 @@
+package serveHttp
+
+import (
+	"crypto/subtle"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"hallo/domain"
+	"hallo/domain/entity"
+	"hallo/service/auth"
+	"hallo/service/connector"
+	"hallo/util"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ConnectorHandler signs in accounts by the upstream identity providers. The account is redirected to the provider
+// by the login endpoint, and the callback endpoint redirects it to LoginRedirectUri with a code, which is exchanged
+// for the session at POST /sessions/external, which challenges the second factor of account as POST /sessions does.
+// The link endpoint starts the same flow for the signed in account, whose callback binds the identity instead.
+// BaseUrl is the external URL of hallo, the callback URL registered at providers is built from it
+type ConnectorHandler struct {
+	Connectors       []connector.Connector
+	BaseUrl          string
+	LoginRedirectUri string
+	AccountManager   domain.AccountManager
+	TokenService     *auth.TokenService
+}
+
+// connectorStateCookie keeps the state in the browser which starts the flow, the callback is accepted only in it
+const connectorStateCookie = "hallo_connector_state"
+
+func (handler *ConnectorHandler) RegisterRoutes(r *gin.RouterGroup) {
+	r.GET("", handler.listConnectors)
+	r.GET("/:id/login", handler.login)
+	r.GET("/:id/callback", handler.callback)
+	r.POST("/:id/link", auth.AuthenticateByToken(handler.TokenService), auth.AuthenticatedCheck(), auth.FirstPartyCheck(), handler.link)
+}
+
+func (handler *ConnectorHandler) listConnectors(c *gin.Context) {
+	connectors := []gin.H{}
+	for _, connector := range handler.Connectors {
+		connectors = append(connectors, gin.H{"id": connector.Id(), "name": connector.Name()})
+	}
+	c.JSON(http.StatusOK, connectors)
+}
+
+// login starts the flow of linking when link_token issued by the link endpoint is present
+func (handler *ConnectorHandler) login(c *gin.Context) {
+	upstream := connector.Find(handler.Connectors, c.Param("id"))
+	if upstream == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "connector not found"})
+		return
+	}
+
+	var accountId uint64
+	if linkToken := c.Query("link_token"); linkToken != "" {
+		link, err := handler.TokenService.TakeConnectorLink(linkToken)
+		if err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to link identity"})
+			return
+		}
+		if link == nil || link.ConnectorId != upstream.Id() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "link token is invalid or expired"})
+			return
+		}
+		accountId = link.AccountId
+	}
+
+	connectorPath := strings.TrimSuffix(c.Request.URL.Path, "/login")
+	state, authorizationUrl, err := handler.authorizationUrlOf(upstream, connectorPath, accountId)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to sign in"})
+		return
+	}
+	handler.setStateCookie(c, connectorPath, state, int(auth.ConnectorStateExpiration.Seconds()))
+	c.Redirect(http.StatusFound, authorizationUrl)
+}
+
+// link responds the URL of login endpoint with a link token instead of redirecting, as the token of account is not
+// sent by browser navigation. The login endpoint is navigated by the browser so that the state is kept in it
+func (handler *ConnectorHandler) link(c *gin.Context) {
+	upstream := connector.Find(handler.Connectors, c.Param("id"))
+	if upstream == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "connector not found"})
+		return
+	}
+
+	accountId := auth.LoadFromRequestContext(c).Principal.Id
+	linkToken, err := handler.TokenService.IssueConnectorLink(&auth.ConnectorLink{ConnectorId: upstream.Id(), AccountId: accountId})
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to link identity"})
+		return
+	}
+	loginUrl := handler.BaseUrl + strings.TrimSuffix(c.Request.URL.Path, "/link") + "/login?" +
+		url.Values{"link_token": {linkToken}}.Encode()
+	c.JSON(http.StatusOK, gin.H{"authorizationUrl": loginUrl})
+}
+
+// authorizationUrlOf keeps the state of flow, the callback endpoint is under the connectorPath
+func (handler *ConnectorHandler) authorizationUrlOf(upstream connector.Connector, connectorPath string, accountId uint64) (
+	string, string, error) {
+	codeVerifier, err := util.RandomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	redirectUri := handler.BaseUrl + connectorPath + "/callback"
+	state, err := handler.TokenService.IssueConnectorState(&auth.ConnectorState{ConnectorId: upstream.Id(),
+		RedirectUri: redirectUri, CodeVerifier: codeVerifier, AccountId: accountId})
+	if err != nil {
+		return "", "", err
+	}
+	return state, upstream.AuthCodeURL(redirectUri, state, auth.CodeChallengeOf(codeVerifier)), nil
+}
+
+// setStateCookie keeps the state for the callback, the cookie is Lax as the callback is navigated from the provider
+func (handler *ConnectorHandler) setStateCookie(c *gin.Context, connectorPath string, state string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(connectorStateCookie, state, maxAge, connectorPath, "", strings.HasPrefix(handler.BaseUrl, "https://"), true)
+}
+
+// callback binds the identity of provider to an account, see AccountManager.AuthenticateExternalIdentity
+func (handler *ConnectorHandler) callback(c *gin.Context) {
+	upstream := connector.Find(handler.Connectors, c.Param("id"))
+	if upstream == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "connector not found"})
+		return
+	}
+	stateParam := c.Query("state")
+	stateCookie, _ := c.Cookie(connectorStateCookie)
+	if stateParam == "" || subtle.ConstantTimeCompare([]byte(stateParam), []byte(stateCookie)) != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "state is invalid or expired"})
+		return
+	}
+	handler.setStateCookie(c, strings.TrimSuffix(c.Request.URL.Path, "/callback"), "", -1)
+	state, err := handler.TokenService.TakeConnectorState(stateParam)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to sign in"})
+		return
+	}
+	if state == nil || state.ConnectorId != upstream.Id() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "state is invalid or expired"})
+		return
+	}
+	if providerError := c.Query("error"); providerError != "" {
+		log.Printf("sign in by %s failed: %s %s\n", upstream.Id(), providerError, c.Query("error_description"))
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "sign in is denied by identity provider"})
+		return
+	}
+
+	identity, err := upstream.Exchange(c.Request.Context(), state.RedirectUri, c.Query("code"), state.CodeVerifier)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to get identity from identity provider"})
+		return
+	}
+
+	if state.AccountId != 0 {
+		handler.bindIdentity(c, state.AccountId, identity)
+		return
+	}
+
+	account, err := handler.AccountManager.AuthenticateExternalIdentity(*identity)
+	if err != nil {
+		respondExternalIdentityError(c, err)
+		return
+	}
+	redirectLoginCode(c, handler.TokenService, handler.LoginRedirectUri, account)
+}
+
+func (handler *ConnectorHandler) bindIdentity(c *gin.Context, accountId uint64, identity *entity.ExternalIdentity) {
+	if err := handler.AccountManager.BindExternalIdentity(accountId, *identity); err != nil {
+		log.Printf("error: %v\n", err)
+		var identityBound *domain.IdentityIsBound
+		if errors.As(err, &identityBound) {
+			c.JSON(http.StatusConflict, gin.H{"error": identityBound.Error()})
+		} else if gorm.IsRecordNotFoundError(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to link identity"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"providerId": identity.ProviderId, "providerAccountId": identity.ProviderAccountId})
+}
+
+func respondExternalIdentityError(c *gin.Context, err error) {
+	log.Printf("error: %v\n", err)
+
+	var emailMissing *domain.ExternalIdentityEmailMissing
+	var emailOccupied *domain.AccountEmailIsOccupied
+	if errors.As(err, &emailMissing) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": emailMissing.Error()})
+	} else if errors.As(err, &emailOccupied) {
+		c.JSON(http.StatusConflict, gin.H{"error": emailOccupied.Error()})
+	} else {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to sign in"})
+	}
+}
+
+// redirectLoginCode redirects the browser to loginRedirectUri with the code of account, which is exchanged for
+// the session by the application at POST /sessions/external
+func redirectLoginCode(c *gin.Context, tokenService *auth.TokenService, loginRedirectUri string, account *entity.Account) {
+	location, err := url.Parse(loginRedirectUri)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create session"})
+		return
+	}
+	code, err := tokenService.IssueLoginCode(&auth.LoginCode{AccountId: account.Id})
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create session"})
+		return
+	}
+	query := location.Query()
+	query.Set("code", code)
+	location.RawQuery = query.Encode()
+	c.Redirect(http.StatusFound, location.String())
+}
@@ -0,0 +1,181 @@
+package serveHttp
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"hallo/domain"
+	"hallo/domain/entity"
+	"hallo/service/auth"
+	"hallo/service/connector"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestConnectorHandler(it *testing.T) {
+	it.Run("should sign in by upstream provider with state and pkce", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		accountManager := domain.NewMockAccountManager(mockCtl)
+
+		// the fake provider issues "good-code" for the code challenge of the last authorization request
+		var codeChallenge string
+		provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			switch r.URL.Path {
+			case "/token":
+				_ = r.ParseForm()
+				if r.PostForm.Get("code") != "good-code" || auth.CodeChallengeOf(r.PostForm.Get("code_verifier")) != codeChallenge {
+					w.WriteHeader(http.StatusBadRequest)
+					_, _ = w.Write([]byte(`{"error": "invalid_grant"}`))
+					return
+				}
+				_, _ = w.Write([]byte(`{"access_token": "good-token"}`))
+			case "/userinfo":
+				if r.Header.Get("Authorization") != "Bearer good-token" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				_, _ = w.Write([]byte(`{"sub": "u-1", "preferred_username": "ann", "email": "ann@test.fundwit.com", "email_verified": true}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer provider.Close()
+
+		corp, err := connector.NewOAuth2Connector(context.Background(), connector.Config{Id: "corp", Type: connector.TypeOAuth2,
+			Name: "Corp", ClientId: "hallo", ClientSecret: "secret", AuthorizationEndpoint: provider.URL + "/authorize",
+			TokenEndpoint: provider.URL + "/token", UserInfoEndpoint: provider.URL + "/userinfo",
+			Claims: connector.ClaimMapping{Id: "sub", Name: "preferred_username", Email: "email", EmailVerified: "email_verified"}})
+		assert.Nil(t, err)
+
+		tokenService := &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), OneTimeTokenStore: auth.NewMemoryOneTimeTokenStore(),
+			RefreshTokenStore: auth.NewMemoryRefreshTokenStore()}
+		connectorHandler := ConnectorHandler{
+			Connectors:       []connector.Connector{corp},
+			BaseUrl:          "https://hallo.test.fundwit.com",
+			LoginRedirectUri: "https://app.test.fundwit.com/login?from=corp",
+			AccountManager:   accountManager,
+			TokenService:     tokenService,
+		}
+		engine := gin.Default()
+		connectorHandler.RegisterRoutes(engine.Group("/connectors"))
+		// the browser keeps the state cookie of the last login
+		var stateCookie *http.Cookie
+		doRequest := func(path string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			if stateCookie != nil {
+				req.AddCookie(stateCookie)
+			}
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			return w
+		}
+		login := func(path string) url.Values {
+			w := doRequest(path)
+			assert.Equal(t, http.StatusFound, w.Code)
+			location, err := url.Parse(w.Header().Get("Location"))
+			assert.Nil(t, err)
+			assert.Equal(t, "/authorize", location.Path)
+			codeChallenge = location.Query().Get("code_challenge")
+			cookies := w.Result().Cookies()
+			assert.Len(t, cookies, 1)
+			stateCookie = cookies[0]
+			assert.Equal(t, connectorStateCookie, stateCookie.Name)
+			assert.Equal(t, location.Query().Get("state"), stateCookie.Value)
+			assert.Equal(t, "/connectors/corp", stateCookie.Path)
+			assert.True(t, stateCookie.HttpOnly)
+			assert.True(t, stateCookie.Secure)
+			assert.Equal(t, http.SameSiteLaxMode, stateCookie.SameSite)
+			return location.Query()
+		}
+
+		w := doRequest("/connectors")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `[{"id": "corp", "name": "Corp"}]`, w.Body.String())
+		w = doRequest("/connectors/unknown/login")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		query := login("/connectors/corp/login")
+		assert.Equal(t, "https://hallo.test.fundwit.com/connectors/corp/callback", query.Get("redirect_uri"))
+		w = doRequest("/connectors/corp/callback?code=good-code&state=bad-state")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		// the state is refused in another browser
+		cookie := stateCookie
+		stateCookie = nil
+		w = doRequest("/connectors/corp/callback?code=good-code&state=" + query.Get("state"))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		stateCookie = cookie
+
+		identity := entity.ExternalIdentity{ProviderId: "corp", ProviderAccountId: "u-1", Name: "ann",
+			Email: "ann@test.fundwit.com", EmailVerified: true}
+		accountManager.EXPECT().AuthenticateExternalIdentity(identity).Return(&entity.Account{Id: 123, Name: "ann"}, nil)
+		w = doRequest("/connectors/corp/callback?code=good-code&state=" + query.Get("state"))
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.NotContains(t, w.Body.String(), "token")
+		location, err := url.Parse(w.Header().Get("Location"))
+		assert.Nil(t, err)
+		assert.Equal(t, "app.test.fundwit.com", location.Host)
+		assert.Equal(t, "corp", location.Query().Get("from"))
+		loginCode, err := tokenService.TakeLoginCode(location.Query().Get("code"))
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(123), loginCode.AccountId)
+
+		// the state is taken only once
+		w = doRequest("/connectors/corp/callback?code=good-code&state=" + query.Get("state"))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		query = login("/connectors/corp/login")
+		w = doRequest("/connectors/corp/callback?error=access_denied&state=" + query.Get("state"))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		query = login("/connectors/corp/login")
+		w = doRequest("/connectors/corp/callback?code=bad-code&state=" + query.Get("state"))
+		assert.Equal(t, http.StatusBadGateway, w.Code)
+
+		accountManager.EXPECT().AuthenticateExternalIdentity(identity).Return(nil, &domain.AccountEmailIsOccupied{})
+		query = login("/connectors/corp/login")
+		w = doRequest("/connectors/corp/callback?code=good-code&state=" + query.Get("state"))
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		// the signed in account links the identity by the login endpoint navigated in browser
+		sc, _ := tokenService.Issue(auth.Principal{Id: 456, Name: "bob"})
+		link := func() url.Values {
+			req := httptest.NewRequest(http.MethodPost, "/connectors/corp/link", nil)
+			req.Header.Set("Authorization", "Bearer "+sc.Token)
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+			var body struct {
+				AuthorizationUrl string `json:"authorizationUrl"`
+			}
+			assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &body))
+			loginUrl, err := url.Parse(body.AuthorizationUrl)
+			assert.Nil(t, err)
+			assert.Equal(t, "hallo.test.fundwit.com", loginUrl.Host)
+			assert.Equal(t, "/connectors/corp/login", loginUrl.Path)
+			return login(loginUrl.RequestURI())
+		}
+		w = doRequest("/connectors/corp/link")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w = doRequest("/connectors/corp/login?link_token=bad-token")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		accountManager.EXPECT().BindExternalIdentity(uint64(456), identity).Return(nil)
+		query = link()
+		assert.Equal(t, "https://hallo.test.fundwit.com/connectors/corp/callback", query.Get("redirect_uri"))
+		w = doRequest("/connectors/corp/callback?code=good-code&state=" + query.Get("state"))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"providerId": "corp", "providerAccountId": "u-1"}`, w.Body.String())
+
+		accountManager.EXPECT().BindExternalIdentity(uint64(456), identity).Return(&domain.IdentityIsBound{})
+		query = link()
+		w = doRequest("/connectors/corp/callback?code=good-code&state=" + query.Get("state"))
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+}
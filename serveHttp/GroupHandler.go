@@ -0,0 +1,191 @@
+package serveHttp
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/jinzhu/gorm"
+	"hallo/domain"
+	"hallo/domain/entity"
+	"hallo/service/auth"
+	"log"
+	"net/http"
+)
+
+type GroupHandler struct {
+	GroupManager    domain.GroupManager
+	GroupRepository domain.GroupRepository
+	TokenService    *auth.TokenService
+}
+
+type GroupCreateForm struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	ParentId    uint64 `json:"parentId"`
+}
+
+// GroupPatchForm changes the present fields only, zero parentId moves the group to top level
+type GroupPatchForm struct {
+	Name        string  `json:"name"`
+	Description *string `json:"description"`
+	ParentId    *uint64 `json:"parentId"`
+}
+
+func (handler *GroupHandler) RegisterRoutes(r *gin.RouterGroup) {
+	authenticate := auth.AuthenticateByToken(handler.TokenService)
+	canRead := auth.RequirePermission(domain.PermissionGroupRead)
+	canWrite := auth.RequirePermission(domain.PermissionGroupWrite)
+
+	r.GET("", authenticate, auth.AuthenticatedCheck(), canRead, handler.listGroups)
+	r.POST("", authenticate, auth.AuthenticatedCheck(), canWrite, handler.createGroup)
+	r.GET("/:id", authenticate, auth.AuthenticatedCheck(), canRead, handler.getGroup)
+	r.PATCH("/:id", authenticate, auth.AuthenticatedCheck(), canWrite, handler.updateGroup)
+	r.DELETE("/:id", authenticate, auth.AuthenticatedCheck(), canWrite, handler.deleteGroup)
+	r.GET("/:id/members", authenticate, auth.AuthenticatedCheck(), canRead, handler.listMembers)
+	r.PUT("/:id/members/:accountId", authenticate, auth.AuthenticatedCheck(), canWrite, handler.addMember)
+	r.DELETE("/:id/members/:accountId", authenticate, auth.AuthenticatedCheck(), canWrite, handler.removeMember)
+}
+
+func (handler *GroupHandler) listGroups(c *gin.Context) {
+	groups, err := handler.GroupRepository.FindAll()
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list groups"})
+		return
+	}
+	c.JSON(http.StatusOK, groups)
+}
+
+func (handler *GroupHandler) createGroup(c *gin.Context) {
+	var form GroupCreateForm
+	if err := c.ShouldBindJSON(&form); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bad request body"})
+		return
+	}
+
+	group, err := handler.GroupManager.CreateGroup(entity.GroupCreateRequest{
+		Name: form.Name, Description: form.Description, ParentId: form.ParentId})
+	if err != nil {
+		respondGroupError(c, err, "failed to create group")
+		return
+	}
+	c.JSON(http.StatusCreated, group)
+}
+
+func (handler *GroupHandler) getGroup(c *gin.Context) {
+	groupId, ok := idParam(c, "id")
+	if !ok {
+		return
+	}
+	group, err := handler.GroupRepository.FindById(groupId)
+	if err != nil {
+		respondGroupError(c, err, "failed to get group")
+		return
+	}
+	c.JSON(http.StatusOK, group)
+}
+
+func (handler *GroupHandler) updateGroup(c *gin.Context) {
+	groupId, ok := idParam(c, "id")
+	if !ok {
+		return
+	}
+	var form GroupPatchForm
+	if err := c.ShouldBindJSON(&form); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bad request body"})
+		return
+	}
+
+	group, err := handler.GroupManager.UpdateGroup(groupId, entity.GroupUpdateRequest{
+		Name: form.Name, Description: form.Description, ParentId: form.ParentId})
+	if err != nil {
+		respondGroupError(c, err, "failed to update group")
+		return
+	}
+	c.JSON(http.StatusOK, group)
+}
+
+func (handler *GroupHandler) deleteGroup(c *gin.Context) {
+	groupId, ok := idParam(c, "id")
+	if !ok {
+		return
+	}
+	if err := handler.GroupManager.DeleteGroup(groupId); err != nil {
+		respondGroupError(c, err, "failed to delete group")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (handler *GroupHandler) listMembers(c *gin.Context) {
+	groupId, ok := idParam(c, "id")
+	if !ok {
+		return
+	}
+	if _, err := handler.GroupRepository.FindById(groupId); err != nil {
+		respondGroupError(c, err, "failed to list members")
+		return
+	}
+	accountIds, err := handler.GroupRepository.FindMemberIds(groupId)
+	if err != nil {
+		respondGroupError(c, err, "failed to list members")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"accountIds": accountIds})
+}
+
+func (handler *GroupHandler) addMember(c *gin.Context) {
+	groupId, ok := idParam(c, "id")
+	if !ok {
+		return
+	}
+	accountId, ok := idParam(c, "accountId")
+	if !ok {
+		return
+	}
+	if err := handler.GroupManager.AddMember(groupId, accountId); err != nil {
+		respondGroupError(c, err, "failed to add member")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (handler *GroupHandler) removeMember(c *gin.Context) {
+	groupId, ok := idParam(c, "id")
+	if !ok {
+		return
+	}
+	accountId, ok := idParam(c, "accountId")
+	if !ok {
+		return
+	}
+	if err := handler.GroupManager.RemoveMember(groupId, accountId); err != nil {
+		respondGroupError(c, err, "failed to remove member")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func respondGroupError(c *gin.Context, err error, failure string) {
+	log.Printf("error: %v\n", err)
+
+	var validationErrs validator.ValidationErrors
+	var nameOccupied *domain.GroupNameIsOccupied
+	var cycle *domain.ErrGroupCycle
+	var hasChildren *domain.ErrGroupHasChildren
+	if errors.As(err, &validationErrs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bad request body"})
+	} else if errors.As(err, &nameOccupied) {
+		c.JSON(http.StatusConflict, gin.H{"error": nameOccupied.Error()})
+	} else if errors.As(err, &cycle) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": cycle.Error()})
+	} else if errors.As(err, &hasChildren) {
+		c.JSON(http.StatusConflict, gin.H{"error": hasChildren.Error()})
+	} else if gorm.IsRecordNotFoundError(err) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "group or account not found"})
+	} else {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": failure})
+	}
+}
@@ -0,0 +1,87 @@
+package serveHttp
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"hallo/domain"
+	"hallo/domain/entity"
+	"hallo/service/auth"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGroupHandler(it *testing.T) {
+	it.Run("should manage groups and members with permissions", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		groupManager := domain.NewMockGroupManager(mockCtl)
+		groupRepository := domain.NewMockGroupRepository(mockCtl)
+		tokenService := &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), RefreshTokenStore: auth.NewMemoryRefreshTokenStore()}
+		groupHandler := GroupHandler{GroupManager: groupManager, GroupRepository: groupRepository, TokenService: tokenService}
+
+		engine := gin.Default()
+		groupHandler.RegisterRoutes(engine.Group("/groups"))
+
+		reader, _ := tokenService.Issue(auth.Principal{Id: 1, Name: "reader", Permissions: []string{domain.PermissionGroupRead}})
+		writer, _ := tokenService.Issue(auth.Principal{Id: 2, Name: "writer", Permissions: []string{"groups:*"}})
+		doRequest := func(method, path, body, token string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, path, strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			return w
+		}
+
+		groupRepository.EXPECT().FindAll().Return([]entity.Group{{Id: 10, Name: "dev"}}, nil)
+		w := doRequest(http.MethodGet, "/groups", "", reader.Token)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"name":"dev"`)
+
+		w = doRequest(http.MethodPost, "/groups", `{"name": "ops"}`, reader.Token)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		groupManager.EXPECT().CreateGroup(entity.GroupCreateRequest{Name: "ops", ParentId: 10}).Return(&entity.Group{Id: 11, Name: "ops", ParentId: 10}, nil)
+		groupManager.EXPECT().CreateGroup(entity.GroupCreateRequest{Name: "dev"}).Return(nil, &domain.GroupNameIsOccupied{})
+		w = doRequest(http.MethodPost, "/groups", `{"name": "ops", "parentId": 10}`, writer.Token)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		w = doRequest(http.MethodPost, "/groups", `{"name": "dev"}`, writer.Token)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		root := uint64(0)
+		groupManager.EXPECT().UpdateGroup(uint64(11), entity.GroupUpdateRequest{ParentId: &root}).Return(&entity.Group{Id: 11, Name: "ops"}, nil)
+		groupManager.EXPECT().UpdateGroup(uint64(10), entity.GroupUpdateRequest{ParentId: &root}).Return(nil, &domain.ErrGroupCycle{})
+		w = doRequest(http.MethodPatch, "/groups/11", `{"parentId": 0}`, writer.Token)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = doRequest(http.MethodPatch, "/groups/10", `{"parentId": 0}`, writer.Token)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		groupManager.EXPECT().AddMember(uint64(11), uint64(123)).Return(nil)
+		groupManager.EXPECT().AddMember(uint64(11), uint64(456)).Return(gorm.ErrRecordNotFound)
+		groupManager.EXPECT().RemoveMember(uint64(11), uint64(123)).Return(nil)
+		w = doRequest(http.MethodPut, "/groups/11/members/123", "", writer.Token)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		w = doRequest(http.MethodPut, "/groups/11/members/456", "", writer.Token)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w = doRequest(http.MethodDelete, "/groups/11/members/123", "", writer.Token)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		groupRepository.EXPECT().FindById(uint64(11)).Return(&entity.Group{Id: 11, Name: "ops"}, nil)
+		groupRepository.EXPECT().FindMemberIds(uint64(11)).Return([]uint64{123}, nil)
+		w = doRequest(http.MethodGet, "/groups/11/members", "", reader.Token)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"accountIds": [123]}`, w.Body.String())
+
+		groupManager.EXPECT().DeleteGroup(uint64(10)).Return(&domain.ErrGroupHasChildren{})
+		groupManager.EXPECT().DeleteGroup(uint64(11)).Return(nil)
+		w = doRequest(http.MethodDelete, "/groups/10", "", writer.Token)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		w = doRequest(http.MethodDelete, "/groups/11", "", writer.Token)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		w = doRequest(http.MethodDelete, "/groups/abc", "", writer.Token)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
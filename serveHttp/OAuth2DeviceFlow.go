@@ -0,0 +1,221 @@
+package serveHttp
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"hallo/domain/entity"
+	"hallo/service/auth"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// DeviceAuthorizationForm is requested by the device, which is usually a public client
+type DeviceAuthorizationForm struct {
+	Scope string `form:"scope"`
+	ClientCredentialsForm
+}
+
+// DeviceVerificationForm is posted by the verification page, Decision is "approve" or "deny"
+type DeviceVerificationForm struct {
+	UserCode     string `form:"user_code"`
+	Organization string `form:"organization"`
+	Name         string `form:"name"`
+	Secret       string `form:"secret"`
+	MfaCode      string `form:"mfa_code"`
+	Decision     string `form:"decision"`
+}
+
+// deviceAuthorization starts the device flow, the device shows the user code and polls the token endpoint by device code
+func (handler *OAuth2Handler) deviceAuthorization(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	var form DeviceAuthorizationForm
+	if err := c.ShouldBind(&form); err != nil {
+		respondOAuthError(c, http.StatusBadRequest, "invalid_request", "bad request body")
+		return
+	}
+	client, ok := handler.authenticateClient(c, &form.ClientCredentialsForm)
+	if !ok {
+		return
+	}
+	if description := handler.scopeError(client, form.Scope); description != "" {
+		respondOAuthError(c, http.StatusBadRequest, "invalid_scope", description)
+		return
+	}
+
+	deviceCode, authorization, err := handler.DeviceFlow.Start(client.Id, form.Scope)
+	if err != nil {
+		log.Println(err)
+		respondOAuthError(c, http.StatusInternalServerError, "server_error", "failed to start device authorization")
+		return
+	}
+	userCode := auth.FormatUserCode(authorization.UserCode)
+	verificationUri := handler.verificationUri(c)
+	c.JSON(http.StatusOK, gin.H{
+		"device_code":               deviceCode,
+		"user_code":                 userCode,
+		"verification_uri":          verificationUri,
+		"verification_uri_complete": verificationUri + "?" + url.Values{"user_code": {userCode}}.Encode(),
+		"expires_in":                int(auth.DeviceCodeExpiration.Seconds()),
+		"interval":                  authorization.PollInterval,
+	})
+}
+
+func (handler *OAuth2Handler) verificationUri(c *gin.Context) string {
+	if handler.VerificationUri != "" {
+		return handler.VerificationUri
+	}
+	return handler.BaseUrl + strings.TrimSuffix(c.Request.URL.Path, "/device_authorization") + "/device"
+}
+
+// devicePage is the verification page, the user code is filled when it is in the query
+func (handler *OAuth2Handler) devicePage(c *gin.Context) {
+	data := &devicePageData{UserCode: c.Query("user_code")}
+	if data.UserCode != "" {
+		authorization, err := handler.DeviceFlow.FindPending(data.UserCode)
+		if err != nil {
+			log.Println(err)
+			data.Error = auth.ErrUserCodeInvalid.Error()
+			renderDevicePage(c, http.StatusBadRequest, data)
+			return
+		}
+		data.Client, data.Scope = handler.deviceClient(authorization), authorization.Scope
+	}
+	renderDevicePage(c, http.StatusOK, data)
+}
+
+func (handler *OAuth2Handler) verifyDevice(c *gin.Context) {
+	var form DeviceVerificationForm
+	if err := c.ShouldBind(&form); err != nil {
+		renderAuthorizeError(c, http.StatusBadRequest, "bad request parameters")
+		return
+	}
+	data := &devicePageData{UserCode: form.UserCode, Organization: form.Organization, Name: form.Name}
+
+	authorization, err := handler.DeviceFlow.FindPending(form.UserCode)
+	if errors.Is(err, auth.ErrUserCodeInvalid) {
+		data.Error = err.Error()
+		renderDevicePage(c, http.StatusBadRequest, data)
+		return
+	} else if err != nil {
+		log.Println(err)
+		renderAuthorizeError(c, http.StatusInternalServerError, "failed to load device authorization")
+		return
+	}
+	data.Client, data.Scope = handler.deviceClient(authorization), authorization.Scope
+
+	// the account is authenticated by either decision, so that the device of others can't be denied
+	account, err := handler.authenticateAccount(form.Organization, form.Name, form.Secret, form.MfaCode)
+	if err != nil {
+		log.Println(err)
+		data.Error = "account not exist or secret is not match"
+		renderDevicePage(c, http.StatusUnauthorized, data)
+		return
+	}
+	if form.Decision != "approve" {
+		if err := handler.DeviceFlow.Deny(form.UserCode); err != nil {
+			log.Println(err)
+		}
+		renderHtml(c, http.StatusOK, deviceResultTemplate, "The device is denied.")
+		return
+	}
+
+	principal, err := handler.PrincipalLoader.Load(account)
+	if err != nil {
+		log.Println(err)
+		renderAuthorizeError(c, http.StatusInternalServerError, "failed to load the account")
+		return
+	}
+
+	if err := handler.DeviceFlow.Approve(form.UserCode, principal.GrantTo(authorization.ClientId, authorization.Scope)); errors.Is(err, auth.ErrUserCodeInvalid) {
+		data.Error = err.Error()
+		renderDevicePage(c, http.StatusBadRequest, data)
+		return
+	} else if err != nil {
+		log.Println(err)
+		renderAuthorizeError(c, http.StatusInternalServerError, "failed to approve the device")
+		return
+	}
+	renderHtml(c, http.StatusOK, deviceResultTemplate, "The device is connected, you can return to it now.")
+}
+
+// deviceClient return nil when the client is not found, the page is rendered without the name of client
+func (handler *OAuth2Handler) deviceClient(authorization *entity.DeviceAuthorization) *entity.OAuthClient {
+	client, err := handler.OAuthClientRepository.FindById(authorization.ClientId)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	return client
+}
+
+// respondDevicePollError responds the errors of RFC 8628 section 3.5, the device keeps polling on
+// authorization_pending and slow_down
+func respondDevicePollError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, auth.ErrAuthorizationPending):
+		respondOAuthError(c, http.StatusBadRequest, "authorization_pending", err.Error())
+	case errors.Is(err, auth.ErrSlowDown):
+		respondOAuthError(c, http.StatusBadRequest, "slow_down", err.Error())
+	case errors.Is(err, auth.ErrAccessDenied):
+		respondOAuthError(c, http.StatusBadRequest, "access_denied", err.Error())
+	case errors.Is(err, auth.ErrDeviceCodeExpired):
+		respondOAuthError(c, http.StatusBadRequest, "expired_token", err.Error())
+	case errors.Is(err, auth.ErrDeviceCodeInvalid):
+		respondOAuthError(c, http.StatusBadRequest, "invalid_grant", err.Error())
+	default:
+		log.Println(err)
+		respondOAuthError(c, http.StatusInternalServerError, "server_error", "failed to poll device authorization")
+	}
+}
+
+type devicePageData struct {
+	UserCode     string
+	Client       *entity.OAuthClient
+	Scope        string
+	Organization string
+	Name         string
+	Error        string
+}
+
+var devicePageTemplate = template.Must(template.New("device.html").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Connect a device</title></head>
+<body>
+<h1>{{if .Client}}Connect {{.Client.Name}}{{else}}Connect a device{{end}}</h1>
+{{if .Scope}}<p>The device requests access to: {{.Scope}}</p>{{end}}
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<form method="post" action="device">
+<p><label>Code shown on the device <input type="text" name="user_code" value="{{.UserCode}}" required></label></p>
+<p><label>Organization <input type="text" name="organization" value="{{.Organization}}"></label></p>
+<p><label>Name <input type="text" name="name" value="{{.Name}}" required></label></p>
+<p><label>Secret <input type="password" name="secret" required></label></p>
+<p><label>Verification code (when two-factor authentication is enabled) <input type="text" name="mfa_code" autocomplete="one-time-code" inputmode="numeric"></label></p>
+<p>
+<button type="submit" name="decision" value="approve">Allow</button>
+<button type="submit" name="decision" value="deny">Deny</button>
+</p>
+</form>
+</body>
+</html>
+`))
+
+var deviceResultTemplate = template.Must(template.New("device_result.html").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Connect a device</title></head>
+<body>
+<h1>Connect a device</h1>
+<p>{{.}}</p>
+</body>
+</html>
+`))
+
+func renderDevicePage(c *gin.Context, status int, data *devicePageData) {
+	renderHtml(c, status, devicePageTemplate, data)
+}
@@ -0,0 +1,763 @@
+package serveHttp
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/jinzhu/gorm"
+	"hallo/domain"
+	"hallo/domain/entity"
+	"hallo/service/auth"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OAuth2Handler is the authorization server of OAuth2 (RFC 6749), the authorization code grant with PKCE
+// (RFC 7636) is supported for clients, and the client credentials grant for service accounts.
+// Resource servers introspect (RFC 7662) tokens by client credentials, and clients revoke (RFC 7009) the ones issued to them.
+// The device authorization grant (RFC 8628) is enabled when DeviceFlow is configured, the verification page is
+// at VerificationUri, or under BaseUrl when it is empty. BaseUrl is the external URL of hallo, e.g. https://hallo.example.com,
+// the device flow is disabled when neither is configured.
+// The authorization endpoint renders a page on which the account signs in and approves the client, the code of
+// second factor is required on the page when the account has one and SecondFactorManager is configured, which locks
+// the second factors of account after too many failed codes.
+// It is also the OpenID Connect provider when TokenService has JwtIssuer, which signs the ID tokens.
+type OAuth2Handler struct {
+	AccountManager        domain.AccountManager
+	AccountRepository     domain.AccountRepository
+	PrincipalLoader       *PrincipalLoader
+	OAuthClientManager    domain.OAuthClientManager
+	OAuthClientRepository domain.OAuthClientRepository
+	ServiceAccountManager domain.ServiceAccountManager
+	TokenService          *auth.TokenService
+	DeviceFlow            *auth.DeviceFlow
+	VerificationUri       string
+	BaseUrl               string
+	SecondFactorManager   domain.SecondFactorManager
+}
+
+type AuthorizeForm struct {
+	ResponseType        string `form:"response_type"`
+	ClientId            string `form:"client_id"`
+	RedirectUri         string `form:"redirect_uri"`
+	Scope               string `form:"scope"`
+	State               string `form:"state"`
+	CodeChallenge       string `form:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method"`
+	Nonce               string `form:"nonce"`
+}
+
+// ConsentForm is posted by the authorize page, Decision is "approve" or "deny"
+type ConsentForm struct {
+	AuthorizeForm
+	Organization string `form:"organization"`
+	Name         string `form:"name"`
+	Secret       string `form:"secret"`
+	MfaCode      string `form:"mfa_code"`
+	Decision     string `form:"decision"`
+}
+
+// ClientCredentialsForm authenticates client by client_id and client_secret when HTTP basic authentication is absent
+type ClientCredentialsForm struct {
+	ClientId     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+}
+
+// TokenForm requests Scope by the client credentials grant only
+type TokenForm struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectUri  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	RefreshToken string `form:"refresh_token"`
+	DeviceCode   string `form:"device_code"`
+	Scope        string `form:"scope"`
+	ClientCredentialsForm
+}
+
+// TokenHintForm is the request of both introspection (RFC 7662) and revocation (RFC 7009),
+// TokenTypeHint is "access_token" or "refresh_token"
+type TokenHintForm struct {
+	Token         string `form:"token" binding:"required"`
+	TokenTypeHint string `form:"token_type_hint"`
+	ClientCredentialsForm
+}
+
+// OAuthClientCreateForm registers public client, which has no secret, when Public is true
+type OAuthClientCreateForm struct {
+	Name         string   `json:"name"         binding:"required"`
+	RedirectUris []string `json:"redirectUris" binding:"required,min=1"`
+	Public       bool     `json:"public"`
+	Scopes       []string `json:"scopes"`
+}
+
+func (handler *OAuth2Handler) RegisterRoutes(r *gin.RouterGroup) {
+	authenticate := auth.AuthenticateByToken(handler.TokenService)
+
+	r.GET("/authorize", handler.authorizePage)
+	r.POST("/authorize", handler.authorize)
+	r.POST("/token", handler.token)
+	r.POST("/introspect", handler.introspect)
+	r.POST("/revoke", handler.revoke)
+	if handler.DeviceFlow != nil && (handler.VerificationUri != "" || handler.BaseUrl != "") {
+		r.POST("/device_authorization", handler.deviceAuthorization)
+		r.GET("/device", handler.devicePage)
+		r.POST("/device", handler.verifyDevice)
+	}
+	r.GET("/userinfo", authenticate, handler.userInfo)
+	r.POST("/userinfo", authenticate, handler.userInfo)
+	r.GET("/clients", authenticate, auth.AuthenticatedCheck(), auth.RequirePermission(domain.PermissionClientRead), handler.listClients)
+	r.POST("/clients", authenticate, auth.AuthenticatedCheck(), auth.RequirePermission(domain.PermissionClientWrite), handler.createClient)
+}
+
+func (handler *OAuth2Handler) authorizePage(c *gin.Context) {
+	var form AuthorizeForm
+	if err := c.ShouldBindQuery(&form); err != nil {
+		renderAuthorizeError(c, http.StatusBadRequest, "bad request parameters")
+		return
+	}
+	client, redirectUri, ok := handler.validateAuthorizeRequest(c, &form)
+	if !ok {
+		return
+	}
+	renderAuthorizePage(c, http.StatusOK, &authorizePageData{Client: client, Form: &form, RedirectUri: redirectUri})
+}
+
+func (handler *OAuth2Handler) authorize(c *gin.Context) {
+	var form ConsentForm
+	if err := c.ShouldBind(&form); err != nil {
+		renderAuthorizeError(c, http.StatusBadRequest, "bad request parameters")
+		return
+	}
+	client, redirectUri, ok := handler.validateAuthorizeRequest(c, &form.AuthorizeForm)
+	if !ok {
+		return
+	}
+	if form.Decision != "approve" {
+		redirectWithError(c, redirectUri, form.State, "access_denied", "the request is denied by the account")
+		return
+	}
+
+	account, err := handler.authenticateAccount(form.Organization, form.Name, form.Secret, form.MfaCode)
+	if err != nil {
+		log.Println(err)
+		renderAuthorizePage(c, http.StatusUnauthorized, &authorizePageData{Client: client, Form: &form.AuthorizeForm,
+			RedirectUri: redirectUri, Organization: form.Organization, Name: form.Name,
+			Error: "account not exist or secret is not match"})
+		return
+	}
+	principal, err := handler.PrincipalLoader.Load(account)
+	if err != nil {
+		log.Println(err)
+		redirectWithError(c, redirectUri, form.State, "server_error", "failed to load the account")
+		return
+	}
+
+	code, err := handler.TokenService.IssueAuthorizationCode(&auth.AuthorizationCode{
+		ClientId:      client.Id,
+		RedirectUri:   form.RedirectUri,
+		Scope:         form.Scope,
+		CodeChallenge: form.CodeChallenge,
+		Nonce:         form.Nonce,
+		AuthTime:      time.Now(),
+		Principal:     principal.GrantTo(client.Id, form.Scope),
+	})
+	if err != nil {
+		log.Println(err)
+		redirectWithError(c, redirectUri, form.State, "server_error", "failed to issue authorization code")
+		return
+	}
+
+	redirect(c, redirectUri, url.Values{"code": {code}}, form.State)
+}
+
+// authenticateAccount authenticates the account signing in on the pages, mfaCode is ignored when
+// the account has no second factor
+func (handler *OAuth2Handler) authenticateAccount(organization, name, secret, mfaCode string) (*entity.Account, error) {
+	account, err := handler.AccountManager.AuthenticateInternalIdentity(organization, name, secret)
+	if err != nil || handler.SecondFactorManager == nil {
+		return account, err
+	}
+	hasSecondFactor, err := handler.SecondFactorManager.HasSecondFactor(account.Id)
+	if err != nil {
+		return nil, err
+	}
+	if hasSecondFactor {
+		if err := handler.SecondFactorManager.VerifySecondFactor(account.Id, mfaCode); err != nil {
+			return nil, err
+		}
+	}
+	return account, nil
+}
+
+// validateAuthorizeRequest responds the error page when the client or redirect uri is invalid,
+// the other errors are redirected to the client as RFC 6749 section 4.1.2.1 requires.
+// The redirect uri can be omitted when the client has only one registered.
+func (handler *OAuth2Handler) validateAuthorizeRequest(c *gin.Context, form *AuthorizeForm) (*entity.OAuthClient, string, bool) {
+	client, err := handler.OAuthClientRepository.FindById(form.ClientId)
+	if gorm.IsRecordNotFoundError(err) {
+		renderAuthorizeError(c, http.StatusBadRequest, "client is not registered")
+		return nil, "", false
+	} else if err != nil {
+		log.Println(err)
+		renderAuthorizeError(c, http.StatusInternalServerError, "failed to load client")
+		return nil, "", false
+	}
+
+	redirectUri := form.RedirectUri
+	if registered := client.RedirectUriList(); redirectUri == "" && len(registered) == 1 {
+		redirectUri = registered[0]
+	}
+	if !client.AllowsRedirectUri(redirectUri) {
+		renderAuthorizeError(c, http.StatusBadRequest, "redirect uri is not registered")
+		return nil, "", false
+	}
+
+	if form.ResponseType != "code" {
+		redirectWithError(c, redirectUri, form.State, "unsupported_response_type", "only code is supported")
+		return nil, "", false
+	}
+	if form.CodeChallengeMethod != auth.CodeChallengeMethodS256 || !auth.IsCodeChallengeValid(form.CodeChallenge) {
+		redirectWithError(c, redirectUri, form.State, "invalid_request", "code_challenge with S256 method is required")
+		return nil, "", false
+	}
+	if description := handler.scopeError(client, form.Scope); description != "" {
+		redirectWithError(c, redirectUri, form.State, "invalid_scope", description)
+		return nil, "", false
+	}
+	return client, redirectUri, true
+}
+
+// scopeError describes the first scope which is not allowed to the client, it is empty when all are allowed.
+// The identity scopes of OpenID Connect are allowed to every client, but openid requires signing keys
+func (handler *OAuth2Handler) scopeError(client *entity.OAuthClient, scope string) string {
+	for _, s := range strings.Fields(scope) {
+		if s == "openid" && handler.TokenService.JwtIssuer == nil {
+			return "openid is not supported without signing keys"
+		}
+		if !auth.IsIdentityScope(s) && !auth.ScopeContains(client.Scopes, s) {
+			return "scope " + s + " is not allowed to the client"
+		}
+	}
+	return ""
+}
+
+// refresh tokens are bound to the clients they are issued to
+func (handler *OAuth2Handler) token(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	var form TokenForm
+	if err := c.ShouldBind(&form); err != nil {
+		respondOAuthError(c, http.StatusBadRequest, "invalid_request", "bad request body")
+		return
+	}
+	// service accounts are not OAuth2 clients
+	if form.GrantType == "client_credentials" {
+		handler.clientCredentials(c, &form)
+		return
+	}
+	client, ok := handler.authenticateClient(c, &form.ClientCredentialsForm)
+	if !ok {
+		return
+	}
+
+	var principal auth.Principal
+	var scope, nonce string
+	var authTime time.Time
+	switch form.GrantType {
+	case "authorization_code":
+		code, err := handler.TokenService.TakeAuthorizationCode(form.Code)
+		if err != nil {
+			log.Println(err)
+			respondOAuthError(c, http.StatusInternalServerError, "server_error", "failed to load authorization code")
+			return
+		}
+		if code == nil || code.ClientId != client.Id || code.RedirectUri != form.RedirectUri {
+			respondOAuthError(c, http.StatusBadRequest, "invalid_grant", "authorization code is invalid")
+			return
+		}
+		if !auth.VerifyCodeChallenge(code.CodeChallenge, form.CodeVerifier) {
+			respondOAuthError(c, http.StatusBadRequest, "invalid_grant", "code_verifier is not match")
+			return
+		}
+		principal, scope, nonce, authTime = code.Principal, code.Scope, code.Nonce, code.AuthTime
+	case deviceCodeGrantType:
+		if handler.DeviceFlow == nil {
+			respondOAuthError(c, http.StatusBadRequest, "unsupported_grant_type", "device authorization grant is not enabled")
+			return
+		}
+		authorization, approved, err := handler.DeviceFlow.Poll(form.DeviceCode, client.Id)
+		if err != nil {
+			respondDevicePollError(c, err)
+			return
+		}
+		principal, scope, authTime = *approved, authorization.Scope, *authorization.DecideTime
+	case "refresh_token":
+		sc, refreshToken, err := handler.TokenService.Refresh(form.RefreshToken, client.Id)
+		if err != nil {
+			log.Println(err)
+			if errors.Is(err, auth.ErrRefreshTokenInvalid) || errors.Is(err, auth.ErrRefreshTokenReused) {
+				respondOAuthError(c, http.StatusBadRequest, "invalid_grant", err.Error())
+				return
+			}
+			respondOAuthError(c, http.StatusInternalServerError, "server_error", "failed to refresh token")
+			return
+		}
+		handler.respondToken(c, sc, refreshToken, "", "")
+		return
+	default:
+		respondOAuthError(c, http.StatusBadRequest, "unsupported_grant_type", "only authorization_code, refresh_token, client_credentials and device_code are supported")
+		return
+	}
+
+	// the account may be changed or deleted after it approves
+	reloaded, err := handler.PrincipalLoader.Reload(principal)
+	if errors.Is(err, auth.ErrPrincipalNotFound) {
+		respondOAuthError(c, http.StatusBadRequest, "invalid_grant", "account of the grant is not found")
+		return
+	} else if err != nil {
+		log.Println(err)
+		respondOAuthError(c, http.StatusInternalServerError, "server_error", "failed to load the account")
+		return
+	}
+	principal = *reloaded
+
+	sc, err := handler.TokenService.Issue(principal)
+	if err != nil {
+		log.Println(err)
+		respondOAuthError(c, http.StatusInternalServerError, "server_error", "failed to issue token")
+		return
+	}
+	refreshToken, err := handler.TokenService.IssueRefreshToken(principal)
+	if err != nil {
+		log.Println(err)
+		respondOAuthError(c, http.StatusInternalServerError, "server_error", "failed to issue token")
+		return
+	}
+	idToken := ""
+	if auth.ScopeContains(scope, "openid") {
+		if idToken, err = handler.signIdToken(client, &principal, authTime, nonce); err != nil {
+			log.Println(err)
+			respondOAuthError(c, http.StatusInternalServerError, "server_error", "failed to issue id token")
+			return
+		}
+	}
+	handler.respondToken(c, sc, refreshToken, scope, idToken)
+}
+
+// clientCredentials issues access token to the service account without refresh token, the scopes requested
+// must be allowed to the service account, and all allowed scopes are granted when scope is absent.
+// The known permissions of the scopes are the permissions of the token
+func (handler *OAuth2Handler) clientCredentials(c *gin.Context, form *TokenForm) {
+	clientId, clientSecret, basic, ok := clientCredentialsOf(c, &form.ClientCredentialsForm)
+	if !ok {
+		return
+	}
+	serviceAccount, err := handler.ServiceAccountManager.Authenticate(clientId, clientSecret)
+	if err != nil {
+		respondClientAuthenticationError(c, err, basic)
+		return
+	}
+
+	scopes := serviceAccount.ScopeList()
+	if form.Scope != "" {
+		scopes = strings.Fields(form.Scope)
+		for _, scope := range scopes {
+			if !auth.ScopeContains(serviceAccount.Scopes, scope) {
+				respondOAuthError(c, http.StatusBadRequest, "invalid_scope", "scope "+scope+" is not allowed")
+				return
+			}
+		}
+	}
+	scope := strings.Join(scopes, " ")
+	var permissions []string
+	for _, s := range scopes {
+		if domain.IsKnownPermission(s) {
+			permissions = append(permissions, s)
+		}
+	}
+
+	sc, err := handler.TokenService.Issue(auth.Principal{Id: serviceAccount.Id, Name: serviceAccount.Name,
+		ClientId: serviceAccount.ClientId, Scope: scope, ServiceAccount: true, Permissions: permissions})
+	if err != nil {
+		log.Println(err)
+		respondOAuthError(c, http.StatusInternalServerError, "server_error", "failed to issue token")
+		return
+	}
+	handler.respondToken(c, sc, "", scope, "")
+}
+
+// respondToken omits refresh_token when it is empty
+func (handler *OAuth2Handler) respondToken(c *gin.Context, sc *auth.SecurityContext, refreshToken, scope, idToken string) {
+	response := gin.H{
+		"access_token": sc.Token,
+		"token_type":   "Bearer",
+		"expires_in":   int(handler.TokenService.Expiration().Seconds()),
+	}
+	if refreshToken != "" {
+		response["refresh_token"] = refreshToken
+	}
+	if scope != "" {
+		response["scope"] = scope
+	}
+	if idToken != "" {
+		response["id_token"] = idToken
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// signIdToken loads the account again, so that the claims are up to date
+func (handler *OAuth2Handler) signIdToken(client *entity.OAuthClient, principal *auth.Principal, authTime time.Time, nonce string) (string, error) {
+	account, err := handler.AccountRepository.FindById(principal.Id)
+	if err != nil {
+		return "", err
+	}
+	claims := accountClaims(account, principal)
+	claims["auth_time"] = authTime.Unix()
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+	return handler.TokenService.JwtIssuer.SignIdToken(client.Id, claims)
+}
+
+// userInfo responds the claims of the account as the UserInfo endpoint of OpenID Connect,
+// errors are described by WWW-Authenticate header as RFC 6750 requires
+func (handler *OAuth2Handler) userInfo(c *gin.Context) {
+	sc := auth.LoadFromRequestContext(c)
+	if sc == nil {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token"})
+		return
+	}
+	if !sc.Principal.HasScope("openid") {
+		c.Header("WWW-Authenticate", `Bearer error="insufficient_scope", scope="openid"`)
+		c.JSON(http.StatusForbidden, gin.H{"error": "insufficient_scope"})
+		return
+	}
+
+	account, err := handler.AccountRepository.FindById(sc.Principal.Id)
+	if gorm.IsRecordNotFoundError(err) {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token"})
+		return
+	} else if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load account"})
+		return
+	}
+	c.JSON(http.StatusOK, accountClaims(account, &sc.Principal))
+}
+
+// accountClaims are the standard claims of OpenID Connect which are granted by the scopes of principal
+func accountClaims(account *entity.Account, principal *auth.Principal) map[string]interface{} {
+	claims := map[string]interface{}{"sub": strconv.FormatUint(account.Id, 10)}
+	if principal.HasScope("profile") {
+		claims["name"] = account.Name
+		claims["preferred_username"] = account.Name
+	}
+	if principal.HasScope("email") {
+		claims["email"] = account.Email
+		claims["email_verified"] = account.EmailVerified
+	}
+	return claims
+}
+
+func (handler *OAuth2Handler) authenticateClient(c *gin.Context, form *ClientCredentialsForm) (*entity.OAuthClient, bool) {
+	clientId, clientSecret, basic, ok := clientCredentialsOf(c, form)
+	if !ok {
+		return nil, false
+	}
+	client, err := handler.OAuthClientManager.AuthenticateClient(clientId, clientSecret)
+	if err != nil {
+		respondClientAuthenticationError(c, err, basic)
+		return nil, false
+	}
+	return client, true
+}
+
+// introspect tells resource servers whether the token is active, only confidential clients and service accounts are allowed.
+// Tokens are not bound to clients, so any token issued by hallo can be introspected
+func (handler *OAuth2Handler) introspect(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	var form TokenHintForm
+	if err := c.ShouldBind(&form); err != nil {
+		respondOAuthError(c, http.StatusBadRequest, "invalid_request", "bad request body")
+		return
+	}
+	if _, ok := handler.authenticateCaller(c, &form.ClientCredentialsForm, false); !ok {
+		return
+	}
+
+	introspection, err := handler.TokenService.Introspect(form.Token, form.TokenTypeHint)
+	if err != nil {
+		log.Println(err)
+		respondOAuthError(c, http.StatusInternalServerError, "server_error", "failed to introspect token")
+		return
+	}
+	if introspection == nil {
+		c.JSON(http.StatusOK, gin.H{"active": false})
+		return
+	}
+
+	principal := introspection.Principal
+	response := gin.H{
+		"active":   true,
+		"sub":      strconv.FormatUint(principal.Id, 10),
+		"username": principal.Name,
+		"exp":      introspection.ExpireTime.Unix(),
+	}
+	if introspection.TokenType == "access_token" {
+		response["token_type"] = "Bearer"
+	}
+	if principal.ClientId != "" {
+		response["client_id"] = principal.ClientId
+	}
+	if principal.Scope != "" {
+		response["scope"] = principal.Scope
+	}
+	if principal.ServiceAccount {
+		response["service_account"] = true
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// revoke responds OK for unknown tokens as RFC 7009 requires, public clients are allowed to revoke the tokens they hold.
+// The tokens issued to other clients are refused
+func (handler *OAuth2Handler) revoke(c *gin.Context) {
+	var form TokenHintForm
+	if err := c.ShouldBind(&form); err != nil {
+		respondOAuthError(c, http.StatusBadRequest, "invalid_request", "bad request body")
+		return
+	}
+	clientId, ok := handler.authenticateCaller(c, &form.ClientCredentialsForm, true)
+	if !ok {
+		return
+	}
+
+	if err := handler.TokenService.RevokeToken(form.Token, clientId); errors.Is(err, auth.ErrTokenNotOfClient) {
+		respondOAuthError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	} else if err != nil {
+		log.Println(err)
+		respondOAuthError(c, http.StatusServiceUnavailable, "temporarily_unavailable", "failed to revoke token")
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// authenticateCaller accepts both OAuth2 clients and service accounts, the public clients are rejected unless allowPublic.
+// The client id of the caller is returned
+func (handler *OAuth2Handler) authenticateCaller(c *gin.Context, form *ClientCredentialsForm, allowPublic bool) (string, bool) {
+	clientId, clientSecret, basic, ok := clientCredentialsOf(c, form)
+	if !ok {
+		return "", false
+	}
+
+	client, err := handler.OAuthClientManager.AuthenticateClient(clientId, clientSecret)
+	if err == nil {
+		if client.IsPublic() && !allowPublic {
+			respondClientAuthenticationError(c, &domain.ErrOAuthClientAuthenticationFailure{}, basic)
+			return "", false
+		}
+		return client.Id, true
+	}
+	var failure *domain.ErrOAuthClientAuthenticationFailure
+	if errors.As(err, &failure) && handler.ServiceAccountManager != nil {
+		var serviceAccount *entity.ServiceAccount
+		if serviceAccount, err = handler.ServiceAccountManager.Authenticate(clientId, clientSecret); err == nil {
+			return serviceAccount.ClientId, true
+		}
+	}
+	respondClientAuthenticationError(c, err, basic)
+	return "", false
+}
+
+// clientCredentialsOf prefers HTTP basic authentication, in which client id and secret are form encoded.
+// basic tells whether HTTP basic authentication is used
+func clientCredentialsOf(c *gin.Context, form *ClientCredentialsForm) (clientId, clientSecret string, basic, ok bool) {
+	clientId, clientSecret = form.ClientId, form.ClientSecret
+	username, password, basic := c.Request.BasicAuth()
+	if basic {
+		var err error
+		if clientId, err = url.QueryUnescape(username); err != nil {
+			respondOAuthError(c, http.StatusBadRequest, "invalid_request", "bad client id")
+			return "", "", basic, false
+		}
+		if clientSecret, err = url.QueryUnescape(password); err != nil {
+			respondOAuthError(c, http.StatusBadRequest, "invalid_request", "bad client secret")
+			return "", "", basic, false
+		}
+	}
+	return clientId, clientSecret, basic, true
+}
+
+func respondClientAuthenticationError(c *gin.Context, err error, basic bool) {
+	log.Println(err)
+	var failure *domain.ErrOAuthClientAuthenticationFailure
+	if errors.As(err, &failure) {
+		if basic {
+			c.Header("WWW-Authenticate", `Basic realm="hallo"`)
+		}
+		respondOAuthError(c, http.StatusUnauthorized, "invalid_client", failure.Error())
+	} else {
+		respondOAuthError(c, http.StatusInternalServerError, "server_error", "failed to authenticate client")
+	}
+}
+
+func (handler *OAuth2Handler) listClients(c *gin.Context) {
+	clients, err := handler.OAuthClientRepository.FindAll()
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list clients"})
+		return
+	}
+	views := make([]gin.H, 0, len(clients))
+	for i := range clients {
+		views = append(views, clientView(&clients[i]))
+	}
+	c.JSON(http.StatusOK, views)
+}
+
+// the secret is responded only once on creation
+func (handler *OAuth2Handler) createClient(c *gin.Context) {
+	var form OAuthClientCreateForm
+	if err := c.ShouldBindJSON(&form); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bad request body"})
+		return
+	}
+
+	client, secret, err := handler.OAuthClientManager.CreateClient(entity.OAuthClientCreateRequest{
+		Name: form.Name, RedirectUris: form.RedirectUris, Public: form.Public, Scopes: form.Scopes})
+	if err != nil {
+		log.Printf("error: %v\n", err)
+		var validationErrs validator.ValidationErrors
+		var unknownPermission *domain.ErrUnknownPermission
+		if errors.As(err, &unknownPermission) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": unknownPermission.Error()})
+		} else if errors.As(err, &validationErrs) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "bad request body"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create client"})
+		}
+		return
+	}
+
+	view := clientView(client)
+	if secret != "" {
+		view["secret"] = secret
+	}
+	c.JSON(http.StatusCreated, view)
+}
+
+func clientView(client *entity.OAuthClient) gin.H {
+	return gin.H{
+		"id":             client.Id,
+		"name":           client.Name,
+		"redirectUris":   client.RedirectUriList(),
+		"public":         client.IsPublic(),
+		"scopes":         client.ScopeList(),
+		"createTime":     client.CreateTime,
+		"lastUpdateTime": client.LastUpdateTime,
+	}
+}
+
+func respondOAuthError(c *gin.Context, status int, code, description string) {
+	c.JSON(status, gin.H{"error": code, "error_description": description})
+}
+
+func redirectWithError(c *gin.Context, redirectUri, state, code, description string) {
+	redirect(c, redirectUri, url.Values{"error": {code}, "error_description": {description}}, state)
+}
+
+// redirect keeps the query of redirectUri, state is appended when it is not empty
+func redirect(c *gin.Context, redirectUri string, params url.Values, state string) {
+	target, err := url.Parse(redirectUri)
+	if err != nil {
+		renderAuthorizeError(c, http.StatusBadRequest, "redirect uri is invalid")
+		return
+	}
+	query := target.Query()
+	for name, values := range params {
+		query[name] = values
+	}
+	if state != "" {
+		query.Set("state", state)
+	}
+	target.RawQuery = query.Encode()
+	c.Redirect(http.StatusFound, target.String())
+}
+
+type authorizePageData struct {
+	Client       *entity.OAuthClient
+	Form         *AuthorizeForm
+	RedirectUri  string
+	Organization string
+	Name         string
+	Error        string
+}
+
+var authorizePageTemplate = template.Must(template.New("authorize.html").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Sign in to {{.Client.Name}}</title></head>
+<body>
+<h1>Sign in to {{.Client.Name}}</h1>
+{{if .Form.Scope}}<p>{{.Client.Name}} requests access to: {{.Form.Scope}}</p>{{end}}
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<form method="post" action="authorize">
+<input type="hidden" name="response_type" value="{{.Form.ResponseType}}">
+<input type="hidden" name="client_id" value="{{.Form.ClientId}}">
+<input type="hidden" name="redirect_uri" value="{{.Form.RedirectUri}}">
+<input type="hidden" name="scope" value="{{.Form.Scope}}">
+<input type="hidden" name="state" value="{{.Form.State}}">
+<input type="hidden" name="code_challenge" value="{{.Form.CodeChallenge}}">
+<input type="hidden" name="code_challenge_method" value="{{.Form.CodeChallengeMethod}}">
+<input type="hidden" name="nonce" value="{{.Form.Nonce}}">
+<p><label>Organization <input type="text" name="organization" value="{{.Organization}}"></label></p>
+<p><label>Name <input type="text" name="name" value="{{.Name}}" required></label></p>
+<p><label>Secret <input type="password" name="secret" required></label></p>
+<p><label>Verification code (when two-factor authentication is enabled) <input type="text" name="mfa_code" autocomplete="one-time-code" inputmode="numeric"></label></p>
+<p>
+<button type="submit" name="decision" value="approve">Allow</button>
+<button type="submit" name="decision" value="deny" formnovalidate>Deny</button>
+</p>
+</form>
+</body>
+</html>
+`))
+
+var authorizeErrorTemplate = template.Must(template.New("authorize_error.html").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Authorization failed</title></head>
+<body>
+<h1>Authorization failed</h1>
+<p>{{.}}</p>
+</body>
+</html>
+`))
+
+// the pages are not allowed to be framed, which prevents clickjacking
+func renderAuthorizePage(c *gin.Context, status int, data *authorizePageData) {
+	renderHtml(c, status, authorizePageTemplate, data)
+}
+
+func renderAuthorizeError(c *gin.Context, status int, message string) {
+	renderHtml(c, status, authorizeErrorTemplate, message)
+}
+
+func renderHtml(c *gin.Context, status int, t *template.Template, data interface{}) {
+	c.Header("X-Frame-Options", "DENY")
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(status)
+	if err := t.Execute(c.Writer, data); err != nil {
+		log.Println(err)
+	}
+}
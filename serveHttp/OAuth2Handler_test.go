@@ -0,0 +1,840 @@
+package serveHttp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/golang/mock/gomock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"hallo/domain"
+	"hallo/domain/entity"
+	"hallo/service/auth"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestOAuth2Handler_authorizationCode(it *testing.T) {
+	// the example of RFC 7636 appendix B
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	client := &entity.OAuthClient{Id: "web", Name: "Web App", RedirectUris: "https://app.test/callback https://app.test/other",
+		Scopes: "accounts:read groups:read"}
+
+	setUp := func(t *testing.T) (*gin.Engine, *domain.MockAccountManager, *domain.MockOAuthClientManager, *auth.TokenService, func()) {
+		mockCtl := gomock.NewController(t)
+		accountManager := domain.NewMockAccountManager(mockCtl)
+		accountRepository := domain.NewMockAccountRepository(mockCtl)
+		roleRepository := domain.NewMockRoleRepository(mockCtl)
+		groupManager := domain.NewMockGroupManager(mockCtl)
+		clientManager := domain.NewMockOAuthClientManager(mockCtl)
+		clientRepository := domain.NewMockOAuthClientRepository(mockCtl)
+		tokenService := &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), OneTimeTokenStore: auth.NewMemoryOneTimeTokenStore(), RefreshTokenStore: auth.NewMemoryRefreshTokenStore()}
+		handler := OAuth2Handler{
+			AccountManager: accountManager,
+			PrincipalLoader: &PrincipalLoader{AccountRepository: accountRepository, RoleRepository: roleRepository,
+				GroupManager: groupManager},
+			OAuthClientManager:    clientManager,
+			OAuthClientRepository: clientRepository,
+			TokenService:          tokenService,
+		}
+
+		clientRepository.EXPECT().FindById("web").Return(client, nil).AnyTimes()
+		clientRepository.EXPECT().FindById("unknown").Return(nil, gorm.ErrRecordNotFound).AnyTimes()
+		// the account 456 is deleted after it approves
+		accountRepository.EXPECT().FindById(uint64(123)).Return(&entity.Account{Id: 123, Name: "ann"}, nil).AnyTimes()
+		accountRepository.EXPECT().FindById(uint64(456)).Return(nil, gorm.ErrRecordNotFound).AnyTimes()
+		roleRepository.EXPECT().FindGrantsByAccountId(uint64(123)).Return([]string{"admin"}, []string{"accounts:*"}, nil).AnyTimes()
+		roleRepository.EXPECT().FindGrantsByAccountId(gomock.Any()).Return([]string{}, []string{}, nil).AnyTimes()
+		groupManager.EXPECT().FindMemberships(gomock.Any()).Return([]string{}, nil).AnyTimes()
+
+		engine := gin.Default()
+		handler.RegisterRoutes(engine.Group("/oauth2"))
+		return engine, accountManager, clientManager, tokenService, mockCtl.Finish
+	}
+	authorizeParams := func() url.Values {
+		return url.Values{"response_type": {"code"}, "client_id": {"web"}, "redirect_uri": {"https://app.test/callback"},
+			"scope": {"profile"}, "state": {"xyz"}, "code_challenge": {challenge}, "code_challenge_method": {"S256"}}
+	}
+	post := func(engine *gin.Engine, path string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	it.Run("should render login page for valid request", func(t *testing.T) {
+		engine, _, _, _, finish := setUp(t)
+		defer finish()
+
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/oauth2/authorize?"+authorizeParams().Encode(), nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))
+		assert.Contains(t, w.Body.String(), "Sign in to Web App")
+		assert.Contains(t, w.Body.String(), `name="code_challenge" value="`+challenge+`"`)
+	})
+
+	it.Run("should not redirect to unregistered client or redirect uri", func(t *testing.T) {
+		engine, _, _, _, finish := setUp(t)
+		defer finish()
+
+		params := authorizeParams()
+		params.Set("client_id", "unknown")
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/oauth2/authorize?"+params.Encode(), nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		params = authorizeParams()
+		params.Set("redirect_uri", "https://evil.test/callback")
+		w = httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/oauth2/authorize?"+params.Encode(), nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Empty(t, w.Header().Get("Location"))
+	})
+
+	it.Run("should redirect error when code challenge is absent", func(t *testing.T) {
+		engine, _, _, _, finish := setUp(t)
+		defer finish()
+
+		params := authorizeParams()
+		params.Del("code_challenge_method")
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/oauth2/authorize?"+params.Encode(), nil))
+		assert.Equal(t, http.StatusFound, w.Code)
+		location, _ := url.Parse(w.Header().Get("Location"))
+		assert.Equal(t, "invalid_request", location.Query().Get("error"))
+		assert.Equal(t, "xyz", location.Query().Get("state"))
+	})
+
+	it.Run("should redirect access denied when account denies", func(t *testing.T) {
+		engine, _, _, _, finish := setUp(t)
+		defer finish()
+
+		params := authorizeParams()
+		params.Set("decision", "deny")
+		w := post(engine, "/oauth2/authorize", params)
+		assert.Equal(t, http.StatusFound, w.Code)
+		location, _ := url.Parse(w.Header().Get("Location"))
+		assert.Equal(t, "access_denied", location.Query().Get("error"))
+	})
+
+	it.Run("should exchange authorization code with verifier only once", func(t *testing.T) {
+		engine, accountManager, clientManager, tokenService, finish := setUp(t)
+		defer finish()
+
+		accountManager.EXPECT().AuthenticateInternalIdentity("", "ann", "bad").Return(nil, &domain.AccountAuthenticationFailure{})
+		accountManager.EXPECT().AuthenticateInternalIdentity("", "ann", "secret").Return(&entity.Account{Id: 123, Name: "ann"}, nil)
+		params := authorizeParams()
+		params.Set("decision", "approve")
+		params.Set("name", "ann")
+		params.Set("secret", "bad")
+		w := post(engine, "/oauth2/authorize", params)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "account not exist or secret is not match")
+
+		params.Set("secret", "secret")
+		w = post(engine, "/oauth2/authorize", params)
+		assert.Equal(t, http.StatusFound, w.Code)
+		location, _ := url.Parse(w.Header().Get("Location"))
+		assert.Equal(t, "app.test", location.Host)
+		assert.Equal(t, "xyz", location.Query().Get("state"))
+		code := location.Query().Get("code")
+		assert.NotEmpty(t, code)
+
+		clientManager.EXPECT().AuthenticateClient("web", "client-secret").Return(client, nil).Times(3)
+		clientManager.EXPECT().AuthenticateClient("web", "bad-secret").Return(nil, &domain.ErrOAuthClientAuthenticationFailure{})
+		tokenParams := url.Values{"grant_type": {"authorization_code"}, "code": {code}, "redirect_uri": {"https://app.test/callback"},
+			"code_verifier": {verifier}, "client_id": {"web"}, "client_secret": {"bad-secret"}}
+		w = post(engine, "/oauth2/token", tokenParams)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), `"error":"invalid_client"`)
+
+		tokenParams.Del("client_id")
+		tokenParams.Del("client_secret")
+		req := httptest.NewRequest(http.MethodPost, "/oauth2/token", strings.NewReader(tokenParams.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth("web", "client-secret")
+		w = httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+		body := map[string]interface{}{}
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, "Bearer", body["token_type"])
+		assert.Equal(t, "profile", body["scope"])
+		assert.Equal(t, float64(86400), body["expires_in"])
+		assert.NotEmpty(t, body["refresh_token"])
+		sc, err := tokenService.Authenticate(body["access_token"].(string))
+		assert.Nil(t, err)
+		assert.Equal(t, "ann", sc.Principal.Name)
+
+		// the code has been taken
+		tokenParams.Set("client_id", "web")
+		tokenParams.Set("client_secret", "client-secret")
+		w = post(engine, "/oauth2/token", tokenParams)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"error":"invalid_grant"`)
+
+		w = post(engine, "/oauth2/token", url.Values{"grant_type": {"refresh_token"}, "refresh_token": {body["refresh_token"].(string)},
+			"client_id": {"web"}, "client_secret": {"client-secret"}})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	it.Run("should redirect invalid scope when client is not allowed the scope", func(t *testing.T) {
+		engine, _, _, _, finish := setUp(t)
+		defer finish()
+
+		params := authorizeParams()
+		params.Set("scope", "profile accounts:write")
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/oauth2/authorize?"+params.Encode(), nil))
+		assert.Equal(t, http.StatusFound, w.Code)
+		location, _ := url.Parse(w.Header().Get("Location"))
+		assert.Equal(t, "invalid_scope", location.Query().Get("error"))
+	})
+
+	it.Run("should grant the permissions requested by scope only", func(t *testing.T) {
+		engine, accountManager, clientManager, tokenService, finish := setUp(t)
+		defer finish()
+
+		accountManager.EXPECT().AuthenticateInternalIdentity("", "ann", "secret").Return(&entity.Account{Id: 123, Name: "ann"}, nil)
+		params := authorizeParams()
+		params.Set("scope", "profile accounts:read groups:read")
+		params.Set("decision", "approve")
+		params.Set("name", "ann")
+		params.Set("secret", "secret")
+		w := post(engine, "/oauth2/authorize", params)
+		location, _ := url.Parse(w.Header().Get("Location"))
+
+		clientManager.EXPECT().AuthenticateClient("web", "").Return(client, nil).Times(2)
+		w = post(engine, "/oauth2/token", url.Values{"grant_type": {"authorization_code"}, "code": {location.Query().Get("code")},
+			"redirect_uri": {"https://app.test/callback"}, "code_verifier": {verifier}, "client_id": {"web"}})
+		assert.Equal(t, http.StatusOK, w.Code)
+		body := map[string]interface{}{}
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &body))
+		sc, err := tokenService.Authenticate(body["access_token"].(string))
+		assert.Nil(t, err)
+		assert.Equal(t, "web", sc.Principal.ClientId)
+		assert.Equal(t, []string{"accounts:read"}, sc.Principal.Permissions)
+		assert.Nil(t, sc.Principal.Roles)
+		assert.False(t, sc.Principal.IsFirstParty())
+
+		// the grant is kept on refresh
+		w = post(engine, "/oauth2/token", url.Values{"grant_type": {"refresh_token"}, "refresh_token": {body["refresh_token"].(string)},
+			"client_id": {"web"}})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &body))
+		sc, err = tokenService.Authenticate(body["access_token"].(string))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"accounts:read"}, sc.Principal.Permissions)
+		assert.False(t, sc.Principal.IsFirstParty())
+	})
+
+	it.Run("should reject code when account is deleted after approval", func(t *testing.T) {
+		engine, accountManager, clientManager, _, finish := setUp(t)
+		defer finish()
+
+		accountManager.EXPECT().AuthenticateInternalIdentity("", "bob", "secret").Return(&entity.Account{Id: 456, Name: "bob"}, nil)
+		params := authorizeParams()
+		params.Set("decision", "approve")
+		params.Set("name", "bob")
+		params.Set("secret", "secret")
+		w := post(engine, "/oauth2/authorize", params)
+		location, _ := url.Parse(w.Header().Get("Location"))
+
+		clientManager.EXPECT().AuthenticateClient("web", "").Return(client, nil)
+		w = post(engine, "/oauth2/token", url.Values{"grant_type": {"authorization_code"}, "code": {location.Query().Get("code")},
+			"redirect_uri": {"https://app.test/callback"}, "code_verifier": {verifier}, "client_id": {"web"}})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"error":"invalid_grant"`)
+	})
+
+	it.Run("should reject code when verifier is not match", func(t *testing.T) {
+		engine, accountManager, clientManager, _, finish := setUp(t)
+		defer finish()
+
+		accountManager.EXPECT().AuthenticateInternalIdentity("", "ann", "secret").Return(&entity.Account{Id: 123, Name: "ann"}, nil)
+		params := authorizeParams()
+		params.Set("decision", "approve")
+		params.Set("name", "ann")
+		params.Set("secret", "secret")
+		w := post(engine, "/oauth2/authorize", params)
+		location, _ := url.Parse(w.Header().Get("Location"))
+
+		clientManager.EXPECT().AuthenticateClient("web", "").Return(client, nil)
+		w = post(engine, "/oauth2/token", url.Values{"grant_type": {"authorization_code"}, "code": {location.Query().Get("code")},
+			"redirect_uri": {"https://app.test/callback"}, "code_verifier": {strings.Repeat("a", 43)}, "client_id": {"web"}})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"error":"invalid_grant"`)
+	})
+}
+
+func TestOAuth2Handler_secondFactor(it *testing.T) {
+	it.Run("should require code of second factor on authorize page", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		accountManager := domain.NewMockAccountManager(mockCtl)
+		roleRepository := domain.NewMockRoleRepository(mockCtl)
+		groupManager := domain.NewMockGroupManager(mockCtl)
+		clientRepository := domain.NewMockOAuthClientRepository(mockCtl)
+		secondFactorManager := domain.NewMockSecondFactorManager(mockCtl)
+		handler := OAuth2Handler{
+			AccountManager:        accountManager,
+			PrincipalLoader:       &PrincipalLoader{RoleRepository: roleRepository, GroupManager: groupManager},
+			OAuthClientRepository: clientRepository,
+			TokenService:          &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), OneTimeTokenStore: auth.NewMemoryOneTimeTokenStore(), RefreshTokenStore: auth.NewMemoryRefreshTokenStore()},
+			SecondFactorManager:   secondFactorManager,
+		}
+		engine := gin.Default()
+		handler.RegisterRoutes(engine.Group("/oauth2"))
+		post := func(form url.Values) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPost, "/oauth2/authorize", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			return w
+		}
+
+		clientRepository.EXPECT().FindById("web").Return(&entity.OAuthClient{Id: "web", Name: "Web App",
+			RedirectUris: "https://app.test/callback"}, nil).Times(2)
+		accountManager.EXPECT().AuthenticateInternalIdentity("", "ann", "secret").Return(&entity.Account{Id: 123, Name: "ann"}, nil).Times(2)
+		secondFactorManager.EXPECT().HasSecondFactor(uint64(123)).Return(true, nil).Times(2)
+		secondFactorManager.EXPECT().VerifySecondFactor(uint64(123), "").Return(&domain.SecondFactorAuthenticationFailure{})
+		secondFactorManager.EXPECT().VerifySecondFactor(uint64(123), "123456").Return(nil)
+		roleRepository.EXPECT().FindGrantsByAccountId(uint64(123)).Return([]string{}, []string{}, nil)
+		groupManager.EXPECT().FindMemberships(uint64(123)).Return([]string{}, nil)
+
+		params := url.Values{"response_type": {"code"}, "client_id": {"web"}, "state": {"xyz"},
+			"code_challenge": {"E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"}, "code_challenge_method": {"S256"},
+			"decision": {"approve"}, "name": {"ann"}, "secret": {"secret"}}
+		w := post(params)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), `name="mfa_code"`)
+
+		params.Set("mfa_code", "123456")
+		w = post(params)
+		assert.Equal(t, http.StatusFound, w.Code)
+		location, _ := url.Parse(w.Header().Get("Location"))
+		assert.NotEmpty(t, location.Query().Get("code"))
+	})
+}
+
+func TestOAuth2Handler_clients(it *testing.T) {
+	it.Run("should register clients with permission", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		clientManager := domain.NewMockOAuthClientManager(mockCtl)
+		clientRepository := domain.NewMockOAuthClientRepository(mockCtl)
+		tokenService := &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), OneTimeTokenStore: auth.NewMemoryOneTimeTokenStore(), RefreshTokenStore: auth.NewMemoryRefreshTokenStore()}
+		handler := OAuth2Handler{OAuthClientManager: clientManager, OAuthClientRepository: clientRepository, TokenService: tokenService}
+
+		engine := gin.Default()
+		handler.RegisterRoutes(engine.Group("/oauth2"))
+
+		reader, _ := tokenService.Issue(auth.Principal{Id: 1, Name: "reader", Permissions: []string{domain.PermissionClientRead}})
+		writer, _ := tokenService.Issue(auth.Principal{Id: 2, Name: "writer", Permissions: []string{"clients:*"}})
+		doRequest := func(method, path, body, token string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, path, strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			return w
+		}
+
+		body := `{"name": "Web App", "redirectUris": ["https://app.test/callback"]}`
+		w := doRequest(http.MethodPost, "/oauth2/clients", body, reader.Token)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		created := &entity.OAuthClient{Id: "web", Name: "Web App", HashedSecret: "hashed", RedirectUris: "https://app.test/callback"}
+		clientManager.EXPECT().CreateClient(entity.OAuthClientCreateRequest{Name: "Web App", RedirectUris: []string{"https://app.test/callback"}}).
+			Return(created, "plain-secret", nil)
+		w = doRequest(http.MethodPost, "/oauth2/clients", body, writer.Token)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Contains(t, w.Body.String(), `"secret":"plain-secret"`)
+		assert.NotContains(t, w.Body.String(), "hashed")
+
+		clientRepository.EXPECT().FindAll().Return([]entity.OAuthClient{*created}, nil)
+		w = doRequest(http.MethodGet, "/oauth2/clients", "", reader.Token)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"redirectUris":["https://app.test/callback"]`)
+		assert.NotContains(t, w.Body.String(), "secret")
+	})
+}
+
+func TestOAuth2Handler_openidConnect(it *testing.T) {
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	client := &entity.OAuthClient{Id: "web", Name: "Web App", RedirectUris: "https://app.test/callback"}
+	account := &entity.Account{Id: 123, Name: "ann", Email: "ann@test.fundwit.com", EmailVerified: true}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	issuer, err := auth.NewJwtIssuer("https://id.test", key)
+	if err != nil {
+		panic(err)
+	}
+
+	it.Run("should issue id token with nonce and response claims of scopes by userinfo", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		accountManager := domain.NewMockAccountManager(mockCtl)
+		accountRepository := domain.NewMockAccountRepository(mockCtl)
+		roleRepository := domain.NewMockRoleRepository(mockCtl)
+		groupManager := domain.NewMockGroupManager(mockCtl)
+		clientManager := domain.NewMockOAuthClientManager(mockCtl)
+		clientRepository := domain.NewMockOAuthClientRepository(mockCtl)
+		tokenService := &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), OneTimeTokenStore: auth.NewMemoryOneTimeTokenStore(), JwtIssuer: issuer,
+			RefreshTokenStore: auth.NewMemoryRefreshTokenStore()}
+		handler := OAuth2Handler{
+			AccountManager:    accountManager,
+			AccountRepository: accountRepository,
+			PrincipalLoader: &PrincipalLoader{AccountRepository: accountRepository, RoleRepository: roleRepository,
+				GroupManager: groupManager},
+			OAuthClientManager:    clientManager,
+			OAuthClientRepository: clientRepository,
+			TokenService:          tokenService,
+		}
+		engine := gin.Default()
+		handler.RegisterRoutes(engine.Group("/oauth2"))
+
+		clientRepository.EXPECT().FindById("web").Return(client, nil)
+		accountManager.EXPECT().AuthenticateInternalIdentity("", "ann", "secret").Return(account, nil)
+		// the principal is loaded on approval, and reloaded on exchange of code
+		roleRepository.EXPECT().FindGrantsByAccountId(uint64(123)).Return([]string{}, []string{}, nil).Times(2)
+		groupManager.EXPECT().FindMemberships(uint64(123)).Return([]string{}, nil).Times(2)
+		form := url.Values{"response_type": {"code"}, "client_id": {"web"}, "scope": {"openid email"}, "nonce": {"n-0S6"},
+			"code_challenge": {challenge}, "code_challenge_method": {"S256"}, "decision": {"approve"},
+			"name": {"ann"}, "secret": {"secret"}}
+		req := httptest.NewRequest(http.MethodPost, "/oauth2/authorize", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusFound, w.Code)
+		location, _ := url.Parse(w.Header().Get("Location"))
+
+		clientManager.EXPECT().AuthenticateClient("web", "").Return(client, nil)
+		accountRepository.EXPECT().FindById(uint64(123)).Return(account, nil).Times(3)
+		form = url.Values{"grant_type": {"authorization_code"}, "code": {location.Query().Get("code")},
+			"code_verifier": {verifier}, "client_id": {"web"}}
+		req = httptest.NewRequest(http.MethodPost, "/oauth2/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w = httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		body := map[string]interface{}{}
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &body))
+
+		claims := jwt.MapClaims{}
+		_, err := jwt.ParseWithClaims(body["id_token"].(string), claims, func(token *jwt.Token) (interface{}, error) {
+			return key.Public(), nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, "https://id.test", claims["iss"])
+		assert.Equal(t, "web", claims["aud"])
+		assert.Equal(t, "123", claims["sub"])
+		assert.Equal(t, "n-0S6", claims["nonce"])
+		assert.Equal(t, "ann@test.fundwit.com", claims["email"])
+		assert.Equal(t, true, claims["email_verified"])
+		assert.NotContains(t, claims, "name")
+		assert.Contains(t, claims, "auth_time")
+
+		req = httptest.NewRequest(http.MethodGet, "/oauth2/userinfo", nil)
+		req.Header.Set("Authorization", "Bearer "+body["access_token"].(string))
+		w = httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"sub": "123", "email": "ann@test.fundwit.com", "email_verified": true}`, w.Body.String())
+	})
+
+	it.Run("should reject userinfo request without token or openid scope", func(t *testing.T) {
+		tokenService := &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), OneTimeTokenStore: auth.NewMemoryOneTimeTokenStore(), JwtIssuer: issuer}
+		engine := gin.Default()
+		(&OAuth2Handler{TokenService: tokenService}).RegisterRoutes(engine.Group("/oauth2"))
+
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/oauth2/userinfo", nil))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, `Bearer error="invalid_token"`, w.Header().Get("WWW-Authenticate"))
+
+		sc, _ := tokenService.Issue(auth.Principal{Id: 123, Name: "ann", ClientId: "web", Scope: "profile"})
+		req := httptest.NewRequest(http.MethodPost, "/oauth2/userinfo", nil)
+		req.Header.Set("Authorization", "Bearer "+sc.Token)
+		w = httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	it.Run("should redirect invalid scope when openid is requested without signing keys", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		clientRepository := domain.NewMockOAuthClientRepository(mockCtl)
+		tokenService := &auth.TokenService{SessionStore: auth.NewMemorySessionStore()}
+		engine := gin.Default()
+		(&OAuth2Handler{OAuthClientRepository: clientRepository, TokenService: tokenService}).RegisterRoutes(engine.Group("/oauth2"))
+
+		clientRepository.EXPECT().FindById("web").Return(client, nil)
+		params := url.Values{"response_type": {"code"}, "client_id": {"web"}, "scope": {"openid"},
+			"code_challenge": {challenge}, "code_challenge_method": {"S256"}}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/oauth2/authorize?"+params.Encode(), nil))
+		assert.Equal(t, http.StatusFound, w.Code)
+		location, _ := url.Parse(w.Header().Get("Location"))
+		assert.Equal(t, "invalid_scope", location.Query().Get("error"))
+	})
+}
+
+func TestOAuth2Handler_clientCredentials(it *testing.T) {
+	serviceAccount := &entity.ServiceAccount{Id: 701, ClientId: "job", Name: "nightly-job", Scopes: "accounts:read groups:read"}
+
+	setUp := func(t *testing.T) (*gin.Engine, *domain.MockServiceAccountManager, *auth.TokenService, func()) {
+		mockCtl := gomock.NewController(t)
+		serviceAccountManager := domain.NewMockServiceAccountManager(mockCtl)
+		tokenService := &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), OneTimeTokenStore: auth.NewMemoryOneTimeTokenStore(), RefreshTokenStore: auth.NewMemoryRefreshTokenStore()}
+		handler := OAuth2Handler{ServiceAccountManager: serviceAccountManager, TokenService: tokenService}
+		engine := gin.Default()
+		handler.RegisterRoutes(engine.Group("/oauth2"))
+		return engine, serviceAccountManager, tokenService, mockCtl.Finish
+	}
+	post := func(engine *gin.Engine, form url.Values, basicUser, basicPassword string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/oauth2/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if basicUser != "" {
+			req.SetBasicAuth(basicUser, basicPassword)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	it.Run("should issue access token with requested scopes and without refresh token", func(t *testing.T) {
+		engine, serviceAccountManager, tokenService, finish := setUp(t)
+		defer finish()
+
+		serviceAccountManager.EXPECT().Authenticate("job", "secret").Return(serviceAccount, nil)
+		w := post(engine, url.Values{"grant_type": {"client_credentials"}, "scope": {"accounts:read"}}, "job", "secret")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+		body := map[string]interface{}{}
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, "accounts:read", body["scope"])
+		assert.NotContains(t, body, "refresh_token")
+
+		sc, err := tokenService.Authenticate(body["access_token"].(string))
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(701), sc.Principal.Id)
+		assert.True(t, sc.Principal.ServiceAccount)
+		assert.True(t, sc.Principal.HasPermission(domain.PermissionAccountRead))
+		assert.False(t, sc.Principal.HasPermission(domain.PermissionGroupRead))
+	})
+
+	it.Run("should grant all allowed scopes when scope is absent", func(t *testing.T) {
+		engine, serviceAccountManager, _, finish := setUp(t)
+		defer finish()
+
+		serviceAccountManager.EXPECT().Authenticate("job", "secret").Return(serviceAccount, nil)
+		w := post(engine, url.Values{"grant_type": {"client_credentials"}, "client_id": {"job"}, "client_secret": {"secret"}}, "", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"scope":"accounts:read groups:read"`)
+	})
+
+	it.Run("should reject scope which is not allowed", func(t *testing.T) {
+		engine, serviceAccountManager, _, finish := setUp(t)
+		defer finish()
+
+		serviceAccountManager.EXPECT().Authenticate("job", "secret").Return(serviceAccount, nil)
+		w := post(engine, url.Values{"grant_type": {"client_credentials"}, "scope": {"accounts:read accounts:write"}}, "job", "secret")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"error":"invalid_scope"`)
+	})
+
+	it.Run("should reject bad credentials", func(t *testing.T) {
+		engine, serviceAccountManager, _, finish := setUp(t)
+		defer finish()
+
+		serviceAccountManager.EXPECT().Authenticate("job", "bad").Return(nil, &domain.ErrOAuthClientAuthenticationFailure{})
+		w := post(engine, url.Values{"grant_type": {"client_credentials"}}, "job", "bad")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, `Basic realm="hallo"`, w.Header().Get("WWW-Authenticate"))
+		assert.Contains(t, w.Body.String(), `"error":"invalid_client"`)
+	})
+}
+
+func TestOAuth2Handler_introspectAndRevoke(it *testing.T) {
+	confidential := &entity.OAuthClient{Id: "api", Name: "Resource Server", HashedSecret: "hashed"}
+	public := &entity.OAuthClient{Id: "spa", Name: "Single Page App"}
+
+	setUp := func(t *testing.T) (*gin.Engine, *auth.TokenService, func()) {
+		mockCtl := gomock.NewController(t)
+		clientManager := domain.NewMockOAuthClientManager(mockCtl)
+		serviceAccountManager := domain.NewMockServiceAccountManager(mockCtl)
+		tokenService := &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), OneTimeTokenStore: auth.NewMemoryOneTimeTokenStore(), RefreshTokenStore: auth.NewMemoryRefreshTokenStore()}
+		handler := OAuth2Handler{OAuthClientManager: clientManager, ServiceAccountManager: serviceAccountManager, TokenService: tokenService}
+
+		clientManager.EXPECT().AuthenticateClient("api", "secret").Return(confidential, nil).AnyTimes()
+		clientManager.EXPECT().AuthenticateClient("spa", "").Return(public, nil).AnyTimes()
+		clientManager.EXPECT().AuthenticateClient(gomock.Any(), gomock.Any()).Return(nil, &domain.ErrOAuthClientAuthenticationFailure{}).AnyTimes()
+		serviceAccountManager.EXPECT().Authenticate("job", "secret").
+			Return(&entity.ServiceAccount{Id: 701, ClientId: "job", Name: "nightly-job"}, nil).AnyTimes()
+		serviceAccountManager.EXPECT().Authenticate(gomock.Any(), gomock.Any()).Return(nil, &domain.ErrOAuthClientAuthenticationFailure{}).AnyTimes()
+
+		engine := gin.Default()
+		handler.RegisterRoutes(engine.Group("/oauth2"))
+		return engine, tokenService, mockCtl.Finish
+	}
+	post := func(engine *gin.Engine, path string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	it.Run("should introspect tokens for confidential clients and service accounts only", func(t *testing.T) {
+		engine, tokenService, finish := setUp(t)
+		defer finish()
+
+		sc, err := tokenService.Issue(auth.Principal{Id: 123, Name: "ann", ClientId: "spa", Scope: "openid profile"})
+		assert.Nil(t, err)
+
+		w := post(engine, "/oauth2/introspect", url.Values{"token": {sc.Token}, "client_id": {"api"}, "client_secret": {"secret"}})
+		assert.Equal(t, http.StatusOK, w.Code)
+		body := map[string]interface{}{}
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, true, body["active"])
+		assert.Equal(t, "123", body["sub"])
+		assert.Equal(t, "ann", body["username"])
+		assert.Equal(t, "openid profile", body["scope"])
+		assert.Equal(t, "spa", body["client_id"])
+		assert.Equal(t, "Bearer", body["token_type"])
+		assert.NotEmpty(t, body["exp"])
+
+		w = post(engine, "/oauth2/introspect", url.Values{"token": {"unknown"}, "client_id": {"job"}, "client_secret": {"secret"}})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"active": false}`, w.Body.String())
+
+		w = post(engine, "/oauth2/introspect", url.Values{"token": {sc.Token}, "client_id": {"spa"}})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		w = post(engine, "/oauth2/introspect", url.Values{"token": {sc.Token}, "client_id": {"api"}, "client_secret": {"bad"}})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), `"error":"invalid_client"`)
+		w = post(engine, "/oauth2/introspect", url.Values{"client_id": {"api"}, "client_secret": {"secret"}})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	it.Run("should revoke access token and refresh token", func(t *testing.T) {
+		engine, tokenService, finish := setUp(t)
+		defer finish()
+
+		sc, err := tokenService.Issue(auth.Principal{Id: 123, Name: "ann", ClientId: "spa"})
+		assert.Nil(t, err)
+		refreshToken, err := tokenService.IssueRefreshToken(auth.Principal{Id: 123, Name: "ann", ClientId: "api"})
+		assert.Nil(t, err)
+
+		w := post(engine, "/oauth2/revoke", url.Values{"token": {sc.Token}, "client_id": {"nobody"}})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = post(engine, "/oauth2/revoke", url.Values{"token": {sc.Token}, "client_id": {"spa"}})
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = post(engine, "/oauth2/revoke", url.Values{"token": {refreshToken}, "token_type_hint": {"refresh_token"},
+			"client_id": {"api"}, "client_secret": {"secret"}})
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = post(engine, "/oauth2/revoke", url.Values{"token": {"unknown"}, "client_id": {"spa"}})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		found, err := tokenService.Authenticate(sc.Token)
+		assert.Nil(t, err)
+		assert.Nil(t, found)
+		_, _, err = tokenService.Refresh(refreshToken, "api")
+		assert.Equal(t, auth.ErrRefreshTokenInvalid, err)
+	})
+
+	it.Run("should refuse to revoke tokens of other clients", func(t *testing.T) {
+		engine, tokenService, finish := setUp(t)
+		defer finish()
+
+		session, err := tokenService.Issue(auth.Principal{Id: 123, Name: "ann"})
+		assert.Nil(t, err)
+		sc, err := tokenService.Issue(auth.Principal{Id: 123, Name: "ann", ClientId: "api"})
+		assert.Nil(t, err)
+		refreshToken, err := tokenService.IssueRefreshToken(auth.Principal{Id: 123, Name: "ann", ClientId: "api"})
+		assert.Nil(t, err)
+
+		for _, token := range []string{session.Token, sc.Token, refreshToken} {
+			w := post(engine, "/oauth2/revoke", url.Values{"token": {token}, "client_id": {"spa"}})
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), `"error":"invalid_request"`)
+		}
+		w := post(engine, "/oauth2/revoke", url.Values{"token": {sc.Token}, "client_id": {"job"}, "client_secret": {"secret"}})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		found, err := tokenService.Authenticate(sc.Token)
+		assert.Nil(t, err)
+		assert.NotNil(t, found)
+		_, _, err = tokenService.Refresh(refreshToken, "api")
+		assert.Nil(t, err)
+	})
+}
+
+func TestOAuth2Handler_deviceFlow(it *testing.T) {
+	client := &entity.OAuthClient{Id: "cli", Name: "Command Line", RedirectUris: "http://127.0.0.1/callback"}
+
+	it.Run("should issue tokens to device after account approves on verification page", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		accountManager := domain.NewMockAccountManager(mockCtl)
+		accountRepository := domain.NewMockAccountRepository(mockCtl)
+		roleRepository := domain.NewMockRoleRepository(mockCtl)
+		groupManager := domain.NewMockGroupManager(mockCtl)
+		clientManager := domain.NewMockOAuthClientManager(mockCtl)
+		clientRepository := domain.NewMockOAuthClientRepository(mockCtl)
+		tokenService := &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), OneTimeTokenStore: auth.NewMemoryOneTimeTokenStore(), RefreshTokenStore: auth.NewMemoryRefreshTokenStore()}
+		handler := OAuth2Handler{
+			AccountManager: accountManager,
+			PrincipalLoader: &PrincipalLoader{AccountRepository: accountRepository, RoleRepository: roleRepository,
+				GroupManager: groupManager},
+			OAuthClientManager:    clientManager,
+			OAuthClientRepository: clientRepository,
+			TokenService:          tokenService,
+			DeviceFlow:            &auth.DeviceFlow{Store: auth.NewMemoryDeviceAuthorizationStore()},
+			BaseUrl:               "https://hallo.test.fundwit.com",
+		}
+		engine := gin.Default()
+		handler.RegisterRoutes(engine.Group("/oauth2"))
+
+		clientManager.EXPECT().AuthenticateClient("cli", "").Return(client, nil).AnyTimes()
+		clientRepository.EXPECT().FindById("cli").Return(client, nil).AnyTimes()
+		accountRepository.EXPECT().FindById(uint64(123)).Return(&entity.Account{Id: 123, Name: "ann"}, nil)
+		roleRepository.EXPECT().FindGrantsByAccountId(uint64(123)).Return([]string{}, []string{}, nil).Times(2)
+		groupManager.EXPECT().FindMemberships(uint64(123)).Return([]string{}, nil).Times(2)
+		post := func(path string, form url.Values) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			return w
+		}
+
+		w := post("/oauth2/device_authorization", url.Values{"client_id": {"cli"}, "scope": {"profile"}})
+		assert.Equal(t, http.StatusOK, w.Code)
+		started := map[string]interface{}{}
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &started))
+		deviceCode, userCode := started["device_code"].(string), started["user_code"].(string)
+		assert.Equal(t, "https://hallo.test.fundwit.com/oauth2/device", started["verification_uri"])
+		assert.Equal(t, "https://hallo.test.fundwit.com/oauth2/device?user_code="+userCode, started["verification_uri_complete"])
+		assert.Equal(t, float64(auth.DevicePollInterval), started["interval"])
+
+		pollForm := url.Values{"grant_type": {deviceCodeGrantType}, "device_code": {deviceCode}, "client_id": {"cli"}}
+		w = post("/oauth2/token", pollForm)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"error":"authorization_pending"`)
+
+		w = httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/oauth2/device?user_code="+userCode, nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))
+		assert.Contains(t, w.Body.String(), "Connect Command Line")
+		assert.Contains(t, w.Body.String(), `value="`+userCode+`"`)
+
+		accountManager.EXPECT().AuthenticateInternalIdentity("", "ann", "bad").Return(nil, &domain.AccountAuthenticationFailure{})
+		accountManager.EXPECT().AuthenticateInternalIdentity("", "ann", "secret").Return(&entity.Account{Id: 123, Name: "ann"}, nil)
+		w = post("/oauth2/device", url.Values{"user_code": {userCode}, "name": {"ann"}, "secret": {"bad"}, "decision": {"approve"}})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		w = post("/oauth2/device", url.Values{"user_code": {strings.ToLower(userCode)}, "name": {"ann"}, "secret": {"secret"},
+			"decision": {"approve"}})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "The device is connected")
+
+		w = post("/oauth2/token", pollForm)
+		assert.Equal(t, http.StatusOK, w.Code)
+		body := map[string]interface{}{}
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.NotEmpty(t, body["refresh_token"])
+		sc, err := tokenService.Authenticate(body["access_token"].(string))
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(123), sc.Principal.Id)
+		assert.Equal(t, "profile", sc.Principal.Scope)
+
+		w = post("/oauth2/token", pollForm)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"error":"invalid_grant"`)
+	})
+
+	it.Run("should deny device only when account is authenticated", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		accountManager := domain.NewMockAccountManager(mockCtl)
+		clientManager := domain.NewMockOAuthClientManager(mockCtl)
+		clientRepository := domain.NewMockOAuthClientRepository(mockCtl)
+		handler := OAuth2Handler{
+			AccountManager:        accountManager,
+			OAuthClientManager:    clientManager,
+			OAuthClientRepository: clientRepository,
+			DeviceFlow:            &auth.DeviceFlow{Store: auth.NewMemoryDeviceAuthorizationStore()},
+			BaseUrl:               "https://hallo.test.fundwit.com",
+		}
+		engine := gin.Default()
+		handler.RegisterRoutes(engine.Group("/oauth2"))
+
+		clientManager.EXPECT().AuthenticateClient("cli", "").Return(client, nil)
+		clientRepository.EXPECT().FindById("cli").Return(client, nil).AnyTimes()
+		post := func(path string, form url.Values) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			return w
+		}
+
+		w := post("/oauth2/device_authorization", url.Values{"client_id": {"cli"}})
+		assert.Equal(t, http.StatusOK, w.Code)
+		started := map[string]interface{}{}
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &started))
+		userCode := started["user_code"].(string)
+
+		accountManager.EXPECT().AuthenticateInternalIdentity("", "ann", "").Return(nil, &domain.AccountAuthenticationFailure{})
+		accountManager.EXPECT().AuthenticateInternalIdentity("", "ann", "secret").Return(&entity.Account{Id: 123, Name: "ann"}, nil)
+		w = post("/oauth2/device", url.Values{"user_code": {userCode}, "name": {"ann"}, "decision": {"deny"}})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		authorization, err := handler.DeviceFlow.FindPending(userCode)
+		assert.Nil(t, err)
+		assert.NotNil(t, authorization)
+
+		w = post("/oauth2/device", url.Values{"user_code": {userCode}, "name": {"ann"}, "secret": {"secret"}, "decision": {"deny"}})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "The device is denied")
+		_, err = handler.DeviceFlow.FindPending(userCode)
+		assert.Equal(t, auth.ErrUserCodeInvalid, err)
+	})
+
+	it.Run("should disable device flow without base url or verification uri", func(t *testing.T) {
+		handler := OAuth2Handler{DeviceFlow: &auth.DeviceFlow{Store: auth.NewMemoryDeviceAuthorizationStore()}}
+		engine := gin.Default()
+		handler.RegisterRoutes(engine.Group("/oauth2"))
+
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/oauth2/device_authorization", nil))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	it.Run("should reject unknown user code on verification page", func(t *testing.T) {
+		handler := OAuth2Handler{DeviceFlow: &auth.DeviceFlow{Store: auth.NewMemoryDeviceAuthorizationStore()},
+			BaseUrl: "https://hallo.test.fundwit.com"}
+		engine := gin.Default()
+		handler.RegisterRoutes(engine.Group("/oauth2"))
+
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/oauth2/device?user_code=BCDF-GHJK", nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), auth.ErrUserCodeInvalid.Error())
+	})
+}
@@ -0,0 +1,180 @@
+package serveHttp
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/jinzhu/gorm"
+	"hallo/domain"
+	"hallo/domain/entity"
+	"hallo/service/auth"
+	"log"
+	"net/http"
+)
+
+type OrganizationHandler struct {
+	OrganizationManager    domain.OrganizationManager
+	OrganizationRepository domain.OrganizationRepository
+	TokenService           *auth.TokenService
+}
+
+type OrganizationCreateForm struct {
+	Name        string `json:"name" binding:"required"`
+	DisplayName string `json:"displayName"`
+}
+
+// MemberRolesForm replaces the roles of member in the organization
+type MemberRolesForm struct {
+	Roles []string `json:"roles" binding:"required,min=1"`
+}
+
+func (handler *OrganizationHandler) RegisterRoutes(r *gin.RouterGroup) {
+	authenticate := auth.AuthenticateByToken(handler.TokenService)
+	canRead := auth.RequirePermission(domain.PermissionOrganizationRead)
+	canWrite := auth.RequirePermission(domain.PermissionOrganizationWrite)
+
+	r.GET("", authenticate, auth.AuthenticatedCheck(), canRead, handler.listOrganizations)
+	r.POST("", authenticate, auth.AuthenticatedCheck(), canWrite, handler.createOrganization)
+	// the organization is managed by the principals granted the permissions in it as well
+	canReadIt := requireOrganizationPermission(domain.PermissionOrganizationRead)
+	canWriteIt := requireOrganizationPermission(domain.PermissionOrganizationWrite)
+	r.GET("/:id", authenticate, auth.AuthenticatedCheck(), canReadIt, handler.getOrganization)
+	r.GET("/:id/members", authenticate, auth.AuthenticatedCheck(), canReadIt, handler.listMembers)
+	r.PUT("/:id/members/:accountId", authenticate, auth.AuthenticatedCheck(), canWriteIt, handler.setMemberRoles)
+	r.DELETE("/:id/members/:accountId", authenticate, auth.AuthenticatedCheck(), canWriteIt, handler.removeMember)
+}
+
+// requireOrganizationPermission aborts with 403 when the principal is not granted the permission on the organization
+// of the id parameter, it should be used after AuthenticatedCheck
+func requireOrganizationPermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		organizationId, ok := idParam(c, "id")
+		if !ok {
+			c.Abort()
+			return
+		}
+		if !auth.LoadFromRequestContext(c).Principal.HasOrganizationPermission(organizationId, permission) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "permission denied"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func (handler *OrganizationHandler) listOrganizations(c *gin.Context) {
+	organizations, err := handler.OrganizationRepository.FindAll()
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list organizations"})
+		return
+	}
+	c.JSON(http.StatusOK, organizations)
+}
+
+func (handler *OrganizationHandler) createOrganization(c *gin.Context) {
+	var form OrganizationCreateForm
+	if err := c.ShouldBindJSON(&form); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bad request body"})
+		return
+	}
+
+	organization, err := handler.OrganizationManager.CreateOrganization(entity.OrganizationCreateRequest{
+		Name: form.Name, DisplayName: form.DisplayName})
+	if err != nil {
+		respondOrganizationError(c, err, "failed to create organization")
+		return
+	}
+	c.JSON(http.StatusCreated, organization)
+}
+
+func (handler *OrganizationHandler) getOrganization(c *gin.Context) {
+	organizationId, ok := idParam(c, "id")
+	if !ok {
+		return
+	}
+	organization, err := handler.OrganizationRepository.FindById(organizationId)
+	if err != nil {
+		respondOrganizationError(c, err, "failed to get organization")
+		return
+	}
+	c.JSON(http.StatusOK, organization)
+}
+
+func (handler *OrganizationHandler) listMembers(c *gin.Context) {
+	organizationId, ok := idParam(c, "id")
+	if !ok {
+		return
+	}
+	if _, err := handler.OrganizationRepository.FindById(organizationId); err != nil {
+		respondOrganizationError(c, err, "failed to list members")
+		return
+	}
+	members, err := handler.OrganizationRepository.FindMembers(organizationId)
+	if err != nil {
+		respondOrganizationError(c, err, "failed to list members")
+		return
+	}
+	c.JSON(http.StatusOK, members)
+}
+
+func (handler *OrganizationHandler) setMemberRoles(c *gin.Context) {
+	organizationId, ok := idParam(c, "id")
+	if !ok {
+		return
+	}
+	accountId, ok := idParam(c, "accountId")
+	if !ok {
+		return
+	}
+	var form MemberRolesForm
+	if err := c.ShouldBindJSON(&form); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bad request body"})
+		return
+	}
+
+	if err := handler.OrganizationManager.SetMemberRoles(organizationId, accountId, form.Roles); err != nil {
+		respondOrganizationError(c, err, "failed to set member roles")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (handler *OrganizationHandler) removeMember(c *gin.Context) {
+	organizationId, ok := idParam(c, "id")
+	if !ok {
+		return
+	}
+	accountId, ok := idParam(c, "accountId")
+	if !ok {
+		return
+	}
+	if err := handler.OrganizationManager.SetMemberRoles(organizationId, accountId, nil); err != nil {
+		respondOrganizationError(c, err, "failed to remove member")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func respondOrganizationError(c *gin.Context, err error, failure string) {
+	log.Printf("error: %v\n", err)
+
+	var validationErrs validator.ValidationErrors
+	var nameOccupied *domain.OrganizationNameIsOccupied
+	var outsideOrganization *domain.ErrAccountOutsideOrganization
+	var roleNotFound *domain.ErrRoleNotFound
+	if errors.As(err, &validationErrs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bad request body"})
+	} else if errors.As(err, &nameOccupied) {
+		c.JSON(http.StatusConflict, gin.H{"error": nameOccupied.Error()})
+	} else if errors.As(err, &outsideOrganization) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": outsideOrganization.Error()})
+	} else if errors.As(err, &roleNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": roleNotFound.Error()})
+	} else if gorm.IsRecordNotFoundError(err) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "organization or account not found"})
+	} else {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": failure})
+	}
+}
@@ -0,0 +1,132 @@
+package serveHttp
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"hallo/domain"
+	"hallo/domain/entity"
+	"hallo/service/auth"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOrganizationHandler(it *testing.T) {
+	it.Run("should manage organizations and member roles with permissions", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		organizationManager := domain.NewMockOrganizationManager(mockCtl)
+		organizationRepository := domain.NewMockOrganizationRepository(mockCtl)
+		tokenService := &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), RefreshTokenStore: auth.NewMemoryRefreshTokenStore()}
+		organizationHandler := OrganizationHandler{
+			OrganizationManager:    organizationManager,
+			OrganizationRepository: organizationRepository,
+			TokenService:           tokenService,
+		}
+
+		engine := gin.Default()
+		organizationHandler.RegisterRoutes(engine.Group("/organizations"))
+
+		reader, _ := tokenService.Issue(auth.Principal{Id: 1, Name: "reader", Permissions: []string{domain.PermissionOrganizationRead}})
+		writer, _ := tokenService.Issue(auth.Principal{Id: 2, Name: "writer", Permissions: []string{"organizations:*"}})
+		doRequest := func(method, path, body, token string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, path, strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			return w
+		}
+
+		organizationRepository.EXPECT().FindAll().Return([]entity.Organization{{Id: 601, Name: "acme"}}, nil)
+		w := doRequest(http.MethodGet, "/organizations", "", reader.Token)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"name":"acme"`)
+
+		w = doRequest(http.MethodPost, "/organizations", `{"name": "globex"}`, reader.Token)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		organizationManager.EXPECT().CreateOrganization(entity.OrganizationCreateRequest{Name: "globex", DisplayName: "Globex"}).
+			Return(&entity.Organization{Id: 602, Name: "globex", DisplayName: "Globex"}, nil)
+		organizationManager.EXPECT().CreateOrganization(entity.OrganizationCreateRequest{Name: "acme"}).
+			Return(nil, &domain.OrganizationNameIsOccupied{})
+		w = doRequest(http.MethodPost, "/organizations", `{"name": "globex", "displayName": "Globex"}`, writer.Token)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		w = doRequest(http.MethodPost, "/organizations", `{"name": "acme"}`, writer.Token)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		organizationRepository.EXPECT().FindById(uint64(603)).Return(nil, gorm.ErrRecordNotFound)
+		w = doRequest(http.MethodGet, "/organizations/603", "", reader.Token)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		organizationManager.EXPECT().SetMemberRoles(uint64(602), uint64(123), []string{"owner"}).Return(nil)
+		organizationManager.EXPECT().SetMemberRoles(uint64(602), uint64(456), []string{"owner"}).Return(gorm.ErrRecordNotFound)
+		organizationManager.EXPECT().SetMemberRoles(uint64(602), uint64(123), nil).Return(nil)
+		w = doRequest(http.MethodPut, "/organizations/602/members/123", `{"roles": ["owner"]}`, writer.Token)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		w = doRequest(http.MethodPut, "/organizations/602/members/456", `{"roles": ["owner"]}`, writer.Token)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w = doRequest(http.MethodPut, "/organizations/602/members/123", `{"roles": []}`, writer.Token)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w = doRequest(http.MethodDelete, "/organizations/602/members/123", "", writer.Token)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		organizationRepository.EXPECT().FindById(uint64(602)).Return(&entity.Organization{Id: 602, Name: "globex"}, nil)
+		organizationRepository.EXPECT().FindMembers(uint64(602)).
+			Return([]entity.OrganizationMember{{OrganizationId: 602, AccountId: 123, RoleName: "owner"}}, nil)
+		w = doRequest(http.MethodGet, "/organizations/602/members", "", reader.Token)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"roleName":"owner"`)
+
+		organizationManager.EXPECT().SetMemberRoles(uint64(602), uint64(789), []string{"owner"}).
+			Return(&domain.ErrAccountOutsideOrganization{})
+		organizationManager.EXPECT().SetMemberRoles(uint64(602), uint64(123), []string{"nobody"}).
+			Return(&domain.ErrRoleNotFound{Role: "nobody"})
+		w = doRequest(http.MethodPut, "/organizations/602/members/789", `{"roles": ["owner"]}`, writer.Token)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w = doRequest(http.MethodPut, "/organizations/602/members/123", `{"roles": ["nobody"]}`, writer.Token)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"error": "role nobody is not found"}`, w.Body.String())
+	})
+
+	it.Run("should allow administrators of organization to manage their own organization only", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		organizationManager := domain.NewMockOrganizationManager(mockCtl)
+		organizationRepository := domain.NewMockOrganizationRepository(mockCtl)
+		tokenService := &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), RefreshTokenStore: auth.NewMemoryRefreshTokenStore()}
+		organizationHandler := OrganizationHandler{
+			OrganizationManager:    organizationManager,
+			OrganizationRepository: organizationRepository,
+			TokenService:           tokenService,
+		}
+
+		engine := gin.Default()
+		organizationHandler.RegisterRoutes(engine.Group("/organizations"))
+
+		admin, _ := tokenService.Issue(auth.Principal{Id: 123, Name: "ann", OrganizationId: 601,
+			OrganizationRoles: []string{"owner"}, OrganizationPermissions: []string{"*"}})
+		doRequest := func(method, path, body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, path, strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+admin.Token)
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			return w
+		}
+
+		organizationManager.EXPECT().SetMemberRoles(uint64(601), uint64(456), []string{"viewer"}).Return(nil)
+		w := doRequest(http.MethodPut, "/organizations/601/members/456", `{"roles": ["viewer"]}`)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		w = doRequest(http.MethodPut, "/organizations/602/members/456", `{"roles": ["viewer"]}`)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = doRequest(http.MethodGet, "/organizations/602", "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = doRequest(http.MethodGet, "/organizations", "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = doRequest(http.MethodPost, "/organizations", `{"name": "globex"}`)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
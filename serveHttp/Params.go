@@ -0,0 +1,17 @@
+package serveHttp
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+// idParam parses the id path parameter, the bad request is responded when it is invalid
+func idParam(c *gin.Context, name string) (uint64, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bad " + name})
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,264 @@
+package serveHttp
+
+import (
+	"errors"
+	"github.com/duo-labs/webauthn/protocol"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"hallo/domain"
+	"hallo/service/auth"
+	"hallo/service/passkey"
+	"log"
+	"net/http"
+	"time"
+)
+
+const defaultPasskeyName = "passkey"
+
+// PasskeyRegistrationForm confirms the account again before a passkey is registered, by the secret of its internal
+// identity or by the code of its second factor. Code is checked when both are given
+type PasskeyRegistrationForm struct {
+	Secret string `json:"secret"`
+	Code   string `json:"code"`
+}
+
+// beginPasskeyRegistration responds the options of navigator.credentials.create(), the response of authenticator is
+// posted to /:id/passkeys in WebAuthnCeremonyExpiration. Only the account itself is able to register passkeys, and
+// the session alone is not enough since a passkey signs in without other factors
+func (handler *AccountHandler) beginPasskeyRegistration(c *gin.Context) {
+	accountId, ok := accountIdParam(c)
+	if !ok {
+		return
+	}
+	if sc := auth.LoadFromRequestContext(c); sc.Principal.Id != accountId {
+		c.JSON(http.StatusForbidden, gin.H{"error": (&domain.ErrForbidden{}).Error()})
+		return
+	}
+	var form PasskeyRegistrationForm
+	if err := c.ShouldBindJSON(&form); err != nil || (form.Secret == "" && form.Code == "") {
+		log.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bad request body"})
+		return
+	}
+	if !handler.reauthenticate(c, accountId, &form) {
+		return
+	}
+
+	account, err := handler.AccountRepository.FindById(accountId)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to register passkey"})
+		return
+	}
+	credentials, err := handler.WebAuthnCredentialRepository.FindByAccountId(accountId)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to register passkey"})
+		return
+	}
+	options, session, err := handler.RelyingParty.BeginRegistration(account, credentials)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to register passkey"})
+		return
+	}
+	if err := handler.TokenService.SaveWebAuthnCeremony(&auth.WebAuthnCeremony{AccountId: accountId, Session: *session}); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to register passkey"})
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, options)
+}
+
+// registerPasskey verifies the PublicKeyCredential created by authenticator and saves it, the name of passkey is
+// taken from the query parameter "name"
+func (handler *AccountHandler) registerPasskey(c *gin.Context) {
+	accountId, ok := accountIdParam(c)
+	if !ok {
+		return
+	}
+	if sc := auth.LoadFromRequestContext(c); sc.Principal.Id != accountId {
+		c.JSON(http.StatusForbidden, gin.H{"error": (&domain.ErrForbidden{}).Error()})
+		return
+	}
+	response, err := protocol.ParseCredentialCreationResponseBody(c.Request.Body)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bad request body"})
+		return
+	}
+	ceremony, err := handler.TokenService.TakeWebAuthnCeremony(response.Response.CollectedClientData.Challenge)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to register passkey"})
+		return
+	}
+	if ceremony == nil || ceremony.AccountId != accountId {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "challenge is invalid or expired"})
+		return
+	}
+
+	account, err := handler.AccountRepository.FindById(accountId)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to register passkey"})
+		return
+	}
+	name := c.DefaultQuery("name", defaultPasskeyName)
+	credential, err := handler.RelyingParty.FinishRegistration(account, ceremony.Session, response, name)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to verify credential"})
+		return
+	}
+	if err := handler.WebAuthnCredentialRepository.Create(credential); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to register passkey"})
+		return
+	}
+	c.JSON(http.StatusCreated, credential)
+}
+
+// reauthenticate verifies the code of second factor, or the secret when no code is given. The error response is
+// written when the account is not confirmed
+func (handler *AccountHandler) reauthenticate(c *gin.Context, accountId uint64, form *PasskeyRegistrationForm) bool {
+	if form.Code != "" {
+		if err := handler.SecondFactorManager.VerifySecondFactor(accountId, form.Code); err != nil {
+			respondTotpError(c, err)
+			return false
+		}
+		return true
+	}
+	if err := handler.InternalIdentityRepository.Authenticate(accountId, form.Secret); err != nil {
+		log.Println(err)
+		var authenticationFailure *domain.AccountAuthenticationFailure
+		if errors.As(err, &authenticationFailure) {
+			c.JSON(http.StatusForbidden, gin.H{"error": authenticationFailure.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to register passkey"})
+		}
+		return false
+	}
+	return true
+}
+
+func (handler *AccountHandler) listPasskeys(c *gin.Context) {
+	accountId, ok := accountIdParam(c)
+	if !ok {
+		return
+	}
+	if !handler.authorizeAccount(c, accountId, domain.PermissionAccountRead) {
+		return
+	}
+
+	credentials, err := handler.WebAuthnCredentialRepository.FindByAccountId(accountId)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list passkeys"})
+		return
+	}
+	c.JSON(http.StatusOK, credentials)
+}
+
+func (handler *AccountHandler) deletePasskey(c *gin.Context) {
+	accountId, ok := accountIdParam(c)
+	if !ok {
+		return
+	}
+	if !handler.authorizeAccount(c, accountId, domain.PermissionAccountWrite) {
+		return
+	}
+
+	if err := handler.AccountManager.DeletePasskey(accountId, c.Param("credential")); err != nil {
+		log.Println(err)
+		var lastIdentity *domain.LastIdentityIsRequired
+		if errors.As(err, &lastIdentity) {
+			c.JSON(http.StatusConflict, gin.H{"error": lastIdentity.Error()})
+		} else if gorm.IsRecordNotFoundError(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "passkey not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete passkey"})
+		}
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// beginPasskeyLogin responds the options of navigator.credentials.get(), any discoverable credential of
+// the relying party is accepted
+func (handler *SessionHandler) beginPasskeyLogin(c *gin.Context) {
+	options, session, err := handler.RelyingParty.BeginLogin()
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create session"})
+		return
+	}
+	if err := handler.TokenService.SaveWebAuthnCeremony(&auth.WebAuthnCeremony{Session: *session}); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create session"})
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, options)
+}
+
+// passkeyLogin creates the session by the assertion of a passkey, the second factors are not challenged since
+// the user is verified by authenticator. The assertions with a sign count which is not increased are refused
+func (handler *SessionHandler) passkeyLogin(c *gin.Context) {
+	response, err := protocol.ParseCredentialRequestResponseBody(c.Request.Body)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bad request body"})
+		return
+	}
+	ceremony, err := handler.TokenService.TakeWebAuthnCeremony(response.Response.CollectedClientData.Challenge)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create session"})
+		return
+	}
+	if ceremony == nil || ceremony.AccountId != 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "challenge is invalid or expired"})
+		return
+	}
+
+	credential, err := handler.WebAuthnCredentialRepository.FindById(passkey.CredentialKey(response.RawID))
+	if err != nil {
+		log.Println(err)
+		if gorm.IsRecordNotFoundError(err) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "passkey is not registered"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create session"})
+		}
+		return
+	}
+	account, err := handler.AccountRepository.FindById(credential.AccountId)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create session"})
+		return
+	}
+	signCount, err := handler.RelyingParty.FinishLogin(account, credential, ceremony.Session, response)
+	if err != nil {
+		log.Println(err)
+		if errors.Is(err, passkey.ErrCloneWarning) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "failed to verify assertion"})
+		}
+		return
+	}
+	// the count is compared again in case of concurrent assertions of the same credential
+	updated, err := handler.WebAuthnCredentialRepository.UpdateSignCount(credential.Id, credential.SignCount, signCount, time.Now())
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create session"})
+		return
+	}
+	if !updated {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": passkey.ErrCloneWarning.Error()})
+		return
+	}
+	handler.issueSession(c, account)
+}
@@ -0,0 +1,96 @@
+package serveHttp
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"hallo/domain"
+	"hallo/service/auth"
+	"hallo/service/mail"
+	"log"
+	"net/http"
+)
+
+type PasswordResetHandler struct {
+	AccountRepository    domain.AccountRepository
+	PasswordResetManager domain.PasswordResetManager
+	TokenService         *auth.TokenService
+	Mailer               mail.Mailer
+}
+
+type PasswordResetRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type PasswordResetForm struct {
+	Secret string `json:"secret" binding:"required"`
+}
+
+func (handler *PasswordResetHandler) RegisterRoutes(r *gin.RouterGroup) {
+	r.POST("", handler.requestPasswordReset)
+	r.PUT("/:token", handler.resetPassword)
+}
+
+// the response doesn't tell whether the email is registered
+func (handler *PasswordResetHandler) requestPasswordReset(c *gin.Context) {
+	var request PasswordResetRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bad request body"})
+		return
+	}
+
+	account, err := handler.AccountRepository.FindByEmail(request.Email)
+	if gorm.IsRecordNotFoundError(err) {
+		c.JSON(http.StatusAccepted, gin.H{"email": request.Email})
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to request password reset"})
+		return
+	}
+
+	token, err := handler.PasswordResetManager.IssueToken(account.Id)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to request password reset"})
+		return
+	}
+
+	message, err := mail.PasswordResetMessage(account.Email, account.Name, token)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := handler.Mailer.Send(message); err != nil {
+		log.Printf("failed to send password reset token to %s: %v\n", account.Email, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to send password reset token"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"email": request.Email})
+}
+
+func (handler *PasswordResetHandler) resetPassword(c *gin.Context) {
+	var form PasswordResetForm
+	if err := c.ShouldBindJSON(&form); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bad request body"})
+		return
+	}
+
+	err := handler.PasswordResetManager.ResetSecret(c.Param("token"), form.Secret, func(accountId uint64) error {
+		return handler.TokenService.RevokeByAccountId(accountId, "")
+	})
+	if err != nil {
+		log.Println(err)
+		if _, ok := err.(*domain.ErrPasswordResetTokenInvalid); ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset password"})
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
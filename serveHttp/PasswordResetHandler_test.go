@@ -0,0 +1,114 @@
+package serveHttp
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"hallo/domain"
+	"hallo/domain/entity"
+	"hallo/service/auth"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPasswordResetHandler(it *testing.T) {
+	it.Run("should reset secret by the token sent by email and revoke all sessions", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		accountRepository := domain.NewMockAccountRepository(mockCtl)
+		passwordResetManager := domain.NewMockPasswordResetManager(mockCtl)
+		accountRepository.EXPECT().FindByEmail("ann@test.fundwit.com").
+			Return(&entity.Account{Id: 123, Name: "ann", Email: "ann@test.fundwit.com"}, nil)
+		passwordResetManager.EXPECT().IssueToken(uint64(123)).Return("reset-token-123", nil)
+		passwordResetManager.EXPECT().ResetSecret("reset-token-123", "new-secret", gomock.Any()).
+			DoAndReturn(func(token, secret string, revoke func(accountId uint64) error) error {
+				return revoke(123)
+			})
+		passwordResetManager.EXPECT().ResetSecret("reset-token-123", "new-secret", gomock.Any()).
+			Return(&domain.ErrPasswordResetTokenInvalid{})
+
+		mailer := &recordingMailer{}
+		tokenService := &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), RefreshTokenStore: auth.NewMemoryRefreshTokenStore()}
+		handler := PasswordResetHandler{
+			AccountRepository:    accountRepository,
+			PasswordResetManager: passwordResetManager,
+			TokenService:         tokenService,
+			Mailer:               mailer,
+		}
+		engine := gin.Default()
+		handler.RegisterRoutes(engine.Group("/password_resets"))
+
+		sc, err := tokenService.Issue(auth.Principal{Id: 123, Name: "ann"})
+		assert.Nil(t, err)
+
+		req := httptest.NewRequest(http.MethodPost, "/password_resets", strings.NewReader(`{"email": "ann@test.fundwit.com"}`))
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		httpResponse := w.Result()
+		defer httpResponse.Body.Close()
+		body, _ := ioutil.ReadAll(httpResponse.Body)
+		assert.Equal(t, http.StatusAccepted, httpResponse.StatusCode)
+		assert.JSONEq(t, `{"email": "ann@test.fundwit.com"}`, string(body))
+
+		assert.Len(t, mailer.messages, 1)
+		assert.Equal(t, []string{"ann@test.fundwit.com"}, mailer.messages[0].To)
+		assert.Contains(t, mailer.messages[0].Text, "reset-token-123")
+		token := "reset-token-123"
+
+		req = httptest.NewRequest(http.MethodPut, "/password_resets/"+token, strings.NewReader(`{"secret": "new-secret"}`))
+		w = httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		found, err := tokenService.Authenticate(sc.Token)
+		assert.Nil(t, err)
+		assert.Nil(t, found)
+
+		// token is taken by the manager once
+		req = httptest.NewRequest(http.MethodPut, "/password_resets/"+token, strings.NewReader(`{"secret": "new-secret"}`))
+		w = httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"error": "password_reset.token.is.invalid"}`, w.Body.String())
+	})
+
+	it.Run("should not tell whether email is registered", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		accountRepository := domain.NewMockAccountRepository(mockCtl)
+		accountRepository.EXPECT().FindByEmail("nobody@test.fundwit.com").Return(nil, gorm.ErrRecordNotFound)
+
+		mailer := &recordingMailer{}
+		handler := PasswordResetHandler{AccountRepository: accountRepository, Mailer: mailer}
+		engine := gin.Default()
+		handler.RegisterRoutes(engine.Group("/password_resets"))
+
+		req := httptest.NewRequest(http.MethodPost, "/password_resets", strings.NewReader(`{"email": "nobody@test.fundwit.com"}`))
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		assert.JSONEq(t, `{"email": "nobody@test.fundwit.com"}`, w.Body.String())
+		assert.Len(t, mailer.messages, 0)
+	})
+
+	it.Run("should response 400 when request body is bad", func(t *testing.T) {
+		handler := PasswordResetHandler{}
+		engine := gin.Default()
+		handler.RegisterRoutes(engine.Group("/password_resets"))
+
+		req := httptest.NewRequest(http.MethodPost, "/password_resets", strings.NewReader(`{"email": "not-an-email"}`))
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		req = httptest.NewRequest(http.MethodPut, "/password_resets/unknown", strings.NewReader(`{}`))
+		w = httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"error": "bad request body"}`, w.Body.String())
+	})
+}
@@ -0,0 +1,72 @@
+package serveHttp
+
+import (
+	"github.com/jinzhu/gorm"
+	"hallo/domain"
+	"hallo/domain/entity"
+	"hallo/service/auth"
+)
+
+// PrincipalLoader loads the roles, permissions and group memberships of the authenticated account.
+// AccountRepository is required to reload principals
+type PrincipalLoader struct {
+	AccountRepository      domain.AccountRepository
+	RoleRepository         domain.RoleRepository
+	GroupManager           domain.GroupManager
+	OrganizationRepository domain.OrganizationRepository
+}
+
+func (loader *PrincipalLoader) Load(account *entity.Account) (auth.Principal, error) {
+	roles, permissions, err := loader.RoleRepository.FindGrantsByAccountId(account.Id)
+	if err != nil {
+		return auth.Principal{}, err
+	}
+	organizationRoles, organizationPermissions, err := loader.findOrganizationGrants(account)
+	if err != nil {
+		return auth.Principal{}, err
+	}
+	groups, err := loader.GroupManager.FindMemberships(account.Id)
+	if err != nil {
+		return auth.Principal{}, err
+	}
+	return auth.Principal{Id: account.Id, Name: account.Name, OrganizationId: account.OrganizationId,
+		Roles: roles, Permissions: permissions, Groups: groups,
+		OrganizationRoles: organizationRoles, OrganizationPermissions: organizationPermissions}, nil
+}
+
+// Reload loads the principal of the account again, it is granted to the same client by the same scope as before.
+// auth.ErrPrincipalNotFound is returned when the account is deleted
+func (loader *PrincipalLoader) Reload(principal auth.Principal) (*auth.Principal, error) {
+	account, err := loader.AccountRepository.FindById(principal.Id)
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, auth.ErrPrincipalNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	reloaded, err := loader.Load(account)
+	if err != nil {
+		return nil, err
+	}
+	if !principal.IsFirstParty() {
+		reloaded = reloaded.GrantTo(principal.ClientId, principal.Scope)
+	}
+	return &reloaded, nil
+}
+
+// findOrganizationGrants finds the roles of account in the organization it belongs to, the permissions of them are
+// kept apart from the global ones so that they are checked against the organization of resources
+func (loader *PrincipalLoader) findOrganizationGrants(account *entity.Account) ([]string, []string, error) {
+	if account.OrganizationId == 0 {
+		return nil, nil, nil
+	}
+	roles, err := loader.OrganizationRepository.FindMemberRoles(account.OrganizationId, account.Id)
+	if err != nil || len(roles) == 0 {
+		return nil, nil, err
+	}
+	permissions, err := loader.RoleRepository.FindPermissionsByRoles(roles)
+	if err != nil {
+		return nil, nil, err
+	}
+	return roles, permissions, nil
+}
@@ -3,18 +3,24 @@ package serveHttp
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
 	"github.com/patrickmn/go-cache"
 	"hallo/domain"
 	"hallo/service/auth"
+	"hallo/service/mail"
+	"log"
 	"net/http"
 )
 
 type RegistryHandler struct {
-	AccountRepository domain.AccountRepository
+	AccountRepository      domain.AccountRepository
+	OrganizationRepository domain.OrganizationRepository
+	Mailer                 mail.Mailer
 }
 
+// EmailOccupiedQuery is validated as an address, since the email is the recipient of register token
 type EmailOccupiedQuery struct {
-	Email string `json:"email"  binding:"required"`
+	Email string `json:"email"  binding:"required,email"`
 }
 
 type EmailOccupiedInfo struct {
@@ -22,8 +28,10 @@ type EmailOccupiedInfo struct {
 	Occupied bool   `json:"occupied" binding:"required"`
 }
 
+// UsernameOccupiedQuery checks the name within the organization, the default organization is used when Organization is empty
 type UsernameOccupiedQuery struct {
-	Name string `json:"name"  binding:"required"`
+	Organization string `json:"organization"`
+	Name         string `json:"name"  binding:"required"`
 }
 
 type UsernameOccupiedInfo struct {
@@ -76,8 +84,16 @@ func (handler *RegistryHandler) acquireEmailRegisterToken(c *gin.Context) {
 		auth.RegisterTokenCache.Set(query.Email, token, cache.DefaultExpiration)
 	}
 
-	// send token to
-	// ...
+	message, err := mail.RegisterTokenMessage(query.Email, token.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := handler.Mailer.Send(message); err != nil {
+		log.Printf("failed to send register token to %s: %v\n", query.Email, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to send register token"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"email": query.Email})
 }
@@ -89,7 +105,20 @@ func (handler *RegistryHandler) usernameOccupied(c *gin.Context) {
 		return
 	}
 
-	isOccupied, err := handler.AccountRepository.IsAccountNameOccupied(query.Name)
+	var organizationId uint64
+	if query.Organization != "" {
+		organization, err := handler.OrganizationRepository.FindByName(query.Organization)
+		if gorm.IsRecordNotFoundError(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "organization not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, err)
+			return
+		}
+		organizationId = organization.Id
+	}
+
+	isOccupied, err := handler.AccountRepository.IsAccountNameOccupied(organizationId, query.Name)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, err)
 		return
@@ -3,12 +3,15 @@ package serveHttp
 import (
 	bytes2 "bytes"
 	"encoding/json"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/patrickmn/go-cache"
 	"github.com/stretchr/testify/assert"
 	"hallo/domain"
+	"hallo/infra"
 	"hallo/service/auth"
+	"hallo/service/mail"
 	"hallo/testinfra"
 	"hallo/util"
 	"io/ioutil"
@@ -32,6 +35,7 @@ func TestRegistryHandler_emailOccupied(it *testing.T) {
 				AccountRepository:          accountRepository,
 				IdentityBindingRepository:  &domain.DatabaseIdentityBindingRepository{Database: ds.Database},
 				InternalIdentityRepository: &domain.DatabaseInternalIdentityRepository{Database: ds.Database},
+				UnitOfWork:                 &domain.DatabaseUnitOfWork{TransactionSupport: &infra.GormTransactionSupport{Database: ds.Database}, IdWorker: util.DefaultIdWorker},
 			},
 			AccountRepository: accountRepository,
 		}
@@ -101,6 +105,7 @@ func TestRegistryHandler_emailOccupied(it *testing.T) {
 				AccountRepository:          accountRepository,
 				IdentityBindingRepository:  &domain.DatabaseIdentityBindingRepository{Database: ds.Database},
 				InternalIdentityRepository: &domain.DatabaseInternalIdentityRepository{Database: ds.Database},
+				UnitOfWork:                 &domain.DatabaseUnitOfWork{TransactionSupport: &infra.GormTransactionSupport{Database: ds.Database}, IdWorker: util.DefaultIdWorker},
 			},
 			AccountRepository: accountRepository,
 		}
@@ -158,3 +163,86 @@ func TestRegistryHandler_emailOccupied(it *testing.T) {
 		assert.JSONEq(t, string(wantedBody), string(body))
 	})
 }
+
+type recordingMailer struct {
+	messages []*mail.Message
+	err      error
+}
+
+func (mailer *recordingMailer) Send(message *mail.Message) error {
+	mailer.messages = append(mailer.messages, message)
+	return mailer.err
+}
+
+func TestRegistryHandler_acquireEmailRegisterToken(it *testing.T) {
+	it.Run("should send register token to email", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		mailer := &recordingMailer{}
+		registryHandler := RegistryHandler{
+			AccountRepository: &domain.DatabaseAccountRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database},
+			Mailer:            mailer,
+		}
+		engine := gin.Default()
+		registryHandler.RegisterRoutes(engine.Group("/registry"))
+
+		registerEmail := uuid.New().String() + "@test.fundwit.com"
+		req := httptest.NewRequest(http.MethodPost, "/registry/email_register_tokens", strings.NewReader("{\"email\": \""+registerEmail+"\"}"))
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+
+		httpResponse := w.Result()
+		defer httpResponse.Body.Close()
+		body, _ := ioutil.ReadAll(httpResponse.Body)
+		assert.Equal(t, http.StatusOK, httpResponse.StatusCode)
+		assert.JSONEq(t, "{\"email\": \""+registerEmail+"\"}", string(body))
+
+		token, found := auth.RegisterTokenCache.Get(registerEmail)
+		assert.True(t, found)
+		assert.Len(t, mailer.messages, 1)
+		assert.Equal(t, []string{registerEmail}, mailer.messages[0].To)
+		assert.Contains(t, mailer.messages[0].Text, token.(string))
+		assert.Contains(t, mailer.messages[0].Html, token.(string))
+	})
+
+	it.Run("should response 500 when failed to send register token", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		registryHandler := RegistryHandler{
+			AccountRepository: &domain.DatabaseAccountRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database},
+			Mailer:            &recordingMailer{err: errors.New("connection refused")},
+		}
+		engine := gin.Default()
+		registryHandler.RegisterRoutes(engine.Group("/registry"))
+
+		registerEmail := uuid.New().String() + "@test.fundwit.com"
+		req := httptest.NewRequest(http.MethodPost, "/registry/email_register_tokens", strings.NewReader("{\"email\": \""+registerEmail+"\"}"))
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+
+		httpResponse := w.Result()
+		defer httpResponse.Body.Close()
+		body, _ := ioutil.ReadAll(httpResponse.Body)
+		assert.Equal(t, http.StatusInternalServerError, httpResponse.StatusCode)
+		assert.JSONEq(t, "{\"error\": \"failed to send register token\"}", string(body))
+	})
+
+	it.Run("should refuse malformed email without sending", func(t *testing.T) {
+		mailer := &recordingMailer{}
+		registryHandler := RegistryHandler{Mailer: mailer}
+		engine := gin.Default()
+		registryHandler.RegisterRoutes(engine.Group("/registry"))
+
+		for _, email := range []string{"not-an-email", "ann@test.fundwit.com\r\nBcc: bob@test.fundwit.com"} {
+			requestBody, err := json.Marshal(gin.H{"email": email})
+			assert.Nil(t, err)
+			req := httptest.NewRequest(http.MethodPost, "/registry/email_register_tokens", bytes2.NewReader(requestBody))
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		}
+		assert.Empty(t, mailer.messages)
+	})
+}
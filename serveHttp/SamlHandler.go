@@ -0,0 +1,145 @@
+package serveHttp
+
+import (
+	"crypto/subtle"
+	"github.com/gin-gonic/gin"
+	"hallo/domain"
+	"hallo/service/auth"
+	"hallo/service/saml"
+	"hallo/util"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// SamlHandler is the service provider of SAML identity providers, it must be registered at SAML_BASE_URL,
+// see saml.LoadProviders. Only the SP initiated flow is accepted, the RelayState is the state of request.
+// The account is redirected to LoginRedirectUri with a code as ConnectorHandler does
+type SamlHandler struct {
+	Providers        []*saml.Provider
+	LoginRedirectUri string
+	AccountManager   domain.AccountManager
+	TokenService     *auth.TokenService
+}
+
+// samlStateCookie keeps the relay state in the browser which starts the flow, the assertion is accepted only in it
+const samlStateCookie = "hallo_saml_state"
+
+func (handler *SamlHandler) RegisterRoutes(r *gin.RouterGroup) {
+	r.GET("", handler.listProviders)
+	r.GET("/:id/metadata", handler.metadata)
+	r.GET("/:id/login", handler.login)
+	r.POST("/:id/acs", handler.assertionConsumerService)
+}
+
+func (handler *SamlHandler) listProviders(c *gin.Context) {
+	providers := []gin.H{}
+	for _, provider := range handler.Providers {
+		providers = append(providers, gin.H{"id": provider.Id, "name": provider.Name})
+	}
+	c.JSON(http.StatusOK, providers)
+}
+
+func (handler *SamlHandler) metadata(c *gin.Context) {
+	provider := handler.findProvider(c.Param("id"))
+	if provider == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "saml provider not found"})
+		return
+	}
+	metadata, err := provider.Metadata()
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate metadata"})
+		return
+	}
+	c.Data(http.StatusOK, "application/samlmetadata+xml", metadata)
+}
+
+func (handler *SamlHandler) login(c *gin.Context) {
+	provider := handler.findProvider(c.Param("id"))
+	if provider == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "saml provider not found"})
+		return
+	}
+
+	relayState, err := util.RandomToken(32)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to sign in"})
+		return
+	}
+	requestId, redirectUrl, postForm, err := provider.AuthnRequest(relayState)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to sign in"})
+		return
+	}
+	if err := handler.TokenService.SaveSamlRequestState(relayState, &auth.SamlRequestState{ProviderId: provider.Id,
+		RequestId: requestId}); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to sign in"})
+		return
+	}
+
+	handler.setStateCookie(c, strings.TrimSuffix(c.Request.URL.Path, "/login"), relayState,
+		int(auth.SamlRequestExpiration.Seconds()))
+
+	if redirectUrl != nil {
+		c.Redirect(http.StatusFound, redirectUrl.String())
+		return
+	}
+	// the form is submitted to identity provider by script
+	c.Header("X-Frame-Options", "DENY")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", postForm)
+}
+
+// setStateCookie keeps the relay state for the assertion consumer service, the cookie is None as the assertion
+// is posted cross site from the identity provider, so it must be Secure
+func (handler *SamlHandler) setStateCookie(c *gin.Context, providerPath string, relayState string, maxAge int) {
+	c.SetSameSite(http.SameSiteNoneMode)
+	c.SetCookie(samlStateCookie, relayState, maxAge, providerPath, "", true, true)
+}
+
+// assertionConsumerService signs in the account as ConnectorHandler does
+func (handler *SamlHandler) assertionConsumerService(c *gin.Context) {
+	provider := handler.findProvider(c.Param("id"))
+	if provider == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "saml provider not found"})
+		return
+	}
+	relayState := c.PostForm("RelayState")
+	stateCookie, _ := c.Cookie(samlStateCookie)
+	if relayState == "" || subtle.ConstantTimeCompare([]byte(relayState), []byte(stateCookie)) != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "relay state is invalid or expired"})
+		return
+	}
+	handler.setStateCookie(c, strings.TrimSuffix(c.Request.URL.Path, "/acs"), "", -1)
+	state, err := handler.TokenService.TakeSamlRequestState(relayState)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to sign in"})
+		return
+	}
+	if state == nil || state.ProviderId != provider.Id {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "relay state is invalid or expired"})
+		return
+	}
+
+	identity, err := provider.ParseResponse(c.Request, state.RequestId)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "saml response is invalid"})
+		return
+	}
+
+	account, err := handler.AccountManager.AuthenticateExternalIdentity(*identity)
+	if err != nil {
+		respondExternalIdentityError(c, err)
+		return
+	}
+	redirectLoginCode(c, handler.TokenService, handler.LoginRedirectUri, account)
+}
+
+func (handler *SamlHandler) findProvider(id string) *saml.Provider {
+	return saml.Find(handler.Providers, id)
+}
@@ -0,0 +1,127 @@
+package serveHttp
+
+import (
+	"context"
+	crewjam "github.com/crewjam/saml"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"hallo/domain"
+	"hallo/domain/entity"
+	"hallo/service/auth"
+	"hallo/service/saml"
+	"hallo/testinfra"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSamlHandler(it *testing.T) {
+	it.Run("should sign in by assertion of saml identity provider", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		accountManager := domain.NewMockAccountManager(mockCtl)
+
+		idp, err := testinfra.NewSamlIdentityProvider()
+		assert.Nil(t, err)
+		defer idp.Close()
+		dir, err := ioutil.TempDir("", "saml")
+		assert.Nil(t, err)
+		defer os.RemoveAll(dir)
+		keyFile, certificateFile, err := testinfra.WriteSamlKeyPair(dir)
+		assert.Nil(t, err)
+		provider, err := saml.NewProvider(context.Background(), "https://hallo.test.fundwit.com/saml", saml.Config{Id: "corp",
+			Name: "Corp", IdpMetadataUrl: idp.MetadataUrl(), KeyFile: keyFile, CertificateFile: certificateFile, EmailVerified: true})
+		assert.Nil(t, err)
+
+		tokenService := &auth.TokenService{OneTimeTokenStore: auth.NewMemoryOneTimeTokenStore()}
+		samlHandler := SamlHandler{
+			Providers:        []*saml.Provider{provider},
+			LoginRedirectUri: "https://app.test.fundwit.com/login",
+			AccountManager:   accountManager,
+			TokenService:     tokenService,
+		}
+		engine := gin.Default()
+		samlHandler.RegisterRoutes(engine.Group("/saml"))
+		var stateCookie *http.Cookie
+		doRequest := func(method, path string, form url.Values) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if stateCookie != nil {
+				req.AddCookie(stateCookie)
+			}
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			return w
+		}
+		login := func() url.Values {
+			w := doRequest(http.MethodGet, "/saml/corp/login", nil)
+			assert.Equal(t, http.StatusFound, w.Code)
+			_, form, err := idp.SignIn(w.Header().Get("Location"))
+			assert.Nil(t, err)
+			cookies := w.Result().Cookies()
+			assert.Len(t, cookies, 1)
+			stateCookie = cookies[0]
+			assert.Equal(t, samlStateCookie, stateCookie.Name)
+			assert.Equal(t, form.Get("RelayState"), stateCookie.Value)
+			assert.Equal(t, "/saml/corp", stateCookie.Path)
+			assert.True(t, stateCookie.HttpOnly)
+			assert.True(t, stateCookie.Secure)
+			assert.Equal(t, http.SameSiteNoneMode, stateCookie.SameSite)
+			return form
+		}
+
+		w := doRequest(http.MethodGet, "/saml", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `[{"id": "corp", "name": "Corp"}]`, w.Body.String())
+		w = doRequest(http.MethodGet, "/saml/unknown/login", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = doRequest(http.MethodGet, "/saml/corp/metadata", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/samlmetadata+xml", w.Header().Get("Content-Type"))
+		assert.Nil(t, idp.RegisterServiceProvider(w.Body.Bytes()))
+
+		idp.Session = &crewjam.Session{ID: "session-1", NameID: "ann-persistent-id", UserName: "ann",
+			CustomAttributes: []crewjam.Attribute{{Name: "mail", Values: []crewjam.AttributeValue{{Value: "ann@test.fundwit.com"}}}}}
+		form := login()
+		w = doRequest(http.MethodPost, "/saml/corp/acs", url.Values{"RelayState": {"bad-state"}, "SAMLResponse": form["SAMLResponse"]})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		// the assertion is not accepted in another browser
+		cookie := stateCookie
+		stateCookie = nil
+		w = doRequest(http.MethodPost, "/saml/corp/acs", form)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		stateCookie = cookie
+
+		identity := entity.ExternalIdentity{ProviderId: "corp", ProviderAccountId: "ann-persistent-id", Name: "ann",
+			Email: "ann@test.fundwit.com", EmailVerified: true}
+		accountManager.EXPECT().AuthenticateExternalIdentity(identity).Return(&entity.Account{Id: 123, Name: "ann"}, nil)
+		w = doRequest(http.MethodPost, "/saml/corp/acs", form)
+		assert.Equal(t, http.StatusFound, w.Code)
+		location, err := url.Parse(w.Header().Get("Location"))
+		assert.Nil(t, err)
+		assert.Equal(t, "app.test.fundwit.com", location.Host)
+		loginCode, err := tokenService.TakeLoginCode(location.Query().Get("code"))
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(123), loginCode.AccountId)
+
+		// the relay state is taken only once
+		w = doRequest(http.MethodPost, "/saml/corp/acs", form)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		// the response of another request is rejected
+		first, second := login(), login()
+		w = doRequest(http.MethodPost, "/saml/corp/acs", url.Values{"RelayState": second["RelayState"], "SAMLResponse": first["SAMLResponse"]})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		form = login()
+		accountManager.EXPECT().AuthenticateExternalIdentity(identity).Return(nil, &domain.AccountEmailIsOccupied{})
+		w = doRequest(http.MethodPost, "/saml/corp/acs", form)
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+}
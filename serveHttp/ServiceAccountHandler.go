@@ -0,0 +1,189 @@
+package serveHttp
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/jinzhu/gorm"
+	"hallo/domain"
+	"hallo/domain/entity"
+	"hallo/service/auth"
+	"log"
+	"net/http"
+	"time"
+)
+
+// ServiceAccountHandler manages the service accounts, which get access tokens by the client credentials grant of OAuth2Handler.
+// The secrets are responded only once, when they are created. The scopes of service account are limited to the
+// permissions held by its creator, and its access tokens are revoked when it is deleted or its secret is rotated
+type ServiceAccountHandler struct {
+	ServiceAccountManager    domain.ServiceAccountManager
+	ServiceAccountRepository domain.ServiceAccountRepository
+	TokenService             *auth.TokenService
+}
+
+type ServiceAccountCreateForm struct {
+	Name        string   `json:"name" binding:"required"`
+	Description string   `json:"description"`
+	Scopes      []string `json:"scopes"`
+}
+
+// SecretRotateForm keeps the existing secrets valid in OverlapSeconds, they expire immediately when it is zero
+type SecretRotateForm struct {
+	OverlapSeconds int64 `json:"overlapSeconds" binding:"min=0"`
+}
+
+func (handler *ServiceAccountHandler) RegisterRoutes(r *gin.RouterGroup) {
+	authenticate := auth.AuthenticateByToken(handler.TokenService)
+	canRead := auth.RequirePermission(domain.PermissionServiceAccountRead)
+	canWrite := auth.RequirePermission(domain.PermissionServiceAccountWrite)
+
+	r.GET("", authenticate, auth.AuthenticatedCheck(), canRead, handler.listServiceAccounts)
+	r.POST("", authenticate, auth.AuthenticatedCheck(), canWrite, handler.createServiceAccount)
+	r.GET("/:id", authenticate, auth.AuthenticatedCheck(), canRead, handler.getServiceAccount)
+	r.DELETE("/:id", authenticate, auth.AuthenticatedCheck(), canWrite, handler.deleteServiceAccount)
+	r.GET("/:id/secrets", authenticate, auth.AuthenticatedCheck(), canRead, handler.listSecrets)
+	r.POST("/:id/secrets", authenticate, auth.AuthenticatedCheck(), canWrite, handler.rotateSecret)
+	r.DELETE("/:id/secrets/:secretId", authenticate, auth.AuthenticatedCheck(), canWrite, handler.deleteSecret)
+}
+
+func (handler *ServiceAccountHandler) listServiceAccounts(c *gin.Context) {
+	serviceAccounts, err := handler.ServiceAccountRepository.FindAll()
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list service accounts"})
+		return
+	}
+	c.JSON(http.StatusOK, serviceAccounts)
+}
+
+func (handler *ServiceAccountHandler) createServiceAccount(c *gin.Context) {
+	var form ServiceAccountCreateForm
+	if err := c.ShouldBindJSON(&form); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bad request body"})
+		return
+	}
+	sc := auth.LoadFromRequestContext(c)
+	for _, scope := range form.Scopes {
+		if !sc.Principal.HasPermission(scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "scope " + scope + " is not held by the creator"})
+			return
+		}
+	}
+
+	serviceAccount, secret, err := handler.ServiceAccountManager.CreateServiceAccount(entity.ServiceAccountCreateRequest{
+		Name: form.Name, Description: form.Description, Scopes: form.Scopes})
+	if err != nil {
+		respondServiceAccountError(c, err, "failed to create service account")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"serviceAccount": serviceAccount, "clientSecret": secret})
+}
+
+func (handler *ServiceAccountHandler) getServiceAccount(c *gin.Context) {
+	serviceAccountId, ok := idParam(c, "id")
+	if !ok {
+		return
+	}
+	serviceAccount, err := handler.ServiceAccountRepository.FindById(serviceAccountId)
+	if err != nil {
+		respondServiceAccountError(c, err, "failed to get service account")
+		return
+	}
+	c.JSON(http.StatusOK, serviceAccount)
+}
+
+func (handler *ServiceAccountHandler) deleteServiceAccount(c *gin.Context) {
+	serviceAccountId, ok := idParam(c, "id")
+	if !ok {
+		return
+	}
+	if err := handler.ServiceAccountManager.DeleteServiceAccount(serviceAccountId); err != nil {
+		respondServiceAccountError(c, err, "failed to delete service account")
+		return
+	}
+	handler.revokeTokens(serviceAccountId)
+	c.Status(http.StatusNoContent)
+}
+
+func (handler *ServiceAccountHandler) listSecrets(c *gin.Context) {
+	serviceAccountId, ok := idParam(c, "id")
+	if !ok {
+		return
+	}
+	if _, err := handler.ServiceAccountRepository.FindById(serviceAccountId); err != nil {
+		respondServiceAccountError(c, err, "failed to list secrets")
+		return
+	}
+	secrets, err := handler.ServiceAccountRepository.FindSecrets(serviceAccountId)
+	if err != nil {
+		respondServiceAccountError(c, err, "failed to list secrets")
+		return
+	}
+	c.JSON(http.StatusOK, secrets)
+}
+
+func (handler *ServiceAccountHandler) rotateSecret(c *gin.Context) {
+	serviceAccountId, ok := idParam(c, "id")
+	if !ok {
+		return
+	}
+	var form SecretRotateForm
+	if err := c.ShouldBindJSON(&form); err != nil {
+		log.Println(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bad request body"})
+		return
+	}
+
+	secret, plainSecret, err := handler.ServiceAccountManager.RotateSecret(serviceAccountId, time.Duration(form.OverlapSeconds)*time.Second)
+	if err != nil {
+		respondServiceAccountError(c, err, "failed to rotate secret")
+		return
+	}
+	handler.revokeTokens(serviceAccountId)
+	c.JSON(http.StatusCreated, gin.H{"secret": secret, "clientSecret": plainSecret})
+}
+
+// revokeTokens revokes the access tokens of service account, the ids of service accounts and accounts are generated
+// by the same worker so that they never collide
+func (handler *ServiceAccountHandler) revokeTokens(serviceAccountId uint64) {
+	if err := handler.TokenService.RevokeByAccountId(serviceAccountId, ""); err != nil {
+		log.Printf("failed to revoke tokens of service account [%d]: %v\n", serviceAccountId, err)
+	}
+}
+
+func (handler *ServiceAccountHandler) deleteSecret(c *gin.Context) {
+	serviceAccountId, ok := idParam(c, "id")
+	if !ok {
+		return
+	}
+	secretId, ok := idParam(c, "secretId")
+	if !ok {
+		return
+	}
+	if err := handler.ServiceAccountRepository.DeleteSecret(serviceAccountId, secretId); err != nil {
+		respondServiceAccountError(c, err, "failed to delete secret")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func respondServiceAccountError(c *gin.Context, err error, failure string) {
+	log.Printf("error: %v\n", err)
+
+	var validationErrs validator.ValidationErrors
+	var nameOccupied *domain.ServiceAccountNameIsOccupied
+	var unknownPermission *domain.ErrUnknownPermission
+	if errors.As(err, &validationErrs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bad request body"})
+	} else if errors.As(err, &unknownPermission) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": unknownPermission.Error()})
+	} else if errors.As(err, &nameOccupied) {
+		c.JSON(http.StatusConflict, gin.H{"error": nameOccupied.Error()})
+	} else if gorm.IsRecordNotFoundError(err) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "service account or secret not found"})
+	} else {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": failure})
+	}
+}
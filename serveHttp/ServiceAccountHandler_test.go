@@ -0,0 +1,133 @@
+package serveHttp
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"hallo/domain"
+	"hallo/domain/entity"
+	"hallo/service/auth"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServiceAccountHandler(it *testing.T) {
+	it.Run("should manage service accounts and rotate secrets with permissions", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		serviceAccountManager := domain.NewMockServiceAccountManager(mockCtl)
+		serviceAccountRepository := domain.NewMockServiceAccountRepository(mockCtl)
+		tokenService := &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), RefreshTokenStore: auth.NewMemoryRefreshTokenStore()}
+		serviceAccountHandler := ServiceAccountHandler{
+			ServiceAccountManager:    serviceAccountManager,
+			ServiceAccountRepository: serviceAccountRepository,
+			TokenService:             tokenService,
+		}
+
+		engine := gin.Default()
+		serviceAccountHandler.RegisterRoutes(engine.Group("/service_accounts"))
+
+		reader, _ := tokenService.Issue(auth.Principal{Id: 1, Name: "reader", Permissions: []string{domain.PermissionServiceAccountRead}})
+		writer, _ := tokenService.Issue(auth.Principal{Id: 2, Name: "writer", Permissions: []string{"service_accounts:*", "accounts:read"}})
+		doRequest := func(method, path, body, token string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, path, strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			return w
+		}
+
+		body := `{"name": "nightly-job", "scopes": ["accounts:read"]}`
+		w := doRequest(http.MethodPost, "/service_accounts", body, reader.Token)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		created := &entity.ServiceAccount{Id: 701, ClientId: "job", Name: "nightly-job", Scopes: "accounts:read"}
+		serviceAccountManager.EXPECT().CreateServiceAccount(entity.ServiceAccountCreateRequest{Name: "nightly-job", Scopes: []string{"accounts:read"}}).
+			Return(created, "plain-secret", nil)
+		serviceAccountManager.EXPECT().CreateServiceAccount(entity.ServiceAccountCreateRequest{Name: "taken"}).
+			Return(nil, "", &domain.ServiceAccountNameIsOccupied{})
+		w = doRequest(http.MethodPost, "/service_accounts", body, writer.Token)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Contains(t, w.Body.String(), `"clientSecret":"plain-secret"`)
+		assert.Contains(t, w.Body.String(), `"clientId":"job"`)
+		w = doRequest(http.MethodPost, "/service_accounts", `{"name": "taken"}`, writer.Token)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		serviceAccountRepository.EXPECT().FindAll().Return([]entity.ServiceAccount{*created}, nil)
+		w = doRequest(http.MethodGet, "/service_accounts", "", reader.Token)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"name":"nightly-job"`)
+
+		serviceAccountManager.EXPECT().RotateSecret(uint64(701), time.Hour).
+			Return(&entity.ServiceAccountSecret{Id: 801, ServiceAccountId: 701, HashedSecret: "hashed"}, "new-secret", nil)
+		serviceAccountManager.EXPECT().RotateSecret(uint64(702), time.Duration(0)).Return(nil, "", gorm.ErrRecordNotFound)
+		issued, _ := tokenService.Issue(auth.Principal{Id: 701, Name: "nightly-job", ServiceAccount: true, Scope: "accounts:read"})
+		w = doRequest(http.MethodPost, "/service_accounts/701/secrets", `{"overlapSeconds": 3600}`, writer.Token)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Contains(t, w.Body.String(), `"clientSecret":"new-secret"`)
+		assert.NotContains(t, w.Body.String(), "hashed")
+		// the tokens issued before rotation are revoked
+		found, err := tokenService.Authenticate(issued.Token)
+		assert.Nil(t, err)
+		assert.Nil(t, found)
+		w = doRequest(http.MethodPost, "/service_accounts/702/secrets", `{}`, writer.Token)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w = doRequest(http.MethodPost, "/service_accounts/701/secrets", `{"overlapSeconds": -1}`, writer.Token)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		serviceAccountRepository.EXPECT().FindById(uint64(701)).Return(created, nil)
+		serviceAccountRepository.EXPECT().FindSecrets(uint64(701)).
+			Return([]entity.ServiceAccountSecret{{Id: 801, ServiceAccountId: 701, HashedSecret: "hashed"}}, nil)
+		w = doRequest(http.MethodGet, "/service_accounts/701/secrets", "", reader.Token)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"id":801`)
+		assert.NotContains(t, w.Body.String(), "hashed")
+
+		serviceAccountRepository.EXPECT().DeleteSecret(uint64(701), uint64(801)).Return(nil)
+		w = doRequest(http.MethodDelete, "/service_accounts/701/secrets/801", "", writer.Token)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		issued, _ = tokenService.Issue(auth.Principal{Id: 701, Name: "nightly-job", ServiceAccount: true, Scope: "accounts:read"})
+		serviceAccountManager.EXPECT().DeleteServiceAccount(uint64(701)).Return(nil)
+		w = doRequest(http.MethodDelete, "/service_accounts/701", "", writer.Token)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		found, err = tokenService.Authenticate(issued.Token)
+		assert.Nil(t, err)
+		assert.Nil(t, found)
+	})
+
+	it.Run("should limit scopes to the known permissions held by creator", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		serviceAccountManager := domain.NewMockServiceAccountManager(mockCtl)
+		tokenService := &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), RefreshTokenStore: auth.NewMemoryRefreshTokenStore()}
+		engine := gin.Default()
+		(&ServiceAccountHandler{ServiceAccountManager: serviceAccountManager, TokenService: tokenService}).
+			RegisterRoutes(engine.Group("/service_accounts"))
+
+		writer, _ := tokenService.Issue(auth.Principal{Id: 2, Name: "writer", Permissions: []string{"service_accounts:*"}})
+		admin, _ := tokenService.Issue(auth.Principal{Id: 3, Name: "admin", Permissions: []string{"*"}})
+		doRequest := func(body, token string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPost, "/service_accounts", strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			return w
+		}
+
+		w := doRequest(`{"name": "nightly-job", "scopes": ["accounts:write"]}`, writer.Token)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = doRequest(`{"name": "nightly-job", "scopes": ["*"]}`, writer.Token)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		serviceAccountManager.EXPECT().CreateServiceAccount(entity.ServiceAccountCreateRequest{Name: "nightly-job", Scopes: []string{"*"}}).
+			Return(nil, "", &domain.ErrUnknownPermission{Permission: "*"})
+		w = doRequest(`{"name": "nightly-job", "scopes": ["*"]}`, admin.Token)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "permission * is unknown")
+	})
+}
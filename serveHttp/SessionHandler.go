@@ -1,28 +1,67 @@
 package serveHttp
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
-	"github.com/patrickmn/go-cache"
-	uuid "github.com/satori/go.uuid"
 	"hallo/domain"
+	"hallo/domain/entity"
 	"hallo/service/auth"
+	"hallo/service/passkey"
 	"log"
 	"net/http"
+	"time"
 )
 
 type SessionHandler struct {
-	AccountManager domain.AccountManager
+	AccountManager         domain.AccountManager
+	RoleRepository         domain.RoleRepository
+	GroupManager           domain.GroupManager
+	OrganizationRepository domain.OrganizationRepository
+	TokenService           *auth.TokenService
+	// SecondFactorManager is optional, the second factors are not required to sign in without it
+	SecondFactorManager domain.SecondFactorManager
+	// RelyingParty is optional, passkey login is enabled with it
+	RelyingParty                 *passkey.RelyingParty
+	WebAuthnCredentialRepository domain.WebAuthnCredentialRepository
+	AccountRepository            domain.AccountRepository
 }
 
+// LoginRequest authenticates within the organization, the default organization is used when Organization is empty.
+// Provider is "internal" (default) or "ldap", the accounts of directory are in the default organization
 type LoginRequest struct {
-	Name   string `json:"name"   binding:"required" pact:"example=sally"`
-	Secret string `json:"secret" binding:"required" pact:"example=secret"`
+	Organization string `json:"organization"`
+	Provider     string `json:"provider" binding:"omitempty,oneof=internal ldap"`
+	Name         string `json:"name"   binding:"required" pact:"example=sally"`
+	Secret       string `json:"secret" binding:"required" pact:"example=secret"`
+}
+
+// MfaRequest exchanges the challenge token responded by newSession for a session, Code is the current code of
+// authenticator app or an unused recovery code
+type MfaRequest struct {
+	MfaToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code"      binding:"required"`
+}
+
+// ExternalLoginRequest exchanges the code redirected by ConnectorHandler or SamlHandler for a session
+type ExternalLoginRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
 }
 
 func (handler *SessionHandler) RegisterRoutes(r *gin.RouterGroup) {
 	r.POST("", handler.newSession)
-	r.DELETE("", auth.AuthenticateByToken(), deleteSession)
-	r.GET("/me", auth.AuthenticateByToken(), auth.AuthenticatedCheck(), currentSession)
+	r.POST("/mfa", handler.verifySecondFactor)
+	r.POST("/external", handler.externalLogin)
+	if handler.RelyingParty != nil {
+		r.POST("/passkey/options", handler.beginPasskeyLogin)
+		r.POST("/passkey", handler.passkeyLogin)
+	}
+	r.POST("/refresh", handler.refreshSession)
+	r.DELETE("", auth.AuthenticateByToken(handler.TokenService), handler.deleteSession)
+	r.GET("/me", auth.AuthenticateByToken(handler.TokenService), auth.AuthenticatedCheck(), currentSession)
 }
 
 // authentication
@@ -35,27 +74,189 @@ func (handler *SessionHandler) newSession(c *gin.Context) {
 		return
 	}
 
-	_, err := handler.AccountManager.AuthenticateInternalIdentity(login.Name, login.Secret)
+	var account *entity.Account
+	var err error
+	if login.Provider == domain.LdapProviderId {
+		account, err = handler.AccountManager.AuthenticateDirectoryIdentity(login.Name, login.Secret)
+	} else {
+		account, err = handler.AccountManager.AuthenticateInternalIdentity(login.Organization, login.Name, login.Secret)
+	}
 	if err != nil {
 		log.Println(err)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "account not exist or secret is not match"})
 		return
 	}
 
-	token := uuid.NewV4().String()
+	handler.signIn(c, account)
+}
+
+// signIn challenges the second factor of account when it has one, otherwise the session is created
+func (handler *SessionHandler) signIn(c *gin.Context, account *entity.Account) {
+	if handler.SecondFactorManager != nil {
+		hasSecondFactor, err := handler.SecondFactorManager.HasSecondFactor(account.Id)
+		if err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create session"})
+			return
+		}
+		if hasSecondFactor {
+			handler.challengeSecondFactor(c, account)
+			return
+		}
+	}
+	handler.issueSession(c, account)
+}
+
+// challengeSecondFactor responds the token with which the session is created by the second factor,
+// the token is accepted in MfaChallengeExpiration
+func (handler *SessionHandler) challengeSecondFactor(c *gin.Context, account *entity.Account) {
+	token, err := handler.TokenService.IssueMfaChallenge(&auth.MfaChallenge{Account: *account,
+		ExpireTime: time.Now().Add(auth.MfaChallengeExpiration)})
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create session"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"mfa_required": true, "mfa_token": token,
+		"expires_in": int(auth.MfaChallengeExpiration.Seconds())})
+}
+
+// verifySecondFactor creates the session when the code is accepted, a challenge is dropped after
+// MfaChallengeAttempts failed codes. The failed codes of account are limited by SecondFactorManager across challenges
+func (handler *SessionHandler) verifySecondFactor(c *gin.Context) {
+	var request MfaRequest
+	if paramErr := c.ShouldBindJSON(&request); paramErr != nil {
+		log.Println(paramErr)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bad request body"})
+		return
+	}
+	challenge, err := handler.TokenService.TakeMfaChallenge(request.MfaToken)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify code"})
+		return
+	}
+	if challenge == nil || handler.SecondFactorManager == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "mfa token is invalid or expired"})
+		return
+	}
+
+	err = handler.SecondFactorManager.VerifySecondFactor(challenge.Account.Id, request.Code)
+	if err != nil {
+		log.Println(err)
+		var failure *domain.SecondFactorAuthenticationFailure
+		var locked *domain.SecondFactorIsLocked
+		if errors.As(err, &failure) {
+			challenge.Attempts++
+			handler.returnMfaChallenge(request.MfaToken, challenge)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": failure.Error()})
+		} else if errors.As(err, &locked) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": locked.Error()})
+		} else {
+			handler.returnMfaChallenge(request.MfaToken, challenge)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify code"})
+		}
+		return
+	}
+	handler.issueSession(c, &challenge.Account)
+}
+
+func (handler *SessionHandler) returnMfaChallenge(token string, challenge *auth.MfaChallenge) {
+	if err := handler.TokenService.ReturnMfaChallenge(token, challenge); err != nil {
+		log.Println(err)
+	}
+}
 
-	sc := &auth.SecurityContext{Token: token, Principal: auth.Principal{Name: login.Name}}
-	auth.TokenCache.Set(token, sc, cache.DefaultExpiration)
+// externalLogin signs in the account which is authenticated by an upstream identity provider, the second factor
+// is challenged as newSession does
+func (handler *SessionHandler) externalLogin(c *gin.Context) {
+	var request ExternalLoginRequest
+	if paramErr := c.ShouldBindJSON(&request); paramErr != nil {
+		log.Println(paramErr)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bad request body"})
+		return
+	}
+	login, err := handler.TokenService.TakeLoginCode(request.Code)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create session"})
+		return
+	}
+	if login == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "code is invalid or expired"})
+		return
+	}
+	account, err := handler.AccountRepository.FindById(login.AccountId)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create session"})
+		return
+	}
+	handler.signIn(c, account)
+}
+
+func (handler *SessionHandler) issueSession(c *gin.Context, account *entity.Account) {
+	principal, err := handler.principalLoader().Load(account)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create session"})
+		return
+	}
+	sc, err := handler.TokenService.Issue(principal)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create session"})
+		return
+	}
+	refreshToken, err := handler.TokenService.IssueRefreshToken(principal)
+	if err != nil {
+		log.Println(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create session"})
+		return
+	}
+	auth.SaveToRequestContext(c, sc)
+
+	c.Header("Authentication", sc.Token)
+	c.JSON(http.StatusOK, gin.H{"token": sc.Token, "refresh_token": refreshToken, "principal": gin.H{"name": sc.Principal.Name}})
+}
+
+func (handler *SessionHandler) principalLoader() *PrincipalLoader {
+	return &PrincipalLoader{RoleRepository: handler.RoleRepository, GroupManager: handler.GroupManager,
+		OrganizationRepository: handler.OrganizationRepository}
+}
+
+func (handler *SessionHandler) refreshSession(c *gin.Context) {
+	var request RefreshRequest
+	if paramErr := c.ShouldBindJSON(&request); paramErr != nil {
+		log.Println(paramErr)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bad request body"})
+		return
+	}
+
+	sc, refreshToken, err := handler.TokenService.Refresh(request.RefreshToken, "")
+	if err != nil {
+		log.Println(err)
+		if errors.Is(err, auth.ErrRefreshTokenInvalid) || errors.Is(err, auth.ErrRefreshTokenReused) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to refresh session"})
+		return
+	}
 	auth.SaveToRequestContext(c, sc)
 
-	c.Header("Authentication", token)
-	c.JSON(http.StatusOK, gin.H{"token": sc.Token, "principal": gin.H{"name": sc.Principal.Name}})
+	c.Header("Authentication", sc.Token)
+	c.JSON(http.StatusOK, gin.H{"token": sc.Token, "refresh_token": refreshToken, "principal": gin.H{"name": sc.Principal.Name}})
 }
 
-func deleteSession(c *gin.Context) {
+func (handler *SessionHandler) deleteSession(c *gin.Context) {
 	securityContext := auth.LoadFromRequestContext(c)
 	if securityContext != nil {
-		auth.TokenCache.Delete(securityContext.Token)
+		if err := handler.TokenService.Revoke(securityContext.Token); err != nil {
+			log.Println(err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete session"})
+			return
+		}
 	}
 	c.Status(http.StatusNoContent)
 }
@@ -63,7 +264,11 @@ func deleteSession(c *gin.Context) {
 func currentSession(c *gin.Context) {
 	sc := auth.LoadFromRequestContext(c)
 	if sc != nil {
-		c.JSON(http.StatusOK, gin.H{"token": sc.Token, "principal": gin.H{"name": sc.Principal.Name}})
+		groups := sc.Principal.Groups
+		if groups == nil {
+			groups = []string{}
+		}
+		c.JSON(http.StatusOK, gin.H{"token": sc.Token, "principal": gin.H{"name": sc.Principal.Name, "groups": groups}})
 	} else {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": (&domain.ErrUnauthorized{}).Error()})
 	}
@@ -2,13 +2,21 @@ package serveHttp
 
 import (
 	bytes2 "bytes"
+	"crypto/rand"
+	"crypto/rsa"
 	"encoding/json"
+	"fmt"
+	"github.com/duo-labs/webauthn/protocol"
 	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
 	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
 	"github.com/stretchr/testify/assert"
 	"hallo/domain"
 	"hallo/domain/entity"
+	"hallo/infra"
 	"hallo/service/auth"
+	"hallo/service/passkey"
 	"hallo/testinfra"
 	"hallo/util"
 	"io/ioutil"
@@ -28,7 +36,11 @@ func TestSessionHandler_newSession(it *testing.T) {
 				AccountRepository:          &domain.DatabaseAccountRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database},
 				IdentityBindingRepository:  &domain.DatabaseIdentityBindingRepository{Database: ds.Database},
 				InternalIdentityRepository: &domain.DatabaseInternalIdentityRepository{Database: ds.Database},
+				UnitOfWork:                 &domain.DatabaseUnitOfWork{TransactionSupport: &infra.GormTransactionSupport{Database: ds.Database}, IdWorker: util.DefaultIdWorker},
 			},
+			RoleRepository: &domain.DatabaseRoleRepository{Database: ds.Database},
+			GroupManager:   &domain.GroupManagerImpl{GroupRepository: &domain.DatabaseGroupRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database}},
+			TokenService:   &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), RefreshTokenStore: auth.NewMemoryRefreshTokenStore()},
 		}
 
 		engine := gin.Default()
@@ -62,7 +74,11 @@ func TestSessionHandler_newSession(it *testing.T) {
 				AccountRepository:          &domain.DatabaseAccountRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database},
 				IdentityBindingRepository:  &domain.DatabaseIdentityBindingRepository{Database: ds.Database},
 				InternalIdentityRepository: &domain.DatabaseInternalIdentityRepository{Database: ds.Database},
+				UnitOfWork:                 &domain.DatabaseUnitOfWork{TransactionSupport: &infra.GormTransactionSupport{Database: ds.Database}, IdWorker: util.DefaultIdWorker},
 			},
+			RoleRepository: &domain.DatabaseRoleRepository{Database: ds.Database},
+			GroupManager:   &domain.GroupManagerImpl{GroupRepository: &domain.DatabaseGroupRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database}},
+			TokenService:   &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), RefreshTokenStore: auth.NewMemoryRefreshTokenStore()},
 		}
 		engine := gin.Default()
 		sessionHandler.RegisterRoutes(engine.Group("/sessions"))
@@ -130,7 +146,11 @@ func TestSessionHandler_newSession(it *testing.T) {
 				AccountRepository:          &domain.DatabaseAccountRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database},
 				IdentityBindingRepository:  &domain.DatabaseIdentityBindingRepository{Database: ds.Database},
 				InternalIdentityRepository: &domain.DatabaseInternalIdentityRepository{Database: ds.Database},
+				UnitOfWork:                 &domain.DatabaseUnitOfWork{TransactionSupport: &infra.GormTransactionSupport{Database: ds.Database}, IdWorker: util.DefaultIdWorker},
 			},
+			RoleRepository: &domain.DatabaseRoleRepository{Database: ds.Database},
+			GroupManager:   &domain.GroupManagerImpl{GroupRepository: &domain.DatabaseGroupRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database}},
+			TokenService:   &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), RefreshTokenStore: auth.NewMemoryRefreshTokenStore()},
 		}
 		engine := gin.Default()
 		sessionHandler.RegisterRoutes(engine.Group("/sessions"))
@@ -138,7 +158,7 @@ func TestSessionHandler_newSession(it *testing.T) {
 		// create account
 		accountName := uuid.New().String()
 		accountSecret := uuid.New().String()
-		_, err := sessionHandler.AccountManager.CreateAccount(
+		account, err := sessionHandler.AccountManager.CreateAccount(
 			entity.EmailAccountCreateRequest{Name: accountName, Email: accountName + "@test.fundwit.com", Secret: accountSecret})
 
 		requestBody, err := json.Marshal(LoginRequest{Name: accountName, Secret: accountSecret})
@@ -158,18 +178,573 @@ func TestSessionHandler_newSession(it *testing.T) {
 		assert.Equal(t, http.StatusOK, httpResponse.StatusCode)
 		token := httpResponse.Header.Get("Authentication")
 		assert.NotNil(t, token)
-		wantedBody, err := json.Marshal(gin.H{"token": token, "principal": gin.H{"name": accountName}})
+		bodyJson := map[string]interface{}{}
+		if err := json.Unmarshal(responseBody, &bodyJson); err != nil {
+			panic(err)
+		}
+		assert.NotEmpty(t, bodyJson["refresh_token"])
+		wantedBody, err := json.Marshal(gin.H{"token": token, "refresh_token": bodyJson["refresh_token"], "principal": gin.H{"name": accountName}})
 		if err != nil {
 			panic(err)
 		}
 		assert.JSONEq(t, string(wantedBody), string(responseBody))
 
-		// token was cached
-		sc, found := auth.TokenCache.Get(token)
-		assert.True(t, found)
-		want := &auth.SecurityContext{Token: token, Principal: auth.Principal{Name: accountName}}
+		// session was saved
+		sc, err := sessionHandler.TokenService.Authenticate(token)
+		assert.Nil(t, err)
+		want := &auth.SecurityContext{Token: token, Principal: auth.Principal{Id: account.Id, Name: accountName}}
 		assert.Equal(t, want, sc)
 	})
+
+	it.Run("should login success with jwt access token", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			panic(err)
+		}
+		jwtIssuer, err := auth.NewJwtIssuer("hallo-test", key)
+		if err != nil {
+			panic(err)
+		}
+
+		sessionHandler := SessionHandler{
+			AccountManager: &domain.AccountManagerImpl{
+				AccountRepository:          &domain.DatabaseAccountRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database},
+				IdentityBindingRepository:  &domain.DatabaseIdentityBindingRepository{Database: ds.Database},
+				InternalIdentityRepository: &domain.DatabaseInternalIdentityRepository{Database: ds.Database},
+				UnitOfWork:                 &domain.DatabaseUnitOfWork{TransactionSupport: &infra.GormTransactionSupport{Database: ds.Database}, IdWorker: util.DefaultIdWorker},
+			},
+			RoleRepository: &domain.DatabaseRoleRepository{Database: ds.Database},
+			GroupManager:   &domain.GroupManagerImpl{GroupRepository: &domain.DatabaseGroupRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database}},
+			TokenService:   &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), JwtIssuer: jwtIssuer, RefreshTokenStore: auth.NewMemoryRefreshTokenStore()},
+		}
+		engine := gin.Default()
+		sessionHandler.RegisterRoutes(engine.Group("/sessions"))
+
+		// create account
+		accountName := uuid.New().String()
+		accountSecret := uuid.New().String()
+		account, err := sessionHandler.AccountManager.CreateAccount(
+			entity.EmailAccountCreateRequest{Name: accountName, Email: accountName + "@test.fundwit.com", Secret: accountSecret})
+
+		requestBody, err := json.Marshal(LoginRequest{Name: accountName, Secret: accountSecret})
+		if err != nil {
+			panic(err)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/sessions", bytes2.NewReader(requestBody))
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+
+		httpResponse := w.Result()
+		defer httpResponse.Body.Close()
+
+		// assertion
+		assert.Equal(t, http.StatusOK, httpResponse.StatusCode)
+		token := httpResponse.Header.Get("Authentication")
+		claims, err := jwtIssuer.Verify(token)
+		assert.Nil(t, err)
+		assert.Equal(t, fmt.Sprintf("%d", account.Id), claims.Subject)
+		assert.Equal(t, accountName, claims.Name)
+
+		// jwt is accepted
+		req = httptest.NewRequest(http.MethodGet, "/sessions/me", nil)
+		req.Header.Set("Authorization", "bearer "+token)
+		w = httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		httpResponse = w.Result()
+		defer httpResponse.Body.Close()
+		assert.Equal(t, http.StatusOK, httpResponse.StatusCode)
+	})
+}
+
+func TestSessionHandler_organizationGrants(it *testing.T) {
+	it.Run("should keep roles in organization apart from global roles", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		accountManager := domain.NewMockAccountManager(mockCtl)
+		roleRepository := domain.NewMockRoleRepository(mockCtl)
+		groupManager := domain.NewMockGroupManager(mockCtl)
+		organizationRepository := domain.NewMockOrganizationRepository(mockCtl)
+		tokenService := &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), RefreshTokenStore: auth.NewMemoryRefreshTokenStore()}
+		sessionHandler := SessionHandler{
+			AccountManager:         accountManager,
+			RoleRepository:         roleRepository,
+			GroupManager:           groupManager,
+			OrganizationRepository: organizationRepository,
+			TokenService:           tokenService,
+		}
+
+		engine := gin.Default()
+		sessionHandler.RegisterRoutes(engine.Group("/sessions"))
+
+		accountManager.EXPECT().AuthenticateInternalIdentity("acme", "ann", "secret").
+			Return(&entity.Account{Id: 123, OrganizationId: 601, Name: "ann"}, nil)
+		roleRepository.EXPECT().FindGrantsByAccountId(uint64(123)).Return([]string{"viewer"}, []string{"accounts:read"}, nil)
+		organizationRepository.EXPECT().FindMemberRoles(uint64(601), uint64(123)).Return([]string{"owner", "viewer"}, nil)
+		roleRepository.EXPECT().FindPermissionsByRoles([]string{"owner", "viewer"}).Return([]string{"accounts:read", "groups:*"}, nil)
+		groupManager.EXPECT().FindMemberships(uint64(123)).Return([]string{}, nil)
+
+		req := httptest.NewRequest(http.MethodPost, "/sessions",
+			strings.NewReader(`{"organization": "acme", "name": "ann", "secret": "secret"}`))
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		body := map[string]interface{}{}
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &body))
+		sc, err := tokenService.Authenticate(body["token"].(string))
+		assert.Nil(t, err)
+		assert.Equal(t, auth.Principal{Id: 123, Name: "ann", OrganizationId: 601, Roles: []string{"viewer"},
+			Permissions: []string{"accounts:read"}, Groups: []string{},
+			OrganizationRoles: []string{"owner", "viewer"}, OrganizationPermissions: []string{"accounts:read", "groups:*"}}, sc.Principal)
+		assert.False(t, sc.Principal.HasPermission("groups:write"))
+		assert.True(t, sc.Principal.HasOrganizationPermission(601, "groups:write"))
+	})
+}
+
+func TestSessionHandler_directory(it *testing.T) {
+	it.Run("should login by ldap provider", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		accountManager := domain.NewMockAccountManager(mockCtl)
+		roleRepository := domain.NewMockRoleRepository(mockCtl)
+		groupManager := domain.NewMockGroupManager(mockCtl)
+		sessionHandler := SessionHandler{
+			AccountManager: accountManager,
+			RoleRepository: roleRepository,
+			GroupManager:   groupManager,
+			TokenService:   &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), RefreshTokenStore: auth.NewMemoryRefreshTokenStore()},
+		}
+
+		engine := gin.Default()
+		sessionHandler.RegisterRoutes(engine.Group("/sessions"))
+		doRequest := func(body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPost, "/sessions", strings.NewReader(body))
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			return w
+		}
+
+		accountManager.EXPECT().AuthenticateDirectoryIdentity("ann", "secret").Return(&entity.Account{Id: 123, Name: "Ann"}, nil)
+		accountManager.EXPECT().AuthenticateDirectoryIdentity("ann", "bad").Return(nil, &domain.AccountAuthenticationFailure{})
+		roleRepository.EXPECT().FindGrantsByAccountId(uint64(123)).Return([]string{}, []string{}, nil)
+		groupManager.EXPECT().FindMemberships(uint64(123)).Return([]string{}, nil)
+
+		w := doRequest(`{"provider": "ldap", "name": "ann", "secret": "secret"}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"principal":{"name":"Ann"}`)
+		w = doRequest(`{"provider": "ldap", "name": "ann", "secret": "bad"}`)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		w = doRequest(`{"provider": "unknown", "name": "ann", "secret": "secret"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestSessionHandler_secondFactor(it *testing.T) {
+	it.Run("should create session by mfa token and code", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		accountManager := domain.NewMockAccountManager(mockCtl)
+		roleRepository := domain.NewMockRoleRepository(mockCtl)
+		groupManager := domain.NewMockGroupManager(mockCtl)
+		secondFactorManager := domain.NewMockSecondFactorManager(mockCtl)
+		sessionHandler := SessionHandler{
+			AccountManager:      accountManager,
+			RoleRepository:      roleRepository,
+			GroupManager:        groupManager,
+			TokenService:        &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), OneTimeTokenStore: auth.NewMemoryOneTimeTokenStore(), RefreshTokenStore: auth.NewMemoryRefreshTokenStore()},
+			SecondFactorManager: secondFactorManager,
+		}
+
+		engine := gin.Default()
+		sessionHandler.RegisterRoutes(engine.Group("/sessions"))
+		doRequest := func(path, body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			return w
+		}
+		login := func() string {
+			w := doRequest("/sessions", `{"name": "ann", "secret": "secret"}`)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Empty(t, w.Header().Get("Authentication"))
+			var body struct {
+				MfaRequired bool   `json:"mfa_required"`
+				MfaToken    string `json:"mfa_token"`
+				ExpiresIn   int    `json:"expires_in"`
+				Token       string `json:"token"`
+			}
+			assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.True(t, body.MfaRequired)
+			assert.Equal(t, 300, body.ExpiresIn)
+			assert.Empty(t, body.Token)
+			return body.MfaToken
+		}
+
+		account := &entity.Account{Id: 123, Name: "Ann"}
+		accountManager.EXPECT().AuthenticateInternalIdentity("", "ann", "secret").Return(account, nil).Times(2)
+		secondFactorManager.EXPECT().HasSecondFactor(uint64(123)).Return(true, nil).Times(2)
+
+		mfaToken := login()
+		w := doRequest("/sessions/mfa", `{"mfa_token": "bad-token", "code": "123456"}`)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		secondFactorManager.EXPECT().VerifySecondFactor(uint64(123), "000000").Return(&domain.SecondFactorAuthenticationFailure{})
+		w = doRequest("/sessions/mfa", `{"mfa_token": "`+mfaToken+`", "code": "000000"}`)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		// the token is kept after a wrong code, and it is taken by the session
+		secondFactorManager.EXPECT().VerifySecondFactor(uint64(123), "123456").Return(nil)
+		roleRepository.EXPECT().FindGrantsByAccountId(uint64(123)).Return([]string{}, []string{}, nil)
+		groupManager.EXPECT().FindMemberships(uint64(123)).Return([]string{}, nil)
+		w = doRequest("/sessions/mfa", `{"mfa_token": "`+mfaToken+`", "code": "123456"}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"principal":{"name":"Ann"}`)
+		sc, err := sessionHandler.TokenService.Authenticate(w.Header().Get("Authentication"))
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(123), sc.Principal.Id)
+		w = doRequest("/sessions/mfa", `{"mfa_token": "`+mfaToken+`", "code": "123456"}`)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		// the token is dropped when attempts are exhausted
+		mfaToken = login()
+		secondFactorManager.EXPECT().VerifySecondFactor(uint64(123), "000000").Return(&domain.SecondFactorAuthenticationFailure{}).
+			Times(auth.MfaChallengeAttempts)
+		for i := 0; i < auth.MfaChallengeAttempts; i++ {
+			w = doRequest("/sessions/mfa", `{"mfa_token": "`+mfaToken+`", "code": "000000"}`)
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+		}
+		w = doRequest("/sessions/mfa", `{"mfa_token": "`+mfaToken+`", "code": "123456"}`)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	it.Run("should refuse codes when second factor of account is locked", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		accountManager := domain.NewMockAccountManager(mockCtl)
+		secondFactorManager := domain.NewMockSecondFactorManager(mockCtl)
+		sessionHandler := SessionHandler{
+			AccountManager:      accountManager,
+			TokenService:        &auth.TokenService{OneTimeTokenStore: auth.NewMemoryOneTimeTokenStore()},
+			SecondFactorManager: secondFactorManager,
+		}
+		engine := gin.Default()
+		sessionHandler.RegisterRoutes(engine.Group("/sessions"))
+
+		accountManager.EXPECT().AuthenticateInternalIdentity("", "ann", "secret").Return(&entity.Account{Id: 123, Name: "Ann"}, nil)
+		secondFactorManager.EXPECT().HasSecondFactor(uint64(123)).Return(true, nil)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/sessions", strings.NewReader(`{"name": "ann", "secret": "secret"}`)))
+		assert.Equal(t, http.StatusOK, w.Code)
+		var body struct {
+			MfaToken string `json:"mfa_token"`
+		}
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &body))
+
+		secondFactorManager.EXPECT().VerifySecondFactor(uint64(123), "123456").Return(&domain.SecondFactorIsLocked{})
+		w = httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/sessions/mfa",
+			strings.NewReader(`{"mfa_token": "`+body.MfaToken+`", "code": "123456"}`)))
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Empty(t, w.Header().Get("Authentication"))
+	})
+
+	it.Run("should create session directly when account has no second factor", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		accountManager := domain.NewMockAccountManager(mockCtl)
+		roleRepository := domain.NewMockRoleRepository(mockCtl)
+		groupManager := domain.NewMockGroupManager(mockCtl)
+		secondFactorManager := domain.NewMockSecondFactorManager(mockCtl)
+		sessionHandler := SessionHandler{
+			AccountManager:      accountManager,
+			RoleRepository:      roleRepository,
+			GroupManager:        groupManager,
+			TokenService:        &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), RefreshTokenStore: auth.NewMemoryRefreshTokenStore()},
+			SecondFactorManager: secondFactorManager,
+		}
+		engine := gin.Default()
+		sessionHandler.RegisterRoutes(engine.Group("/sessions"))
+
+		accountManager.EXPECT().AuthenticateInternalIdentity("", "ann", "secret").Return(&entity.Account{Id: 123, Name: "Ann"}, nil)
+		secondFactorManager.EXPECT().HasSecondFactor(uint64(123)).Return(false, nil)
+		roleRepository.EXPECT().FindGrantsByAccountId(uint64(123)).Return([]string{}, []string{}, nil)
+		groupManager.EXPECT().FindMemberships(uint64(123)).Return([]string{}, nil)
+		req := httptest.NewRequest(http.MethodPost, "/sessions", strings.NewReader(`{"name": "ann", "secret": "secret"}`))
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotEmpty(t, w.Header().Get("Authentication"))
+	})
+}
+
+func TestSessionHandler_externalLogin(it *testing.T) {
+	it.Run("should create session by login code only once", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		accountRepository := domain.NewMockAccountRepository(mockCtl)
+		roleRepository := domain.NewMockRoleRepository(mockCtl)
+		groupManager := domain.NewMockGroupManager(mockCtl)
+		tokenService := &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), OneTimeTokenStore: auth.NewMemoryOneTimeTokenStore(),
+			RefreshTokenStore: auth.NewMemoryRefreshTokenStore()}
+		sessionHandler := SessionHandler{
+			AccountRepository: accountRepository,
+			RoleRepository:    roleRepository,
+			GroupManager:      groupManager,
+			TokenService:      tokenService,
+		}
+		engine := gin.Default()
+		sessionHandler.RegisterRoutes(engine.Group("/sessions"))
+		doRequest := func(body string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/sessions/external", strings.NewReader(body)))
+			return w
+		}
+
+		code, err := tokenService.IssueLoginCode(&auth.LoginCode{AccountId: 123})
+		assert.Nil(t, err)
+		w := doRequest(`{"code": "bad-code"}`)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		accountRepository.EXPECT().FindById(uint64(123)).Return(&entity.Account{Id: 123, Name: "Ann"}, nil)
+		roleRepository.EXPECT().FindGrantsByAccountId(uint64(123)).Return([]string{}, []string{}, nil)
+		groupManager.EXPECT().FindMemberships(uint64(123)).Return([]string{}, nil)
+		w = doRequest(`{"code": "` + code + `"}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"principal":{"name":"Ann"}`)
+		sc, err := tokenService.Authenticate(w.Header().Get("Authentication"))
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(123), sc.Principal.Id)
+
+		w = doRequest(`{"code": "` + code + `"}`)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	it.Run("should challenge second factor of account signed in by identity provider", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		accountRepository := domain.NewMockAccountRepository(mockCtl)
+		secondFactorManager := domain.NewMockSecondFactorManager(mockCtl)
+		tokenService := &auth.TokenService{OneTimeTokenStore: auth.NewMemoryOneTimeTokenStore()}
+		sessionHandler := SessionHandler{
+			AccountRepository:   accountRepository,
+			TokenService:        tokenService,
+			SecondFactorManager: secondFactorManager,
+		}
+		engine := gin.Default()
+		sessionHandler.RegisterRoutes(engine.Group("/sessions"))
+
+		code, err := tokenService.IssueLoginCode(&auth.LoginCode{AccountId: 123})
+		assert.Nil(t, err)
+		accountRepository.EXPECT().FindById(uint64(123)).Return(&entity.Account{Id: 123, Name: "Ann"}, nil)
+		secondFactorManager.EXPECT().HasSecondFactor(uint64(123)).Return(true, nil)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/sessions/external", strings.NewReader(`{"code": "`+code+`"}`)))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("Authentication"))
+		assert.Contains(t, w.Body.String(), `"mfa_required":true`)
+	})
+}
+
+func TestSessionHandler_passkey(it *testing.T) {
+	it.Run("should create session by assertion of passkey", func(t *testing.T) {
+		mockCtl := gomock.NewController(t)
+		defer mockCtl.Finish()
+		accountRepository := domain.NewMockAccountRepository(mockCtl)
+		credentialRepository := domain.NewMockWebAuthnCredentialRepository(mockCtl)
+		roleRepository := domain.NewMockRoleRepository(mockCtl)
+		groupManager := domain.NewMockGroupManager(mockCtl)
+		relyingParty, err := passkey.NewRelyingParty(passkey.Config{Id: "hallo.test.fundwit.com", Origin: "https://hallo.test.fundwit.com"})
+		assert.Nil(t, err)
+		sessionHandler := SessionHandler{
+			RoleRepository:               roleRepository,
+			GroupManager:                 groupManager,
+			TokenService:                 &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), OneTimeTokenStore: auth.NewMemoryOneTimeTokenStore(), RefreshTokenStore: auth.NewMemoryRefreshTokenStore()},
+			RelyingParty:                 relyingParty,
+			WebAuthnCredentialRepository: credentialRepository,
+			AccountRepository:            accountRepository,
+		}
+
+		engine := gin.Default()
+		sessionHandler.RegisterRoutes(engine.Group("/sessions"))
+		doRequest := func(path string, body []byte) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPost, path, bytes2.NewReader(body))
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			return w
+		}
+
+		// the passkey is registered by the software authenticator
+		account := &entity.Account{Id: 123, Name: "Ann"}
+		authenticator := testinfra.NewWebAuthnAuthenticator("https://hallo.test.fundwit.com")
+		options, session, err := relyingParty.BeginRegistration(account, nil)
+		assert.Nil(t, err)
+		optionsJson, err := json.Marshal(options)
+		assert.Nil(t, err)
+		created, err := authenticator.Create(optionsJson)
+		assert.Nil(t, err)
+		parsed, err := protocol.ParseCredentialCreationResponseBody(bytes2.NewReader(created))
+		assert.Nil(t, err)
+		credential, err := relyingParty.FinishRegistration(account, *session, parsed, "laptop")
+		assert.Nil(t, err)
+		clone := authenticator.Clone()
+		assertByOptions := func(authenticator *testinfra.WebAuthnAuthenticator) []byte {
+			w := doRequest("/sessions/passkey/options", nil)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assertion, err := authenticator.Get(w.Body.Bytes())
+			assert.Nil(t, err)
+			return assertion
+		}
+
+		assertion := assertByOptions(authenticator)
+		stored := *credential
+		credentialRepository.EXPECT().FindById(credential.Id).Return(&stored, nil)
+		accountRepository.EXPECT().FindById(uint64(123)).Return(account, nil)
+		credentialRepository.EXPECT().UpdateSignCount(credential.Id, uint32(0), uint32(1), gomock.Any()).Return(true, nil)
+		roleRepository.EXPECT().FindGrantsByAccountId(uint64(123)).Return([]string{}, []string{}, nil)
+		groupManager.EXPECT().FindMemberships(uint64(123)).Return([]string{}, nil)
+		w := doRequest("/sessions/passkey", assertion)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"principal":{"name":"Ann"}`)
+		sc, err := sessionHandler.TokenService.Authenticate(w.Header().Get("Authentication"))
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(123), sc.Principal.Id)
+
+		// the assertion is not replayed
+		w = doRequest("/sessions/passkey", assertion)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		w = doRequest("/sessions/passkey", []byte("{}"))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		// the sign count of cloned authenticator is not increased
+		stored.SignCount = 1
+		credentialRepository.EXPECT().FindById(credential.Id).Return(&stored, nil)
+		accountRepository.EXPECT().FindById(uint64(123)).Return(account, nil)
+		w = doRequest("/sessions/passkey", assertByOptions(clone))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		// the sign count was updated by a concurrent assertion
+		credentialRepository.EXPECT().FindById(credential.Id).Return(&stored, nil)
+		accountRepository.EXPECT().FindById(uint64(123)).Return(account, nil)
+		credentialRepository.EXPECT().UpdateSignCount(credential.Id, uint32(1), uint32(2), gomock.Any()).Return(false, nil)
+		w = doRequest("/sessions/passkey", assertByOptions(authenticator))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		credentialRepository.EXPECT().FindById(credential.Id).Return(nil, gorm.ErrRecordNotFound)
+		w = doRequest("/sessions/passkey", assertByOptions(authenticator))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	it.Run("should not route passkey login without relying party", func(t *testing.T) {
+		sessionHandler := SessionHandler{TokenService: &auth.TokenService{SessionStore: auth.NewMemorySessionStore()}}
+		engine := gin.Default()
+		sessionHandler.RegisterRoutes(engine.Group("/sessions"))
+		req := httptest.NewRequest(http.MethodPost, "/sessions/passkey/options", nil)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestSessionHandler_refreshSession(it *testing.T) {
+	it.Run("should rotate refresh token and revoke the family when reused", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		sessionHandler := SessionHandler{
+			AccountManager: &domain.AccountManagerImpl{
+				AccountRepository:          &domain.DatabaseAccountRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database},
+				IdentityBindingRepository:  &domain.DatabaseIdentityBindingRepository{Database: ds.Database},
+				InternalIdentityRepository: &domain.DatabaseInternalIdentityRepository{Database: ds.Database},
+				UnitOfWork:                 &domain.DatabaseUnitOfWork{TransactionSupport: &infra.GormTransactionSupport{Database: ds.Database}, IdWorker: util.DefaultIdWorker},
+			},
+			RoleRepository: &domain.DatabaseRoleRepository{Database: ds.Database},
+			GroupManager:   &domain.GroupManagerImpl{GroupRepository: &domain.DatabaseGroupRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database}},
+			TokenService:   &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), RefreshTokenStore: auth.NewMemoryRefreshTokenStore()},
+		}
+		engine := gin.Default()
+		sessionHandler.RegisterRoutes(engine.Group("/sessions"))
+
+		// --- bad body ---
+		req := httptest.NewRequest(http.MethodPost, "/sessions/refresh", strings.NewReader("xxx"))
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		httpResponse := w.Result()
+		defer httpResponse.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, httpResponse.StatusCode)
+
+		// --- unknown refresh token ---
+		req = httptest.NewRequest(http.MethodPost, "/sessions/refresh", strings.NewReader(`{"refresh_token": "unknown"}`))
+		w = httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		httpResponse = w.Result()
+		defer httpResponse.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, httpResponse.StatusCode)
+
+		// login
+		accountName := uuid.New().String()
+		accountSecret := uuid.New().String()
+		_, err := sessionHandler.AccountManager.CreateAccount(
+			entity.EmailAccountCreateRequest{Name: accountName, Email: accountName + "@test.fundwit.com", Secret: accountSecret})
+		requestBody, err := json.Marshal(LoginRequest{Name: accountName, Secret: accountSecret})
+		if err != nil {
+			panic(err)
+		}
+		req = httptest.NewRequest(http.MethodPost, "/sessions", bytes2.NewReader(requestBody))
+		w = httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		httpResponse = w.Result()
+		defer httpResponse.Body.Close()
+		responseBody, _ := ioutil.ReadAll(httpResponse.Body)
+		assert.Equal(t, http.StatusOK, httpResponse.StatusCode)
+		loginJson := map[string]interface{}{}
+		if err := json.Unmarshal(responseBody, &loginJson); err != nil {
+			panic(err)
+		}
+		firstRefreshToken := loginJson["refresh_token"].(string)
+
+		// --- refresh success ---
+		req = httptest.NewRequest(http.MethodPost, "/sessions/refresh", strings.NewReader(`{"refresh_token": "`+firstRefreshToken+`"}`))
+		w = httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		httpResponse = w.Result()
+		defer httpResponse.Body.Close()
+		responseBody, _ = ioutil.ReadAll(httpResponse.Body)
+		assert.Equal(t, http.StatusOK, httpResponse.StatusCode)
+		refreshJson := map[string]interface{}{}
+		if err := json.Unmarshal(responseBody, &refreshJson); err != nil {
+			panic(err)
+		}
+		secondRefreshToken := refreshJson["refresh_token"].(string)
+		assert.NotEqual(t, firstRefreshToken, secondRefreshToken)
+		assert.NotEqual(t, loginJson["token"], refreshJson["token"])
+		assert.Equal(t, httpResponse.Header.Get("Authentication"), refreshJson["token"])
+		sc, err := sessionHandler.TokenService.Authenticate(refreshJson["token"].(string))
+		assert.Nil(t, err)
+		assert.Equal(t, accountName, sc.Principal.Name)
+
+		// --- reuse the rotated refresh token ---
+		req = httptest.NewRequest(http.MethodPost, "/sessions/refresh", strings.NewReader(`{"refresh_token": "`+firstRefreshToken+`"}`))
+		w = httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		httpResponse = w.Result()
+		defer httpResponse.Body.Close()
+		responseBody, _ = ioutil.ReadAll(httpResponse.Body)
+		assert.Equal(t, http.StatusUnauthorized, httpResponse.StatusCode)
+		assert.JSONEq(t, `{"error": "`+auth.ErrRefreshTokenReused.Error()+`"}`, string(responseBody))
+
+		// --- the whole family has been revoked ---
+		req = httptest.NewRequest(http.MethodPost, "/sessions/refresh", strings.NewReader(`{"refresh_token": "`+secondRefreshToken+`"}`))
+		w = httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		httpResponse = w.Result()
+		defer httpResponse.Body.Close()
+		responseBody, _ = ioutil.ReadAll(httpResponse.Body)
+		assert.Equal(t, http.StatusUnauthorized, httpResponse.StatusCode)
+		assert.JSONEq(t, `{"error": "`+auth.ErrRefreshTokenInvalid.Error()+`"}`, string(responseBody))
+	})
 }
 
 func TestSessionHandler_deleteSession(it *testing.T) {
@@ -182,7 +757,11 @@ func TestSessionHandler_deleteSession(it *testing.T) {
 				AccountRepository:          &domain.DatabaseAccountRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database},
 				IdentityBindingRepository:  &domain.DatabaseIdentityBindingRepository{Database: ds.Database},
 				InternalIdentityRepository: &domain.DatabaseInternalIdentityRepository{Database: ds.Database},
+				UnitOfWork:                 &domain.DatabaseUnitOfWork{TransactionSupport: &infra.GormTransactionSupport{Database: ds.Database}, IdWorker: util.DefaultIdWorker},
 			},
+			RoleRepository: &domain.DatabaseRoleRepository{Database: ds.Database},
+			GroupManager:   &domain.GroupManagerImpl{GroupRepository: &domain.DatabaseGroupRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database}},
+			TokenService:   &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), RefreshTokenStore: auth.NewMemoryRefreshTokenStore()},
 		}
 
 		engine := gin.Default()
@@ -209,7 +788,11 @@ func TestSessionHandler_deleteSession(it *testing.T) {
 				AccountRepository:          &domain.DatabaseAccountRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database},
 				IdentityBindingRepository:  &domain.DatabaseIdentityBindingRepository{Database: ds.Database},
 				InternalIdentityRepository: &domain.DatabaseInternalIdentityRepository{Database: ds.Database},
+				UnitOfWork:                 &domain.DatabaseUnitOfWork{TransactionSupport: &infra.GormTransactionSupport{Database: ds.Database}, IdWorker: util.DefaultIdWorker},
 			},
+			RoleRepository: &domain.DatabaseRoleRepository{Database: ds.Database},
+			GroupManager:   &domain.GroupManagerImpl{GroupRepository: &domain.DatabaseGroupRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database}},
+			TokenService:   &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), RefreshTokenStore: auth.NewMemoryRefreshTokenStore()},
 		}
 
 		engine := gin.Default()
@@ -218,7 +801,7 @@ func TestSessionHandler_deleteSession(it *testing.T) {
 		// create account
 		accountName := uuid.New().String()
 		accountSecret := uuid.New().String()
-		_, err := sessionHandler.AccountManager.CreateAccount(
+		account, err := sessionHandler.AccountManager.CreateAccount(
 			entity.EmailAccountCreateRequest{Name: accountName, Email: accountName + "@test.fundwit.com", Secret: accountSecret})
 
 		// login account
@@ -236,9 +819,9 @@ func TestSessionHandler_deleteSession(it *testing.T) {
 		assert.Equal(t, http.StatusOK, httpResponse.StatusCode)
 		token := httpResponse.Header.Get("Authentication")
 		assert.NotNil(t, token)
-		sc, found := auth.TokenCache.Get(token)
-		assert.True(t, found)
-		want := &auth.SecurityContext{Token: token, Principal: auth.Principal{Name: accountName}}
+		sc, err := sessionHandler.TokenService.Authenticate(token)
+		assert.Nil(t, err)
+		want := &auth.SecurityContext{Token: token, Principal: auth.Principal{Id: account.Id, Name: accountName}}
 		assert.Equal(t, want, sc)
 
 		// --- logout with bad token ---
@@ -251,9 +834,10 @@ func TestSessionHandler_deleteSession(it *testing.T) {
 
 		// assertion
 		assert.Equal(t, http.StatusNoContent, httpResponse.StatusCode)
-		// token still exist cleaned
-		sc, found = auth.TokenCache.Get(token)
-		assert.True(t, found)
+		// session still exist
+		sc, err = sessionHandler.TokenService.Authenticate(token)
+		assert.Nil(t, err)
+		assert.NotNil(t, sc)
 
 		// --- logout with correct token ---
 		req = httptest.NewRequest(http.MethodDelete, "/sessions", nil)
@@ -265,9 +849,10 @@ func TestSessionHandler_deleteSession(it *testing.T) {
 
 		// assertion
 		assert.Equal(t, http.StatusNoContent, httpResponse.StatusCode)
-		// token has been cleaned
-		sc, found = auth.TokenCache.Get(token)
-		assert.False(t, found)
+		// session has been deleted
+		sc, err = sessionHandler.TokenService.Authenticate(token)
+		assert.Nil(t, err)
+		assert.Nil(t, sc)
 	})
 }
 
@@ -281,7 +866,11 @@ func TestSessionHandler_currentSession(it *testing.T) {
 				AccountRepository:          &domain.DatabaseAccountRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database},
 				IdentityBindingRepository:  &domain.DatabaseIdentityBindingRepository{Database: ds.Database},
 				InternalIdentityRepository: &domain.DatabaseInternalIdentityRepository{Database: ds.Database},
+				UnitOfWork:                 &domain.DatabaseUnitOfWork{TransactionSupport: &infra.GormTransactionSupport{Database: ds.Database}, IdWorker: util.DefaultIdWorker},
 			},
+			RoleRepository: &domain.DatabaseRoleRepository{Database: ds.Database},
+			GroupManager:   &domain.GroupManagerImpl{GroupRepository: &domain.DatabaseGroupRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database}},
+			TokenService:   &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), RefreshTokenStore: auth.NewMemoryRefreshTokenStore()},
 		}
 
 		engine := gin.Default()
@@ -308,7 +897,11 @@ func TestSessionHandler_currentSession(it *testing.T) {
 				AccountRepository:          &domain.DatabaseAccountRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database},
 				IdentityBindingRepository:  &domain.DatabaseIdentityBindingRepository{Database: ds.Database},
 				InternalIdentityRepository: &domain.DatabaseInternalIdentityRepository{Database: ds.Database},
+				UnitOfWork:                 &domain.DatabaseUnitOfWork{TransactionSupport: &infra.GormTransactionSupport{Database: ds.Database}, IdWorker: util.DefaultIdWorker},
 			},
+			RoleRepository: &domain.DatabaseRoleRepository{Database: ds.Database},
+			GroupManager:   &domain.GroupManagerImpl{GroupRepository: &domain.DatabaseGroupRepository{IdWorker: util.DefaultIdWorker, Database: ds.Database}},
+			TokenService:   &auth.TokenService{SessionStore: auth.NewMemorySessionStore(), RefreshTokenStore: auth.NewMemoryRefreshTokenStore()},
 		}
 
 		engine := gin.Default()
@@ -317,7 +910,7 @@ func TestSessionHandler_currentSession(it *testing.T) {
 		// create account
 		accountName := uuid.New().String()
 		accountSecret := uuid.New().String()
-		_, err := sessionHandler.AccountManager.CreateAccount(
+		account, err := sessionHandler.AccountManager.CreateAccount(
 			entity.EmailAccountCreateRequest{Name: accountName, Email: accountName + "@test.fundwit.com", Secret: accountSecret})
 
 		// login account
@@ -335,9 +928,9 @@ func TestSessionHandler_currentSession(it *testing.T) {
 		assert.Equal(t, http.StatusOK, httpResponse.StatusCode)
 		token := httpResponse.Header.Get("Authentication")
 		assert.NotNil(t, token)
-		sc, found := auth.TokenCache.Get(token)
-		assert.True(t, found)
-		want := &auth.SecurityContext{Token: token, Principal: auth.Principal{Name: accountName}}
+		sc, err := sessionHandler.TokenService.Authenticate(token)
+		assert.Nil(t, err)
+		want := &auth.SecurityContext{Token: token, Principal: auth.Principal{Id: account.Id, Name: accountName}}
 		assert.Equal(t, want, sc)
 
 		// --- get session with bad token ---
@@ -362,7 +955,7 @@ func TestSessionHandler_currentSession(it *testing.T) {
 
 		// assertion
 		assert.Equal(t, http.StatusOK, httpResponse.StatusCode)
-		wantedBody, err := json.Marshal(gin.H{"token": token, "principal": gin.H{"name": accountName}})
+		wantedBody, err := json.Marshal(gin.H{"token": token, "principal": gin.H{"name": accountName, "groups": []string{}}})
 		if err != nil {
 			panic(err)
 		}
@@ -0,0 +1,61 @@
+package serveHttp
+
+import (
+	"github.com/gin-gonic/gin"
+	"hallo/service/auth"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type WellKnownHandler struct {
+	JwtIssuer *auth.JwtIssuer
+}
+
+func (handler *WellKnownHandler) RegisterRoutes(r *gin.RouterGroup) {
+	r.GET("/jwks.json", handler.jwks)
+	r.GET("/openid-configuration", handler.openidConfiguration)
+}
+
+func (handler *WellKnownHandler) jwks(c *gin.Context) {
+	if handler.JwtIssuer == nil {
+		c.JSON(http.StatusOK, &auth.JSONWebKeySet{Keys: []auth.JSONWebKey{}})
+		return
+	}
+	c.JSON(http.StatusOK, handler.JwtIssuer.KeySet())
+}
+
+// openidConfiguration is the provider metadata of OpenID Connect Discovery, the endpoints are under the issuer,
+// which must be the external URL of hallo
+func (handler *WellKnownHandler) openidConfiguration(c *gin.Context) {
+	if handler.JwtIssuer == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "openid connect is not enabled"})
+		return
+	}
+	issuer, err := url.Parse(handler.JwtIssuer.Issuer)
+	if err != nil || (issuer.Scheme != "https" && issuer.Scheme != "http") || issuer.Host == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "openid connect is not enabled"})
+		return
+	}
+
+	base := strings.TrimSuffix(handler.JwtIssuer.Issuer, "/")
+	c.JSON(http.StatusOK, gin.H{
+		"issuer":                                handler.JwtIssuer.Issuer,
+		"authorization_endpoint":                base + "/oauth2/authorize",
+		"token_endpoint":                        base + "/oauth2/token",
+		"userinfo_endpoint":                     base + "/oauth2/userinfo",
+		"introspection_endpoint":                base + "/oauth2/introspect",
+		"revocation_endpoint":                   base + "/oauth2/revoke",
+		"device_authorization_endpoint":         base + "/oauth2/device_authorization",
+		"jwks_uri":                              base + "/.well-known/jwks.json",
+		"scopes_supported":                      []string{"openid", "profile", "email"},
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{"authorization_code", "refresh_token", "client_credentials", deviceCodeGrantType},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{handler.JwtIssuer.Algorithm()},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+		"code_challenge_methods_supported":      []string{auth.CodeChallengeMethodS256},
+		"claims_supported": []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce",
+			"name", "preferred_username", "email", "email_verified"},
+	})
+}
@@ -0,0 +1,106 @@
+package serveHttp
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"hallo/service/auth"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWellKnownHandler_jwks(it *testing.T) {
+	it.Run("should response empty key set when jwt is not enabled", func(t *testing.T) {
+		engine := gin.Default()
+		(&WellKnownHandler{}).RegisterRoutes(engine.Group("/.well-known"))
+
+		req := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+
+		httpResponse := w.Result()
+		defer httpResponse.Body.Close()
+		body, _ := ioutil.ReadAll(httpResponse.Body)
+
+		assert.Equal(t, http.StatusOK, httpResponse.StatusCode)
+		assert.JSONEq(t, `{"keys": []}`, string(body))
+	})
+
+	it.Run("should response public keys of jwt issuer", func(t *testing.T) {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			panic(err)
+		}
+		issuer, err := auth.NewJwtIssuer("hallo-test", key)
+		if err != nil {
+			panic(err)
+		}
+
+		engine := gin.Default()
+		(&WellKnownHandler{JwtIssuer: issuer}).RegisterRoutes(engine.Group("/.well-known"))
+
+		req := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+
+		httpResponse := w.Result()
+		defer httpResponse.Body.Close()
+		body, _ := ioutil.ReadAll(httpResponse.Body)
+
+		assert.Equal(t, http.StatusOK, httpResponse.StatusCode)
+		wantedBody, err := json.Marshal(issuer.KeySet())
+		if err != nil {
+			panic(err)
+		}
+		assert.JSONEq(t, string(wantedBody), string(body))
+		assert.Equal(t, "RSA", issuer.KeySet().Keys[0].KeyType)
+		assert.Equal(t, "AQAB", issuer.KeySet().Keys[0].E)
+	})
+}
+
+func TestWellKnownHandler_openidConfiguration(it *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+
+	it.Run("should not be found when issuer is not an url", func(t *testing.T) {
+		issuer, err := auth.NewJwtIssuer("hallo-test", key)
+		if err != nil {
+			panic(err)
+		}
+		for _, handler := range []*WellKnownHandler{{}, {JwtIssuer: issuer}} {
+			engine := gin.Default()
+			handler.RegisterRoutes(engine.Group("/.well-known"))
+
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil))
+			assert.Equal(t, http.StatusNotFound, w.Code)
+		}
+	})
+
+	it.Run("should response endpoints under issuer", func(t *testing.T) {
+		issuer, err := auth.NewJwtIssuer("https://id.test/", key)
+		if err != nil {
+			panic(err)
+		}
+		engine := gin.Default()
+		(&WellKnownHandler{JwtIssuer: issuer}).RegisterRoutes(engine.Group("/.well-known"))
+
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		configuration := map[string]interface{}{}
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &configuration))
+		assert.Equal(t, "https://id.test/", configuration["issuer"])
+		assert.Equal(t, "https://id.test/oauth2/authorize", configuration["authorization_endpoint"])
+		assert.Equal(t, "https://id.test/oauth2/userinfo", configuration["userinfo_endpoint"])
+		assert.Equal(t, "https://id.test/.well-known/jwks.json", configuration["jwks_uri"])
+		assert.Equal(t, []interface{}{"RS256"}, configuration["id_token_signing_alg_values_supported"])
+	})
+}
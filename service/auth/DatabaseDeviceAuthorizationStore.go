@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/jinzhu/gorm"
+	"hallo/domain/entity"
+	"time"
+)
+
+const DeviceAuthorizationTableName = "device_authorizations"
+
+// DatabaseDeviceAuthorizationStore shares the authorizations between instances,
+// the device may poll an instance other than the one on which the account approves
+type DatabaseDeviceAuthorizationStore struct {
+	Database *gorm.DB
+}
+
+func (store *DatabaseDeviceAuthorizationStore) Save(authorization *entity.DeviceAuthorization) error {
+	validate := validator.New()
+	if err := validate.Struct(authorization); err != nil {
+		return err
+	}
+	return store.Database.Save(authorization).Error
+}
+
+func (store *DatabaseDeviceAuthorizationStore) FindByHashedDeviceCode(hashedDeviceCode string) (*entity.DeviceAuthorization, error) {
+	return store.findOne("hashed_device_code = ?", hashedDeviceCode)
+}
+
+func (store *DatabaseDeviceAuthorizationStore) FindByUserCode(userCode string) (*entity.DeviceAuthorization, error) {
+	return store.findOne("user_code = ?", userCode)
+}
+
+func (store *DatabaseDeviceAuthorizationStore) findOne(query string, value string) (*entity.DeviceAuthorization, error) {
+	authorization := &entity.DeviceAuthorization{}
+	err := store.Database.Table(DeviceAuthorizationTableName).Where(query, value).First(authorization).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return authorization, nil
+}
+
+func (store *DatabaseDeviceAuthorizationStore) Decide(userCode, status, principal string, decideTime time.Time) (bool, error) {
+	// conditional update, the authorization is decided only once
+	db := store.Database.Table(DeviceAuthorizationTableName).
+		Where("user_code = ? AND status = ? AND expire_time > ?", userCode, DeviceAuthorizationPending, decideTime).
+		Updates(map[string]interface{}{"status": status, "principal": principal, "decide_time": decideTime})
+	return db.RowsAffected > 0, db.Error
+}
+
+func (store *DatabaseDeviceAuthorizationStore) RecordPoll(hashedDeviceCode string, pollTime time.Time, interval int) error {
+	return store.Database.Table(DeviceAuthorizationTableName).Where("hashed_device_code = ?", hashedDeviceCode).
+		Updates(map[string]interface{}{"last_poll_time": pollTime, "poll_interval": interval}).Error
+}
+
+func (store *DatabaseDeviceAuthorizationStore) Delete(hashedDeviceCode string) (bool, error) {
+	db := store.Database.Where("hashed_device_code = ?", hashedDeviceCode).Delete(&entity.DeviceAuthorization{})
+	return db.RowsAffected > 0, db.Error
+}
+
+func (store *DatabaseDeviceAuthorizationStore) DeleteExpired(now time.Time) (int64, error) {
+	db := store.Database.Where("expire_time <= ?", now).Delete(&entity.DeviceAuthorization{})
+	return db.RowsAffected, db.Error
+}
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"github.com/stretchr/testify/assert"
+	"hallo/testinfra"
+	"testing"
+	"time"
+)
+
+func TestDatabaseDeviceAuthorizationStore(it *testing.T) {
+	it.Run("should share device authorizations between flows and sweep expired ones", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		// the device polls an instance other than the one on which the account approves
+		device := &DeviceFlow{Store: &DatabaseDeviceAuthorizationStore{Database: ds.Database}}
+		verifier := &DeviceFlow{Store: &DatabaseDeviceAuthorizationStore{Database: ds.Database}}
+
+		deviceCode, authorization, err := device.Start("cli", "profile")
+		assert.Nil(t, err)
+		_, _, err = device.Poll(deviceCode, "cli")
+		assert.Equal(t, ErrAuthorizationPending, err)
+		_, _, err = device.Poll(deviceCode, "cli")
+		assert.Equal(t, ErrSlowDown, err)
+
+		principal := Principal{Id: 123, Name: "ann", Scope: "profile"}
+		assert.Nil(t, verifier.Approve(authorization.UserCode, principal))
+		assert.Equal(t, ErrUserCodeInvalid, verifier.Deny(authorization.UserCode))
+
+		_, approved, err := device.Poll(deviceCode, "cli")
+		assert.Nil(t, err)
+		assert.Equal(t, principal, *approved)
+		_, _, err = device.Poll(deviceCode, "cli")
+		assert.Equal(t, ErrDeviceCodeInvalid, err)
+
+		_, _, err = device.Start("cli", "")
+		assert.Nil(t, err)
+		count, err := device.Store.DeleteExpired(time.Now().Add(DeviceCodeExpiration + time.Minute))
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), count)
+	})
+}
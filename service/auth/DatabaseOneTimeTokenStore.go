@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/jinzhu/gorm"
+	"hallo/domain/entity"
+	"time"
+)
+
+const OneTimeTokenTableName = "one_time_tokens"
+
+// DatabaseOneTimeTokenStore shares the tokens between instances, a flow may be continued on an instance other than
+// the one on which it started
+type DatabaseOneTimeTokenStore struct {
+	Database *gorm.DB
+}
+
+func (store *DatabaseOneTimeTokenStore) Save(token *entity.OneTimeToken) error {
+	validate := validator.New()
+	if err := validate.Struct(token); err != nil {
+		return err
+	}
+	return store.Database.Save(token).Error
+}
+
+func (store *DatabaseOneTimeTokenStore) Take(kind, hashedToken string, now time.Time) (*entity.OneTimeToken, error) {
+	token := &entity.OneTimeToken{}
+	err := store.Database.Table(OneTimeTokenTableName).
+		Where("hashed_token = ? AND kind = ? AND expire_time > ?", hashedToken, kind, now).First(token).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// conditional delete, only one of concurrent takes wins
+	db := store.Database.Where("hashed_token = ?", hashedToken).Delete(&entity.OneTimeToken{})
+	if db.Error != nil || db.RowsAffected == 0 {
+		return nil, db.Error
+	}
+	return token, nil
+}
+
+func (store *DatabaseOneTimeTokenStore) DeleteExpired(now time.Time) (int64, error) {
+	db := store.Database.Where("expire_time <= ?", now).Delete(&entity.OneTimeToken{})
+	return db.RowsAffected, db.Error
+}
@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"github.com/stretchr/testify/assert"
+	"hallo/domain/entity"
+	"hallo/testinfra"
+	"testing"
+	"time"
+)
+
+func TestDatabaseOneTimeTokenStore(it *testing.T) {
+	it.Run("should take tokens of kind once and sweep expired ones", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		store := &DatabaseOneTimeTokenStore{Database: ds.Database}
+		now := time.Now()
+		assert.Nil(t, store.Save(&entity.OneTimeToken{HashedToken: "h1", Kind: "code", Payload: `{"a":1}`,
+			ExpireTime: now.Add(time.Minute), CreateTime: now}))
+		assert.Nil(t, store.Save(&entity.OneTimeToken{HashedToken: "h2", Kind: "code", Payload: `{}`,
+			ExpireTime: now.Add(-time.Minute), CreateTime: now}))
+
+		// the token of another kind is not taken
+		token, err := store.Take("state", "h1", now)
+		assert.Nil(t, err)
+		assert.Nil(t, token)
+		token, err = store.Take("code", "h1", now)
+		assert.Nil(t, err)
+		assert.Equal(t, `{"a":1}`, token.Payload)
+		token, err = store.Take("code", "h1", now)
+		assert.Nil(t, err)
+		assert.Nil(t, token)
+
+		token, err = store.Take("code", "h2", now)
+		assert.Nil(t, err)
+		assert.Nil(t, token)
+		count, err := store.DeleteExpired(now)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), count)
+	})
+}
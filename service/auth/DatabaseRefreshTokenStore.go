@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/jinzhu/gorm"
+	"hallo/domain/entity"
+	"time"
+)
+
+const RefreshTokenTableName = "refresh_tokens"
+
+type DatabaseRefreshTokenStore struct {
+	Database *gorm.DB
+}
+
+func (store *DatabaseRefreshTokenStore) Save(refreshToken *entity.RefreshToken) error {
+	validate := validator.New()
+	if err := validate.Struct(refreshToken); err != nil {
+		return err
+	}
+	return store.Database.Save(refreshToken).Error
+}
+
+func (store *DatabaseRefreshTokenStore) FindByHashedToken(hashedToken string) (*entity.RefreshToken, error) {
+	refreshToken := &entity.RefreshToken{}
+	err := store.Database.Table(RefreshTokenTableName).Where("hashed_token = ?", hashedToken).First(refreshToken).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return refreshToken, nil
+}
+
+func (store *DatabaseRefreshTokenStore) MarkRotated(hashedToken string) (bool, error) {
+	// conditional update, only one of concurrent rotations wins
+	db := store.Database.Table(RefreshTokenTableName).Where("hashed_token = ? AND rotated = ?", hashedToken, false).
+		Update("rotated", true)
+	return db.RowsAffected > 0, db.Error
+}
+
+func (store *DatabaseRefreshTokenStore) RevokeFamily(familyId string) error {
+	return store.Database.Table(RefreshTokenTableName).Where("family_id = ?", familyId).Update("revoked", true).Error
+}
+
+func (store *DatabaseRefreshTokenStore) RevokeByAccountId(accountId uint64) error {
+	return store.Database.Table(RefreshTokenTableName).Where("account_id = ?", accountId).Update("revoked", true).Error
+}
+
+func (store *DatabaseRefreshTokenStore) DeleteExpired(now time.Time) (int64, error) {
+	db := store.Database.Where("revoked = ? OR expire_time <= ?", true, now).Delete(&entity.RefreshToken{})
+	return db.RowsAffected, db.Error
+}
@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"github.com/stretchr/testify/assert"
+	"hallo/domain/entity"
+	"hallo/testinfra"
+	"testing"
+	"time"
+)
+
+func TestDatabaseRefreshTokenStore(it *testing.T) {
+	it.Run("should delete expired and revoked tokens but keep rotated ones", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		store := &DatabaseRefreshTokenStore{Database: ds.Database}
+		now := time.Now().Truncate(time.Second)
+		for _, refreshToken := range []*entity.RefreshToken{
+			{HashedToken: "expired", FamilyId: "f1", AccountId: 1, Principal: "{}", ExpireTime: now.Add(-time.Minute)},
+			{HashedToken: "revoked", FamilyId: "f2", AccountId: 1, Principal: "{}", Revoked: true, ExpireTime: now.Add(time.Hour)},
+			{HashedToken: "rotated", FamilyId: "f3", AccountId: 1, Principal: "{}", Rotated: true, ExpireTime: now.Add(time.Hour)},
+		} {
+			refreshToken.FamilyCreateTime = now
+			refreshToken.CreateTime = now
+			assert.Nil(t, store.Save(refreshToken))
+		}
+
+		count, err := store.DeleteExpired(now)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(2), count)
+		found, err := store.FindByHashedToken("expired")
+		assert.Nil(t, err)
+		assert.Nil(t, found)
+		found, err = store.FindByHashedToken("revoked")
+		assert.Nil(t, err)
+		assert.Nil(t, found)
+		found, err = store.FindByHashedToken("rotated")
+		assert.Nil(t, err)
+		assert.NotNil(t, found)
+	})
+}
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"encoding/json"
+	"github.com/go-playground/validator/v10"
+	"github.com/jinzhu/gorm"
+	"hallo/domain/entity"
+	"time"
+)
+
+const SessionTableName = "sessions"
+
+// DatabaseSessionStore persists sessions, so that they survive restarts and are shared between instances
+type DatabaseSessionStore struct {
+	Database *gorm.DB
+}
+
+func (store *DatabaseSessionStore) Save(securityContext *SecurityContext) error {
+	principal, err := json.Marshal(securityContext.Principal)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	session := entity.Session{
+		Token:      securityContext.Token,
+		AccountId:  securityContext.Principal.Id,
+		Principal:  string(principal),
+		ExpireTime: now.Add(SessionExpiration),
+		CreateTime: now,
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(session); err != nil {
+		return err
+	}
+
+	return store.Database.Save(session).Error
+}
+
+func (store *DatabaseSessionStore) Load(token string) (*SecurityContext, error) {
+	securityContext, _, err := store.LoadWithExpireTime(token)
+	return securityContext, err
+}
+
+func (store *DatabaseSessionStore) LoadWithExpireTime(token string) (*SecurityContext, time.Time, error) {
+	session := entity.Session{}
+	err := store.Database.Table(SessionTableName).Where("token = ? AND expire_time > ?", token, time.Now()).First(&session).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, time.Time{}, nil
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	securityContext := &SecurityContext{Token: session.Token}
+	if err := json.Unmarshal([]byte(session.Principal), &securityContext.Principal); err != nil {
+		return nil, time.Time{}, err
+	}
+	return securityContext, session.ExpireTime, nil
+}
+
+func (store *DatabaseSessionStore) Delete(token string) error {
+	return store.Database.Where(entity.Session{Token: token}).Delete(&entity.Session{}).Error
+}
+
+func (store *DatabaseSessionStore) DeleteByAccountId(accountId uint64, exceptToken string) error {
+	return store.Database.Where("account_id = ? AND token <> ?", accountId, exceptToken).Delete(&entity.Session{}).Error
+}
+
+func (store *DatabaseSessionStore) DeleteExpired(now time.Time) (int64, error) {
+	db := store.Database.Where("expire_time <= ?", now).Delete(&entity.Session{})
+	return db.RowsAffected, db.Error
+}
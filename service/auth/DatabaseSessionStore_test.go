@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"hallo/domain/entity"
+	"hallo/testinfra"
+	"testing"
+	"time"
+)
+
+func TestDatabaseSessionStore(it *testing.T) {
+	it.Run("should save, load and delete session correctly", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		store := &DatabaseSessionStore{Database: ds.Database}
+		token := uuid.New().String()
+
+		sc, err := store.Load(token)
+		assert.Nil(t, err)
+		assert.Nil(t, sc)
+
+		want := &SecurityContext{Token: token, Principal: Principal{Id: 123, Name: "test"}}
+		assert.Nil(t, store.Save(want))
+
+		// a new store shares the sessions, just like another instance of service
+		sc, err = (&DatabaseSessionStore{Database: ds.Database}).Load(token)
+		assert.Nil(t, err)
+		assert.Equal(t, want, sc)
+		sc, expireTime, err := store.LoadWithExpireTime(token)
+		assert.Nil(t, err)
+		assert.Equal(t, want, sc)
+		assert.WithinDuration(t, time.Now().Add(SessionExpiration), expireTime, time.Minute)
+
+		assert.Nil(t, store.Delete(token))
+		sc, err = store.Load(token)
+		assert.Nil(t, err)
+		assert.Nil(t, sc)
+	})
+
+	it.Run("should not load expired session", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		store := &DatabaseSessionStore{Database: ds.Database}
+		token := uuid.New().String()
+		ds.Database.Save(entity.Session{Token: token, AccountId: 123, Principal: `{"id":123,"name":"test"}`,
+			ExpireTime: time.Now().Add(-time.Minute), CreateTime: time.Now().Add(-SessionExpiration)})
+
+		sc, err := store.Load(token)
+		assert.Nil(t, err)
+		assert.Nil(t, sc)
+	})
+
+	it.Run("should delete expired sessions only", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		store := &DatabaseSessionStore{Database: ds.Database}
+		expired := uuid.New().String()
+		ds.Database.Save(entity.Session{Token: expired, AccountId: 123, Principal: `{"id":123,"name":"test"}`,
+			ExpireTime: time.Now().Add(-time.Minute), CreateTime: time.Now().Add(-SessionExpiration)})
+		active := &SecurityContext{Token: uuid.New().String(), Principal: Principal{Id: 123, Name: "test"}}
+		assert.Nil(t, store.Save(active))
+
+		count, err := store.DeleteExpired(time.Now())
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), count)
+		assert.True(t, ds.Database.Where(entity.Session{Token: expired}).First(&entity.Session{}).RecordNotFound())
+		sc, err := store.Load(active.Token)
+		assert.Nil(t, err)
+		assert.Equal(t, active, sc)
+	})
+
+	it.Run("should delete all sessions of account", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		store := &DatabaseSessionStore{Database: ds.Database}
+		mine := &SecurityContext{Token: uuid.New().String(), Principal: Principal{Id: 123, Name: "test"}}
+		other := &SecurityContext{Token: uuid.New().String(), Principal: Principal{Id: 456, Name: "other"}}
+		assert.Nil(t, store.Save(mine))
+		assert.Nil(t, store.Save(other))
+
+		assert.Nil(t, store.DeleteByAccountId(123, ""))
+
+		sc, err := store.Load(mine.Token)
+		assert.Nil(t, err)
+		assert.Nil(t, sc)
+		sc, err = store.Load(other.Token)
+		assert.Nil(t, err)
+		assert.Equal(t, other, sc)
+	})
+
+	it.Run("should save failed when validate not pass", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		store := &DatabaseSessionStore{Database: ds.Database}
+		err := store.Save(&SecurityContext{Token: uuid.New().String(), Principal: Principal{Name: "test"}})
+		assert.Equal(t, "Key: 'Session.AccountId' Error:Field validation for 'AccountId' failed on the 'required' tag", fmt.Sprintf("%s", err))
+	})
+}
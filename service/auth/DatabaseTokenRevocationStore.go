@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/jinzhu/gorm"
+	"hallo/domain/entity"
+	"time"
+)
+
+const (
+	RevokedTokenTableName           = "revoked_tokens"
+	AccountTokenRevocationTableName = "account_token_revocations"
+)
+
+// DatabaseTokenRevocationStore shares the revocations between instances, the JWT revoked on one instance is denied by all
+type DatabaseTokenRevocationStore struct {
+	Database *gorm.DB
+}
+
+func (store *DatabaseTokenRevocationStore) RevokeToken(revokedToken *entity.RevokedToken) error {
+	validate := validator.New()
+	if err := validate.Struct(revokedToken); err != nil {
+		return err
+	}
+	return store.Database.Save(revokedToken).Error
+}
+
+func (store *DatabaseTokenRevocationStore) RevokeByAccountId(revocation *entity.AccountTokenRevocation) error {
+	validate := validator.New()
+	if err := validate.Struct(revocation); err != nil {
+		return err
+	}
+	return store.Database.Save(revocation).Error
+}
+
+func (store *DatabaseTokenRevocationStore) IsRevoked(tokenId string, accountId uint64, issueTime time.Time) (bool, error) {
+	var count int
+	if err := store.Database.Table(RevokedTokenTableName).Where("token_id = ?", tokenId).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+
+	revocation := &entity.AccountTokenRevocation{}
+	err := store.Database.Table(AccountTokenRevocationTableName).Where("account_id = ?", accountId).First(revocation).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return isRevokedByAccount(revocation, tokenId, issueTime), nil
+}
+
+func (store *DatabaseTokenRevocationStore) DeleteExpired(now time.Time) (int64, error) {
+	db := store.Database.Where("expire_time <= ?", now).Delete(&entity.RevokedToken{})
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	count := db.RowsAffected
+	db = store.Database.Where("expire_time <= ?", now).Delete(&entity.AccountTokenRevocation{})
+	return count + db.RowsAffected, db.Error
+}
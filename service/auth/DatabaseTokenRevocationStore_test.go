@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"github.com/stretchr/testify/assert"
+	"hallo/domain/entity"
+	"hallo/testinfra"
+	"testing"
+	"time"
+)
+
+func TestDatabaseTokenRevocationStore(it *testing.T) {
+	it.Run("should deny token by its id and tokens of account issued before revocation", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		store := &DatabaseTokenRevocationStore{Database: ds.Database}
+		now := time.Now().Truncate(time.Second)
+
+		assert.Nil(t, store.RevokeToken(&entity.RevokedToken{TokenId: "t1", ExpireTime: now.Add(time.Hour)}))
+		revoked, err := store.IsRevoked("t1", 123, now)
+		assert.Nil(t, err)
+		assert.True(t, revoked)
+		revoked, err = store.IsRevoked("t2", 123, now)
+		assert.Nil(t, err)
+		assert.False(t, revoked)
+
+		assert.Nil(t, store.RevokeByAccountId(&entity.AccountTokenRevocation{AccountId: 123, ExceptTokenId: "t3",
+			RevokeTime: now, ExpireTime: now.Add(time.Hour)}))
+		revoked, err = store.IsRevoked("t2", 123, now.Add(-time.Minute))
+		assert.Nil(t, err)
+		assert.True(t, revoked)
+		revoked, err = store.IsRevoked("t3", 123, now.Add(-time.Minute))
+		assert.Nil(t, err)
+		assert.False(t, revoked)
+		revoked, err = store.IsRevoked("t4", 123, now)
+		assert.Nil(t, err)
+		assert.False(t, revoked)
+		revoked, err = store.IsRevoked("t2", 456, now.Add(-time.Minute))
+		assert.Nil(t, err)
+		assert.False(t, revoked)
+	})
+
+	it.Run("should delete expired revocations", func(t *testing.T) {
+		ds := testinfra.NewTemporaryDatabase()
+		defer ds.CleanAndDisconnect()
+
+		store := &DatabaseTokenRevocationStore{Database: ds.Database}
+		now := time.Now().Truncate(time.Second)
+		assert.Nil(t, store.RevokeToken(&entity.RevokedToken{TokenId: "t1", ExpireTime: now.Add(-time.Minute)}))
+		assert.Nil(t, store.RevokeToken(&entity.RevokedToken{TokenId: "t2", ExpireTime: now.Add(time.Hour)}))
+		assert.Nil(t, store.RevokeByAccountId(&entity.AccountTokenRevocation{AccountId: 123, RevokeTime: now.Add(-time.Hour),
+			ExpireTime: now.Add(-time.Minute)}))
+
+		count, err := store.DeleteExpired(now)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(2), count)
+		revoked, err := store.IsRevoked("t2", 123, now)
+		assert.Nil(t, err)
+		assert.True(t, revoked)
+	})
+}
@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"hallo/domain/entity"
+	"sync"
+	"time"
+)
+
+const (
+	DeviceAuthorizationPending  = "pending"
+	DeviceAuthorizationApproved = "approved"
+	DeviceAuthorizationDenied   = "denied"
+)
+
+// DeviceAuthorizationStore keeps device authorizations by the hash of device code,
+// the expired ones are kept until DeleteExpired, so that the device is told about expiration
+type DeviceAuthorizationStore interface {
+	Save(authorization *entity.DeviceAuthorization) error
+	// return (nil, nil) when device code is not found
+	FindByHashedDeviceCode(hashedDeviceCode string) (*entity.DeviceAuthorization, error)
+	// return (nil, nil) when user code is not found
+	FindByUserCode(userCode string) (*entity.DeviceAuthorization, error)
+	// Decide changes the status of the pending authorization which has not expired, return false when there is not one
+	Decide(userCode, status, principal string, decideTime time.Time) (bool, error)
+	RecordPoll(hashedDeviceCode string, pollTime time.Time, interval int) error
+	// return false when the authorization has already been deleted
+	Delete(hashedDeviceCode string) (bool, error)
+	// return the number of expired authorizations deleted
+	DeleteExpired(now time.Time) (int64, error)
+}
+
+type MemoryDeviceAuthorizationStore struct {
+	lock           sync.Mutex
+	authorizations map[string]entity.DeviceAuthorization
+}
+
+func NewMemoryDeviceAuthorizationStore() *MemoryDeviceAuthorizationStore {
+	return &MemoryDeviceAuthorizationStore{authorizations: map[string]entity.DeviceAuthorization{}}
+}
+
+func (store *MemoryDeviceAuthorizationStore) Save(authorization *entity.DeviceAuthorization) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	store.authorizations[authorization.HashedDeviceCode] = *authorization
+	return nil
+}
+
+func (store *MemoryDeviceAuthorizationStore) FindByHashedDeviceCode(hashedDeviceCode string) (*entity.DeviceAuthorization, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if authorization, found := store.authorizations[hashedDeviceCode]; found {
+		return &authorization, nil
+	}
+	return nil, nil
+}
+
+func (store *MemoryDeviceAuthorizationStore) FindByUserCode(userCode string) (*entity.DeviceAuthorization, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	for _, authorization := range store.authorizations {
+		if authorization.UserCode == userCode {
+			return &authorization, nil
+		}
+	}
+	return nil, nil
+}
+
+func (store *MemoryDeviceAuthorizationStore) Decide(userCode, status, principal string, decideTime time.Time) (bool, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	for hashedDeviceCode, authorization := range store.authorizations {
+		if authorization.UserCode != userCode {
+			continue
+		}
+		if authorization.Status != DeviceAuthorizationPending || !authorization.ExpireTime.After(decideTime) {
+			return false, nil
+		}
+		authorization.Status = status
+		authorization.Principal = principal
+		authorization.DecideTime = &decideTime
+		store.authorizations[hashedDeviceCode] = authorization
+		return true, nil
+	}
+	return false, nil
+}
+
+func (store *MemoryDeviceAuthorizationStore) RecordPoll(hashedDeviceCode string, pollTime time.Time, interval int) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if authorization, found := store.authorizations[hashedDeviceCode]; found {
+		authorization.LastPollTime = &pollTime
+		authorization.PollInterval = interval
+		store.authorizations[hashedDeviceCode] = authorization
+	}
+	return nil
+}
+
+func (store *MemoryDeviceAuthorizationStore) Delete(hashedDeviceCode string) (bool, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if _, found := store.authorizations[hashedDeviceCode]; !found {
+		return false, nil
+	}
+	delete(store.authorizations, hashedDeviceCode)
+	return true, nil
+}
+
+func (store *MemoryDeviceAuthorizationStore) DeleteExpired(now time.Time) (int64, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	var count int64
+	for hashedDeviceCode, authorization := range store.authorizations {
+		if !authorization.ExpireTime.After(now) {
+			delete(store.authorizations, hashedDeviceCode)
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,207 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"hallo/domain/entity"
+	"hallo/util"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+)
+
+const (
+	DeviceCodeExpiration = 10 * time.Minute
+	// DevicePollInterval is the least seconds between polls, it is increased by 5 seconds on each slow_down
+	DevicePollInterval = 5
+
+	// userCodeCharset has no vowels, so that no words are spelled, as RFC 8628 section 6.1 suggests
+	userCodeCharset = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength  = 8
+)
+
+// DeviceFlow is the device authorization grant (RFC 8628). The device polls by the device code,
+// while the account enters the user code on the verification page and approves the device.
+// The approved principal is taken only once by the device.
+type DeviceFlow struct {
+	Store DeviceAuthorizationStore
+}
+
+// Start return the plain device code with the authorization, only the hash of device code is kept
+func (flow *DeviceFlow) Start(clientId, scope string) (string, *entity.DeviceAuthorization, error) {
+	deviceCode, err := util.RandomToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+	userCode, err := flow.newUserCode()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	authorization := &entity.DeviceAuthorization{
+		HashedDeviceCode: util.HashSha256Hex([]byte(deviceCode)),
+		UserCode:         userCode,
+		ClientId:         clientId,
+		Scope:            scope,
+		Status:           DeviceAuthorizationPending,
+		PollInterval:     DevicePollInterval,
+		ExpireTime:       now.Add(DeviceCodeExpiration),
+		CreateTime:       now,
+	}
+	if err := flow.Store.Save(authorization); err != nil {
+		return "", nil, err
+	}
+	return deviceCode, authorization, nil
+}
+
+// newUserCode retries a few times, the user codes of unexpired authorizations must be unique
+func (flow *DeviceFlow) newUserCode() (string, error) {
+	for i := 0; i < 3; i++ {
+		code := make([]byte, userCodeLength)
+		for j := range code {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(userCodeCharset))))
+			if err != nil {
+				return "", err
+			}
+			code[j] = userCodeCharset[n.Int64()]
+		}
+		existing, err := flow.Store.FindByUserCode(string(code))
+		if err != nil {
+			return "", err
+		}
+		if existing == nil {
+			return string(code), nil
+		}
+	}
+	return "", ErrUserCodeConflict
+}
+
+// FindPending return ErrUserCodeInvalid when the user code is unknown, expired or decided
+func (flow *DeviceFlow) FindPending(userCode string) (*entity.DeviceAuthorization, error) {
+	authorization, err := flow.Store.FindByUserCode(NormalizeUserCode(userCode))
+	if err != nil {
+		return nil, err
+	}
+	if authorization == nil || authorization.Status != DeviceAuthorizationPending || !authorization.ExpireTime.After(time.Now()) {
+		return nil, ErrUserCodeInvalid
+	}
+	return authorization, nil
+}
+
+func (flow *DeviceFlow) Approve(userCode string, principal Principal) error {
+	principalJson, err := json.Marshal(principal)
+	if err != nil {
+		return err
+	}
+	return flow.decide(userCode, DeviceAuthorizationApproved, string(principalJson))
+}
+
+func (flow *DeviceFlow) Deny(userCode string) error {
+	return flow.decide(userCode, DeviceAuthorizationDenied, "")
+}
+
+func (flow *DeviceFlow) decide(userCode, status, principal string) error {
+	decided, err := flow.Store.Decide(NormalizeUserCode(userCode), status, principal, time.Now())
+	if err != nil {
+		return err
+	}
+	if !decided {
+		return ErrUserCodeInvalid
+	}
+	return nil
+}
+
+// Poll return the approved principal, or ErrAuthorizationPending, ErrSlowDown, ErrAccessDenied, ErrDeviceCodeExpired
+// and ErrDeviceCodeInvalid, the device code issued to another client is invalid
+func (flow *DeviceFlow) Poll(deviceCode, clientId string) (*entity.DeviceAuthorization, *Principal, error) {
+	hashedDeviceCode := util.HashSha256Hex([]byte(deviceCode))
+	authorization, err := flow.Store.FindByHashedDeviceCode(hashedDeviceCode)
+	if err != nil {
+		return nil, nil, err
+	}
+	if authorization == nil || authorization.ClientId != clientId {
+		return nil, nil, ErrDeviceCodeInvalid
+	}
+	now := time.Now()
+	if !authorization.ExpireTime.After(now) {
+		return nil, nil, ErrDeviceCodeExpired
+	}
+
+	switch authorization.Status {
+	case DeviceAuthorizationApproved:
+		taken, err := flow.Store.Delete(hashedDeviceCode)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !taken {
+			return nil, nil, ErrDeviceCodeInvalid
+		}
+		principal := &Principal{}
+		if err := json.Unmarshal([]byte(authorization.Principal), principal); err != nil {
+			return nil, nil, err
+		}
+		return authorization, principal, nil
+	case DeviceAuthorizationDenied:
+		if _, err := flow.Store.Delete(hashedDeviceCode); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, ErrAccessDenied
+	}
+
+	interval := authorization.PollInterval
+	tooFast := authorization.LastPollTime != nil && now.Sub(*authorization.LastPollTime) < time.Duration(interval)*time.Second
+	if tooFast {
+		interval += DevicePollInterval
+	}
+	if err := flow.Store.RecordPoll(hashedDeviceCode, now, interval); err != nil {
+		return nil, nil, err
+	}
+	if tooFast {
+		return nil, nil, ErrSlowDown
+	}
+	return nil, nil, ErrAuthorizationPending
+}
+
+// StartSweeper deletes the expired authorizations periodically in background, until stop is called
+func (flow *DeviceFlow) StartSweeper(period time.Duration) (stop func()) {
+	ticker := time.NewTicker(period)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				if count, err := flow.Store.DeleteExpired(now); err != nil {
+					log.Printf("failed to sweep expired device authorizations: %v\n", err)
+				} else if count > 0 {
+					log.Printf("%d expired device authorizations are swept\n", count)
+				}
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
+
+// NormalizeUserCode accepts user code in lower case and with separators
+func NormalizeUserCode(userCode string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(userCode))
+}
+
+// FormatUserCode splits user code into halves for reading, such as "BCDF-GHJK"
+func FormatUserCode(userCode string) string {
+	if len(userCode) != userCodeLength {
+		return userCode
+	}
+	return userCode[:userCodeLength/2] + "-" + userCode[userCodeLength/2:]
+}
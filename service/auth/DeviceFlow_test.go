@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"github.com/stretchr/testify/assert"
+	"hallo/domain/entity"
+	"hallo/util"
+	"testing"
+	"time"
+)
+
+func TestDeviceFlow(it *testing.T) {
+	it.Run("should poll pending, slow down and take approved principal only once", func(t *testing.T) {
+		store := NewMemoryDeviceAuthorizationStore()
+		flow := &DeviceFlow{Store: store}
+
+		deviceCode, authorization, err := flow.Start("cli", "profile")
+		assert.Nil(t, err)
+		assert.Len(t, authorization.UserCode, 8)
+		assert.Equal(t, DevicePollInterval, authorization.PollInterval)
+		assert.NotEqual(t, deviceCode, authorization.HashedDeviceCode)
+
+		_, _, err = flow.Poll(deviceCode, "cli")
+		assert.Equal(t, ErrAuthorizationPending, err)
+		_, _, err = flow.Poll(deviceCode, "cli")
+		assert.Equal(t, ErrSlowDown, err)
+		polled, _ := store.FindByHashedDeviceCode(authorization.HashedDeviceCode)
+		assert.Equal(t, DevicePollInterval*2, polled.PollInterval)
+
+		_, _, err = flow.Poll(deviceCode, "other")
+		assert.Equal(t, ErrDeviceCodeInvalid, err)
+
+		userCode := FormatUserCode(authorization.UserCode)
+		found, err := flow.FindPending(userCode)
+		assert.Nil(t, err)
+		assert.Equal(t, "cli", found.ClientId)
+
+		principal := Principal{Id: 123, Name: "ann", Scope: "profile"}
+		assert.Nil(t, flow.Approve(userCode, principal))
+		assert.Equal(t, ErrUserCodeInvalid, flow.Approve(userCode, principal))
+		_, err = flow.FindPending(userCode)
+		assert.Equal(t, ErrUserCodeInvalid, err)
+
+		approved, approvedPrincipal, err := flow.Poll(deviceCode, "cli")
+		assert.Nil(t, err)
+		assert.Equal(t, principal, *approvedPrincipal)
+		assert.Equal(t, "profile", approved.Scope)
+		assert.NotNil(t, approved.DecideTime)
+		_, _, err = flow.Poll(deviceCode, "cli")
+		assert.Equal(t, ErrDeviceCodeInvalid, err)
+	})
+
+	it.Run("should tell device about denial and expiration", func(t *testing.T) {
+		store := NewMemoryDeviceAuthorizationStore()
+		flow := &DeviceFlow{Store: store}
+
+		deviceCode, authorization, err := flow.Start("cli", "")
+		assert.Nil(t, err)
+		assert.Nil(t, flow.Deny(authorization.UserCode))
+		_, _, err = flow.Poll(deviceCode, "cli")
+		assert.Equal(t, ErrAccessDenied, err)
+		_, _, err = flow.Poll(deviceCode, "cli")
+		assert.Equal(t, ErrDeviceCodeInvalid, err)
+
+		expiredCode := "expired-device-code"
+		past := time.Now().Add(-time.Minute)
+		assert.Nil(t, store.Save(&entity.DeviceAuthorization{HashedDeviceCode: util.HashSha256Hex([]byte(expiredCode)),
+			UserCode: "BCDFGHJK", ClientId: "cli", Status: DeviceAuthorizationPending, PollInterval: DevicePollInterval,
+			ExpireTime: past, CreateTime: past.Add(-DeviceCodeExpiration)}))
+		_, _, err = flow.Poll(expiredCode, "cli")
+		assert.Equal(t, ErrDeviceCodeExpired, err)
+		assert.Equal(t, ErrUserCodeInvalid, flow.Approve("bcdf-ghjk", Principal{Id: 123}))
+
+		count, err := store.DeleteExpired(time.Now())
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), count)
+		_, _, err = flow.Poll(expiredCode, "cli")
+		assert.Equal(t, ErrDeviceCodeInvalid, err)
+	})
+
+	it.Run("should sweep expired authorizations in background", func(t *testing.T) {
+		store := NewMemoryDeviceAuthorizationStore()
+		flow := &DeviceFlow{Store: store}
+		past := time.Now().Add(-time.Minute)
+		assert.Nil(t, store.Save(&entity.DeviceAuthorization{HashedDeviceCode: "hashed", UserCode: "BCDFGHJK", ClientId: "cli",
+			Status: DeviceAuthorizationPending, PollInterval: DevicePollInterval, ExpireTime: past, CreateTime: past}))
+
+		stop := flow.StartSweeper(10 * time.Millisecond)
+		defer stop()
+		assert.Eventually(t, func() bool {
+			found, _ := store.FindByHashedDeviceCode("hashed")
+			return found == nil
+		}, time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestNormalizeUserCode(it *testing.T) {
+	it.Run("should ignore case, separators and spaces of user code", func(t *testing.T) {
+		assert.Equal(t, "BCDFGHJK", NormalizeUserCode("bcdf-ghjk"))
+		assert.Equal(t, "BCDFGHJK", NormalizeUserCode(" BCDF GHJK "))
+		assert.Equal(t, "BCDF-GHJK", FormatUserCode("BCDFGHJK"))
+	})
+}
@@ -0,0 +1,19 @@
+package auth
+
+import "errors"
+
+var (
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid")
+	ErrRefreshTokenReused  = errors.New("refresh token has been reused")
+	ErrTokenNotRevocable   = errors.New("jwt can't be revoked without revocation store")
+	ErrPrincipalNotFound   = errors.New("account of principal is not found")
+	ErrTokenNotOfClient    = errors.New("token is not issued to the client")
+
+	ErrAuthorizationPending = errors.New("authorization is pending")
+	ErrSlowDown             = errors.New("device polls too frequently")
+	ErrAccessDenied         = errors.New("authorization is denied")
+	ErrDeviceCodeExpired    = errors.New("device code has expired")
+	ErrDeviceCodeInvalid    = errors.New("device code is invalid")
+	ErrUserCodeInvalid      = errors.New("user code is invalid or expired")
+	ErrUserCodeConflict     = errors.New("failed to generate unique user code")
+)
@@ -0,0 +1,299 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	uuid "github.com/satori/go.uuid"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultJwtIssuerName = "hallo"
+
+// AccessTokenType is the "typ" header of access tokens (RFC 9068), which tells them from the ID tokens signed by
+// the same key. The ID tokens are typed "JWT"
+const AccessTokenType = "at+jwt"
+
+type AccessTokenClaims struct {
+	Name           string   `json:"name"`
+	OrganizationId uint64   `json:"org,omitempty"`
+	ClientId       string   `json:"client_id,omitempty"`
+	Scope          string   `json:"scope,omitempty"`
+	ServiceAccount bool     `json:"sa,omitempty"`
+	Roles          []string `json:"roles,omitempty"`
+	Permissions    []string `json:"permissions,omitempty"`
+	Groups         []string `json:"groups,omitempty"`
+
+	OrganizationRoles       []string `json:"org_roles,omitempty"`
+	OrganizationPermissions []string `json:"org_permissions,omitempty"`
+	jwt.StandardClaims
+}
+
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyId     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	Y     string `json:"y,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+type verificationKey struct {
+	method    jwt.SigningMethod
+	publicKey crypto.PublicKey
+	jwk       JSONWebKey
+}
+
+// JwtIssuer signs access tokens which downstream services are able to verify locally with the published key set.
+// Only the signing key is used to sign, the other keys (e.g. retired signing keys) are still accepted and published.
+type JwtIssuer struct {
+	Issuer     string
+	Expiration time.Duration
+
+	signingKey       crypto.Signer
+	signingKeyId     string
+	verificationKeys map[string]*verificationKey
+}
+
+// LoadJwtIssuer loads keys from the files configured by environment variables, return (nil, nil) when not configured:
+// JWT_SIGNING_KEY_FILE: PEM encoded RSA (RS256) or P-256 EC (ES256) private key
+// JWT_VERIFICATION_KEY_FILES: comma separated PEM encoded public keys, optional
+// JWT_ISSUER: value of the "iss" claim, optional. It should be the external URL of hallo to enable OpenID Connect
+func LoadJwtIssuer() (*JwtIssuer, error) {
+	signingKeyFile := os.Getenv("JWT_SIGNING_KEY_FILE")
+	if signingKeyFile == "" {
+		return nil, nil
+	}
+	signingKeyPem, err := ioutil.ReadFile(signingKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	signingKey, err := ParsePrivateKeyPem(signingKeyPem)
+	if err != nil {
+		return nil, fmt.Errorf("bad signing key file %s: %w", signingKeyFile, err)
+	}
+
+	var verificationKeys []crypto.PublicKey
+	for _, file := range strings.Split(os.Getenv("JWT_VERIFICATION_KEY_FILES"), ",") {
+		if strings.TrimSpace(file) == "" {
+			continue
+		}
+		keyPem, err := ioutil.ReadFile(strings.TrimSpace(file))
+		if err != nil {
+			return nil, err
+		}
+		key, err := parsePublicKeyPem(keyPem)
+		if err != nil {
+			return nil, fmt.Errorf("bad verification key file %s: %w", file, err)
+		}
+		verificationKeys = append(verificationKeys, key)
+	}
+
+	issuer := os.Getenv("JWT_ISSUER")
+	if issuer == "" {
+		issuer = DefaultJwtIssuerName
+	}
+	return NewJwtIssuer(issuer, signingKey, verificationKeys...)
+}
+
+func NewJwtIssuer(issuer string, signingKey crypto.Signer, verificationKeys ...crypto.PublicKey) (*JwtIssuer, error) {
+	jwtIssuer := &JwtIssuer{
+		Issuer:           issuer,
+		Expiration:       SessionExpiration,
+		signingKey:       signingKey,
+		verificationKeys: map[string]*verificationKey{},
+	}
+
+	signingKeyId, err := jwtIssuer.addVerificationKey(signingKey.Public())
+	if err != nil {
+		return nil, err
+	}
+	jwtIssuer.signingKeyId = signingKeyId
+
+	for _, key := range verificationKeys {
+		if _, err := jwtIssuer.addVerificationKey(key); err != nil {
+			return nil, err
+		}
+	}
+	return jwtIssuer, nil
+}
+
+func (issuer *JwtIssuer) Sign(principal Principal) (string, error) {
+	now := time.Now()
+	claims := AccessTokenClaims{
+		Name:           principal.Name,
+		OrganizationId: principal.OrganizationId,
+		ClientId:       principal.ClientId,
+		Scope:          principal.Scope,
+		ServiceAccount: principal.ServiceAccount,
+		Roles:          principal.Roles,
+		Permissions:    principal.Permissions,
+		Groups:         principal.Groups,
+
+		OrganizationRoles:       principal.OrganizationRoles,
+		OrganizationPermissions: principal.OrganizationPermissions,
+		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.NewV4().String(),
+			Issuer:    issuer.Issuer,
+			Subject:   strconv.FormatUint(principal.Id, 10),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(issuer.Expiration).Unix(),
+		},
+	}
+
+	return issuer.sign(claims, AccessTokenType)
+}
+
+// SignIdToken signs the ID token of OpenID Connect for the client of audience, claims of the subject are required.
+// iss, aud, iat and exp are set by issuer
+func (issuer *JwtIssuer) SignIdToken(audience string, claims jwt.MapClaims) (string, error) {
+	now := time.Now()
+	idTokenClaims := jwt.MapClaims{}
+	for name, value := range claims {
+		idTokenClaims[name] = value
+	}
+	idTokenClaims["iss"] = issuer.Issuer
+	idTokenClaims["aud"] = audience
+	idTokenClaims["iat"] = now.Unix()
+	idTokenClaims["exp"] = now.Add(issuer.Expiration).Unix()
+	return issuer.sign(idTokenClaims, "JWT")
+}
+
+// Algorithm is the JWS algorithm of the signing key, "RS256" or "ES256"
+func (issuer *JwtIssuer) Algorithm() string {
+	return issuer.verificationKeys[issuer.signingKeyId].method.Alg()
+}
+
+func (issuer *JwtIssuer) sign(claims jwt.Claims, tokenType string) (string, error) {
+	key := issuer.verificationKeys[issuer.signingKeyId]
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["typ"] = tokenType
+	token.Header["kid"] = issuer.signingKeyId
+	return token.SignedString(issuer.signingKey)
+}
+
+// Verify accepts access tokens only, the other tokens signed by issuer, e.g. ID tokens, are rejected by their "typ"
+func (issuer *JwtIssuer) Verify(tokenString string) (*AccessTokenClaims, error) {
+	claims := &AccessTokenClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if tokenType, _ := token.Header["typ"].(string); tokenType != AccessTokenType {
+			return nil, errors.New("not an access token")
+		}
+		keyId, _ := token.Header["kid"].(string)
+		key, found := issuer.verificationKeys[keyId]
+		if !found {
+			return nil, errors.New("unknown key id")
+		}
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, errors.New("unexpected signing method")
+		}
+		return key.publicKey, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !claims.VerifyIssuer(issuer.Issuer, true) {
+		return nil, errors.New("unexpected issuer")
+	}
+	return claims, nil
+}
+
+func (issuer *JwtIssuer) KeySet() *JSONWebKeySet {
+	keySet := &JSONWebKeySet{Keys: []JSONWebKey{issuer.verificationKeys[issuer.signingKeyId].jwk}}
+	for keyId, key := range issuer.verificationKeys {
+		if keyId != issuer.signingKeyId {
+			keySet.Keys = append(keySet.Keys, key.jwk)
+		}
+	}
+	return keySet
+}
+
+func (issuer *JwtIssuer) addVerificationKey(publicKey crypto.PublicKey) (string, error) {
+	key := &verificationKey{publicKey: publicKey}
+	switch k := publicKey.(type) {
+	case *rsa.PublicKey:
+		key.method = jwt.SigningMethodRS256
+		key.jwk = JSONWebKey{KeyType: "RSA", Use: "sig", Algorithm: key.method.Alg(),
+			N: encodeBase64Url(k.N.Bytes()), E: encodeBase64Url(big.NewInt(int64(k.E)).Bytes())}
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return "", errors.New("only P-256 curve is supported")
+		}
+		key.method = jwt.SigningMethodES256
+		key.jwk = JSONWebKey{KeyType: "EC", Use: "sig", Algorithm: key.method.Alg(), Curve: "P-256",
+			X: encodeBase64Url(padBytes(k.X.Bytes(), 32)), Y: encodeBase64Url(padBytes(k.Y.Bytes(), 32))}
+	default:
+		return "", errors.New("unsupported key type")
+	}
+
+	key.jwk.KeyId = thumbprint(key.jwk)
+	issuer.verificationKeys[key.jwk.KeyId] = key
+	return key.jwk.KeyId, nil
+}
+
+func ParsePrivateKeyPem(keyPem []byte) (crypto.Signer, error) {
+	if key, err := jwt.ParseRSAPrivateKeyFromPEM(keyPem); err == nil {
+		return key, nil
+	}
+	if key, err := jwt.ParseECPrivateKeyFromPEM(keyPem); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("neither RSA nor EC private key")
+}
+
+func parsePublicKeyPem(keyPem []byte) (crypto.PublicKey, error) {
+	if key, err := jwt.ParseRSAPublicKeyFromPEM(keyPem); err == nil {
+		return key, nil
+	}
+	if key, err := jwt.ParseECPublicKeyFromPEM(keyPem); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("neither RSA nor EC public key")
+}
+
+// key id is the JWK thumbprint defined in RFC 7638
+func thumbprint(jwk JSONWebKey) string {
+	var members map[string]string
+	if jwk.KeyType == "RSA" {
+		members = map[string]string{"e": jwk.E, "kty": jwk.KeyType, "n": jwk.N}
+	} else {
+		members = map[string]string{"crv": jwk.Curve, "kty": jwk.KeyType, "x": jwk.X, "y": jwk.Y}
+	}
+	// json.Marshal sorts map keys, which is the lexicographic order required
+	bytes, _ := json.Marshal(members)
+	sum := sha256.Sum256(bytes)
+	return encodeBase64Url(sum[:])
+}
+
+func encodeBase64Url(bytes []byte) string {
+	return base64.RawURLEncoding.EncodeToString(bytes)
+}
+
+func padBytes(bytes []byte, size int) []byte {
+	if len(bytes) >= size {
+		return bytes
+	}
+	return append(make([]byte, size-len(bytes)), bytes...)
+}
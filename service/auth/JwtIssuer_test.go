@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestJwtIssuer_SignAndVerify(it *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+
+	it.Run("should sign and verify token with RS256 and ES256 keys", func(t *testing.T) {
+		for _, alg := range []string{"RS256", "ES256"} {
+			issuer, err := NewJwtIssuer("hallo-test", rsaKey)
+			if alg == "ES256" {
+				issuer, err = NewJwtIssuer("hallo-test", ecKey)
+			}
+			assert.Nil(t, err)
+
+			token, err := issuer.Sign(Principal{Id: 123, Name: "ann"})
+			assert.Nil(t, err)
+
+			claims, err := issuer.Verify(token)
+			assert.Nil(t, err)
+			assert.Equal(t, "123", claims.Subject)
+			assert.Equal(t, "ann", claims.Name)
+			assert.Equal(t, "hallo-test", claims.Issuer)
+			assert.True(t, claims.ExpiresAt > time.Now().Unix())
+
+			keySet := issuer.KeySet()
+			assert.Equal(t, 1, len(keySet.Keys))
+			assert.Equal(t, alg, keySet.Keys[0].Algorithm)
+		}
+	})
+
+	it.Run("should verify failed when token is tampered, expired or signed by unknown key", func(t *testing.T) {
+		issuer, err := NewJwtIssuer("hallo-test", rsaKey)
+		assert.Nil(t, err)
+		token, err := issuer.Sign(Principal{Id: 123, Name: "ann"})
+		assert.Nil(t, err)
+
+		_, err = issuer.Verify(token + "x")
+		assert.NotNil(t, err)
+
+		otherIssuer, err := NewJwtIssuer("hallo-test", ecKey)
+		assert.Nil(t, err)
+		_, err = otherIssuer.Verify(token)
+		assert.NotNil(t, err)
+
+		issuer.Expiration = -time.Minute
+		token, err = issuer.Sign(Principal{Id: 123, Name: "ann"})
+		assert.Nil(t, err)
+		_, err = issuer.Verify(token)
+		assert.NotNil(t, err)
+	})
+
+	it.Run("should accept tokens signed by retired keys", func(t *testing.T) {
+		retiredIssuer, err := NewJwtIssuer("hallo-test", rsaKey)
+		assert.Nil(t, err)
+		token, err := retiredIssuer.Sign(Principal{Id: 123, Name: "ann"})
+		assert.Nil(t, err)
+
+		issuer, err := NewJwtIssuer("hallo-test", ecKey, rsaKey.Public())
+		assert.Nil(t, err)
+		claims, err := issuer.Verify(token)
+		assert.Nil(t, err)
+		assert.Equal(t, "ann", claims.Name)
+
+		keySet := issuer.KeySet()
+		assert.Equal(t, 2, len(keySet.Keys))
+		assert.Equal(t, "ES256", keySet.Keys[0].Algorithm)
+	})
+
+	it.Run("should reject tokens which are not typed as access token", func(t *testing.T) {
+		issuer, err := NewJwtIssuer("hallo-test", ecKey)
+		assert.Nil(t, err)
+		idToken, err := issuer.SignIdToken("web", jwt.MapClaims{"sub": "123", "name": "ann"})
+		assert.Nil(t, err)
+		_, err = issuer.Verify(idToken)
+		assert.NotNil(t, err)
+
+		// signed by the same key, without typ
+		token := jwt.NewWithClaims(jwt.SigningMethodES256, &AccessTokenClaims{Name: "ann",
+			StandardClaims: jwt.StandardClaims{Issuer: "hallo-test", Subject: "123", ExpiresAt: time.Now().Add(time.Hour).Unix()}})
+		token.Header["kid"] = issuer.KeySet().Keys[0].KeyId
+		untyped, err := token.SignedString(ecKey)
+		assert.Nil(t, err)
+		_, err = issuer.Verify(untyped)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestJwtIssuer_SignIdToken(it *testing.T) {
+	it.Run("should sign id token with audience and claims of subject", func(t *testing.T) {
+		ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			panic(err)
+		}
+		issuer, err := NewJwtIssuer("https://id.test", ecKey)
+		assert.Nil(t, err)
+		assert.Equal(t, "ES256", issuer.Algorithm())
+
+		idToken, err := issuer.SignIdToken("web", jwt.MapClaims{"sub": "123", "nonce": "n-0S6", "iss": "overridden"})
+		assert.Nil(t, err)
+
+		claims := jwt.MapClaims{}
+		token, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+			return ecKey.Public(), nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, issuer.KeySet().Keys[0].KeyId, token.Header["kid"])
+		assert.Equal(t, "JWT", token.Header["typ"])
+		assert.Equal(t, "https://id.test", claims["iss"])
+		assert.Equal(t, "web", claims["aud"])
+		assert.Equal(t, "123", claims["sub"])
+		assert.Equal(t, "n-0S6", claims["nonce"])
+		assert.True(t, claims.VerifyExpiresAt(time.Now().Unix(), true))
+	})
+}
+
+func TestLoadJwtIssuer(it *testing.T) {
+	it.Run("should return nil when signing key is not configured", func(t *testing.T) {
+		os.Unsetenv("JWT_SIGNING_KEY_FILE")
+		issuer, err := LoadJwtIssuer()
+		assert.Nil(t, err)
+		assert.Nil(t, issuer)
+	})
+
+	it.Run("should load keys from files", func(t *testing.T) {
+		ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			panic(err)
+		}
+		keyBytes, err := x509.MarshalECPrivateKey(ecKey)
+		if err != nil {
+			panic(err)
+		}
+		keyFile, err := ioutil.TempFile("", "jwt-key-*.pem")
+		if err != nil {
+			panic(err)
+		}
+		defer os.Remove(keyFile.Name())
+		if err := pem.Encode(keyFile, &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes}); err != nil {
+			panic(err)
+		}
+		keyFile.Close()
+
+		os.Setenv("JWT_SIGNING_KEY_FILE", keyFile.Name())
+		defer os.Unsetenv("JWT_SIGNING_KEY_FILE")
+		issuer, err := LoadJwtIssuer()
+		assert.Nil(t, err)
+		assert.Equal(t, DefaultJwtIssuerName, issuer.Issuer)
+		assert.Equal(t, "ES256", issuer.KeySet().Keys[0].Algorithm)
+	})
+}
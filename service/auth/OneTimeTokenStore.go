@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"hallo/domain/entity"
+	"sync"
+	"time"
+)
+
+// OneTimeTokenStore keeps the states of short-lived flows by the hash of their tokens, each one is taken once
+type OneTimeTokenStore interface {
+	Save(token *entity.OneTimeToken) error
+	// Take deletes the token of kind which has not expired and returns it, return (nil, nil) when there is not one,
+	// e.g. the token has been taken concurrently
+	Take(kind, hashedToken string, now time.Time) (*entity.OneTimeToken, error)
+	// return the number of expired tokens deleted
+	DeleteExpired(now time.Time) (int64, error)
+}
+
+// MemoryOneTimeTokenStore keeps tokens in process, the flows are not able to be continued on other instances
+type MemoryOneTimeTokenStore struct {
+	lock   sync.Mutex
+	tokens map[string]entity.OneTimeToken
+}
+
+func NewMemoryOneTimeTokenStore() *MemoryOneTimeTokenStore {
+	return &MemoryOneTimeTokenStore{tokens: map[string]entity.OneTimeToken{}}
+}
+
+func (store *MemoryOneTimeTokenStore) Save(token *entity.OneTimeToken) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	store.tokens[token.HashedToken] = *token
+	return nil
+}
+
+func (store *MemoryOneTimeTokenStore) Take(kind, hashedToken string, now time.Time) (*entity.OneTimeToken, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	token, found := store.tokens[hashedToken]
+	if !found || token.Kind != kind || !token.ExpireTime.After(now) {
+		return nil, nil
+	}
+	delete(store.tokens, hashedToken)
+	return &token, nil
+}
+
+func (store *MemoryOneTimeTokenStore) DeleteExpired(now time.Time) (int64, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	var count int64
+	for hashedToken, token := range store.tokens {
+		if !token.ExpireTime.After(now) {
+			delete(store.tokens, hashedToken)
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"regexp"
+)
+
+// CodeChallengeMethodS256 is the only supported transformation of PKCE (RFC 7636), "plain" is not accepted
+const CodeChallengeMethodS256 = "S256"
+
+var codeVerifierPattern = regexp.MustCompile(`^[A-Za-z0-9\-._~]{43,128}$`)
+
+// S256 code challenges are the unpadded base64url encoding of 32 bytes
+var codeChallengePattern = regexp.MustCompile(`^[A-Za-z0-9\-_]{43}$`)
+
+func IsCodeChallengeValid(challenge string) bool {
+	return codeChallengePattern.MatchString(challenge)
+}
+
+// VerifyCodeChallenge checks that challenge is BASE64URL(SHA256(verifier))
+func VerifyCodeChallenge(challenge, verifier string) bool {
+	if !codeVerifierPattern.MatchString(verifier) {
+		return false
+	}
+	expected := CodeChallengeOf(verifier)
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+// CodeChallengeOf transforms verifier by S256, it is used when hallo is the client of upstream provider
+func CodeChallengeOf(verifier string) string {
+	digest := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(digest[:])
+}
@@ -0,0 +1,24 @@
+package auth
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestVerifyCodeChallenge(it *testing.T) {
+	// the example of RFC 7636 appendix B
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	it.Run("should verify S256 code challenge", func(t *testing.T) {
+		assert.True(t, IsCodeChallengeValid(challenge))
+		assert.True(t, VerifyCodeChallenge(challenge, verifier))
+	})
+
+	it.Run("should reject mismatched or malformed verifier", func(t *testing.T) {
+		assert.False(t, VerifyCodeChallenge(challenge, verifier[:42]+"x"))
+		assert.False(t, VerifyCodeChallenge(challenge, "short"))
+		assert.False(t, VerifyCodeChallenge(verifier, verifier))
+		assert.False(t, IsCodeChallengeValid("plain-challenge"))
+	})
+}
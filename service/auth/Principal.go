@@ -1,5 +1,96 @@
 package auth
 
+import "strings"
+
+// Principal carries the roles, permissions and group memberships at login, they are refreshed on next login.
+// OrganizationId is the organization the account belongs to, zero for the default organization. OrganizationRoles and
+// OrganizationPermissions are granted to the member within that organization only.
+// ClientId is the OAuth2 client the principal is granted to, and Scope is the space separated scopes granted to it,
+// both are empty for sessions of hallo itself.
+// Id is the id of service account rather than account when ServiceAccount is true
 type Principal struct {
-	Name string
+	Id             uint64   `json:"id"`
+	Name           string   `json:"name"`
+	OrganizationId uint64   `json:"organizationId,omitempty"`
+	ClientId       string   `json:"clientId,omitempty"`
+	Scope          string   `json:"scope,omitempty"`
+	ServiceAccount bool     `json:"serviceAccount,omitempty"`
+	Roles          []string `json:"roles,omitempty"`
+	Permissions    []string `json:"permissions,omitempty"`
+	Groups         []string `json:"groups,omitempty"`
+
+	OrganizationRoles       []string `json:"organizationRoles,omitempty"`
+	OrganizationPermissions []string `json:"organizationPermissions,omitempty"`
+}
+
+// HasPermission matches permission with the global ones, "*" and "<resource>:*" are wildcards
+func (principal *Principal) HasPermission(permission string) bool {
+	return matchPermission(principal.Permissions, permission)
+}
+
+// HasOrganizationPermission matches permission on the resources of organization, which is granted globally or
+// within the organization the principal belongs to
+func (principal *Principal) HasOrganizationPermission(organizationId uint64, permission string) bool {
+	if principal.HasPermission(permission) {
+		return true
+	}
+	return organizationId != 0 && organizationId == principal.OrganizationId &&
+		matchPermission(principal.OrganizationPermissions, permission)
+}
+
+func matchPermission(grants []string, permission string) bool {
+	for _, granted := range grants {
+		if granted == permission || granted == "*" {
+			return true
+		}
+		if strings.HasSuffix(granted, ":*") && strings.HasPrefix(permission, granted[:len(granted)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFirstParty tells whether the principal is a session of hallo itself, rather than a grant to OAuth2 client or
+// service account. Only first party principals act as the account itself
+func (principal *Principal) IsFirstParty() bool {
+	return principal.ClientId == "" && !principal.ServiceAccount
+}
+
+// HasScope is always true for the first party principal
+func (principal *Principal) HasScope(scope string) bool {
+	return principal.IsFirstParty() || ScopeContains(principal.Scope, scope)
+}
+
+// GrantTo returns the principal granted to the OAuth2 client by scope, only the permissions which are both held and
+// requested are kept. Roles are dropped since they are not granted to clients
+func (principal Principal) GrantTo(clientId, scope string) Principal {
+	granted := principal
+	granted.ClientId, granted.Scope = clientId, scope
+	granted.Roles, granted.OrganizationRoles = nil, nil
+	granted.Permissions, granted.OrganizationPermissions = nil, nil
+	for _, s := range strings.Fields(scope) {
+		if matchPermission(principal.Permissions, s) {
+			granted.Permissions = append(granted.Permissions, s)
+		}
+		if matchPermission(principal.OrganizationPermissions, s) {
+			granted.OrganizationPermissions = append(granted.OrganizationPermissions, s)
+		}
+	}
+	return granted
+}
+
+// IsIdentityScope tells whether scope is one of OpenID Connect, which grant the claims of account rather than
+// permissions. They are allowed to every client
+func IsIdentityScope(scope string) bool {
+	return scope == "openid" || scope == "profile" || scope == "email"
+}
+
+// ScopeContains tells whether the space separated scopes contain scope
+func ScopeContains(scopes, scope string) bool {
+	for _, s := range strings.Fields(scopes) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
 }
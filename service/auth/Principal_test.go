@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPrincipal_HasPermission(it *testing.T) {
+	it.Run("should match granted permissions and wildcards", func(t *testing.T) {
+		assert.False(t, (&Principal{}).HasPermission("accounts:read"))
+
+		principal := &Principal{Permissions: []string{"accounts:read", "groups:*"}}
+		assert.True(t, principal.HasPermission("accounts:read"))
+		assert.False(t, principal.HasPermission("accounts:write"))
+		assert.True(t, principal.HasPermission("groups:write"))
+		assert.False(t, principal.HasPermission("groupsx:write"))
+
+		assert.True(t, (&Principal{Permissions: []string{"*"}}).HasPermission("accounts:write"))
+		// permissions of organization are not global
+		assert.False(t, (&Principal{OrganizationId: 7, OrganizationPermissions: []string{"*"}}).HasPermission("accounts:write"))
+	})
+}
+
+func TestPrincipal_HasOrganizationPermission(it *testing.T) {
+	it.Run("should match permissions of organization within the organization only", func(t *testing.T) {
+		principal := &Principal{OrganizationId: 7, OrganizationPermissions: []string{"accounts:*"}}
+		assert.True(t, principal.HasOrganizationPermission(7, "accounts:write"))
+		assert.False(t, principal.HasOrganizationPermission(8, "accounts:write"))
+		assert.False(t, principal.HasOrganizationPermission(7, "groups:write"))
+		assert.False(t, (&Principal{OrganizationPermissions: []string{"*"}}).HasOrganizationPermission(0, "accounts:write"))
+
+		global := &Principal{OrganizationId: 7, Permissions: []string{"accounts:read"}}
+		assert.True(t, global.HasOrganizationPermission(8, "accounts:read"))
+	})
+}
+
+func TestPrincipal_HasScope(it *testing.T) {
+	it.Run("should match granted scopes and allow all scopes to first party", func(t *testing.T) {
+		principal := &Principal{ClientId: "client-1", Scope: "openid email"}
+		assert.True(t, principal.HasScope("openid"))
+		assert.True(t, principal.HasScope("email"))
+		assert.False(t, principal.HasScope("profile"))
+		assert.False(t, principal.HasScope("open"))
+
+		assert.True(t, (&Principal{}).HasScope("profile"))
+		assert.False(t, (&Principal{ClientId: "client-1"}).HasScope("profile"))
+		assert.False(t, (&Principal{ServiceAccount: true}).HasScope("profile"))
+	})
+}
+
+func TestPrincipal_GrantTo(it *testing.T) {
+	it.Run("should keep the permissions both held and requested", func(t *testing.T) {
+		principal := Principal{Id: 1, Name: "ann", OrganizationId: 7, Roles: []string{"admin"},
+			Permissions: []string{"accounts:*"}, Groups: []string{"dev"},
+			OrganizationRoles: []string{"owner"}, OrganizationPermissions: []string{"groups:read", "accounts:read"}}
+
+		granted := principal.GrantTo("client-1", "openid accounts:read groups:read clients:write")
+		assert.Equal(t, Principal{Id: 1, Name: "ann", OrganizationId: 7, ClientId: "client-1",
+			Scope: "openid accounts:read groups:read clients:write", Groups: []string{"dev"},
+			Permissions: []string{"accounts:read"}, OrganizationPermissions: []string{"accounts:read", "groups:read"}}, granted)
+		assert.False(t, granted.IsFirstParty())
+		assert.True(t, principal.IsFirstParty())
+
+		granted = principal.GrantTo("client-1", "")
+		assert.Nil(t, granted.Permissions)
+		assert.Nil(t, granted.OrganizationPermissions)
+		assert.False(t, granted.HasScope("openid"))
+	})
+}
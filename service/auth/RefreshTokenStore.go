@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"hallo/domain/entity"
+	"sync"
+	"time"
+)
+
+const (
+	// RefreshTokenExpiration is the lifetime of a refresh token, which is renewed by rotation
+	RefreshTokenExpiration = 30 * 24 * time.Hour
+	// RefreshTokenMaxLifetime is the lifetime of a family, the account signs in again after it
+	RefreshTokenMaxLifetime = 90 * 24 * time.Hour
+)
+
+// RefreshTokenStore keeps refresh tokens by their hash, tokens rotated from the same login share a family
+type RefreshTokenStore interface {
+	Save(refreshToken *entity.RefreshToken) error
+	// return (nil, nil) when token is not found
+	FindByHashedToken(hashedToken string) (*entity.RefreshToken, error)
+	// return false when the token has already been rotated
+	MarkRotated(hashedToken string) (bool, error)
+	RevokeFamily(familyId string) error
+	RevokeByAccountId(accountId uint64) error
+	// DeleteExpired deletes the expired and the revoked tokens, the rotated ones are kept until they expire to detect
+	// reuse. Return the number of tokens deleted
+	DeleteExpired(now time.Time) (int64, error)
+}
+
+type MemoryRefreshTokenStore struct {
+	lock   sync.Mutex
+	tokens map[string]entity.RefreshToken
+}
+
+func NewMemoryRefreshTokenStore() *MemoryRefreshTokenStore {
+	return &MemoryRefreshTokenStore{tokens: map[string]entity.RefreshToken{}}
+}
+
+func (store *MemoryRefreshTokenStore) Save(refreshToken *entity.RefreshToken) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	store.tokens[refreshToken.HashedToken] = *refreshToken
+	return nil
+}
+
+func (store *MemoryRefreshTokenStore) FindByHashedToken(hashedToken string) (*entity.RefreshToken, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if refreshToken, found := store.tokens[hashedToken]; found {
+		return &refreshToken, nil
+	}
+	return nil, nil
+}
+
+func (store *MemoryRefreshTokenStore) MarkRotated(hashedToken string) (bool, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	refreshToken, found := store.tokens[hashedToken]
+	if !found || refreshToken.Rotated {
+		return false, nil
+	}
+	refreshToken.Rotated = true
+	store.tokens[hashedToken] = refreshToken
+	return true, nil
+}
+
+func (store *MemoryRefreshTokenStore) RevokeFamily(familyId string) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	for hashedToken, refreshToken := range store.tokens {
+		if refreshToken.FamilyId == familyId {
+			refreshToken.Revoked = true
+			store.tokens[hashedToken] = refreshToken
+		}
+	}
+	return nil
+}
+
+func (store *MemoryRefreshTokenStore) RevokeByAccountId(accountId uint64) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	for hashedToken, refreshToken := range store.tokens {
+		if refreshToken.AccountId == accountId {
+			refreshToken.Revoked = true
+			store.tokens[hashedToken] = refreshToken
+		}
+	}
+	return nil
+}
+
+func (store *MemoryRefreshTokenStore) DeleteExpired(now time.Time) (int64, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	var count int64
+	for hashedToken, refreshToken := range store.tokens {
+		if refreshToken.Revoked || !refreshToken.ExpireTime.After(now) {
+			delete(store.tokens, hashedToken)
+			count++
+		}
+	}
+	return count, nil
+}
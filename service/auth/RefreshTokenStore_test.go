@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"github.com/stretchr/testify/assert"
+	"hallo/domain/entity"
+	"hallo/util"
+	"testing"
+	"time"
+)
+
+// principalReloader reloads principals of the accounts in it, the others are deleted
+type principalReloader map[uint64]Principal
+
+func (reloader principalReloader) Reload(principal Principal) (*Principal, error) {
+	reloaded, found := reloader[principal.Id]
+	if !found {
+		return nil, ErrPrincipalNotFound
+	}
+	reloaded.Scope = principal.Scope
+	return &reloaded, nil
+}
+
+func TestMemoryRefreshTokenStore(it *testing.T) {
+	it.Run("should rotate token only once and revoke the whole family", func(t *testing.T) {
+		store := NewMemoryRefreshTokenStore()
+		now := time.Now()
+		assert.Nil(t, store.Save(&entity.RefreshToken{HashedToken: "a", FamilyId: "f1", AccountId: 1, Principal: "{}", ExpireTime: now, CreateTime: now}))
+		assert.Nil(t, store.Save(&entity.RefreshToken{HashedToken: "b", FamilyId: "f1", AccountId: 1, Principal: "{}", ExpireTime: now, CreateTime: now}))
+		assert.Nil(t, store.Save(&entity.RefreshToken{HashedToken: "c", FamilyId: "f2", AccountId: 1, Principal: "{}", ExpireTime: now, CreateTime: now}))
+
+		found, err := store.FindByHashedToken("unknown")
+		assert.Nil(t, err)
+		assert.Nil(t, found)
+
+		rotated, err := store.MarkRotated("a")
+		assert.Nil(t, err)
+		assert.True(t, rotated)
+		rotated, err = store.MarkRotated("a")
+		assert.Nil(t, err)
+		assert.False(t, rotated)
+
+		assert.Nil(t, store.RevokeFamily("f1"))
+		found, _ = store.FindByHashedToken("b")
+		assert.True(t, found.Revoked)
+		found, _ = store.FindByHashedToken("c")
+		assert.False(t, found.Revoked)
+	})
+}
+
+func TestMemoryRefreshTokenStore_DeleteExpired(it *testing.T) {
+	it.Run("should delete expired and revoked tokens but keep rotated ones", func(t *testing.T) {
+		store := NewMemoryRefreshTokenStore()
+		now := time.Now()
+		assert.Nil(t, store.Save(&entity.RefreshToken{HashedToken: "expired", FamilyId: "f1", AccountId: 1, Principal: "{}", ExpireTime: now.Add(-time.Minute), CreateTime: now}))
+		assert.Nil(t, store.Save(&entity.RefreshToken{HashedToken: "revoked", FamilyId: "f2", AccountId: 1, Principal: "{}", Revoked: true, ExpireTime: now.Add(time.Hour), CreateTime: now}))
+		assert.Nil(t, store.Save(&entity.RefreshToken{HashedToken: "rotated", FamilyId: "f3", AccountId: 1, Principal: "{}", Rotated: true, ExpireTime: now.Add(time.Hour), CreateTime: now}))
+
+		count, err := store.DeleteExpired(now)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(2), count)
+		found, _ := store.FindByHashedToken("expired")
+		assert.Nil(t, found)
+		found, _ = store.FindByHashedToken("revoked")
+		assert.Nil(t, found)
+		found, _ = store.FindByHashedToken("rotated")
+		assert.NotNil(t, found)
+	})
+}
+
+func TestTokenService_Refresh(it *testing.T) {
+	it.Run("should rotate refresh token and detect reuse", func(t *testing.T) {
+		service := &TokenService{SessionStore: NewMemorySessionStore(), RefreshTokenStore: NewMemoryRefreshTokenStore()}
+		principal := Principal{Id: 123, Name: "ann"}
+
+		first, err := service.IssueRefreshToken(principal)
+		assert.Nil(t, err)
+
+		sc, second, err := service.Refresh(first, "")
+		assert.Nil(t, err)
+		assert.Equal(t, principal, sc.Principal)
+		assert.NotEqual(t, first, second)
+
+		_, _, err = service.Refresh(first, "")
+		assert.Equal(t, ErrRefreshTokenReused, err)
+
+		_, _, err = service.Refresh(second, "")
+		assert.Equal(t, ErrRefreshTokenInvalid, err)
+
+		_, _, err = service.Refresh("unknown", "")
+		assert.Equal(t, ErrRefreshTokenInvalid, err)
+	})
+
+	it.Run("should refuse refresh token issued to another client", func(t *testing.T) {
+		service := &TokenService{SessionStore: NewMemorySessionStore(), RefreshTokenStore: NewMemoryRefreshTokenStore()}
+		refreshToken, err := service.IssueRefreshToken(Principal{Id: 123, Name: "ann", ClientId: "web", Scope: "profile"})
+		assert.Nil(t, err)
+
+		_, _, err = service.Refresh(refreshToken, "other")
+		assert.Equal(t, ErrRefreshTokenInvalid, err)
+		_, _, err = service.Refresh(refreshToken, "")
+		assert.Equal(t, ErrRefreshTokenInvalid, err)
+		// the token is not rotated by the refused requests
+		_, _, err = service.Refresh(refreshToken, "web")
+		assert.Nil(t, err)
+	})
+
+	it.Run("should issue tokens of reloaded principal and revoke family of deleted account", func(t *testing.T) {
+		reloader := principalReloader{123: {Id: 123, Name: "ann", Roles: []string{"admin"}}}
+		service := &TokenService{SessionStore: NewMemorySessionStore(), RefreshTokenStore: NewMemoryRefreshTokenStore(),
+			PrincipalReloader: reloader}
+
+		first, err := service.IssueRefreshToken(Principal{Id: 123, Name: "ann", Scope: "profile"})
+		assert.Nil(t, err)
+		sc, second, err := service.Refresh(first, "")
+		assert.Nil(t, err)
+		assert.Equal(t, Principal{Id: 123, Name: "ann", Scope: "profile", Roles: []string{"admin"}}, sc.Principal)
+
+		delete(reloader, 123)
+		_, _, err = service.Refresh(second, "")
+		assert.Equal(t, ErrRefreshTokenInvalid, err)
+		found, err := service.RefreshTokenStore.FindByHashedToken(util.HashSha256Hex([]byte(second)))
+		assert.Nil(t, err)
+		assert.True(t, found.Revoked)
+	})
+
+	it.Run("should not renew expiration beyond max lifetime of family", func(t *testing.T) {
+		store := NewMemoryRefreshTokenStore()
+		service := &TokenService{SessionStore: NewMemorySessionStore(), RefreshTokenStore: store}
+		familyCreateTime := time.Now().Add(-RefreshTokenMaxLifetime + time.Hour)
+		assert.Nil(t, store.Save(&entity.RefreshToken{HashedToken: util.HashSha256Hex([]byte("old")), FamilyId: "f1", AccountId: 123,
+			Principal: `{"id":123,"name":"ann"}`, FamilyCreateTime: familyCreateTime, ExpireTime: time.Now().Add(time.Hour),
+			CreateTime: time.Now()}))
+
+		_, refreshToken, err := service.Refresh("old", "")
+		assert.Nil(t, err)
+		found, err := store.FindByHashedToken(util.HashSha256Hex([]byte(refreshToken)))
+		assert.Nil(t, err)
+		assert.Equal(t, familyCreateTime, found.FamilyCreateTime)
+		assert.Equal(t, familyCreateTime.Add(RefreshTokenMaxLifetime), found.ExpireTime)
+	})
+}
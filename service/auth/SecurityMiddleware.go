@@ -2,29 +2,65 @@ package auth
 
 import (
 	"github.com/gin-gonic/gin"
+	"log"
 	"net/http"
 	"strings"
 )
 
-func AuthenticateByToken() gin.HandlerFunc {
+func AuthenticateByToken(tokenService *TokenService) gin.HandlerFunc {
 	return func(context *gin.Context) {
 		auth := context.Request.Header.Get("Authorization")
 		if strings.HasPrefix(strings.ToLower(auth), "bearer ") {
 			token := auth[7:]
-			if securityContext, find := TokenCache.Get(token); find {
-				SaveToRequestContext(context, securityContext.(*SecurityContext))
+			securityContext, err := tokenService.Authenticate(token)
+			if err != nil {
+				log.Printf("failed to authenticate token: %v\n", err)
+			} else if securityContext != nil {
+				SaveToRequestContext(context, securityContext)
 			}
 		}
 		context.Next()
 	}
 }
+
+// AuthenticatedCheck aborts with 401 when the request is not authenticated, so that the handlers after it always find
+// the security context
 func AuthenticatedCheck() gin.HandlerFunc {
 	return func(context *gin.Context) {
 		securityContext := LoadFromRequestContext(context)
 		if securityContext != nil {
 			context.Next()
 		} else {
-			context.JSON(http.StatusUnauthorized, gin.H{"error": "authentication is required"})
+			context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authentication is required"})
+		}
+	}
+}
+
+// RequirePermission aborts with 403 when the principal is not granted the permission, it should be used after AuthenticatedCheck
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		securityContext := LoadFromRequestContext(context)
+		if securityContext == nil {
+			context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authentication is required"})
+		} else if !securityContext.Principal.HasPermission(permission) {
+			context.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "permission denied"})
+		} else {
+			context.Next()
+		}
+	}
+}
+
+// FirstPartyCheck aborts with 403 when the principal is granted to OAuth2 client or is a service account, it guards
+// the endpoints on which the account manages itself. It should be used after AuthenticatedCheck
+func FirstPartyCheck() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		securityContext := LoadFromRequestContext(context)
+		if securityContext == nil {
+			context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authentication is required"})
+		} else if !securityContext.Principal.IsFirstParty() {
+			context.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "permission denied"})
+		} else {
+			context.Next()
 		}
 	}
 }
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthenticatedCheck(it *testing.T) {
+	it.Run("should not call handlers after it when request is not authenticated", func(t *testing.T) {
+		called := false
+		engine := gin.Default()
+		engine.Use(AuthenticateByToken(&TokenService{SessionStore: NewMemorySessionStore()}))
+		engine.GET("/accounts/me", AuthenticatedCheck(), func(c *gin.Context) {
+			called = true
+			c.Status(http.StatusNoContent)
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/accounts/me", nil)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.JSONEq(t, `{"error": "authentication is required"}`, w.Body.String())
+		assert.False(t, called)
+	})
+}
+
+func TestRequirePermission(it *testing.T) {
+	it.Run("should only allow principal granted the permission", func(t *testing.T) {
+		service := &TokenService{SessionStore: NewMemorySessionStore()}
+		reader, _ := service.Issue(Principal{Id: 1, Name: "reader", Permissions: []string{"accounts:read"}})
+		writer, _ := service.Issue(Principal{Id: 2, Name: "writer", Permissions: []string{"accounts:*"}})
+
+		engine := gin.Default()
+		engine.Use(AuthenticateByToken(service))
+		engine.POST("/accounts", AuthenticatedCheck(), RequirePermission("accounts:write"), func(c *gin.Context) {
+			c.Status(http.StatusNoContent)
+		})
+
+		for token, status := range map[string]int{"": http.StatusUnauthorized, reader.Token: http.StatusForbidden, writer.Token: http.StatusNoContent} {
+			req := httptest.NewRequest(http.MethodPost, "/accounts", nil)
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			assert.Equal(t, status, w.Code, token)
+		}
+	})
+}
+
+func TestFirstPartyCheck(it *testing.T) {
+	it.Run("should refuse principals of OAuth2 clients and service accounts", func(t *testing.T) {
+		service := &TokenService{SessionStore: NewMemorySessionStore()}
+		session, _ := service.Issue(Principal{Id: 1, Name: "ann"})
+		client, _ := service.Issue(Principal{Id: 1, Name: "ann", ClientId: "client-1", Scope: "openid"})
+		serviceAccount, _ := service.Issue(Principal{Id: 1, Name: "job", ServiceAccount: true})
+
+		engine := gin.Default()
+		engine.Use(AuthenticateByToken(service))
+		engine.PUT("/accounts/me/secret", AuthenticatedCheck(), FirstPartyCheck(), func(c *gin.Context) {
+			c.Status(http.StatusNoContent)
+		})
+
+		for token, status := range map[string]int{session.Token: http.StatusNoContent, client.Token: http.StatusForbidden,
+			serviceAccount.Token: http.StatusForbidden} {
+			req := httptest.NewRequest(http.MethodPut, "/accounts/me/secret", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			assert.Equal(t, status, w.Code, token)
+		}
+	})
+}
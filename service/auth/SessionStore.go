@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"github.com/patrickmn/go-cache"
+	"time"
+)
+
+const SessionExpiration = 24 * time.Hour
+
+type SessionStore interface {
+	Save(securityContext *SecurityContext) error
+	// return (nil, nil) when token is not found or has expired
+	Load(token string) (*SecurityContext, error)
+	// return (nil, zero time, nil) when token is not found or has expired
+	LoadWithExpireTime(token string) (*SecurityContext, time.Time, error)
+	Delete(token string) error
+	// delete all sessions of the account except the one of exceptToken, which can be empty
+	DeleteByAccountId(accountId uint64, exceptToken string) error
+	// return the number of expired sessions deleted
+	DeleteExpired(now time.Time) (int64, error)
+}
+
+// MemorySessionStore keeps sessions in process, sessions are lost on restart and not shared between instances
+type MemorySessionStore struct {
+	cache *cache.Cache
+}
+
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{cache: cache.New(SessionExpiration, 1*time.Minute)}
+}
+
+func (store *MemorySessionStore) Save(securityContext *SecurityContext) error {
+	store.cache.Set(securityContext.Token, securityContext, cache.DefaultExpiration)
+	return nil
+}
+
+func (store *MemorySessionStore) Load(token string) (*SecurityContext, error) {
+	securityContext, _, err := store.LoadWithExpireTime(token)
+	return securityContext, err
+}
+
+func (store *MemorySessionStore) LoadWithExpireTime(token string) (*SecurityContext, time.Time, error) {
+	if securityContext, expireTime, found := store.cache.GetWithExpiration(token); found {
+		return securityContext.(*SecurityContext), expireTime, nil
+	}
+	return nil, time.Time{}, nil
+}
+
+func (store *MemorySessionStore) Delete(token string) error {
+	store.cache.Delete(token)
+	return nil
+}
+
+func (store *MemorySessionStore) DeleteByAccountId(accountId uint64, exceptToken string) error {
+	for token, item := range store.cache.Items() {
+		if item.Object.(*SecurityContext).Principal.Id == accountId && token != exceptToken {
+			store.cache.Delete(token)
+		}
+	}
+	return nil
+}
+
+// DeleteExpired deletes the sessions expired by the clock of cache, now is ignored
+func (store *MemorySessionStore) DeleteExpired(now time.Time) (int64, error) {
+	count := store.cache.ItemCount()
+	store.cache.DeleteExpired()
+	return int64(count - store.cache.ItemCount()), nil
+}
+
+func (store *MemorySessionStore) Flush() {
+	store.cache.Flush()
+}
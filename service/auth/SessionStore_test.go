@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMemorySessionStore(it *testing.T) {
+	it.Run("should save, load and delete session correctly", func(t *testing.T) {
+		store := NewMemorySessionStore()
+		token := uuid.New().String()
+
+		sc, err := store.Load(token)
+		assert.Nil(t, err)
+		assert.Nil(t, sc)
+
+		want := &SecurityContext{Token: token, Principal: Principal{Id: 123, Name: "test"}}
+		assert.Nil(t, store.Save(want))
+
+		sc, err = store.Load(token)
+		assert.Nil(t, err)
+		assert.Equal(t, want, sc)
+		sc, expireTime, err := store.LoadWithExpireTime(token)
+		assert.Nil(t, err)
+		assert.Equal(t, want, sc)
+		assert.WithinDuration(t, time.Now().Add(SessionExpiration), expireTime, time.Minute)
+
+		assert.Nil(t, store.Delete(token))
+		sc, err = store.Load(token)
+		assert.Nil(t, err)
+		assert.Nil(t, sc)
+	})
+}
+
+func TestMemorySessionStore_DeleteByAccountId(it *testing.T) {
+	it.Run("should delete sessions of account except the kept one", func(t *testing.T) {
+		store := NewMemorySessionStore()
+		first := &SecurityContext{Token: uuid.New().String(), Principal: Principal{Id: 123, Name: "test"}}
+		second := &SecurityContext{Token: uuid.New().String(), Principal: Principal{Id: 123, Name: "test"}}
+		other := &SecurityContext{Token: uuid.New().String(), Principal: Principal{Id: 456, Name: "other"}}
+		for _, sc := range []*SecurityContext{first, second, other} {
+			assert.Nil(t, store.Save(sc))
+		}
+
+		assert.Nil(t, store.DeleteByAccountId(123, second.Token))
+
+		sc, err := store.Load(first.Token)
+		assert.Nil(t, err)
+		assert.Nil(t, sc)
+		sc, err = store.Load(second.Token)
+		assert.Nil(t, err)
+		assert.Equal(t, second, sc)
+		sc, err = store.Load(other.Token)
+		assert.Nil(t, err)
+		assert.Equal(t, other, sc)
+
+		assert.Nil(t, store.DeleteByAccountId(123, ""))
+		sc, err = store.Load(second.Token)
+		assert.Nil(t, err)
+		assert.Nil(t, sc)
+	})
+}
+
+func TestMemorySessionStore_DeleteExpired(it *testing.T) {
+	it.Run("should delete expired sessions only", func(t *testing.T) {
+		store := NewMemorySessionStore()
+		expired := &SecurityContext{Token: uuid.New().String(), Principal: Principal{Id: 123, Name: "test"}}
+		active := &SecurityContext{Token: uuid.New().String(), Principal: Principal{Id: 123, Name: "test"}}
+		store.cache.Set(expired.Token, expired, time.Millisecond)
+		assert.Nil(t, store.Save(active))
+		time.Sleep(10 * time.Millisecond)
+
+		count, err := store.DeleteExpired(time.Now())
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), count)
+		sc, err := store.Load(active.Token)
+		assert.Nil(t, err)
+		assert.Equal(t, active, sc)
+	})
+}
@@ -1,9 +1,281 @@
 package auth
 
 import (
+	"encoding/json"
+	"github.com/duo-labs/webauthn/webauthn"
 	"github.com/patrickmn/go-cache"
+	"hallo/domain/entity"
+	"hallo/util"
+	"log"
 	"time"
 )
 
-var TokenCache = cache.New(24*time.Hour, 1*time.Minute)
 var RegisterTokenCache = cache.New(30*time.Minute, 1*time.Minute)
+
+// the kinds of one-time tokens
+const (
+	authorizationCodeKind = "authorization_code"
+	connectorStateKind    = "connector_state"
+	connectorLinkKind     = "connector_link"
+	loginCodeKind         = "login_code"
+	samlRequestKind       = "saml_request"
+	mfaChallengeKind      = "mfa_challenge"
+	webAuthnCeremonyKind  = "webauthn_ceremony"
+)
+
+// issueOneTimeToken generates a token of payload, which is accepted in expiration
+func (service *TokenService) issueOneTimeToken(kind string, payload interface{}, expiration time.Duration) (string, error) {
+	token, err := util.RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	if err := service.saveOneTimeToken(kind, token, payload, time.Now().Add(expiration)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// saveOneTimeToken keeps the JSON of payload by the hash of token until expireTime
+func (service *TokenService) saveOneTimeToken(kind, token string, payload interface{}, expireTime time.Time) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return service.OneTimeTokenStore.Save(&entity.OneTimeToken{HashedToken: util.HashSha256Hex([]byte(token)), Kind: kind,
+		Payload: string(data), ExpireTime: expireTime, CreateTime: time.Now()})
+}
+
+// takeOneTimeToken deletes the token and unmarshals its payload into payload, return false when the token is unknown,
+// expired or taken
+func (service *TokenService) takeOneTimeToken(kind, token string, payload interface{}) (bool, error) {
+	taken, err := service.OneTimeTokenStore.Take(kind, util.HashSha256Hex([]byte(token)), time.Now())
+	if err != nil || taken == nil {
+		return false, err
+	}
+	if err := json.Unmarshal([]byte(taken.Payload), payload); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// StartOneTimeTokenSweeper deletes the expired one-time tokens periodically in background, until stop is called
+func (service *TokenService) StartOneTimeTokenSweeper(period time.Duration) (stop func()) {
+	ticker := time.NewTicker(period)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				if count, err := service.OneTimeTokenStore.DeleteExpired(now); err != nil {
+					log.Printf("failed to sweep expired one-time tokens: %v\n", err)
+				} else if count > 0 {
+					log.Printf("%d expired one-time tokens are swept\n", count)
+				}
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
+
+const AuthorizationCodeExpiration = 10 * time.Minute
+
+// AuthorizationCode is issued by the authorization endpoint of OAuth2 and exchanged for tokens by the client,
+// the principal is loaded when the account approves the request. Nonce is the one of OpenID Connect
+type AuthorizationCode struct {
+	ClientId      string
+	RedirectUri   string
+	Scope         string
+	CodeChallenge string
+	Nonce         string
+	AuthTime      time.Time
+	Principal     Principal
+}
+
+// IssueAuthorizationCode returns the code of the request, only the hash of code is kept
+func (service *TokenService) IssueAuthorizationCode(request *AuthorizationCode) (string, error) {
+	return service.issueOneTimeToken(authorizationCodeKind, request, AuthorizationCodeExpiration)
+}
+
+// TakeAuthorizationCode removes the code and returns the request of it, so that each code is used only once.
+// Return (nil, nil) when the code is unknown, expired or used
+func (service *TokenService) TakeAuthorizationCode(code string) (*AuthorizationCode, error) {
+	request := &AuthorizationCode{}
+	found, err := service.takeOneTimeToken(authorizationCodeKind, code, request)
+	if err != nil || !found {
+		return nil, err
+	}
+	return request, nil
+}
+
+const ConnectorStateExpiration = 10 * time.Minute
+
+// ConnectorState is kept while the account signs in at the upstream identity provider,
+// CodeVerifier is the one of PKCE with which hallo exchanges the code. AccountId is the signed in account to which
+// the identity is linked, zero when the account signs in by the identity
+type ConnectorState struct {
+	ConnectorId  string
+	RedirectUri  string
+	CodeVerifier string
+	AccountId    uint64
+}
+
+// IssueConnectorState returns the state parameter sent to the upstream provider, only the hash of it is kept
+func (service *TokenService) IssueConnectorState(state *ConnectorState) (string, error) {
+	return service.issueOneTimeToken(connectorStateKind, state, ConnectorStateExpiration)
+}
+
+// TakeConnectorState removes the state and returns it, so that each callback is accepted only once.
+// Return (nil, nil) when the state is unknown, expired or taken
+func (service *TokenService) TakeConnectorState(token string) (*ConnectorState, error) {
+	state := &ConnectorState{}
+	found, err := service.takeOneTimeToken(connectorStateKind, token, state)
+	if err != nil || !found {
+		return nil, err
+	}
+	return state, nil
+}
+
+const ConnectorLinkExpiration = time.Minute
+
+// ConnectorLink is issued to the signed in account, the login endpoint of the connector takes it in the browser
+// and starts the flow which links the identity to AccountId
+type ConnectorLink struct {
+	ConnectorId string
+	AccountId   uint64
+}
+
+func (service *TokenService) IssueConnectorLink(link *ConnectorLink) (string, error) {
+	return service.issueOneTimeToken(connectorLinkKind, link, ConnectorLinkExpiration)
+}
+
+// TakeConnectorLink removes the link token and returns it, return (nil, nil) when it is unknown, expired or taken
+func (service *TokenService) TakeConnectorLink(token string) (*ConnectorLink, error) {
+	link := &ConnectorLink{}
+	found, err := service.takeOneTimeToken(connectorLinkKind, token, link)
+	if err != nil || !found {
+		return nil, err
+	}
+	return link, nil
+}
+
+const LoginCodeExpiration = time.Minute
+
+// LoginCode is redirected to the application when the account signs in by an upstream identity provider,
+// the application exchanges it for the session, so that the tokens are not exposed in the browser navigation
+type LoginCode struct {
+	AccountId uint64
+}
+
+func (service *TokenService) IssueLoginCode(login *LoginCode) (string, error) {
+	return service.issueOneTimeToken(loginCodeKind, login, LoginCodeExpiration)
+}
+
+// TakeLoginCode removes the code and returns it, return (nil, nil) when the code is unknown, expired or used
+func (service *TokenService) TakeLoginCode(code string) (*LoginCode, error) {
+	login := &LoginCode{}
+	found, err := service.takeOneTimeToken(loginCodeKind, code, login)
+	if err != nil || !found {
+		return nil, err
+	}
+	return login, nil
+}
+
+const SamlRequestExpiration = 10 * time.Minute
+
+// SamlRequestState is kept while the account signs in at the SAML identity provider, the response must be
+// InResponseTo the AuthnRequest of RequestId
+type SamlRequestState struct {
+	ProviderId string
+	RequestId  string
+}
+
+// SaveSamlRequestState keeps the state by the RelayState sent to the SAML identity provider, only the hash of it is kept
+func (service *TokenService) SaveSamlRequestState(relayState string, state *SamlRequestState) error {
+	return service.saveOneTimeToken(samlRequestKind, relayState, state, time.Now().Add(SamlRequestExpiration))
+}
+
+// TakeSamlRequestState removes the state and returns it, so that each response is accepted only once.
+// Return (nil, nil) when the relay state is unknown, expired or taken
+func (service *TokenService) TakeSamlRequestState(relayState string) (*SamlRequestState, error) {
+	state := &SamlRequestState{}
+	found, err := service.takeOneTimeToken(samlRequestKind, relayState, state)
+	if err != nil || !found {
+		return nil, err
+	}
+	return state, nil
+}
+
+const MfaChallengeExpiration = 5 * time.Minute
+
+// MfaChallengeAttempts is the number of codes which are able to be tried with one challenge
+const MfaChallengeAttempts = 5
+
+// MfaChallenge is issued when the account passes the first factor, and it is exchanged for a session by
+// the second factor before ExpireTime. Attempts is the number of failed codes
+type MfaChallenge struct {
+	Account    entity.Account
+	Attempts   int
+	ExpireTime time.Time
+}
+
+// IssueMfaChallenge returns the token of challenge, only the hash of it is kept until ExpireTime of challenge
+func (service *TokenService) IssueMfaChallenge(challenge *MfaChallenge) (string, error) {
+	token, err := util.RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	if err := service.saveOneTimeToken(mfaChallengeKind, token, challenge, challenge.ExpireTime); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// TakeMfaChallenge removes the challenge and returns it, so that a challenge is not tried concurrently.
+// Return (nil, nil) when the token is unknown, expired or taken
+func (service *TokenService) TakeMfaChallenge(token string) (*MfaChallenge, error) {
+	challenge := &MfaChallenge{}
+	found, err := service.takeOneTimeToken(mfaChallengeKind, token, challenge)
+	if err != nil || !found {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// ReturnMfaChallenge puts the challenge back after a failed attempt, it is dropped when the attempts are exhausted
+func (service *TokenService) ReturnMfaChallenge(token string, challenge *MfaChallenge) error {
+	if challenge.Attempts >= MfaChallengeAttempts || !challenge.ExpireTime.After(time.Now()) {
+		return nil
+	}
+	return service.saveOneTimeToken(mfaChallengeKind, token, challenge, challenge.ExpireTime)
+}
+
+const WebAuthnCeremonyExpiration = 5 * time.Minute
+
+// WebAuthnCeremony is kept between the options and the response of a WebAuthn ceremony, the response is matched by
+// the challenge in its client data. AccountId is the account registering a credential, zero for the login ceremony
+type WebAuthnCeremony struct {
+	AccountId uint64
+	Session   webauthn.SessionData
+}
+
+// SaveWebAuthnCeremony keeps the ceremony by its challenge, only the hash of the challenge is kept
+func (service *TokenService) SaveWebAuthnCeremony(ceremony *WebAuthnCeremony) error {
+	return service.saveOneTimeToken(webAuthnCeremonyKind, ceremony.Session.Challenge, ceremony,
+		time.Now().Add(WebAuthnCeremonyExpiration))
+}
+
+// TakeWebAuthnCeremony removes the ceremony and returns it, so that each challenge is answered only once.
+// Return (nil, nil) when the challenge is unknown, expired or taken
+func (service *TokenService) TakeWebAuthnCeremony(challenge string) (*WebAuthnCeremony, error) {
+	ceremony := &WebAuthnCeremony{}
+	found, err := service.takeOneTimeToken(webAuthnCeremonyKind, challenge, ceremony)
+	if err != nil || !found {
+		return nil, err
+	}
+	return ceremony, nil
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"github.com/duo-labs/webauthn/protocol"
+	"github.com/duo-labs/webauthn/webauthn"
+	"github.com/stretchr/testify/assert"
+	"hallo/domain/entity"
+	"testing"
+	"time"
+)
+
+func TestTokenService_AuthorizationCode(it *testing.T) {
+	it.Run("should take authorization code only once", func(t *testing.T) {
+		store := NewMemoryOneTimeTokenStore()
+		service := &TokenService{OneTimeTokenStore: store}
+		request := &AuthorizationCode{ClientId: "client", RedirectUri: "https://app/callback", Scope: "openid",
+			AuthTime: time.Now().Round(time.Second), Principal: Principal{Id: 123, Name: "ann", Scope: "openid"}}
+		code, err := service.IssueAuthorizationCode(request)
+		assert.Nil(t, err)
+		// only the hash of code is kept
+		for hashedToken := range store.tokens {
+			assert.NotContains(t, hashedToken, code)
+		}
+
+		found, err := service.TakeAuthorizationCode(code)
+		assert.Nil(t, err)
+		assert.Equal(t, request.Principal, found.Principal)
+		assert.Equal(t, request.RedirectUri, found.RedirectUri)
+		assert.True(t, request.AuthTime.Equal(found.AuthTime))
+
+		found, err = service.TakeAuthorizationCode(code)
+		assert.Nil(t, err)
+		assert.Nil(t, found)
+	})
+}
+
+func TestTokenService_MfaChallenge(it *testing.T) {
+	it.Run("should return mfa challenge until attempts are exhausted", func(t *testing.T) {
+		store := NewMemoryOneTimeTokenStore()
+		service := &TokenService{OneTimeTokenStore: store}
+		token, err := service.IssueMfaChallenge(&MfaChallenge{Account: entity.Account{Id: 123},
+			ExpireTime: time.Now().Add(MfaChallengeExpiration)})
+		assert.Nil(t, err)
+		// only the hash of token is kept
+		for hashedToken := range store.tokens {
+			assert.NotContains(t, hashedToken, token)
+		}
+
+		for i := 1; i < MfaChallengeAttempts; i++ {
+			found, err := service.TakeMfaChallenge(token)
+			assert.Nil(t, err)
+			assert.Equal(t, uint64(123), found.Account.Id)
+			taken, err := service.TakeMfaChallenge(token)
+			assert.Nil(t, err)
+			assert.Nil(t, taken)
+
+			found.Attempts++
+			assert.Nil(t, service.ReturnMfaChallenge(token, found))
+		}
+		found, err := service.TakeMfaChallenge(token)
+		assert.Nil(t, err)
+		found.Attempts++
+		assert.Nil(t, service.ReturnMfaChallenge(token, found))
+		found, err = service.TakeMfaChallenge(token)
+		assert.Nil(t, err)
+		assert.Nil(t, found)
+	})
+}
+
+func TestTokenService_WebAuthnCeremony(it *testing.T) {
+	it.Run("should return webauthn ceremony once by its challenge", func(t *testing.T) {
+		store := NewMemoryOneTimeTokenStore()
+		service := &TokenService{OneTimeTokenStore: store}
+		session := webauthn.SessionData{Challenge: "Y2hhbGxlbmdl", UserID: []byte{1, 2, 3},
+			AllowedCredentialIDs: [][]byte{{4, 5}}, UserVerification: protocol.VerificationRequired}
+		assert.Nil(t, service.SaveWebAuthnCeremony(&WebAuthnCeremony{AccountId: 123, Session: session}))
+		for hashedToken := range store.tokens {
+			assert.NotContains(t, hashedToken, session.Challenge)
+		}
+
+		found, err := service.TakeWebAuthnCeremony("unknown")
+		assert.Nil(t, err)
+		assert.Nil(t, found)
+		found, err = service.TakeWebAuthnCeremony(session.Challenge)
+		assert.Nil(t, err)
+		assert.Equal(t, &WebAuthnCeremony{AccountId: 123, Session: session}, found)
+		found, err = service.TakeWebAuthnCeremony(session.Challenge)
+		assert.Nil(t, err)
+		assert.Nil(t, found)
+	})
+}
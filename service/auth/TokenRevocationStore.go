@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"hallo/domain/entity"
+	"sync"
+	"time"
+)
+
+// TokenRevocationStore denies JWTs before they expire, by their ids or by the accounts they are issued to.
+// The tokens of account issued before RevokeTime are denied, the issue time of JWT is in seconds
+type TokenRevocationStore interface {
+	RevokeToken(revokedToken *entity.RevokedToken) error
+	// RevokeByAccountId replaces the former revocation of the account
+	RevokeByAccountId(revocation *entity.AccountTokenRevocation) error
+	IsRevoked(tokenId string, accountId uint64, issueTime time.Time) (bool, error)
+	// return the number of expired records deleted
+	DeleteExpired(now time.Time) (int64, error)
+}
+
+type MemoryTokenRevocationStore struct {
+	lock        sync.Mutex
+	tokens      map[string]entity.RevokedToken
+	revocations map[uint64]entity.AccountTokenRevocation
+}
+
+func NewMemoryTokenRevocationStore() *MemoryTokenRevocationStore {
+	return &MemoryTokenRevocationStore{tokens: map[string]entity.RevokedToken{},
+		revocations: map[uint64]entity.AccountTokenRevocation{}}
+}
+
+func (store *MemoryTokenRevocationStore) RevokeToken(revokedToken *entity.RevokedToken) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	store.tokens[revokedToken.TokenId] = *revokedToken
+	return nil
+}
+
+func (store *MemoryTokenRevocationStore) RevokeByAccountId(revocation *entity.AccountTokenRevocation) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	store.revocations[revocation.AccountId] = *revocation
+	return nil
+}
+
+func (store *MemoryTokenRevocationStore) IsRevoked(tokenId string, accountId uint64, issueTime time.Time) (bool, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if _, found := store.tokens[tokenId]; found {
+		return true, nil
+	}
+	revocation, found := store.revocations[accountId]
+	return found && isRevokedByAccount(&revocation, tokenId, issueTime), nil
+}
+
+func (store *MemoryTokenRevocationStore) DeleteExpired(now time.Time) (int64, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	var count int64
+	for tokenId, token := range store.tokens {
+		if !token.ExpireTime.After(now) {
+			delete(store.tokens, tokenId)
+			count++
+		}
+	}
+	for accountId, revocation := range store.revocations {
+		if !revocation.ExpireTime.After(now) {
+			delete(store.revocations, accountId)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func isRevokedByAccount(revocation *entity.AccountTokenRevocation, tokenId string, issueTime time.Time) bool {
+	return issueTime.Before(revocation.RevokeTime) && (revocation.ExceptTokenId == "" || revocation.ExceptTokenId != tokenId)
+}
@@ -0,0 +1,415 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	uuid "github.com/satori/go.uuid"
+	"hallo/domain/entity"
+	"hallo/util"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TokenService issues access tokens and authenticates them.
+// Tokens are opaque and kept in SessionStore, or signed JWTs when JwtIssuer is configured.
+// Both kinds of token are accepted, JWTs are revoked before they expire by RevocationStore, which is
+// required to revoke them. The resource servers verifying JWTs locally don't know the revocations, they should
+// introspect tokens when it matters.
+// Refresh tokens are rotated on each use, reusing a rotated one revokes all tokens of its family.
+// The principal of refresh token is reloaded by PrincipalReloader on rotation when it is configured, so that
+// the changes of roles are taken and the deleted accounts are refused.
+// The one-time tokens of short-lived flows, e.g. authorization codes, are kept in OneTimeTokenStore.
+type TokenService struct {
+	SessionStore      SessionStore
+	JwtIssuer         *JwtIssuer
+	RefreshTokenStore RefreshTokenStore
+	RevocationStore   TokenRevocationStore
+	PrincipalReloader PrincipalReloader
+	OneTimeTokenStore OneTimeTokenStore
+}
+
+// PrincipalReloader loads the principal of the same account again, ErrPrincipalNotFound is returned
+// when the account is deleted
+type PrincipalReloader interface {
+	Reload(principal Principal) (*Principal, error)
+}
+
+func (service *TokenService) Issue(principal Principal) (*SecurityContext, error) {
+	if service.JwtIssuer != nil {
+		token, err := service.JwtIssuer.Sign(principal)
+		if err != nil {
+			return nil, err
+		}
+		return &SecurityContext{Token: token, Principal: principal}, nil
+	}
+
+	securityContext := &SecurityContext{Token: uuid.NewV4().String(), Principal: principal}
+	if err := service.SessionStore.Save(securityContext); err != nil {
+		return nil, err
+	}
+	return securityContext, nil
+}
+
+// Expiration is the lifetime of the access tokens issued
+func (service *TokenService) Expiration() time.Duration {
+	if service.JwtIssuer != nil {
+		return service.JwtIssuer.Expiration
+	}
+	return SessionExpiration
+}
+
+// return (nil, nil) when token is unknown, expired or invalid
+func (service *TokenService) Authenticate(token string) (*SecurityContext, error) {
+	if !isJwt(token) {
+		return service.SessionStore.Load(token)
+	}
+	if service.JwtIssuer == nil {
+		return nil, nil
+	}
+
+	claims, principal, err := service.verifyJwt(token)
+	if err != nil || claims == nil {
+		return nil, err
+	}
+	return &SecurityContext{Token: token, Principal: *principal}, nil
+}
+
+// verifyJwt return (nil, nil, nil) when the JWT is invalid, expired or revoked
+func (service *TokenService) verifyJwt(token string) (*AccessTokenClaims, *Principal, error) {
+	claims, err := service.JwtIssuer.Verify(token)
+	if err != nil {
+		return nil, nil, nil
+	}
+	principal, err := principalFromClaims(claims)
+	if err != nil {
+		return nil, nil, err
+	}
+	if service.RevocationStore != nil {
+		revoked, err := service.RevocationStore.IsRevoked(claims.Id, principal.Id, time.Unix(claims.IssuedAt, 0))
+		if err != nil || revoked {
+			return nil, nil, err
+		}
+	}
+	return claims, principal, nil
+}
+
+// Revoke ignores unknown tokens, ErrTokenNotRevocable is returned for JWTs when RevocationStore is absent
+func (service *TokenService) Revoke(token string) error {
+	if !isJwt(token) {
+		return service.SessionStore.Delete(token)
+	}
+	if service.JwtIssuer == nil {
+		return nil
+	}
+	if service.RevocationStore == nil {
+		return ErrTokenNotRevocable
+	}
+	claims, err := service.JwtIssuer.Verify(token)
+	if err != nil {
+		return nil
+	}
+	return service.RevocationStore.RevokeToken(&entity.RevokedToken{TokenId: claims.Id, ExpireTime: time.Unix(claims.ExpiresAt, 0)})
+}
+
+// RevokeByAccountId revokes all the access tokens except exceptToken and all the refresh tokens of the account.
+// The JWTs are revoked only when RevocationStore is present, otherwise ErrTokenNotRevocable is returned after
+// the others are revoked
+func (service *TokenService) RevokeByAccountId(accountId uint64, exceptToken string) error {
+	if err := service.SessionStore.DeleteByAccountId(accountId, exceptToken); err != nil {
+		return err
+	}
+	if err := service.RefreshTokenStore.RevokeByAccountId(accountId); err != nil {
+		return err
+	}
+	if service.JwtIssuer == nil {
+		return nil
+	}
+	if service.RevocationStore == nil {
+		return ErrTokenNotRevocable
+	}
+
+	// the issue time of JWT is in seconds, the revocation takes effect from the next second, and it is waited for,
+	// so that the tokens issued after return, e.g. by the refresh token of a new session, are not denied
+	revocation := &entity.AccountTokenRevocation{AccountId: accountId, RevokeTime: time.Now().Truncate(time.Second).Add(time.Second)}
+	revocation.ExpireTime = revocation.RevokeTime.Add(service.JwtIssuer.Expiration)
+	if isJwt(exceptToken) {
+		if claims, err := service.JwtIssuer.Verify(exceptToken); err == nil {
+			revocation.ExceptTokenId = claims.Id
+		}
+	}
+	if err := service.RevocationStore.RevokeByAccountId(revocation); err != nil {
+		return err
+	}
+	time.Sleep(time.Until(revocation.RevokeTime))
+	return nil
+}
+
+// StartRevocationSweeper deletes the expired revocations of JWTs periodically in background, until stop is called
+func (service *TokenService) StartRevocationSweeper(period time.Duration) (stop func()) {
+	ticker := time.NewTicker(period)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				if count, err := service.RevocationStore.DeleteExpired(now); err != nil {
+					log.Printf("failed to sweep expired token revocations: %v\n", err)
+				} else if count > 0 {
+					log.Printf("%d expired token revocations are swept\n", count)
+				}
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
+
+// StartSessionSweeper deletes the expired sessions periodically in background, until stop is called
+func (service *TokenService) StartSessionSweeper(period time.Duration) (stop func()) {
+	ticker := time.NewTicker(period)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				if count, err := service.SessionStore.DeleteExpired(now); err != nil {
+					log.Printf("failed to sweep expired sessions: %v\n", err)
+				} else if count > 0 {
+					log.Printf("%d expired sessions are swept\n", count)
+				}
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
+
+// StartRefreshTokenSweeper deletes the expired and revoked refresh tokens periodically in background, until stop is called
+func (service *TokenService) StartRefreshTokenSweeper(period time.Duration) (stop func()) {
+	ticker := time.NewTicker(period)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				if count, err := service.RefreshTokenStore.DeleteExpired(now); err != nil {
+					log.Printf("failed to sweep expired refresh tokens: %v\n", err)
+				} else if count > 0 {
+					log.Printf("%d expired refresh tokens are swept\n", count)
+				}
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
+
+// TokenIntrospection describes an active token, TokenType is "access_token" or "refresh_token"
+type TokenIntrospection struct {
+	TokenType  string
+	Principal  Principal
+	ExpireTime time.Time
+}
+
+// Introspect return (nil, nil) when token is neither an active access token nor an active refresh token.
+// Refresh token is looked up first when tokenTypeHint is "refresh_token"
+func (service *TokenService) Introspect(token, tokenTypeHint string) (*TokenIntrospection, error) {
+	lookups := []func(string) (*TokenIntrospection, error){service.introspectAccessToken, service.introspectRefreshToken}
+	if tokenTypeHint == "refresh_token" {
+		lookups[0], lookups[1] = lookups[1], lookups[0]
+	}
+	for _, lookup := range lookups {
+		introspection, err := lookup(token)
+		if err != nil || introspection != nil {
+			return introspection, err
+		}
+	}
+	return nil, nil
+}
+
+func (service *TokenService) introspectAccessToken(token string) (*TokenIntrospection, error) {
+	if !isJwt(token) {
+		securityContext, expireTime, err := service.SessionStore.LoadWithExpireTime(token)
+		if err != nil || securityContext == nil {
+			return nil, err
+		}
+		return &TokenIntrospection{TokenType: "access_token", Principal: securityContext.Principal, ExpireTime: expireTime}, nil
+	}
+	if service.JwtIssuer == nil {
+		return nil, nil
+	}
+
+	claims, principal, err := service.verifyJwt(token)
+	if err != nil || claims == nil {
+		return nil, err
+	}
+	return &TokenIntrospection{TokenType: "access_token", Principal: *principal, ExpireTime: time.Unix(claims.ExpiresAt, 0)}, nil
+}
+
+func (service *TokenService) introspectRefreshToken(token string) (*TokenIntrospection, error) {
+	if service.RefreshTokenStore == nil {
+		return nil, nil
+	}
+	record, err := service.RefreshTokenStore.FindByHashedToken(util.HashSha256Hex([]byte(token)))
+	if err != nil {
+		return nil, err
+	}
+	if record == nil || record.Revoked || record.Rotated || record.ExpireTime.Before(time.Now()) {
+		return nil, nil
+	}
+
+	principal := Principal{}
+	if err := json.Unmarshal([]byte(record.Principal), &principal); err != nil {
+		return nil, err
+	}
+	return &TokenIntrospection{TokenType: "refresh_token", Principal: principal, ExpireTime: record.ExpireTime}, nil
+}
+
+// RevokeToken revokes the access token, or the family of the refresh token, which is issued to the client of clientId.
+// Unknown tokens are ignored, ErrTokenNotOfClient is returned when the token is issued to another client or to
+// hallo itself (RFC 7009 section 2.1)
+func (service *TokenService) RevokeToken(token, clientId string) error {
+	if service.RefreshTokenStore != nil {
+		record, err := service.RefreshTokenStore.FindByHashedToken(util.HashSha256Hex([]byte(token)))
+		if err != nil {
+			return err
+		}
+		if record != nil {
+			principal := Principal{}
+			if err := json.Unmarshal([]byte(record.Principal), &principal); err != nil {
+				return err
+			}
+			if principal.ClientId == "" || principal.ClientId != clientId {
+				return ErrTokenNotOfClient
+			}
+			return service.RefreshTokenStore.RevokeFamily(record.FamilyId)
+		}
+	}
+
+	introspection, err := service.introspectAccessToken(token)
+	if err != nil || introspection == nil {
+		return err
+	}
+	if introspection.Principal.ClientId == "" || introspection.Principal.ClientId != clientId {
+		return ErrTokenNotOfClient
+	}
+	return service.Revoke(token)
+}
+
+// IssueRefreshToken starts a new family of refresh tokens for the principal
+func (service *TokenService) IssueRefreshToken(principal Principal) (string, error) {
+	return service.issueRefreshToken(principal, uuid.NewV4().String(), time.Now())
+}
+
+// Refresh rotates the refresh token issued to the client of clientId, which is empty for the sessions of hallo itself.
+// Return a new access token and a new refresh token of the same family.
+// The family is revoked when the account is deleted, ErrRefreshTokenInvalid is returned then
+func (service *TokenService) Refresh(refreshToken, clientId string) (*SecurityContext, string, error) {
+	hashedToken := util.HashSha256Hex([]byte(refreshToken))
+	record, err := service.RefreshTokenStore.FindByHashedToken(hashedToken)
+	if err != nil {
+		return nil, "", err
+	}
+	if record == nil || record.Revoked || record.ExpireTime.Before(time.Now()) {
+		return nil, "", ErrRefreshTokenInvalid
+	}
+	principal := Principal{}
+	if err := json.Unmarshal([]byte(record.Principal), &principal); err != nil {
+		return nil, "", err
+	}
+	if principal.ClientId != clientId {
+		return nil, "", ErrRefreshTokenInvalid
+	}
+
+	rotated, err := service.RefreshTokenStore.MarkRotated(hashedToken)
+	if err != nil {
+		return nil, "", err
+	}
+	if !rotated {
+		log.Printf("refresh token of family %s has been reused, revoke the family\n", record.FamilyId)
+		if err := service.RefreshTokenStore.RevokeFamily(record.FamilyId); err != nil {
+			return nil, "", err
+		}
+		return nil, "", ErrRefreshTokenReused
+	}
+
+	if service.PrincipalReloader != nil {
+		reloaded, err := service.PrincipalReloader.Reload(principal)
+		if errors.Is(err, ErrPrincipalNotFound) {
+			if err := service.RefreshTokenStore.RevokeFamily(record.FamilyId); err != nil {
+				return nil, "", err
+			}
+			return nil, "", ErrRefreshTokenInvalid
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		principal = *reloaded
+	}
+	securityContext, err := service.Issue(principal)
+	if err != nil {
+		return nil, "", err
+	}
+	newRefreshToken, err := service.issueRefreshToken(principal, record.FamilyId, record.FamilyCreateTime)
+	if err != nil {
+		return nil, "", err
+	}
+	return securityContext, newRefreshToken, nil
+}
+
+// issueRefreshToken renews the expiration, but not beyond the max lifetime of the family
+func (service *TokenService) issueRefreshToken(principal Principal, familyId string, familyCreateTime time.Time) (string, error) {
+	principalJson, err := json.Marshal(principal)
+	if err != nil {
+		return "", err
+	}
+
+	token := uuid.NewV4().String()
+	now := time.Now()
+	expireTime := now.Add(RefreshTokenExpiration)
+	if familyExpireTime := familyCreateTime.Add(RefreshTokenMaxLifetime); familyExpireTime.Before(expireTime) {
+		expireTime = familyExpireTime
+	}
+	err = service.RefreshTokenStore.Save(&entity.RefreshToken{
+		HashedToken:      util.HashSha256Hex([]byte(token)),
+		FamilyId:         familyId,
+		AccountId:        principal.Id,
+		Principal:        string(principalJson),
+		FamilyCreateTime: familyCreateTime,
+		ExpireTime:       expireTime,
+		CreateTime:       now,
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func principalFromClaims(claims *AccessTokenClaims) (*Principal, error) {
+	id, err := strconv.ParseUint(claims.Subject, 10, 64)
+	if err != nil {
+		return nil, errors.New("bad subject of token")
+	}
+	return &Principal{Id: id, Name: claims.Name, OrganizationId: claims.OrganizationId,
+		ClientId: claims.ClientId, Scope: claims.Scope,
+		ServiceAccount: claims.ServiceAccount, Roles: claims.Roles, Permissions: claims.Permissions, Groups: claims.Groups,
+		OrganizationRoles: claims.OrganizationRoles, OrganizationPermissions: claims.OrganizationPermissions}, nil
+}
+
+func isJwt(token string) bool {
+	return strings.Count(token, ".") == 2
+}
@@ -0,0 +1,260 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestTokenService(it *testing.T) {
+	it.Run("should issue, authenticate and revoke opaque token", func(t *testing.T) {
+		service := &TokenService{SessionStore: NewMemorySessionStore()}
+
+		sc, err := service.Issue(Principal{Id: 123, Name: "ann"})
+		assert.Nil(t, err)
+
+		found, err := service.Authenticate(sc.Token)
+		assert.Nil(t, err)
+		assert.Equal(t, sc, found)
+
+		assert.Nil(t, service.Revoke(sc.Token))
+		found, err = service.Authenticate(sc.Token)
+		assert.Nil(t, err)
+		assert.Nil(t, found)
+	})
+
+	it.Run("should revoke all opaque tokens and refresh tokens of account", func(t *testing.T) {
+		service := &TokenService{SessionStore: NewMemorySessionStore(), RefreshTokenStore: NewMemoryRefreshTokenStore()}
+
+		sc, err := service.Issue(Principal{Id: 123, Name: "ann"})
+		assert.Nil(t, err)
+		refreshToken, err := service.IssueRefreshToken(Principal{Id: 123, Name: "ann"})
+		assert.Nil(t, err)
+		other, err := service.Issue(Principal{Id: 456, Name: "bob"})
+		assert.Nil(t, err)
+		otherRefreshToken, err := service.IssueRefreshToken(Principal{Id: 456, Name: "bob"})
+		assert.Nil(t, err)
+
+		assert.Nil(t, service.RevokeByAccountId(123, ""))
+
+		found, err := service.Authenticate(sc.Token)
+		assert.Nil(t, err)
+		assert.Nil(t, found)
+		_, _, err = service.Refresh(refreshToken, "")
+		assert.Equal(t, ErrRefreshTokenInvalid, err)
+
+		found, err = service.Authenticate(other.Token)
+		assert.Nil(t, err)
+		assert.Equal(t, other, found)
+		_, _, err = service.Refresh(otherRefreshToken, "")
+		assert.Nil(t, err)
+	})
+
+	it.Run("should accept both jwt and opaque token when jwt issuer is configured", func(t *testing.T) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			panic(err)
+		}
+		issuer, err := NewJwtIssuer("hallo-test", key)
+		assert.Nil(t, err)
+
+		store := NewMemorySessionStore()
+		opaque, err := (&TokenService{SessionStore: store}).Issue(Principal{Id: 456, Name: "bob"})
+		assert.Nil(t, err)
+
+		service := &TokenService{SessionStore: store, JwtIssuer: issuer}
+		principal := Principal{Id: 123, Name: "ann", OrganizationId: 789, Roles: []string{"reader"},
+			Permissions: []string{"accounts:read"}, Groups: []string{"dev", "engineering"},
+			OrganizationRoles: []string{"org-admin"}, OrganizationPermissions: []string{"accounts:*"}}
+		sc, err := service.Issue(principal)
+		assert.Nil(t, err)
+		assert.True(t, isJwt(sc.Token))
+
+		found, err := service.Authenticate(sc.Token)
+		assert.Nil(t, err)
+		assert.Equal(t, &SecurityContext{Token: sc.Token, Principal: principal}, found)
+
+		found, err = service.Authenticate(opaque.Token)
+		assert.Nil(t, err)
+		assert.Equal(t, opaque, found)
+
+		found, err = service.Authenticate(sc.Token + "bad")
+		assert.Nil(t, err)
+		assert.Nil(t, found)
+
+		// ID tokens are signed by the same key, but they are not access tokens
+		idToken, err := issuer.SignIdToken("web", jwt.MapClaims{"sub": "123", "name": "ann"})
+		assert.Nil(t, err)
+		found, err = service.Authenticate(idToken)
+		assert.Nil(t, err)
+		assert.Nil(t, found)
+	})
+
+	it.Run("should revoke jwt by its id and by its account", func(t *testing.T) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			panic(err)
+		}
+		issuer, err := NewJwtIssuer("hallo-test", key)
+		assert.Nil(t, err)
+		service := &TokenService{SessionStore: NewMemorySessionStore(), JwtIssuer: issuer,
+			RefreshTokenStore: NewMemoryRefreshTokenStore(), RevocationStore: NewMemoryTokenRevocationStore()}
+
+		revoked, err := service.Issue(Principal{Id: 123, Name: "ann"})
+		assert.Nil(t, err)
+		current, err := service.Issue(Principal{Id: 123, Name: "ann"})
+		assert.Nil(t, err)
+		other, err := service.Issue(Principal{Id: 123, Name: "ann"})
+		assert.Nil(t, err)
+		another, err := service.Issue(Principal{Id: 456, Name: "bob"})
+		assert.Nil(t, err)
+
+		assert.Nil(t, service.Revoke(revoked.Token))
+		found, err := service.Authenticate(revoked.Token)
+		assert.Nil(t, err)
+		assert.Nil(t, found)
+		introspection, err := service.Introspect(revoked.Token, "")
+		assert.Nil(t, err)
+		assert.Nil(t, introspection)
+
+		assert.Nil(t, service.RevokeByAccountId(123, current.Token))
+		found, err = service.Authenticate(other.Token)
+		assert.Nil(t, err)
+		assert.Nil(t, found)
+		found, err = service.Authenticate(current.Token)
+		assert.Nil(t, err)
+		assert.NotNil(t, found)
+		found, err = service.Authenticate(another.Token)
+		assert.Nil(t, err)
+		assert.NotNil(t, found)
+	})
+
+	it.Run("should accept jwt issued in the same second after revocation of account", func(t *testing.T) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			panic(err)
+		}
+		issuer, err := NewJwtIssuer("hallo-test", key)
+		assert.Nil(t, err)
+		store := NewMemoryTokenRevocationStore()
+		service := &TokenService{SessionStore: NewMemorySessionStore(), JwtIssuer: issuer,
+			RefreshTokenStore: NewMemoryRefreshTokenStore(), RevocationStore: store}
+
+		before, err := service.Issue(Principal{Id: 123, Name: "ann"})
+		assert.Nil(t, err)
+		assert.Nil(t, service.RevokeByAccountId(123, ""))
+		after, err := service.Issue(Principal{Id: 123, Name: "ann"})
+		assert.Nil(t, err)
+
+		// the revocation is waited until the second of the next issue time
+		revocation := store.revocations[123]
+		claims, err := issuer.Verify(after.Token)
+		assert.Nil(t, err)
+		assert.Equal(t, revocation.RevokeTime.Unix(), claims.IssuedAt)
+
+		found, err := service.Authenticate(before.Token)
+		assert.Nil(t, err)
+		assert.Nil(t, found)
+		found, err = service.Authenticate(after.Token)
+		assert.Nil(t, err)
+		assert.NotNil(t, found)
+	})
+
+	it.Run("should not report revocation of jwt without revocation store", func(t *testing.T) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			panic(err)
+		}
+		issuer, err := NewJwtIssuer("hallo-test", key)
+		assert.Nil(t, err)
+		service := &TokenService{SessionStore: NewMemorySessionStore(), JwtIssuer: issuer, RefreshTokenStore: NewMemoryRefreshTokenStore()}
+
+		sc, err := service.Issue(Principal{Id: 123, Name: "ann"})
+		assert.Nil(t, err)
+		assert.Equal(t, ErrTokenNotRevocable, service.Revoke(sc.Token))
+		assert.Equal(t, ErrTokenNotRevocable, service.RevokeByAccountId(123, ""))
+	})
+
+	it.Run("should not accept jwt when jwt issuer is not configured", func(t *testing.T) {
+		found, err := (&TokenService{SessionStore: NewMemorySessionStore()}).Authenticate("a.b.c")
+		assert.Nil(t, err)
+		assert.Nil(t, found)
+	})
+}
+
+func TestTokenService_Introspect(it *testing.T) {
+	it.Run("should introspect active opaque token and refresh token until they are revoked", func(t *testing.T) {
+		service := &TokenService{SessionStore: NewMemorySessionStore(), RefreshTokenStore: NewMemoryRefreshTokenStore()}
+		principal := Principal{Id: 123, Name: "ann", ClientId: "web", Scope: "profile"}
+
+		sc, err := service.Issue(principal)
+		assert.Nil(t, err)
+		refreshToken, err := service.IssueRefreshToken(principal)
+		assert.Nil(t, err)
+
+		introspection, err := service.Introspect(sc.Token, "")
+		assert.Nil(t, err)
+		assert.Equal(t, "access_token", introspection.TokenType)
+		assert.Equal(t, principal, introspection.Principal)
+		assert.WithinDuration(t, time.Now().Add(SessionExpiration), introspection.ExpireTime, time.Minute)
+
+		// the hint is not trusted, the other kind of token is looked up as well
+		introspection, err = service.Introspect(refreshToken, "access_token")
+		assert.Nil(t, err)
+		assert.Equal(t, "refresh_token", introspection.TokenType)
+		assert.Equal(t, principal, introspection.Principal)
+
+		introspection, err = service.Introspect("unknown", "refresh_token")
+		assert.Nil(t, err)
+		assert.Nil(t, introspection)
+
+		// the tokens are revoked by the client they are issued to only
+		assert.Equal(t, ErrTokenNotOfClient, service.RevokeToken(sc.Token, "other"))
+		assert.Equal(t, ErrTokenNotOfClient, service.RevokeToken(refreshToken, "other"))
+		introspection, err = service.Introspect(sc.Token, "")
+		assert.Nil(t, err)
+		assert.NotNil(t, introspection)
+
+		assert.Nil(t, service.RevokeToken(sc.Token, "web"))
+		assert.Nil(t, service.RevokeToken(refreshToken, "web"))
+		assert.Nil(t, service.RevokeToken("unknown", "web"))
+		introspection, err = service.Introspect(sc.Token, "")
+		assert.Nil(t, err)
+		assert.Nil(t, introspection)
+		introspection, err = service.Introspect(refreshToken, "refresh_token")
+		assert.Nil(t, err)
+		assert.Nil(t, introspection)
+		_, _, err = service.Refresh(refreshToken, "web")
+		assert.Equal(t, ErrRefreshTokenInvalid, err)
+
+		session, err := service.Issue(Principal{Id: 123, Name: "ann"})
+		assert.Nil(t, err)
+		assert.Equal(t, ErrTokenNotOfClient, service.RevokeToken(session.Token, ""))
+	})
+
+	it.Run("should introspect jwt with expire time of claims", func(t *testing.T) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			panic(err)
+		}
+		issuer, err := NewJwtIssuer("hallo-test", key)
+		assert.Nil(t, err)
+		service := &TokenService{SessionStore: NewMemorySessionStore(), JwtIssuer: issuer}
+
+		principal := Principal{Id: 701, Name: "nightly-job", Scope: "accounts:read", ServiceAccount: true, Permissions: []string{"accounts:read"}}
+		sc, err := service.Issue(principal)
+		assert.Nil(t, err)
+		introspection, err := service.Introspect(sc.Token, "")
+		assert.Nil(t, err)
+		assert.Equal(t, principal, introspection.Principal)
+		assert.WithinDuration(t, time.Now().Add(issuer.Expiration), introspection.ExpireTime, time.Minute)
+
+		introspection, err = service.Introspect(sc.Token+"bad", "")
+		assert.Nil(t, err)
+		assert.Nil(t, introspection)
+	})
+}
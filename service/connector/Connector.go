@@ -0,0 +1,60 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hallo/domain/entity"
+	"io/ioutil"
+	"os"
+)
+
+// Connector signs in accounts by the upstream identity provider with the authorization code flow
+type Connector interface {
+	Id() string
+	Name() string
+	// AuthCodeURL is the authorization endpoint of provider to which the account is redirected
+	AuthCodeURL(redirectUri, state, codeChallenge string) string
+	// Exchange exchanges the authorization code for the identity of account, ProviderId of identity is Id of connector
+	Exchange(ctx context.Context, redirectUri, code, codeVerifier string) (*entity.ExternalIdentity, error)
+}
+
+// LoadConnectors loads the configs of connectors from the JSON file of IDENTITY_PROVIDERS_FILE,
+// no connector is loaded when it is absent. The ids of connectors are unique and not reserved by domain
+func LoadConnectors() ([]Connector, error) {
+	file := os.Getenv("IDENTITY_PROVIDERS_FILE")
+	if file == "" {
+		return nil, nil
+	}
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var configs []Config
+	if err := json.Unmarshal(content, &configs); err != nil {
+		return nil, fmt.Errorf("bad identity providers file %s: %w", file, err)
+	}
+
+	connectors := make([]Connector, 0, len(configs))
+	for _, config := range configs {
+		if Find(connectors, config.Id) != nil {
+			return nil, fmt.Errorf("duplicate identity provider %s", config.Id)
+		}
+		connector, err := NewOAuth2Connector(context.Background(), config)
+		if err != nil {
+			return nil, fmt.Errorf("bad identity provider %s: %w", config.Id, err)
+		}
+		connectors = append(connectors, connector)
+	}
+	return connectors, nil
+}
+
+// Find return nil when there is no connector of id
+func Find(connectors []Connector, id string) Connector {
+	for _, connector := range connectors {
+		if connector.Id() == id {
+			return connector
+		}
+	}
+	return nil
+}
@@ -0,0 +1,317 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hallo/domain"
+	"hallo/domain/entity"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	TypeOAuth2 = "oauth2"
+	TypeOIDC   = "oidc"
+	TypeGitHub = "github"
+	TypeGoogle = "google"
+	TypeGitLab = "gitlab"
+)
+
+// Config of connector. The presets of Type fill the absent endpoints, scopes and claims,
+// the endpoints of "oidc" are discovered from Issuer. Id is the provider id of identity bindings.
+// EmailsEndpoint lists the emails with their verification, when the user info has no verified email, such as GitHub
+type Config struct {
+	Id                    string       `json:"id"`
+	Type                  string       `json:"type"`
+	Name                  string       `json:"name"`
+	ClientId              string       `json:"clientId"`
+	ClientSecret          string       `json:"clientSecret"`
+	Issuer                string       `json:"issuer"`
+	AuthorizationEndpoint string       `json:"authorizationEndpoint"`
+	TokenEndpoint         string       `json:"tokenEndpoint"`
+	UserInfoEndpoint      string       `json:"userInfoEndpoint"`
+	EmailsEndpoint        string       `json:"emailsEndpoint"`
+	Scopes                []string     `json:"scopes"`
+	Claims                ClaimMapping `json:"claims"`
+}
+
+// ClaimMapping names the claims of user info, EmailVerified is optional
+type ClaimMapping struct {
+	Id            string `json:"id"`
+	Name          string `json:"name"`
+	Email         string `json:"email"`
+	EmailVerified string `json:"emailVerified"`
+}
+
+var oidcClaims = ClaimMapping{Id: "sub", Name: "preferred_username", Email: "email", EmailVerified: "email_verified"}
+
+// OAuth2Connector gets the identity from the user info endpoint with the access token,
+// the ID token of OpenID Connect is not verified, as the user info is responded by provider directly
+type OAuth2Connector struct {
+	Config     Config
+	HttpClient *http.Client
+}
+
+// NewOAuth2Connector applies the preset of config type, and discovers the endpoints of OpenID Connect provider
+func NewOAuth2Connector(ctx context.Context, config Config) (*OAuth2Connector, error) {
+	if domain.IsReservedProviderId(config.Id) {
+		return nil, fmt.Errorf("id %q is reserved", config.Id)
+	}
+	connector := &OAuth2Connector{Config: config, HttpClient: &http.Client{Timeout: 10 * time.Second}}
+	switch config.Type {
+	case TypeGitHub:
+		connector.applyPreset(Config{
+			Name:                  "GitHub",
+			AuthorizationEndpoint: "https://github.com/login/oauth/authorize",
+			TokenEndpoint:         "https://github.com/login/oauth/access_token",
+			UserInfoEndpoint:      "https://api.github.com/user",
+			EmailsEndpoint:        "https://api.github.com/user/emails",
+			Scopes:                []string{"read:user", "user:email"},
+			Claims:                ClaimMapping{Id: "id", Name: "login", Email: "email"},
+		})
+	case TypeGoogle:
+		connector.applyPreset(Config{
+			Name:                  "Google",
+			AuthorizationEndpoint: "https://accounts.google.com/o/oauth2/v2/auth",
+			TokenEndpoint:         "https://oauth2.googleapis.com/token",
+			UserInfoEndpoint:      "https://openidconnect.googleapis.com/v1/userinfo",
+			Scopes:                []string{"openid", "profile", "email"},
+			Claims:                oidcClaims,
+		})
+	case TypeGitLab:
+		// self-managed GitLab is at Issuer
+		issuer := strings.TrimSuffix(config.Issuer, "/")
+		if issuer == "" {
+			issuer = "https://gitlab.com"
+		}
+		connector.applyPreset(Config{
+			Name:                  "GitLab",
+			AuthorizationEndpoint: issuer + "/oauth/authorize",
+			TokenEndpoint:         issuer + "/oauth/token",
+			UserInfoEndpoint:      issuer + "/oauth/userinfo",
+			Scopes:                []string{"openid", "profile", "email"},
+			Claims:                ClaimMapping{Id: "sub", Name: "nickname", Email: "email", EmailVerified: "email_verified"},
+		})
+	case TypeOIDC:
+		if err := connector.discover(ctx); err != nil {
+			return nil, err
+		}
+		connector.applyPreset(Config{Scopes: []string{"openid", "profile", "email"}, Claims: oidcClaims})
+	case TypeOAuth2:
+	default:
+		return nil, fmt.Errorf("unknown type %q of connector", config.Type)
+	}
+
+	c := connector.Config
+	if c.Id == "" || c.ClientId == "" || c.AuthorizationEndpoint == "" || c.TokenEndpoint == "" || c.UserInfoEndpoint == "" {
+		return nil, errors.New("id, clientId and endpoints are required")
+	}
+	if c.Claims.Id == "" || c.Claims.Email == "" {
+		return nil, errors.New("claims of id and email are required")
+	}
+	if c.Name == "" {
+		connector.Config.Name = c.Id
+	}
+	return connector, nil
+}
+
+// applyPreset fills the fields which are absent in config
+func (connector *OAuth2Connector) applyPreset(preset Config) {
+	config := &connector.Config
+	fill := func(field *string, value string) {
+		if *field == "" {
+			*field = value
+		}
+	}
+	fill(&config.Name, preset.Name)
+	fill(&config.AuthorizationEndpoint, preset.AuthorizationEndpoint)
+	fill(&config.TokenEndpoint, preset.TokenEndpoint)
+	fill(&config.UserInfoEndpoint, preset.UserInfoEndpoint)
+	fill(&config.EmailsEndpoint, preset.EmailsEndpoint)
+	fill(&config.Claims.Id, preset.Claims.Id)
+	fill(&config.Claims.Name, preset.Claims.Name)
+	fill(&config.Claims.Email, preset.Claims.Email)
+	fill(&config.Claims.EmailVerified, preset.Claims.EmailVerified)
+	if len(config.Scopes) == 0 {
+		config.Scopes = preset.Scopes
+	}
+}
+
+// discover fills the endpoints by the provider metadata of OpenID Connect Discovery
+func (connector *OAuth2Connector) discover(ctx context.Context) error {
+	if connector.Config.Issuer == "" {
+		return errors.New("issuer is required by oidc connector")
+	}
+	var metadata struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		UserInfoEndpoint      string `json:"userinfo_endpoint"`
+	}
+	discoveryUrl := strings.TrimSuffix(connector.Config.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := connector.getJson(ctx, discoveryUrl, "", &metadata); err != nil {
+		return fmt.Errorf("failed to discover provider: %w", err)
+	}
+	if strings.TrimSuffix(metadata.Issuer, "/") != strings.TrimSuffix(connector.Config.Issuer, "/") {
+		return fmt.Errorf("issuer %s of provider metadata is not match", metadata.Issuer)
+	}
+	connector.applyPreset(Config{AuthorizationEndpoint: metadata.AuthorizationEndpoint,
+		TokenEndpoint: metadata.TokenEndpoint, UserInfoEndpoint: metadata.UserInfoEndpoint})
+	return nil
+}
+
+func (connector *OAuth2Connector) Id() string {
+	return connector.Config.Id
+}
+
+func (connector *OAuth2Connector) Name() string {
+	return connector.Config.Name
+}
+
+// AuthCodeURL requests the code with PKCE, which is ignored by the providers not supporting it
+func (connector *OAuth2Connector) AuthCodeURL(redirectUri, state, codeChallenge string) string {
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {connector.Config.ClientId},
+		"redirect_uri":          {redirectUri},
+		"scope":                 {strings.Join(connector.Config.Scopes, " ")},
+		"state":                 {state},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(connector.Config.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return connector.Config.AuthorizationEndpoint + separator + params.Encode()
+}
+
+func (connector *OAuth2Connector) Exchange(ctx context.Context, redirectUri, code, codeVerifier string) (*entity.ExternalIdentity, error) {
+	accessToken, err := connector.exchangeCode(ctx, redirectUri, code, codeVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	userInfo := map[string]interface{}{}
+	if err := connector.getJson(ctx, connector.Config.UserInfoEndpoint, accessToken, &userInfo); err != nil {
+		return nil, fmt.Errorf("failed to get user info: %w", err)
+	}
+	claims := connector.Config.Claims
+	identity := &entity.ExternalIdentity{
+		ProviderId:        connector.Config.Id,
+		ProviderAccountId: claimString(userInfo[claims.Id]),
+		Name:              claimString(userInfo[claims.Name]),
+		Email:             claimString(userInfo[claims.Email]),
+	}
+	if identity.ProviderAccountId == "" {
+		return nil, fmt.Errorf("claim %s of user info is absent", claims.Id)
+	}
+	if claims.EmailVerified != "" {
+		identity.EmailVerified = claimString(userInfo[claims.EmailVerified]) == "true"
+	}
+
+	if connector.Config.EmailsEndpoint != "" && !identity.EmailVerified {
+		if err := connector.fillVerifiedEmail(ctx, accessToken, identity); err != nil {
+			return nil, err
+		}
+	}
+	return identity, nil
+}
+
+// exchangeCode authenticates the client by client_secret_post, which is supported by most providers
+func (connector *OAuth2Connector) exchangeCode(ctx context.Context, redirectUri, code, codeVerifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectUri},
+		"client_id":     {connector.Config.ClientId},
+		"client_secret": {connector.Config.ClientSecret},
+		"code_verifier": {codeVerifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, connector.Config.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// GitHub responds form encoded body without it
+	req.Header.Set("Accept", "application/json")
+
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := connector.doJson(req, &token); err != nil && token.Error == "" {
+		return "", fmt.Errorf("failed to exchange code: %w", err)
+	}
+	if token.Error != "" {
+		return "", fmt.Errorf("failed to exchange code: %s %s", token.Error, token.ErrorDescription)
+	}
+	if token.AccessToken == "" {
+		return "", errors.New("failed to exchange code: access token is absent")
+	}
+	return token.AccessToken, nil
+}
+
+// fillVerifiedEmail prefers the primary email, the emails not verified are ignored
+func (connector *OAuth2Connector) fillVerifiedEmail(ctx context.Context, accessToken string, identity *entity.ExternalIdentity) error {
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := connector.getJson(ctx, connector.Config.EmailsEndpoint, accessToken, &emails); err != nil {
+		return fmt.Errorf("failed to get emails: %w", err)
+	}
+	for _, email := range emails {
+		if email.Verified && (email.Primary || !identity.EmailVerified) {
+			identity.Email, identity.EmailVerified = email.Email, true
+		}
+	}
+	return nil
+}
+
+func (connector *OAuth2Connector) getJson(ctx context.Context, url, accessToken string, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	return connector.doJson(req, result)
+}
+
+// doJson decodes the body even if the status is not OK, so that the OAuth2 error is kept
+func (connector *OAuth2Connector) doJson(req *http.Request, result interface{}) error {
+	resp, err := connector.HttpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	// the numeric ids are kept as they are
+	decoder.UseNumber()
+	decodeErr := decoder.Decode(result)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d of %s", resp.StatusCode, req.URL.Path)
+	}
+	return decodeErr
+}
+
+func claimString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
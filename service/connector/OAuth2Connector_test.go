@@ -0,0 +1,163 @@
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"hallo/domain"
+	"hallo/domain/entity"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+)
+
+// fakeProvider is a local identity provider which accepts the code "good-code" with the verifier "good-verifier"
+func fakeProvider(t *testing.T, userInfo map[string]interface{}, emails []map[string]interface{}) *httptest.Server {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	writeJson := func(w http.ResponseWriter, status int, body interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(body)
+	}
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJson(w, http.StatusOK, map[string]string{
+			"issuer":                 server.URL,
+			"authorization_endpoint": server.URL + "/authorize",
+			"token_endpoint":         server.URL + "/token",
+			"userinfo_endpoint":      server.URL + "/userinfo",
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		assert.Nil(t, r.ParseForm())
+		assert.Equal(t, "application/json", r.Header.Get("Accept"))
+		if r.PostForm.Get("code") != "good-code" || r.PostForm.Get("code_verifier") != "good-verifier" ||
+			r.PostForm.Get("client_id") != "hallo" || r.PostForm.Get("client_secret") != "secret" {
+			writeJson(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+			return
+		}
+		writeJson(w, http.StatusOK, map[string]string{"access_token": "good-token", "token_type": "Bearer"})
+	})
+	authorized := func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("Authorization") != "Bearer good-token" {
+			writeJson(w, http.StatusUnauthorized, map[string]string{"error": "invalid_token"})
+			return false
+		}
+		return true
+	}
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if authorized(w, r) {
+			writeJson(w, http.StatusOK, userInfo)
+		}
+	})
+	mux.HandleFunc("/emails", func(w http.ResponseWriter, r *http.Request) {
+		if authorized(w, r) {
+			writeJson(w, http.StatusOK, emails)
+		}
+	})
+	return server
+}
+
+func TestOAuth2Connector(it *testing.T) {
+	it.Run("should discover oidc provider and exchange code for identity", func(t *testing.T) {
+		provider := fakeProvider(t, map[string]interface{}{"sub": "u-1", "preferred_username": "ann",
+			"email": "ann@test.fundwit.com", "email_verified": true}, nil)
+		defer provider.Close()
+
+		connector, err := NewOAuth2Connector(context.Background(), Config{Id: "corp", Type: TypeOIDC,
+			ClientId: "hallo", ClientSecret: "secret", Issuer: provider.URL})
+		assert.Nil(t, err)
+		assert.Equal(t, "corp", connector.Id())
+		assert.Equal(t, "corp", connector.Name())
+
+		authCodeUrl, err := url.Parse(connector.AuthCodeURL("http://hallo/callback", "state-1", "challenge-1"))
+		assert.Nil(t, err)
+		assert.Equal(t, provider.URL+"/authorize", authCodeUrl.Scheme+"://"+authCodeUrl.Host+authCodeUrl.Path)
+		query := authCodeUrl.Query()
+		assert.Equal(t, "code", query.Get("response_type"))
+		assert.Equal(t, "hallo", query.Get("client_id"))
+		assert.Equal(t, "http://hallo/callback", query.Get("redirect_uri"))
+		assert.Equal(t, "openid profile email", query.Get("scope"))
+		assert.Equal(t, "state-1", query.Get("state"))
+		assert.Equal(t, "challenge-1", query.Get("code_challenge"))
+		assert.Equal(t, "S256", query.Get("code_challenge_method"))
+
+		identity, err := connector.Exchange(context.Background(), "http://hallo/callback", "good-code", "good-verifier")
+		assert.Nil(t, err)
+		assert.Equal(t, entity.ExternalIdentity{ProviderId: "corp", ProviderAccountId: "u-1", Name: "ann",
+			Email: "ann@test.fundwit.com", EmailVerified: true}, *identity)
+
+		_, err = connector.Exchange(context.Background(), "http://hallo/callback", "bad-code", "good-verifier")
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "invalid_grant")
+	})
+
+	it.Run("should take primary verified email from emails endpoint as github does", func(t *testing.T) {
+		provider := fakeProvider(t, map[string]interface{}{"id": 583231, "login": "octocat", "email": nil},
+			[]map[string]interface{}{
+				{"email": "unverified@test.fundwit.com", "primary": false, "verified": false},
+				{"email": "secondary@test.fundwit.com", "primary": false, "verified": true},
+				{"email": "octocat@test.fundwit.com", "primary": true, "verified": true},
+			})
+		defer provider.Close()
+
+		connector, err := NewOAuth2Connector(context.Background(), Config{Id: "github", Type: TypeGitHub,
+			ClientId: "hallo", ClientSecret: "secret", AuthorizationEndpoint: provider.URL + "/authorize",
+			TokenEndpoint: provider.URL + "/token", UserInfoEndpoint: provider.URL + "/userinfo",
+			EmailsEndpoint: provider.URL + "/emails"})
+		assert.Nil(t, err)
+		assert.Equal(t, "GitHub", connector.Name())
+
+		identity, err := connector.Exchange(context.Background(), "http://hallo/callback", "good-code", "good-verifier")
+		assert.Nil(t, err)
+		assert.Equal(t, entity.ExternalIdentity{ProviderId: "github", ProviderAccountId: "583231", Name: "octocat",
+			Email: "octocat@test.fundwit.com", EmailVerified: true}, *identity)
+	})
+
+	it.Run("should reject invalid config", func(t *testing.T) {
+		_, err := NewOAuth2Connector(context.Background(), Config{Id: "x", Type: "unknown", ClientId: "hallo"})
+		assert.NotNil(t, err)
+
+		_, err = NewOAuth2Connector(context.Background(), Config{Id: "x", Type: TypeOAuth2, ClientId: "hallo"})
+		assert.NotNil(t, err)
+
+		_, err = NewOAuth2Connector(context.Background(), Config{Id: "x", Type: TypeOIDC, ClientId: "hallo"})
+		assert.NotNil(t, err)
+
+		connector, err := NewOAuth2Connector(context.Background(), Config{Id: "gitlab", Type: TypeGitLab, ClientId: "hallo"})
+		assert.Nil(t, err)
+		assert.Equal(t, "https://gitlab.com/oauth/token", connector.Config.TokenEndpoint)
+
+		// the identities of reserved providers are bound to the accounts of hallo itself
+		for _, id := range []string{domain.InternalProviderId, domain.LdapProviderId} {
+			_, err = NewOAuth2Connector(context.Background(), Config{Id: id, Type: TypeGitLab, ClientId: "hallo"})
+			assert.NotNil(t, err)
+		}
+	})
+}
+
+func TestLoadConnectors(it *testing.T) {
+	it.Run("should reject duplicate ids of connectors", func(t *testing.T) {
+		file, err := ioutil.TempFile("", "identity-providers-*.json")
+		assert.Nil(t, err)
+		defer os.Remove(file.Name())
+		_, err = file.WriteString(`[{"id": "gitlab", "type": "gitlab", "clientId": "hallo"},
+			{"id": "corp", "type": "gitlab", "clientId": "hallo", "issuer": "https://gitlab.corp.test"}]`)
+		assert.Nil(t, err)
+		assert.Nil(t, file.Close())
+		assert.Nil(t, os.Setenv("IDENTITY_PROVIDERS_FILE", file.Name()))
+		defer os.Unsetenv("IDENTITY_PROVIDERS_FILE")
+
+		connectors, err := LoadConnectors()
+		assert.Nil(t, err)
+		assert.Len(t, connectors, 2)
+
+		assert.Nil(t, ioutil.WriteFile(file.Name(), []byte(`[{"id": "gitlab", "type": "gitlab", "clientId": "hallo"},
+			{"id": "gitlab", "type": "gitlab", "clientId": "other"}]`), 0600))
+		_, err = LoadConnectors()
+		assert.NotNil(t, err)
+	})
+}
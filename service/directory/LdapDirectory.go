@@ -0,0 +1,150 @@
+package directory
+
+import (
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/go-ldap/ldap/v3"
+	"hallo/domain"
+	"hallo/domain/entity"
+	"net/url"
+	"os"
+	"time"
+	"unicode/utf8"
+)
+
+// LdapDirectory searches the entry of account by UserAttribute with the service account of BindDN, and then binds as the
+// entry with the secret of account. The search is anonymous when BindDN is empty.
+// The attributes of identity are IdAttribute (default UserAttribute), NameAttribute (default UserAttribute) and EmailAttribute,
+// IdAttribute should be immutable, e.g. entryUUID of OpenLDAP or objectGUID of Active Directory
+type LdapDirectory struct {
+	Url            string
+	StartTLS       bool
+	BindDN         string
+	BindPassword   string
+	BaseDN         string
+	UserFilter     string
+	UserAttribute  string
+	IdAttribute    string
+	NameAttribute  string
+	EmailAttribute string
+	Timeout        time.Duration
+}
+
+// LoadLdapDirectory creates directory by environment variables, nil when LDAP_URL is absent:
+// LDAP_URL (ldap:// or ldaps://), LDAP_START_TLS (true or false), LDAP_BIND_DN, LDAP_BIND_PASSWORD, LDAP_BASE_DN,
+// LDAP_USER_FILTER (default "(objectClass=person)"), LDAP_USER_ATTRIBUTE (default "uid", "sAMAccountName" for Active Directory),
+// LDAP_ID_ATTRIBUTE, LDAP_NAME_ATTRIBUTE, LDAP_EMAIL_ATTRIBUTE (default "mail")
+func LoadLdapDirectory() (*LdapDirectory, error) {
+	ldapUrl := os.Getenv("LDAP_URL")
+	if ldapUrl == "" {
+		return nil, nil
+	}
+	directory := &LdapDirectory{
+		Url:            ldapUrl,
+		StartTLS:       os.Getenv("LDAP_START_TLS") == "true",
+		BindDN:         os.Getenv("LDAP_BIND_DN"),
+		BindPassword:   os.Getenv("LDAP_BIND_PASSWORD"),
+		BaseDN:         os.Getenv("LDAP_BASE_DN"),
+		UserFilter:     os.Getenv("LDAP_USER_FILTER"),
+		UserAttribute:  os.Getenv("LDAP_USER_ATTRIBUTE"),
+		IdAttribute:    os.Getenv("LDAP_ID_ATTRIBUTE"),
+		NameAttribute:  os.Getenv("LDAP_NAME_ATTRIBUTE"),
+		EmailAttribute: os.Getenv("LDAP_EMAIL_ATTRIBUTE"),
+	}
+	if directory.BaseDN == "" {
+		return nil, errors.New("LDAP_BASE_DN is required")
+	}
+	return directory, nil
+}
+
+func (directory *LdapDirectory) Authenticate(accountName, secret string) (*entity.ExternalIdentity, error) {
+	// the bind without password is an unauthenticated bind, which succeeds without checking anything
+	if accountName == "" || secret == "" {
+		return nil, &domain.AccountAuthenticationFailure{}
+	}
+
+	conn, err := ldap.DialURL(directory.Url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect ldap server: %w", err)
+	}
+	defer conn.Close()
+	conn.SetTimeout(directory.timeout())
+	if directory.StartTLS {
+		host, err := hostOf(directory.Url)
+		if err != nil {
+			return nil, err
+		}
+		if err := conn.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return nil, fmt.Errorf("failed to start tls: %w", err)
+		}
+	}
+	if directory.BindDN != "" {
+		if err := conn.Bind(directory.BindDN, directory.BindPassword); err != nil {
+			return nil, fmt.Errorf("failed to bind service account: %w", err)
+		}
+	}
+
+	userAttribute := orDefault(directory.UserAttribute, "uid")
+	idAttribute := orDefault(directory.IdAttribute, userAttribute)
+	nameAttribute := orDefault(directory.NameAttribute, userAttribute)
+	emailAttribute := orDefault(directory.EmailAttribute, "mail")
+	filter := fmt.Sprintf("(&%s(%s=%s))", orDefault(directory.UserFilter, "(objectClass=person)"),
+		userAttribute, ldap.EscapeFilter(accountName))
+	// the size limit 2 finds out the ambiguous account name
+	result, err := conn.Search(ldap.NewSearchRequest(directory.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		2, int(directory.timeout().Seconds()), false, filter, []string{idAttribute, nameAttribute, emailAttribute}, nil))
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return nil, fmt.Errorf("failed to search account: %w", err)
+	}
+	if result == nil || len(result.Entries) != 1 {
+		return nil, &domain.AccountAuthenticationFailure{}
+	}
+	entry := result.Entries[0]
+
+	if err := conn.Bind(entry.DN, secret); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, &domain.AccountAuthenticationFailure{}
+		}
+		return nil, fmt.Errorf("failed to bind account: %w", err)
+	}
+
+	return &entity.ExternalIdentity{
+		ProviderId:        domain.LdapProviderId,
+		ProviderAccountId: attributeString(entry.GetRawAttributeValue(idAttribute)),
+		Name:              entry.GetAttributeValue(nameAttribute),
+		Email:             entry.GetAttributeValue(emailAttribute),
+		EmailVerified:     true,
+	}, nil
+}
+
+func (directory *LdapDirectory) timeout() time.Duration {
+	if directory.Timeout > 0 {
+		return directory.Timeout
+	}
+	return 10 * time.Second
+}
+
+func hostOf(rawUrl string) (string, error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return "", err
+	}
+	return u.Hostname(), nil
+}
+
+// attributeString encodes the binary values such as objectGUID in hex
+func attributeString(value []byte) string {
+	if utf8.Valid(value) {
+		return string(value)
+	}
+	return hex.EncodeToString(value)
+}
+
+func orDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
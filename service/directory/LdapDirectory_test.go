@@ -0,0 +1,108 @@
+package directory
+
+import (
+	"github.com/lor00x/goldap/message"
+	"github.com/stretchr/testify/assert"
+	ldapserver "github.com/vjeantet/ldapserver"
+	"hallo/domain"
+	"hallo/domain/entity"
+	"io/ioutil"
+	"log"
+	"net"
+	"testing"
+	"time"
+)
+
+type testEntry struct {
+	dn, uid, entryUUID, cn, mail, password string
+}
+
+// startTestServer serves a directory of entries, the service account is "cn=admin,dc=fundwit,dc=com" with password "admin"
+func startTestServer(t *testing.T, entries ...testEntry) (string, func()) {
+	ldapserver.Logger = log.New(ioutil.Discard, "", 0)
+	routes := ldapserver.NewRouteMux()
+	routes.Bind(func(w ldapserver.ResponseWriter, m *ldapserver.Message) {
+		r := m.GetBindRequest()
+		name, password := string(r.Name()), string(r.AuthenticationSimple())
+		if name == "cn=admin,dc=fundwit,dc=com" && password == "admin" {
+			w.Write(ldapserver.NewBindResponse(ldapserver.LDAPResultSuccess))
+			return
+		}
+		for _, entry := range entries {
+			if entry.dn == name && entry.password == password {
+				w.Write(ldapserver.NewBindResponse(ldapserver.LDAPResultSuccess))
+				return
+			}
+		}
+		w.Write(ldapserver.NewBindResponse(ldapserver.LDAPResultInvalidCredentials))
+	})
+	routes.Search(func(w ldapserver.ResponseWriter, m *ldapserver.Message) {
+		r := m.GetSearchRequest()
+		assert.Equal(t, "ou=people,dc=fundwit,dc=com", string(r.BaseObject()))
+		for _, entry := range entries {
+			if r.FilterString() == "(&(objectClass=person)(uid="+entry.uid+"))" {
+				e := ldapserver.NewSearchResultEntry(entry.dn)
+				e.AddAttribute("uid", message.AttributeValue(entry.uid))
+				e.AddAttribute("entryUUID", message.AttributeValue(entry.entryUUID))
+				e.AddAttribute("cn", message.AttributeValue(entry.cn))
+				e.AddAttribute("mail", message.AttributeValue(entry.mail))
+				w.Write(e)
+			}
+		}
+		w.Write(ldapserver.NewSearchResultDoneResponse(ldapserver.LDAPResultSuccess))
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	address := listener.Addr().String()
+	assert.Nil(t, listener.Close())
+
+	server := ldapserver.NewServer()
+	server.Handle(routes)
+	go func() { _ = server.ListenAndServe(address) }()
+	assert.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", address)
+		if err == nil {
+			_ = conn.Close()
+		}
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	return "ldap://" + address, server.Stop
+}
+
+func TestLdapDirectory_Authenticate(it *testing.T) {
+	it.Run("should search account by attribute and bind as it", func(t *testing.T) {
+		url, stop := startTestServer(t,
+			testEntry{dn: "uid=ann,ou=people,dc=fundwit,dc=com", uid: "ann", entryUUID: "uuid-ann", cn: "Ann",
+				mail: "ann@test.fundwit.com", password: "ann-secret"},
+			testEntry{dn: "uid=twin,ou=a,ou=people,dc=fundwit,dc=com", uid: "twin", password: "twin-secret"},
+			testEntry{dn: "uid=twin,ou=b,ou=people,dc=fundwit,dc=com", uid: "twin", password: "twin-secret"},
+		)
+		defer stop()
+
+		directory := &LdapDirectory{Url: url, BindDN: "cn=admin,dc=fundwit,dc=com", BindPassword: "admin",
+			BaseDN: "ou=people,dc=fundwit,dc=com", IdAttribute: "entryUUID", NameAttribute: "cn", Timeout: 5 * time.Second}
+
+		identity, err := directory.Authenticate("ann", "ann-secret")
+		assert.Nil(t, err)
+		assert.Equal(t, entity.ExternalIdentity{ProviderId: domain.LdapProviderId, ProviderAccountId: "uuid-ann", Name: "Ann",
+			Email: "ann@test.fundwit.com", EmailVerified: true}, *identity)
+
+		_, err = directory.Authenticate("ann", "bad-secret")
+		assert.Equal(t, &domain.AccountAuthenticationFailure{}, err)
+		_, err = directory.Authenticate("ann", "")
+		assert.Equal(t, &domain.AccountAuthenticationFailure{}, err)
+		_, err = directory.Authenticate("bob", "ann-secret")
+		assert.Equal(t, &domain.AccountAuthenticationFailure{}, err)
+		_, err = directory.Authenticate("twin", "twin-secret")
+		assert.Equal(t, &domain.AccountAuthenticationFailure{}, err)
+		// the account name is escaped in filter
+		_, err = directory.Authenticate("ann)(uid=*", "ann-secret")
+		assert.Equal(t, &domain.AccountAuthenticationFailure{}, err)
+
+		directory.BindPassword = "bad"
+		_, err = directory.Authenticate("ann", "ann-secret")
+		assert.NotNil(t, err)
+		assert.NotEqual(t, &domain.AccountAuthenticationFailure{}, err)
+	})
+}
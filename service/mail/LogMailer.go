@@ -0,0 +1,32 @@
+package mail
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"time"
+)
+
+const logMailerFrom = "hallo@localhost"
+
+// LogMailer is for development, messages are written into Directory as .eml files, or printed to log when Directory is empty
+type LogMailer struct {
+	Directory string
+}
+
+func (mailer *LogMailer) Send(message *Message) error {
+	content, err := message.Bytes(logMailerFrom)
+	if err != nil {
+		return err
+	}
+
+	if mailer.Directory == "" {
+		log.Printf("[MAIL] %s\n", string(content))
+		return nil
+	}
+
+	file := filepath.Join(mailer.Directory, fmt.Sprintf("%d.eml", time.Now().UnixNano()))
+	log.Printf("[MAIL] message to %v is written into %s\n", message.To, file)
+	return ioutil.WriteFile(file, content, 0644)
+}
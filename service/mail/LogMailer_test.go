@@ -0,0 +1,36 @@
+package mail
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLogMailer_Send(it *testing.T) {
+	it.Run("should write message into directory", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "mails")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		mailer := &LogMailer{Directory: dir}
+		err = mailer.Send(&Message{To: []string{"someone@test.fundwit.com"}, Subject: "test", Text: "hello"})
+		assert.Nil(t, err)
+
+		files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+		assert.Nil(t, err)
+		assert.Len(t, files, 1)
+		content, err := ioutil.ReadFile(files[0])
+		assert.Nil(t, err)
+		assert.Contains(t, string(content), "To: someone@test.fundwit.com\r\n")
+		assert.Contains(t, string(content), "hello")
+	})
+
+	it.Run("should print message to log when directory is not set", func(t *testing.T) {
+		mailer := &LogMailer{}
+		assert.Nil(t, mailer.Send(&Message{To: []string{"someone@test.fundwit.com"}, Subject: "test", Text: "hello"}))
+	})
+}
@@ -0,0 +1,85 @@
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"os"
+	"strings"
+	"time"
+)
+
+type Message struct {
+	To      []string
+	Subject string
+	Text    string
+	Html    string
+}
+
+type Mailer interface {
+	Send(message *Message) error
+}
+
+// LoadMailer creates mailer by environment variables, SmtpMailer when MAIL_SMTP_HOST is configured, otherwise LogMailer:
+// MAIL_SMTP_HOST, MAIL_SMTP_PORT (default 25), MAIL_SMTP_USERNAME, MAIL_SMTP_PASSWORD, MAIL_FROM: for SmtpMailer
+// MAIL_OUTPUT_DIR: for LogMailer, messages are written into the directory as .eml files instead of logs
+func LoadMailer() Mailer {
+	host := os.Getenv("MAIL_SMTP_HOST")
+	if host == "" {
+		return &LogMailer{Directory: os.Getenv("MAIL_OUTPUT_DIR")}
+	}
+	port := os.Getenv("MAIL_SMTP_PORT")
+	if port == "" {
+		port = "25"
+	}
+	return &SmtpMailer{
+		Address:  host + ":" + port,
+		Username: os.Getenv("MAIL_SMTP_USERNAME"),
+		Password: os.Getenv("MAIL_SMTP_PASSWORD"),
+		From:     os.Getenv("MAIL_FROM"),
+	}
+}
+
+// Bytes encodes the message as a multipart/alternative MIME message
+func (message *Message) Bytes(from string) ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	body := multipart.NewWriter(buffer)
+
+	fmt.Fprintf(buffer, "From: %s\r\n", from)
+	fmt.Fprintf(buffer, "To: %s\r\n", strings.Join(message.To, ", "))
+	fmt.Fprintf(buffer, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", message.Subject))
+	fmt.Fprintf(buffer, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(buffer, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(buffer, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", body.Boundary())
+
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=UTF-8", message.Text},
+		{"text/html; charset=UTF-8", message.Html},
+	} {
+		if part.content == "" {
+			continue
+		}
+		writer, err := body.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		encoder := quotedprintable.NewWriter(writer)
+		if _, err := encoder.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := body.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
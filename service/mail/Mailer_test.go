@@ -0,0 +1,21 @@
+package mail
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestLoadMailer(it *testing.T) {
+	it.Run("should load mailer by environment variables", func(t *testing.T) {
+		os.Setenv("MAIL_OUTPUT_DIR", "/tmp/mails")
+		defer os.Unsetenv("MAIL_OUTPUT_DIR")
+		assert.Equal(t, &LogMailer{Directory: "/tmp/mails"}, LoadMailer())
+
+		os.Setenv("MAIL_SMTP_HOST", "smtp.test.fundwit.com")
+		os.Setenv("MAIL_FROM", "hallo@test.fundwit.com")
+		defer os.Unsetenv("MAIL_SMTP_HOST")
+		defer os.Unsetenv("MAIL_FROM")
+		assert.Equal(t, &SmtpMailer{Address: "smtp.test.fundwit.com:25", From: "hallo@test.fundwit.com"}, LoadMailer())
+	})
+}
@@ -0,0 +1,36 @@
+package mail
+
+import (
+	"errors"
+	"net"
+	"net/smtp"
+)
+
+// SmtpMailer sends messages by the SMTP server on Address (host:port), STARTTLS is used when the server supports it.
+// Authentication is skipped when Username is empty.
+type SmtpMailer struct {
+	Address  string
+	Username string
+	Password string
+	From     string
+}
+
+func (mailer *SmtpMailer) Send(message *Message) error {
+	if len(message.To) == 0 {
+		return errors.New("no recipient")
+	}
+	content, err := message.Bytes(mailer.From)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if mailer.Username != "" {
+		host, _, err := net.SplitHostPort(mailer.Address)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", mailer.Username, mailer.Password, host)
+	}
+	return smtp.SendMail(mailer.Address, auth, mailer.From, message.To, content)
+}
@@ -0,0 +1,108 @@
+package mail
+
+import (
+	"bufio"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"strings"
+	"testing"
+)
+
+// smtpStandIn accepts one SMTP session and records the envelope and data
+type smtpStandIn struct {
+	listener   net.Listener
+	from       string
+	recipients []string
+	data       string
+	done       chan struct{}
+}
+
+func startSmtpStandIn(t *testing.T) *smtpStandIn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &smtpStandIn{listener: listener, done: make(chan struct{})}
+	go server.serve()
+	return server
+}
+
+func (server *smtpStandIn) serve() {
+	defer close(server.done)
+	conn, err := server.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+	reply("220 localhost ESMTP stand-in")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			server.from = strings.Trim(line[len("MAIL FROM:"):], "<>")
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			server.recipients = append(server.recipients, strings.Trim(line[len("RCPT TO:"):], "<>"))
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 end data with <CR><LF>.<CR><LF>")
+			data := strings.Builder{}
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(dataLine)
+			}
+			server.data = data.String()
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestSmtpMailer_Send(it *testing.T) {
+	it.Run("should send multipart message to smtp server", func(t *testing.T) {
+		server := startSmtpStandIn(t)
+		defer server.listener.Close()
+
+		mailer := &SmtpMailer{Address: server.listener.Addr().String(), From: "hallo@test.fundwit.com"}
+		message, err := RegisterTokenMessage("someone@test.fundwit.com", "token-123")
+		assert.Nil(t, err)
+
+		err = mailer.Send(message)
+		assert.Nil(t, err)
+		<-server.done
+
+		assert.Equal(t, "hallo@test.fundwit.com", server.from)
+		assert.Equal(t, []string{"someone@test.fundwit.com"}, server.recipients)
+		assert.Contains(t, server.data, "To: someone@test.fundwit.com\r\n")
+		assert.Contains(t, server.data, "Content-Type: multipart/alternative; boundary=")
+		assert.Contains(t, server.data, "Content-Type: text/plain; charset=UTF-8")
+		assert.Contains(t, server.data, "Content-Type: text/html; charset=UTF-8")
+		assert.Contains(t, server.data, "token-123")
+	})
+
+	it.Run("should fail when no recipient", func(t *testing.T) {
+		mailer := &SmtpMailer{Address: "127.0.0.1:25"}
+		err := mailer.Send(&Message{Subject: "test"})
+		assert.EqualError(t, err, "no recipient")
+	})
+}
@@ -0,0 +1,75 @@
+package mail
+
+import (
+	"bytes"
+	htmlTemplate "html/template"
+	textTemplate "text/template"
+)
+
+type messageTemplate struct {
+	subject string
+	text    *textTemplate.Template
+	html    *htmlTemplate.Template
+}
+
+func (t *messageTemplate) render(to string, data interface{}) (*Message, error) {
+	text := &bytes.Buffer{}
+	if err := t.text.Execute(text, data); err != nil {
+		return nil, err
+	}
+	html := &bytes.Buffer{}
+	if err := t.html.Execute(html, data); err != nil {
+		return nil, err
+	}
+	return &Message{To: []string{to}, Subject: t.subject, Text: text.String(), Html: html.String()}, nil
+}
+
+var registerTokenTemplate = &messageTemplate{
+	subject: "Your hallo register token",
+	text: textTemplate.Must(textTemplate.New("register_token.txt").Parse(`Hello,
+
+Use the token below to finish the registration of {{.Email}}:
+
+    {{.Token}}
+
+The token expires in 30 minutes. If you did not request it, please ignore this message.
+`)),
+	html: htmlTemplate.Must(htmlTemplate.New("register_token.html").Parse(`<html>
+<body>
+<p>Hello,</p>
+<p>Use the token below to finish the registration of {{.Email}}:</p>
+<p><strong>{{.Token}}</strong></p>
+<p>The token expires in 30 minutes. If you did not request it, please ignore this message.</p>
+</body>
+</html>
+`)),
+}
+
+var passwordResetTemplate = &messageTemplate{
+	subject: "Reset your hallo secret",
+	text: textTemplate.Must(textTemplate.New("password_reset.txt").Parse(`Hello {{.Name}},
+
+Use the token below to set a new secret of your account:
+
+    {{.Token}}
+
+The token can be used only once and expires in 30 minutes. If you did not request it, please ignore this message.
+`)),
+	html: htmlTemplate.Must(htmlTemplate.New("password_reset.html").Parse(`<html>
+<body>
+<p>Hello {{.Name}},</p>
+<p>Use the token below to set a new secret of your account:</p>
+<p><strong>{{.Token}}</strong></p>
+<p>The token can be used only once and expires in 30 minutes. If you did not request it, please ignore this message.</p>
+</body>
+</html>
+`)),
+}
+
+func RegisterTokenMessage(email, token string) (*Message, error) {
+	return registerTokenTemplate.render(email, struct{ Email, Token string }{Email: email, Token: token})
+}
+
+func PasswordResetMessage(email, name, token string) (*Message, error) {
+	return passwordResetTemplate.render(email, struct{ Name, Token string }{Name: name, Token: token})
+}
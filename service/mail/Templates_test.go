@@ -0,0 +1,33 @@
+package mail
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRegisterTokenMessage(it *testing.T) {
+	it.Run("should render text and html of register token message", func(t *testing.T) {
+		message, err := RegisterTokenMessage("<someone>@test.fundwit.com", "token-123")
+		assert.Nil(t, err)
+
+		assert.Equal(t, []string{"<someone>@test.fundwit.com"}, message.To)
+		assert.Equal(t, "Your hallo register token", message.Subject)
+		assert.Contains(t, message.Text, "registration of <someone>@test.fundwit.com")
+		assert.Contains(t, message.Text, "token-123")
+		assert.Contains(t, message.Html, "registration of &lt;someone&gt;@test.fundwit.com")
+		assert.Contains(t, message.Html, "<strong>token-123</strong>")
+	})
+}
+
+func TestPasswordResetMessage(it *testing.T) {
+	it.Run("should render text and html of password reset message", func(t *testing.T) {
+		message, err := PasswordResetMessage("someone@test.fundwit.com", "ann", "token-123")
+		assert.Nil(t, err)
+
+		assert.Equal(t, []string{"someone@test.fundwit.com"}, message.To)
+		assert.Equal(t, "Reset your hallo secret", message.Subject)
+		assert.Contains(t, message.Text, "Hello ann,")
+		assert.Contains(t, message.Text, "token-123")
+		assert.Contains(t, message.Html, "<strong>token-123</strong>")
+	})
+}
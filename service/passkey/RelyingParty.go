@@ -0,0 +1,219 @@
+package passkey
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/duo-labs/webauthn/protocol"
+	"github.com/duo-labs/webauthn/webauthn"
+	"hallo/domain/entity"
+	"os"
+	"time"
+)
+
+const DefaultName = "hallo"
+
+// Config of WebAuthn relying party, Id is the domain of Origin or its registrable suffix,
+// e.g. "example.com" for "https://login.example.com". Name is shown by authenticators, DefaultName is used when empty
+type Config struct {
+	Id     string
+	Name   string
+	Origin string
+}
+
+// RelyingParty runs the WebAuthn ceremonies of passkeys, user verification is required in both ceremonies so that
+// a passkey is sufficient to sign in
+type RelyingParty struct {
+	WebAuthn *webauthn.WebAuthn
+}
+
+var ErrCloneWarning = errors.New("sign count of authenticator is not increased, the credential may be cloned")
+
+// LoadRelyingParty loads the config from WEBAUTHN_RP_ID, WEBAUTHN_RP_NAME and WEBAUTHN_RP_ORIGIN,
+// passkeys are disabled when WEBAUTHN_RP_ID is absent
+func LoadRelyingParty() (*RelyingParty, error) {
+	id := os.Getenv("WEBAUTHN_RP_ID")
+	if id == "" {
+		return nil, nil
+	}
+	return NewRelyingParty(Config{Id: id, Name: os.Getenv("WEBAUTHN_RP_NAME"), Origin: os.Getenv("WEBAUTHN_RP_ORIGIN")})
+}
+
+func NewRelyingParty(config Config) (*RelyingParty, error) {
+	if config.Id == "" || config.Origin == "" {
+		return nil, errors.New("id and origin of relying party are required")
+	}
+	name := config.Name
+	if name == "" {
+		name = DefaultName
+	}
+	webAuthn, err := webauthn.New(&webauthn.Config{
+		RPID:          config.Id,
+		RPDisplayName: name,
+		RPOrigin:      config.Origin,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			RequireResidentKey: protocol.ResidentKeyRequired(),
+			ResidentKey:        protocol.ResidentKeyRequirementRequired,
+			UserVerification:   protocol.VerificationRequired,
+		},
+		AttestationPreference: protocol.PreferNoAttestation,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &RelyingParty{WebAuthn: webAuthn}, nil
+}
+
+// BeginRegistration returns the options of navigator.credentials.create(), the registered credentials of account
+// are excluded so that an authenticator is registered once
+func (rp *RelyingParty) BeginRegistration(account *entity.Account, credentials []entity.WebAuthnCredential) (
+	*protocol.CredentialCreation, *webauthn.SessionData, error) {
+	user, err := newUser(account, credentials)
+	if err != nil {
+		return nil, nil, err
+	}
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(user.credentials))
+	for _, credential := range user.credentials {
+		exclusions = append(exclusions, protocol.CredentialDescriptor{Type: protocol.PublicKeyCredentialType, CredentialID: credential.ID})
+	}
+	return rp.WebAuthn.BeginRegistration(user, webauthn.WithExclusions(exclusions))
+}
+
+// FinishRegistration verifies the response of navigator.credentials.create() to the ceremony of session
+func (rp *RelyingParty) FinishRegistration(account *entity.Account, session webauthn.SessionData,
+	response *protocol.ParsedCredentialCreationData, name string) (*entity.WebAuthnCredential, error) {
+	user, err := newUser(account, nil)
+	if err != nil {
+		return nil, err
+	}
+	credential, err := rp.WebAuthn.CreateCredential(user, session, response)
+	if err != nil {
+		return nil, describe(err)
+	}
+	return &entity.WebAuthnCredential{
+		Id:              CredentialKey(credential.ID),
+		AccountId:       account.Id,
+		Name:            name,
+		CredentialId:    base64.RawURLEncoding.EncodeToString(credential.ID),
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		AAGUID:          hex.EncodeToString(credential.Authenticator.AAGUID),
+		SignCount:       credential.Authenticator.SignCount,
+		CreateTime:      time.Now(),
+	}, nil
+}
+
+// BeginLogin returns the options of navigator.credentials.get() without allowed credentials,
+// the account is identified by the discoverable credential chosen on authenticator
+func (rp *RelyingParty) BeginLogin() (*protocol.CredentialAssertion, *webauthn.SessionData, error) {
+	challenge, err := protocol.CreateChallenge()
+	if err != nil {
+		return nil, nil, err
+	}
+	options := protocol.PublicKeyCredentialRequestOptions{
+		Challenge:        challenge,
+		Timeout:          rp.WebAuthn.Config.Timeout,
+		RelyingPartyID:   rp.WebAuthn.Config.RPID,
+		UserVerification: protocol.VerificationRequired,
+	}
+	session := &webauthn.SessionData{Challenge: challenge.String(), UserVerification: options.UserVerification}
+	return &protocol.CredentialAssertion{Response: options}, session, nil
+}
+
+// FinishLogin verifies the response of navigator.credentials.get() by the credential of account, and returns
+// the sign count which is stored for the next assertion. ErrCloneWarning is returned when the sign count is
+// not increased
+func (rp *RelyingParty) FinishLogin(account *entity.Account, credential *entity.WebAuthnCredential,
+	session webauthn.SessionData, response *protocol.ParsedCredentialAssertionData) (uint32, error) {
+	user, err := newUser(account, []entity.WebAuthnCredential{*credential})
+	if err != nil {
+		return 0, err
+	}
+	if !bytes.Equal(response.Response.UserHandle, user.WebAuthnID()) {
+		return 0, errors.New("user handle of assertion is not the account of credential")
+	}
+	session.UserID = user.WebAuthnID()
+	session.AllowedCredentialIDs = nil
+	verified, err := rp.WebAuthn.ValidateLogin(user, session, response)
+	if err != nil {
+		return 0, describe(err)
+	}
+	if verified.Authenticator.CloneWarning {
+		return 0, ErrCloneWarning
+	}
+	return verified.Authenticator.SignCount, nil
+}
+
+// CredentialKey is the id of entity.WebAuthnCredential of the raw credential id
+func CredentialKey(rawId []byte) string {
+	sum := sha256.Sum256(rawId)
+	return hex.EncodeToString(sum[:])
+}
+
+// UserHandle is the user id of account in WebAuthn, it is returned by authenticators in the assertions of
+// discoverable credentials
+func UserHandle(accountId uint64) []byte {
+	handle := make([]byte, 8)
+	binary.BigEndian.PutUint64(handle, accountId)
+	return handle
+}
+
+// user is the webauthn.User of account
+type user struct {
+	account     *entity.Account
+	credentials []webauthn.Credential
+}
+
+func newUser(account *entity.Account, credentials []entity.WebAuthnCredential) (*user, error) {
+	u := &user{account: account}
+	for _, credential := range credentials {
+		id, err := base64.RawURLEncoding.DecodeString(credential.CredentialId)
+		if err != nil {
+			return nil, fmt.Errorf("bad id of credential %s: %w", credential.Id, err)
+		}
+		aaguid, err := hex.DecodeString(credential.AAGUID)
+		if err != nil {
+			return nil, fmt.Errorf("bad aaguid of credential %s: %w", credential.Id, err)
+		}
+		u.credentials = append(u.credentials, webauthn.Credential{
+			ID:              id,
+			PublicKey:       credential.PublicKey,
+			AttestationType: credential.AttestationType,
+			Authenticator:   webauthn.Authenticator{AAGUID: aaguid, SignCount: credential.SignCount},
+		})
+	}
+	return u, nil
+}
+
+func (u *user) WebAuthnID() []byte {
+	return UserHandle(u.account.Id)
+}
+
+func (u *user) WebAuthnName() string {
+	return u.account.Name
+}
+
+func (u *user) WebAuthnDisplayName() string {
+	return u.account.Name
+}
+
+func (u *user) WebAuthnIcon() string {
+	return ""
+}
+
+func (u *user) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+// describe keeps the details of protocol errors, which are dropped by their Error()
+func describe(err error) error {
+	var protocolErr *protocol.Error
+	if errors.As(err, &protocolErr) {
+		return fmt.Errorf("%s: %s %s", protocolErr.Type, protocolErr.Details, protocolErr.DevInfo)
+	}
+	return err
+}
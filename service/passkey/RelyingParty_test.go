@@ -0,0 +1,124 @@
+package passkey
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/duo-labs/webauthn/protocol"
+	"github.com/duo-labs/webauthn/webauthn"
+	"github.com/stretchr/testify/assert"
+	"hallo/domain/entity"
+	"hallo/testinfra"
+	"testing"
+)
+
+func TestRelyingParty(it *testing.T) {
+	it.Run("should register passkey and sign in by its assertions", func(t *testing.T) {
+		rp, err := NewRelyingParty(Config{Id: "hallo.test.fundwit.com", Origin: "https://hallo.test.fundwit.com"})
+		assert.Nil(t, err)
+		authenticator := testinfra.NewWebAuthnAuthenticator("https://hallo.test.fundwit.com")
+		account := &entity.Account{Id: 123, Name: "ann"}
+
+		credential := register(t, rp, authenticator, account)
+		assert.Equal(t, uint64(123), credential.AccountId)
+		assert.Equal(t, "phone", credential.Name)
+		assert.Equal(t, "none", credential.AttestationType)
+		assert.Equal(t, uint32(0), credential.SignCount)
+
+		// the registered authenticator is excluded
+		options, _, err := rp.BeginRegistration(account, []entity.WebAuthnCredential{*credential})
+		assert.Nil(t, err)
+		optionsJson, err := json.Marshal(options)
+		assert.Nil(t, err)
+		_, err = authenticator.Create(optionsJson)
+		assert.NotNil(t, err)
+
+		response, session := login(t, rp, authenticator)
+		assert.Equal(t, credential.Id, CredentialKey(response.RawID))
+		signCount, err := rp.FinishLogin(account, credential, *session, response)
+		assert.Nil(t, err)
+		assert.Equal(t, uint32(1), signCount)
+
+		// the assertion of another account is refused
+		response, session = login(t, rp, authenticator)
+		_, err = rp.FinishLogin(&entity.Account{Id: 456, Name: "bob"}, credential, *session, response)
+		assert.NotNil(t, err)
+
+		// the assertion is refused by another challenge
+		response, _ = login(t, rp, authenticator)
+		_, another := login(t, rp, authenticator)
+		_, err = rp.FinishLogin(account, credential, *another, response)
+		assert.NotNil(t, err)
+	})
+
+	it.Run("should refuse sign count which is not increased", func(t *testing.T) {
+		rp, err := NewRelyingParty(Config{Id: "hallo.test.fundwit.com", Origin: "https://hallo.test.fundwit.com"})
+		assert.Nil(t, err)
+		authenticator := testinfra.NewWebAuthnAuthenticator("https://hallo.test.fundwit.com")
+		account := &entity.Account{Id: 123, Name: "ann"}
+		credential := register(t, rp, authenticator, account)
+		clone := authenticator.Clone()
+
+		response, session := login(t, rp, authenticator)
+		signCount, err := rp.FinishLogin(account, credential, *session, response)
+		assert.Nil(t, err)
+		credential.SignCount = signCount
+
+		response, session = login(t, rp, clone)
+		_, err = rp.FinishLogin(account, credential, *session, response)
+		assert.Equal(t, ErrCloneWarning, err)
+	})
+
+	it.Run("should refuse credential of another origin", func(t *testing.T) {
+		rp, err := NewRelyingParty(Config{Id: "hallo.test.fundwit.com", Origin: "https://hallo.test.fundwit.com"})
+		assert.Nil(t, err)
+		authenticator := testinfra.NewWebAuthnAuthenticator("https://evil.test.fundwit.com")
+
+		options, session, err := rp.BeginRegistration(&entity.Account{Id: 123, Name: "ann"}, nil)
+		assert.Nil(t, err)
+		optionsJson, err := json.Marshal(options)
+		assert.Nil(t, err)
+		responseJson, err := authenticator.Create(optionsJson)
+		assert.Nil(t, err)
+		response, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(responseJson))
+		assert.Nil(t, err)
+		_, err = rp.FinishRegistration(&entity.Account{Id: 123, Name: "ann"}, *session, response, "phone")
+		assert.NotNil(t, err)
+	})
+
+	it.Run("should be disabled without id of relying party", func(t *testing.T) {
+		rp, err := LoadRelyingParty()
+		assert.Nil(t, err)
+		assert.Nil(t, rp)
+
+		_, err = NewRelyingParty(Config{Id: "hallo.test.fundwit.com"})
+		assert.NotNil(t, err)
+	})
+}
+
+func register(t *testing.T, rp *RelyingParty, authenticator *testinfra.WebAuthnAuthenticator, account *entity.Account) *entity.WebAuthnCredential {
+	options, session, err := rp.BeginRegistration(account, nil)
+	assert.Nil(t, err)
+	optionsJson, err := json.Marshal(options)
+	assert.Nil(t, err)
+	responseJson, err := authenticator.Create(optionsJson)
+	assert.Nil(t, err)
+	response, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(responseJson))
+	assert.Nil(t, err)
+	credential, err := rp.FinishRegistration(account, *session, response, "phone")
+	assert.Nil(t, err)
+	return credential
+}
+
+// login returns an assertion of authenticator to a new login ceremony
+func login(t *testing.T, rp *RelyingParty, authenticator *testinfra.WebAuthnAuthenticator) (
+	*protocol.ParsedCredentialAssertionData, *webauthn.SessionData) {
+	options, session, err := rp.BeginLogin()
+	assert.Nil(t, err)
+	optionsJson, err := json.Marshal(options)
+	assert.Nil(t, err)
+	responseJson, err := authenticator.Get(optionsJson)
+	assert.Nil(t, err)
+	response, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(responseJson))
+	assert.Nil(t, err)
+	return response, session
+}
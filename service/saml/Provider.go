@@ -0,0 +1,297 @@
+package saml
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	crewjam "github.com/crewjam/saml"
+	"github.com/crewjam/saml/samlsp"
+	"hallo/domain"
+	"hallo/domain/entity"
+	"hallo/service/auth"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Config of SAML identity provider, the metadata of identity provider is loaded from IdpMetadataFile or IdpMetadataUrl.
+// KeyFile and CertificateFile are the PEM files of the RSA key pair of hallo, with which the requests are signed
+// when SignRequests is true, and the encrypted assertions are decrypted.
+// EmailVerified should be true only when the identity provider verifies the emails of its accounts
+type Config struct {
+	Id              string           `json:"id"`
+	Name            string           `json:"name"`
+	IdpMetadataFile string           `json:"idpMetadataFile"`
+	IdpMetadataUrl  string           `json:"idpMetadataUrl"`
+	KeyFile         string           `json:"keyFile"`
+	CertificateFile string           `json:"certificateFile"`
+	SignRequests    bool             `json:"signRequests"`
+	NameIdFormat    string           `json:"nameIdFormat"`
+	Attributes      AttributeMapping `json:"attributes"`
+	EmailVerified   bool             `json:"emailVerified"`
+}
+
+// AttributeMapping names the attributes of assertion by Name or FriendlyName, the NameID of subject is used
+// when Id is empty. The defaults of Name and Email are "uid" and "mail"
+type AttributeMapping struct {
+	Id    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// Provider is the service provider of hallo for an identity provider,
+// its metadata, assertion consumer service and login endpoints are under the base URL
+type Provider struct {
+	Id              string
+	Name            string
+	ServiceProvider *crewjam.ServiceProvider
+	Attributes      AttributeMapping
+	EmailVerified   bool
+}
+
+// LoadProviders loads the configs of providers from the JSON file of SAML_PROVIDERS_FILE, no provider is loaded
+// when it is absent. The ids of providers are unique and not reserved by domain. SAML_BASE_URL is the external URL of SAML endpoints of hallo, e.g. https://hallo.example.com/saml
+func LoadProviders() ([]*Provider, error) {
+	file := os.Getenv("SAML_PROVIDERS_FILE")
+	if file == "" {
+		return nil, nil
+	}
+	baseUrl := os.Getenv("SAML_BASE_URL")
+	if baseUrl == "" {
+		return nil, errors.New("SAML_BASE_URL is required")
+	}
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var configs []Config
+	if err := json.Unmarshal(content, &configs); err != nil {
+		return nil, fmt.Errorf("bad saml providers file %s: %w", file, err)
+	}
+
+	providers := make([]*Provider, 0, len(configs))
+	for _, config := range configs {
+		if Find(providers, config.Id) != nil {
+			return nil, fmt.Errorf("duplicate saml provider %s", config.Id)
+		}
+		provider, err := NewProvider(context.Background(), baseUrl, config)
+		if err != nil {
+			return nil, fmt.Errorf("bad saml provider %s: %w", config.Id, err)
+		}
+		providers = append(providers, provider)
+	}
+	return providers, nil
+}
+
+// Find returns nil when there is no provider of id
+func Find(providers []*Provider, id string) *Provider {
+	for _, provider := range providers {
+		if provider.Id == id {
+			return provider
+		}
+	}
+	return nil
+}
+
+func NewProvider(ctx context.Context, baseUrl string, config Config) (*Provider, error) {
+	if config.Id == "" {
+		return nil, errors.New("id is required")
+	}
+	if domain.IsReservedProviderId(config.Id) {
+		return nil, fmt.Errorf("id %q is reserved", config.Id)
+	}
+	providerUrl, err := url.Parse(strings.TrimSuffix(baseUrl, "/") + "/" + url.PathEscape(config.Id))
+	if err != nil {
+		return nil, err
+	}
+
+	idpMetadata, err := loadIdpMetadata(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	key, certificate, err := loadKeyPair(config.KeyFile, config.CertificateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	metadataUrl, acsUrl := *providerUrl, *providerUrl
+	metadataUrl.Path += "/metadata"
+	acsUrl.Path += "/acs"
+	serviceProvider := &crewjam.ServiceProvider{
+		EntityID:          metadataUrl.String(),
+		Key:               key,
+		Certificate:       certificate,
+		MetadataURL:       metadataUrl,
+		AcsURL:            acsUrl,
+		IDPMetadata:       idpMetadata,
+		AuthnNameIDFormat: crewjam.NameIDFormat(config.NameIdFormat),
+	}
+	// the transient NameID changes in each assertion, which can not be bound
+	if serviceProvider.AuthnNameIDFormat == "" {
+		serviceProvider.AuthnNameIDFormat = crewjam.PersistentNameIDFormat
+	}
+	if config.SignRequests {
+		serviceProvider.SignatureMethod = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	}
+
+	provider := &Provider{
+		Id:              config.Id,
+		Name:            config.Name,
+		ServiceProvider: serviceProvider,
+		Attributes:      config.Attributes,
+		EmailVerified:   config.EmailVerified,
+	}
+	if provider.Name == "" {
+		provider.Name = config.Id
+	}
+	if provider.Attributes.Name == "" {
+		provider.Attributes.Name = "uid"
+	}
+	if provider.Attributes.Email == "" {
+		provider.Attributes.Email = "mail"
+	}
+	return provider, nil
+}
+
+func loadIdpMetadata(ctx context.Context, config Config) (*crewjam.EntityDescriptor, error) {
+	if config.IdpMetadataFile != "" {
+		content, err := ioutil.ReadFile(config.IdpMetadataFile)
+		if err != nil {
+			return nil, err
+		}
+		return samlsp.ParseMetadata(content)
+	}
+	if config.IdpMetadataUrl != "" {
+		metadataUrl, err := url.Parse(config.IdpMetadataUrl)
+		if err != nil {
+			return nil, err
+		}
+		return samlsp.FetchMetadata(ctx, &http.Client{Timeout: 10 * time.Second}, *metadataUrl)
+	}
+	return nil, errors.New("idpMetadataFile or idpMetadataUrl is required")
+}
+
+func loadKeyPair(keyFile, certificateFile string) (*rsa.PrivateKey, *x509.Certificate, error) {
+	if keyFile == "" || certificateFile == "" {
+		return nil, nil, errors.New("keyFile and certificateFile are required")
+	}
+	keyPem, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	signer, err := auth.ParsePrivateKeyPem(keyPem)
+	if err != nil {
+		return nil, nil, fmt.Errorf("bad key file %s: %w", keyFile, err)
+	}
+	key, ok := signer.(*rsa.PrivateKey)
+	if !ok {
+		return nil, nil, fmt.Errorf("key of %s is not RSA key", keyFile)
+	}
+
+	certificatePem, err := ioutil.ReadFile(certificateFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	block, _ := pem.Decode(certificatePem)
+	if block == nil {
+		return nil, nil, fmt.Errorf("bad certificate file %s", certificateFile)
+	}
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("bad certificate file %s: %w", certificateFile, err)
+	}
+	return key, certificate, nil
+}
+
+// Metadata is the SP metadata to be registered in the identity provider
+func (provider *Provider) Metadata() ([]byte, error) {
+	metadata, err := xml.MarshalIndent(provider.ServiceProvider.Metadata(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), metadata...), nil
+}
+
+// AuthnRequest makes the request by the HTTP-Redirect binding when the identity provider supports it, redirectUrl is
+// returned. Otherwise the HTML form of HTTP-POST binding is returned as postForm
+func (provider *Provider) AuthnRequest(relayState string) (requestId string, redirectUrl *url.URL, postForm []byte, err error) {
+	sp := provider.ServiceProvider
+	if location := sp.GetSSOBindingLocation(crewjam.HTTPRedirectBinding); location != "" {
+		request, err := sp.MakeAuthenticationRequest(location, crewjam.HTTPRedirectBinding, crewjam.HTTPPostBinding)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		redirectUrl, err := request.Redirect(relayState, sp)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		return request.ID, redirectUrl, nil, nil
+	}
+
+	location := sp.GetSSOBindingLocation(crewjam.HTTPPostBinding)
+	if location == "" {
+		return "", nil, nil, errors.New("identity provider supports neither HTTP-Redirect nor HTTP-POST binding")
+	}
+	request, err := sp.MakeAuthenticationRequest(location, crewjam.HTTPPostBinding, crewjam.HTTPPostBinding)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	// the request of HTTP-POST binding is signed by MakeAuthenticationRequest
+	return request.ID, nil, request.Post(relayState), nil
+}
+
+// ParseResponse validates the signature, audience, destination and time of the response to the request of requestId,
+// and maps the assertion to identity. Only the response of HTTP-POST binding is accepted
+func (provider *Provider) ParseResponse(r *http.Request, requestId string) (*entity.ExternalIdentity, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	response, err := base64.StdEncoding.DecodeString(r.PostForm.Get("SAMLResponse"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid saml response: %w", err)
+	}
+	assertion, err := provider.ServiceProvider.ParseXMLResponse(response, []string{requestId})
+	if err != nil {
+		var invalidResponse *crewjam.InvalidResponseError
+		if errors.As(err, &invalidResponse) {
+			return nil, fmt.Errorf("invalid saml response: %w", invalidResponse.PrivateErr)
+		}
+		return nil, err
+	}
+
+	identity := &entity.ExternalIdentity{
+		ProviderId:    provider.Id,
+		Name:          attributeValue(assertion, provider.Attributes.Name),
+		Email:         attributeValue(assertion, provider.Attributes.Email),
+		EmailVerified: provider.EmailVerified,
+	}
+	if provider.Attributes.Id != "" {
+		identity.ProviderAccountId = attributeValue(assertion, provider.Attributes.Id)
+	} else if assertion.Subject != nil && assertion.Subject.NameID != nil {
+		identity.ProviderAccountId = assertion.Subject.NameID.Value
+	}
+	if identity.ProviderAccountId == "" {
+		return nil, errors.New("subject of assertion is absent")
+	}
+	return identity, nil
+}
+
+func attributeValue(assertion *crewjam.Assertion, name string) string {
+	for _, statement := range assertion.AttributeStatements {
+		for _, attribute := range statement.Attributes {
+			if (attribute.Name == name || attribute.FriendlyName == name) && len(attribute.Values) > 0 {
+				return attribute.Values[0].Value
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,118 @@
+package saml
+
+import (
+	"context"
+	crewjam "github.com/crewjam/saml"
+	"github.com/stretchr/testify/assert"
+	"hallo/domain"
+	"hallo/domain/entity"
+	"hallo/testinfra"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestProvider(it *testing.T) {
+	it.Run("should sign in by signed assertion of identity provider", func(t *testing.T) {
+		idp, err := testinfra.NewSamlIdentityProvider()
+		assert.Nil(t, err)
+		defer idp.Close()
+		dir, err := ioutil.TempDir("", "saml")
+		assert.Nil(t, err)
+		defer os.RemoveAll(dir)
+		keyFile, certificateFile, err := testinfra.WriteSamlKeyPair(dir)
+		assert.Nil(t, err)
+
+		provider, err := NewProvider(context.Background(), "https://hallo.test.fundwit.com/saml", Config{Id: "corp",
+			IdpMetadataUrl: idp.MetadataUrl(), KeyFile: keyFile, CertificateFile: certificateFile, EmailVerified: true})
+		assert.Nil(t, err)
+		assert.Equal(t, "corp", provider.Name)
+
+		metadata, err := provider.Metadata()
+		assert.Nil(t, err)
+		assert.Contains(t, string(metadata), `entityID="https://hallo.test.fundwit.com/saml/corp/metadata"`)
+		assert.Contains(t, string(metadata), `Location="https://hallo.test.fundwit.com/saml/corp/acs"`)
+		assert.Nil(t, idp.RegisterServiceProvider(metadata))
+
+		requestId, redirectUrl, postForm, err := provider.AuthnRequest("state-1")
+		assert.Nil(t, err)
+		assert.Nil(t, postForm)
+		assert.True(t, strings.HasPrefix(redirectUrl.String(), idp.Server.URL+"/sso?SAMLRequest="))
+		assert.Equal(t, "state-1", redirectUrl.Query().Get("RelayState"))
+
+		idp.Session = &crewjam.Session{ID: "session-1", NameID: "ann-persistent-id", UserName: "ann",
+			CustomAttributes: []crewjam.Attribute{{Name: "mail", Values: []crewjam.AttributeValue{{Value: "ann@test.fundwit.com"}}}}}
+		acsUrl, form, err := idp.SignIn(redirectUrl.String())
+		assert.Nil(t, err)
+		assert.Equal(t, "https://hallo.test.fundwit.com/saml/corp/acs", acsUrl)
+		assert.Equal(t, "state-1", form.Get("RelayState"))
+		postResponse := func(form url.Values) *http.Request {
+			req := httptest.NewRequest(http.MethodPost, acsUrl, strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			return req
+		}
+
+		identity, err := provider.ParseResponse(postResponse(form), requestId)
+		assert.Nil(t, err)
+		assert.Equal(t, entity.ExternalIdentity{ProviderId: "corp", ProviderAccountId: "ann-persistent-id", Name: "ann",
+			Email: "ann@test.fundwit.com", EmailVerified: true}, *identity)
+
+		// the response is accepted only for its request
+		_, err = provider.ParseResponse(postResponse(form), "id-other")
+		assert.NotNil(t, err)
+
+		// the assertion signed by the key absent in metadata of identity provider is not trusted
+		assert.Nil(t, idp.RotateKey())
+		_, forged, err := idp.SignIn(redirectUrl.String())
+		assert.Nil(t, err)
+		_, err = provider.ParseResponse(postResponse(forged), requestId)
+		assert.NotNil(t, err)
+	})
+
+	it.Run("should make request by post binding when redirect binding is not supported", func(t *testing.T) {
+		idp, err := testinfra.NewSamlIdentityProvider()
+		assert.Nil(t, err)
+		defer idp.Close()
+		dir, err := ioutil.TempDir("", "saml")
+		assert.Nil(t, err)
+		defer os.RemoveAll(dir)
+		keyFile, certificateFile, err := testinfra.WriteSamlKeyPair(dir)
+		assert.Nil(t, err)
+
+		provider, err := NewProvider(context.Background(), "https://hallo.test.fundwit.com/saml", Config{Id: "corp",
+			IdpMetadataUrl: idp.MetadataUrl(), KeyFile: keyFile, CertificateFile: certificateFile, SignRequests: true})
+		assert.Nil(t, err)
+		for i := range provider.ServiceProvider.IDPMetadata.IDPSSODescriptors {
+			descriptor := &provider.ServiceProvider.IDPMetadata.IDPSSODescriptors[i]
+			services := descriptor.SingleSignOnServices[:0]
+			for _, service := range descriptor.SingleSignOnServices {
+				if service.Binding == crewjam.HTTPPostBinding {
+					services = append(services, service)
+				}
+			}
+			descriptor.SingleSignOnServices = services
+		}
+
+		_, redirectUrl, postForm, err := provider.AuthnRequest("state-1")
+		assert.Nil(t, err)
+		assert.Nil(t, redirectUrl)
+		assert.Contains(t, string(postForm), `action="`+idp.Server.URL+`/sso"`)
+		assert.Contains(t, string(postForm), `name="SAMLRequest"`)
+		assert.Contains(t, string(postForm), `value="state-1"`)
+	})
+
+	it.Run("should reject invalid config", func(t *testing.T) {
+		_, err := NewProvider(context.Background(), "https://hallo.test.fundwit.com/saml", Config{Id: "corp"})
+		assert.NotNil(t, err)
+		_, err = NewProvider(context.Background(), "https://hallo.test.fundwit.com/saml", Config{IdpMetadataFile: "idp.xml"})
+		assert.NotNil(t, err)
+		for _, id := range []string{domain.InternalProviderId, domain.LdapProviderId} {
+			_, err = NewProvider(context.Background(), "https://hallo.test.fundwit.com/saml", Config{Id: id, IdpMetadataFile: "idp.xml"})
+			assert.EqualError(t, err, `id "`+id+`" is reserved`)
+		}
+	})
+}
@@ -0,0 +1,172 @@
+package testinfra
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"github.com/crewjam/saml"
+	"html"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"regexp"
+	"time"
+)
+
+// SamlIdentityProvider is a local SAML identity provider serving /metadata and /sso, the assertions are signed by
+// a generated key pair. Every request is signed in as Session, the requests are refused when Session is nil
+type SamlIdentityProvider struct {
+	IdentityProvider *saml.IdentityProvider
+	Server           *httptest.Server
+	Session          *saml.Session
+
+	serviceProviders map[string]*saml.EntityDescriptor
+}
+
+func NewSamlIdentityProvider() (*SamlIdentityProvider, error) {
+	key, certificate, err := generateKeyPair("idp.test.fundwit.com")
+	if err != nil {
+		return nil, err
+	}
+
+	idp := &SamlIdentityProvider{serviceProviders: map[string]*saml.EntityDescriptor{}}
+	idp.IdentityProvider = &saml.IdentityProvider{
+		Key:                     key,
+		Certificate:             certificate,
+		Logger:                  log.New(os.Stderr, "[idp] ", log.LstdFlags),
+		ServiceProviderProvider: idp,
+		SessionProvider:         idp,
+	}
+	// the handler of identity provider is routed by its URLs
+	idp.Server = httptest.NewUnstartedServer(nil)
+	serverUrl := url.URL{Scheme: "http", Host: idp.Server.Listener.Addr().String()}
+	idp.IdentityProvider.MetadataURL = *serverUrl.ResolveReference(&url.URL{Path: "/metadata"})
+	idp.IdentityProvider.SSOURL = *serverUrl.ResolveReference(&url.URL{Path: "/sso"})
+	idp.Server.Config.Handler = idp.IdentityProvider.Handler()
+	idp.Server.Start()
+	return idp, nil
+}
+
+func (idp *SamlIdentityProvider) Close() {
+	idp.Server.Close()
+}
+
+func (idp *SamlIdentityProvider) MetadataUrl() string {
+	return idp.Server.URL + "/metadata"
+}
+
+// RegisterServiceProvider trusts the service provider by its metadata
+func (idp *SamlIdentityProvider) RegisterServiceProvider(metadata []byte) error {
+	descriptor := &saml.EntityDescriptor{}
+	if err := xml.Unmarshal(metadata, descriptor); err != nil {
+		return err
+	}
+	idp.serviceProviders[descriptor.EntityID] = descriptor
+	return nil
+}
+
+func (idp *SamlIdentityProvider) GetServiceProvider(r *http.Request, serviceProviderID string) (*saml.EntityDescriptor, error) {
+	descriptor, found := idp.serviceProviders[serviceProviderID]
+	if !found {
+		return nil, os.ErrNotExist
+	}
+	return descriptor, nil
+}
+
+func (idp *SamlIdentityProvider) GetSession(w http.ResponseWriter, r *http.Request, req *saml.IdpAuthnRequest) *saml.Session {
+	if idp.Session == nil {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+	}
+	return idp.Session
+}
+
+var samlFormAction = regexp.MustCompile(`action="([^"]*)"`)
+var samlFormInput = regexp.MustCompile(`name="(SAMLResponse|RelayState)" value="([^"]*)"`)
+
+// SignIn sends the request of HTTP-Redirect binding, and returns the form of HTTP-POST binding which posts the response
+// to the assertion consumer service of service provider
+func (idp *SamlIdentityProvider) SignIn(redirectUrl string) (string, url.Values, error) {
+	resp, err := http.Get(redirectUrl)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, errors.New("sign in failed: " + resp.Status)
+	}
+
+	action := samlFormAction.FindSubmatch(body)
+	if action == nil {
+		return "", nil, errors.New("no form in response")
+	}
+	form := url.Values{}
+	for _, input := range samlFormInput.FindAllSubmatch(body, -1) {
+		form.Set(string(input[1]), html.UnescapeString(string(input[2])))
+	}
+	return html.UnescapeString(string(action[1])), form, nil
+}
+
+// RotateKey replaces the signing key of identity provider, the assertions signed afterwards are not trusted by
+// the service providers which loaded the metadata before
+func (idp *SamlIdentityProvider) RotateKey() error {
+	key, certificate, err := generateKeyPair("idp.test.fundwit.com")
+	if err != nil {
+		return err
+	}
+	idp.IdentityProvider.Key = key
+	idp.IdentityProvider.Certificate = certificate
+	return nil
+}
+
+// WriteSamlKeyPair writes a generated RSA key and its self-signed certificate as PEM files into dir
+func WriteSamlKeyPair(dir string) (keyFile, certificateFile string, err error) {
+	key, certificate, err := generateKeyPair("sp.test.fundwit.com")
+	if err != nil {
+		return "", "", err
+	}
+	keyFile, certificateFile = dir+"/sp.key", dir+"/sp.crt"
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := ioutil.WriteFile(keyFile, keyPem, 0600); err != nil {
+		return "", "", err
+	}
+	certificatePem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw})
+	if err := ioutil.WriteFile(certificateFile, certificatePem, 0600); err != nil {
+		return "", "", err
+	}
+	return keyFile, certificateFile, nil
+}
+
+func generateKeyPair(commonName string) (*rsa.PrivateKey, *x509.Certificate, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, certificate, nil
+}
@@ -0,0 +1,215 @@
+package testinfra
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"github.com/duo-labs/webauthn/protocol/webauthncbor"
+	"math/big"
+)
+
+// WebAuthnAuthenticator is a software authenticator of discoverable ES256 credentials with "none" attestation,
+// the user is always present and verified. Create and Get take the options returned by the relying party and
+// return the JSON of PublicKeyCredential as browsers do
+type WebAuthnAuthenticator struct {
+	Origin      string
+	credentials []*webAuthnKey
+}
+
+type webAuthnKey struct {
+	id         []byte
+	rpId       string
+	userHandle []byte
+	key        *ecdsa.PrivateKey
+	signCount  uint32
+}
+
+type webAuthnOptions struct {
+	PublicKey struct {
+		// the binary members of options are encoded by standard base64
+		Challenge []byte `json:"challenge"`
+		RpId      string `json:"rpId"`
+		Rp        struct {
+			Id string `json:"id"`
+		} `json:"rp"`
+		User struct {
+			Id []byte `json:"id"`
+		} `json:"user"`
+		ExcludeCredentials []struct {
+			Id []byte `json:"id"`
+		} `json:"excludeCredentials"`
+		AllowCredentials []struct {
+			Id []byte `json:"id"`
+		} `json:"allowCredentials"`
+	} `json:"publicKey"`
+}
+
+func NewWebAuthnAuthenticator(origin string) *WebAuthnAuthenticator {
+	return &WebAuthnAuthenticator{Origin: origin}
+}
+
+// Clone returns an authenticator with copies of the credentials, their sign counts are increased independently
+func (a *WebAuthnAuthenticator) Clone() *WebAuthnAuthenticator {
+	clone := &WebAuthnAuthenticator{Origin: a.Origin}
+	for _, credential := range a.credentials {
+		copied := *credential
+		clone.credentials = append(clone.credentials, &copied)
+	}
+	return clone
+}
+
+// Create is navigator.credentials.create()
+func (a *WebAuthnAuthenticator) Create(optionsJson []byte) ([]byte, error) {
+	var options webAuthnOptions
+	if err := json.Unmarshal(optionsJson, &options); err != nil {
+		return nil, err
+	}
+	rpId := options.PublicKey.Rp.Id
+	for _, excluded := range options.PublicKey.ExcludeCredentials {
+		if a.find(rpId, excluded.Id) != nil {
+			return nil, errors.New("credential is registered already")
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	credential := &webAuthnKey{id: id, rpId: rpId, userHandle: options.PublicKey.User.Id, key: key}
+
+	publicKey, err := webauthncbor.Marshal(map[int]interface{}{
+		1: 2, 3: -7, -1: 1, -2: padBytes(key.X.Bytes(), 32), -3: padBytes(key.Y.Bytes(), 32),
+	})
+	if err != nil {
+		return nil, err
+	}
+	// flags: user present, user verified, attested credential data included
+	authData := a.authData(rpId, 0x45, credential.signCount)
+	authData = append(authData, make([]byte, 16)...) // aaguid
+	idLength := make([]byte, 2)
+	binary.BigEndian.PutUint16(idLength, uint16(len(id)))
+	authData = append(authData, idLength...)
+	authData = append(authData, id...)
+	authData = append(authData, publicKey...)
+	attestationObject, err := webauthncbor.Marshal(map[string]interface{}{
+		"fmt": "none", "attStmt": map[string]interface{}{}, "authData": authData,
+	})
+	if err != nil {
+		return nil, err
+	}
+	clientData, err := a.clientData("webauthn.create", options.PublicKey.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	a.credentials = append(a.credentials, credential)
+	return json.Marshal(map[string]interface{}{
+		"id":    encodeBase64Url(id),
+		"rawId": encodeBase64Url(id),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    encodeBase64Url(clientData),
+			"attestationObject": encodeBase64Url(attestationObject),
+		},
+	})
+}
+
+// Get is navigator.credentials.get(), the first credential of relying party is chosen when the allowed credentials
+// are not specified
+func (a *WebAuthnAuthenticator) Get(optionsJson []byte) ([]byte, error) {
+	var options webAuthnOptions
+	if err := json.Unmarshal(optionsJson, &options); err != nil {
+		return nil, err
+	}
+	rpId := options.PublicKey.RpId
+	var credential *webAuthnKey
+	if len(options.PublicKey.AllowCredentials) == 0 {
+		credential = a.find(rpId, nil)
+	}
+	for _, allowed := range options.PublicKey.AllowCredentials {
+		if credential = a.find(rpId, allowed.Id); credential != nil {
+			break
+		}
+	}
+	if credential == nil {
+		return nil, errors.New("no credential of relying party")
+	}
+
+	credential.signCount++
+	// flags: user present, user verified
+	authData := a.authData(rpId, 0x05, credential.signCount)
+	clientData, err := a.clientData("webauthn.get", options.PublicKey.Challenge)
+	if err != nil {
+		return nil, err
+	}
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	r, s, err := ecdsa.Sign(rand.Reader, credential.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	signature, err := asn1.Marshal(struct{ R, S *big.Int }{r, s})
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"id":    encodeBase64Url(credential.id),
+		"rawId": encodeBase64Url(credential.id),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    encodeBase64Url(clientData),
+			"authenticatorData": encodeBase64Url(authData),
+			"signature":         encodeBase64Url(signature),
+			"userHandle":        encodeBase64Url(credential.userHandle),
+		},
+	})
+}
+
+// find returns the credential of relying party by id, or the first one when id is nil
+func (a *WebAuthnAuthenticator) find(rpId string, id []byte) *webAuthnKey {
+	for _, credential := range a.credentials {
+		if credential.rpId == rpId && (id == nil || bytes.Equal(credential.id, id)) {
+			return credential
+		}
+	}
+	return nil
+}
+
+func (a *WebAuthnAuthenticator) authData(rpId string, flags byte, signCount uint32) []byte {
+	rpIdHash := sha256.Sum256([]byte(rpId))
+	authData := append(rpIdHash[:], flags)
+	counter := make([]byte, 4)
+	binary.BigEndian.PutUint32(counter, signCount)
+	return append(authData, counter...)
+}
+
+func (a *WebAuthnAuthenticator) clientData(ceremonyType string, challenge []byte) ([]byte, error) {
+	return json.Marshal(map[string]string{
+		"type":      ceremonyType,
+		"challenge": encodeBase64Url(challenge),
+		"origin":    a.Origin,
+	})
+}
+
+func encodeBase64Url(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func padBytes(data []byte, size int) []byte {
+	if len(data) >= size {
+		return data
+	}
+	return append(make([]byte, size-len(data)), data...)
+}
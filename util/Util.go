@@ -1,7 +1,10 @@
 package util
 
 import (
+	"crypto/rand"
 	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
 	"fmt"
 )
 
@@ -11,3 +14,16 @@ func HashSha1Hex(input []byte) string {
 	result := fmt.Sprintf("%x", h.Sum(nil))
 	return result
 }
+
+func HashSha256Hex(input []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(input))
+}
+
+// RandomToken returns the unpadded base64url encoding of n random bytes
+func RandomToken(n int) (string, error) {
+	bytes := make([]byte, n)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
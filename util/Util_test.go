@@ -23,3 +23,36 @@ func TestHashSha1Hex(t *testing.T) {
 		})
 	}
 }
+
+func TestHashSha256Hex(t *testing.T) {
+	type args struct {
+		input []byte
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{name: "case1", args: args{input: []byte("abc")}, want: "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HashSha256Hex(tt.args.input); got != tt.want {
+				t.Errorf("HashSha256Hex() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRandomToken(t *testing.T) {
+	token, err := RandomToken(32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(token) != 43 {
+		t.Errorf("RandomToken() length = %v, want %v", len(token), 43)
+	}
+	if another, _ := RandomToken(32); another == token {
+		t.Errorf("RandomToken() should not repeat")
+	}
+}